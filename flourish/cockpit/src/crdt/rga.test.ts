@@ -1,5 +1,5 @@
 import { describe, it, expect } from "vitest";
-import { RGA, type Operation } from "./rga";
+import { RGA, OpType, type Operation } from "./rga";
 
 function applyAll(rga: RGA, ops: Operation[]) {
   for (const op of ops) {
@@ -127,4 +127,23 @@ describe("RGA", () => {
     dest.apply(op1);
     expect(dest.text()).toBe("ab");
   });
+
+  it("提案insert: 採用されるまで本文に含めず、挿入位置の基準には使える", () => {
+    const src = new RGA("site-a");
+    const a = src.insert(null, "a");
+    const dest = new RGA("site-b");
+    dest.apply(a);
+
+    const x: Operation = { requestId: "s1", opType: OpType.Insert, nodeId: { siteId: "site-c", timestamp: 5 }, after: a.nodeId, value: "X", suggestion: "sug-1" };
+    dest.apply(x);
+    // 提案insertの後ろへの通常の挿入
+    dest.apply({ requestId: "s2", opType: OpType.Insert, nodeId: { siteId: "site-c", timestamp: 6 }, after: x.nodeId, value: "b" });
+    expect(dest.text()).toBe("ab");
+    expect(dest.visibleNodes()).toHaveLength(2);
+    expect(dest.suggestedText("sug-1")).toBe("X");
+
+    dest.acceptSuggestion("sug-1");
+    expect(dest.text()).toBe("aXb");
+    expect(dest.suggestedText("sug-1")).toBe("");
+  });
 });
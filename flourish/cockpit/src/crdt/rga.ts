@@ -17,6 +17,8 @@ export interface Operation {
   after: NodeID | null;
   value: string;
   authenticated?: boolean;
+  /** 提案セットのID。採用されるまで本文（text・visibleNodes）には含めない */
+  suggestion?: string;
 }
 
 interface Node {
@@ -25,6 +27,7 @@ interface Node {
   value: string;
   deleted: boolean;
   authenticated: boolean;
+  suggestion?: string;
 }
 
 function nodeIdEqual(a: NodeID, b: NodeID): boolean {
@@ -55,6 +58,7 @@ export class RGA {
   private index: Map<string, number> = new Map();
   private seen: Set<string> = new Set();
   private pending: Operation[] = [];
+  private accepted: Set<string> = new Set();
 
   constructor(siteId: string) {
     this.siteId = siteId;
@@ -102,6 +106,7 @@ export class RGA {
       value: op.value,
       deleted: false,
       authenticated: op.authenticated ?? true,
+      suggestion: op.suggestion,
     };
 
     let insertIdx = 0;
@@ -178,11 +183,37 @@ export class RGA {
     }
   }
 
+  // 本文に含めるノード。未採用の提案insertは挿入位置の基準として持つが本文には出さない
+  private isVisible(n: Node): boolean {
+    return !n.deleted && (n.suggestion === undefined || this.accepted.has(n.suggestion));
+  }
+
+  /** 提案を採用し、その提案insertを本文に含める */
+  acceptSuggestion(suggestion: string): void {
+    this.accepted.add(suggestion);
+  }
+
+  /** 未採用の提案insertの文字列 */
+  suggestedText(suggestion: string): string {
+    if (this.accepted.has(suggestion)) return "";
+    return this.nodes
+      .filter((n) => !n.deleted && n.suggestion === suggestion)
+      .map((n) => n.value)
+      .join("");
+  }
+
+  /** ノードの値。削除済みなら空 */
+  valueOf(nodeId: NodeID): string {
+    const idx = this.index.get(this.nodeKey(nodeId));
+    if (idx === undefined || this.nodes[idx].deleted) return "";
+    return this.nodes[idx].value;
+  }
+
   nodeAt(pos: number): NodeID | null {
     if (pos < 0) return null;
     let i = 0;
     for (const n of this.nodes) {
-      if (n.deleted) continue;
+      if (!this.isVisible(n)) continue;
       if (i === pos) return n.id;
       i++;
     }
@@ -190,7 +221,7 @@ export class RGA {
   }
 
   visibleNodes(): NodeID[] {
-    return this.nodes.filter((n) => !n.deleted).map((n) => n.id);
+    return this.nodes.filter((n) => this.isVisible(n)).map((n) => n.id);
   }
 
   isNodeAuthenticated(nodeId: NodeID): boolean {
@@ -201,7 +232,7 @@ export class RGA {
 
   text(): string {
     return this.nodes
-      .filter((n) => !n.deleted)
+      .filter((n) => this.isVisible(n))
       .map((n) => n.value)
       .join("");
   }
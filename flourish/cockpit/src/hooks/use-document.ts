@@ -4,9 +4,13 @@ import { SyncManager, type SyncState } from "../sync/sync-manager";
 export function useDocument(entryId: string | null, getWsTicket?: () => Promise<string | null>) {
   const [state, setState] = useState<SyncState>({
     text: "",
+    suggestions: [],
     connected: false,
     lastServerSeq: 0,
     authenticated: false,
+    locked: false,
+    deleted: false,
+    authExpiresAt: null,
   });
   const managerRef = useRef<SyncManager | null>(null);
 
@@ -36,15 +40,29 @@ export function useDocument(entryId: string | null, getWsTicket?: () => Promise<
     };
   }, [entryId]);
 
+  // 認証期限の少し前にチケットを取り直して再認証する
+  useEffect(() => {
+    if (!state.authExpiresAt || !getWsTicket) return;
+    const delay = Math.max(0, new Date(state.authExpiresAt).getTime() - Date.now() - 30_000);
+    const timer = setTimeout(async () => {
+      const ticket = await getWsTicket();
+      if (ticket) managerRef.current?.authenticate(ticket);
+    }, delay);
+    return () => clearTimeout(timer);
+  }, [state.authExpiresAt]);
+
   const applyTextChange = useCallback((newText: string) => {
     managerRef.current?.applyTextChange(newText);
   }, []);
 
   return {
     text: state.text,
+    suggestions: state.suggestions,
     connected: state.connected,
     lastServerSeq: state.lastServerSeq,
     authenticated: state.authenticated,
+    locked: state.locked,
+    deleted: state.deleted,
     applyTextChange,
   };
 }
@@ -1,10 +1,18 @@
 import { useState, useEffect, useCallback, useRef } from "preact/hooks";
+import { WSClient } from "../sync/ws-client";
+
+// 一覧の変化の通知をまとめて再取得するまでの待ち時間
+const REFRESH_DEBOUNCE_MS = 500;
 
 export interface EntryListItem {
   id: string;
   title: string;
   content: string;
   thumbnail: string | null;
+  tags: string[];
+  description: string;
+  word_count: number;
+  reading_time_minutes: number;
   created_at: string;
   updated_at: string;
 }
@@ -35,6 +43,34 @@ export function useEntries() {
     fetchEntries();
   }, [fetchEntries]);
 
+  // エントリ一覧を購読し、作成・更新・削除があれば再取得する
+  useEffect(() => {
+    const ws = new WSClient(`${location.protocol === "https:" ? "wss:" : "ws:"}//${location.host}/api/ws`);
+    let timer: ReturnType<typeof setTimeout> | null = null;
+    const remove = ws.onMessage((data: any) => {
+      switch (data.type) {
+        case "__connected":
+          ws.send({ type: "subscribe", request_id: crypto.randomUUID(), list: true });
+          break;
+        case "entry_create":
+        case "entry_update":
+        case "entry_delete":
+          if (timer) clearTimeout(timer);
+          timer = setTimeout(() => {
+            timer = null;
+            fetchEntries();
+          }, REFRESH_DEBOUNCE_MS);
+          break;
+      }
+    });
+    ws.connect();
+    return () => {
+      if (timer) clearTimeout(timer);
+      remove();
+      ws.disconnect();
+    };
+  }, [fetchEntries]);
+
   const createEntry = useCallback(async () => {
     const res = await fetch("/api/entries", { method: "POST" });
     const data = await res.json();
@@ -24,7 +24,7 @@ export function EntryPage(_props: { path?: string }) {
   const { getWsTicket } = useAuth();
 
   // 編集モード時のみWS接続
-  const { text, connected, applyTextChange } = useDocument(
+  const { text, suggestions, connected, applyTextChange } = useDocument(
     isEditing ? id : null,
     getWsTicket,
   );
@@ -140,6 +140,18 @@ export function EntryPage(_props: { path?: string }) {
               }}
               class="w-full bg-transparent text-base text-ink-text font-serif leading-relaxed placeholder:text-ink-muted resize-none focus:outline-none min-h-[200px]"
             />
+            {/* 未決の提案は本文に混ぜずに並べる */}
+            {suggestions.length > 0 && (
+              <ul class="font-mono text-[11px] text-ink-muted space-y-1 py-2">
+                {suggestions.map((s) => (
+                  <li key={s.id}>
+                    提案
+                    {s.inserted && <ins class="ml-2 text-green-500 no-underline">+{s.inserted}</ins>}
+                    {s.deleted && <del class="ml-2 text-red-400">-{s.deleted}</del>}
+                  </li>
+                ))}
+              </ul>
+            )}
             <div class="flex items-center justify-end py-1">
               <button
                 type="button"
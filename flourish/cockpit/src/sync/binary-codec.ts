@@ -0,0 +1,204 @@
+// サブプロトコル flourish.bin のop・ack・syncの符号化・復号（server/handler/ws_binary.go と対）。
+// site IDの辞書を持つので、接続ごとに送信用と受信用を1つずつ使う。
+
+export const SUBPROTOCOL_BINARY = "flourish.bin";
+export const SUBPROTOCOL_JSON = "flourish.json";
+
+const MSG_OP = 0x01;
+const MSG_ACK = 0x02;
+const MSG_SYNC = 0x03;
+
+const FLAG_AFTER = 1 << 0;
+const FLAG_VALUE = 1 << 1;
+const FLAG_SUGGESTION = 1 << 2;
+const FLAG_AUTHENTICATED = 1 << 3;
+
+const MAX_SITES = 4096;
+
+interface NodeIDMsg {
+  site_id: string;
+  timestamp: number;
+}
+
+export interface OpMsg {
+  request_id: string;
+  entry_id: string;
+  op_type: number;
+  node_id: NodeIDMsg;
+  after?: NodeIDMsg | null;
+  value?: string;
+  suggestion?: string;
+}
+
+const encoder = new TextEncoder();
+const decoder = new TextDecoder("utf-8", { fatal: true });
+
+function uuidToBytes(id: string): number[] {
+  const hex = id.replace(/-/g, "");
+  if (hex.length !== 32) throw new Error(`invalid uuid: ${id}`);
+  const bytes: number[] = [];
+  for (let i = 0; i < 32; i += 2) bytes.push(parseInt(hex.slice(i, i + 2), 16));
+  return bytes;
+}
+
+function bytesToUUID(b: Uint8Array): string {
+  const hex = Array.from(b, (x) => x.toString(16).padStart(2, "0")).join("");
+  return `${hex.slice(0, 8)}-${hex.slice(8, 12)}-${hex.slice(12, 16)}-${hex.slice(16, 20)}-${hex.slice(20)}`;
+}
+
+// 64bitに届かない範囲（2^53未満）のunsigned varint
+function pushUvarint(out: number[], v: number): void {
+  while (v >= 0x80) {
+    out.push((v % 0x80) | 0x80);
+    v = Math.floor(v / 0x80);
+  }
+  out.push(v);
+}
+
+class Reader {
+  private pos = 0;
+  private data: Uint8Array;
+
+  constructor(data: Uint8Array) {
+    this.data = data;
+  }
+
+  get done(): boolean {
+    return this.pos === this.data.length;
+  }
+
+  take(n: number): Uint8Array {
+    if (this.pos + n > this.data.length) throw new Error("invalid binary frame");
+    const b = this.data.subarray(this.pos, this.pos + n);
+    this.pos += n;
+    return b;
+  }
+
+  byte(): number {
+    return this.take(1)[0];
+  }
+
+  uuid(): string {
+    return bytesToUUID(this.take(16));
+  }
+
+  uvarint(): number {
+    let v = 0;
+    let mul = 1;
+    for (;;) {
+      const b = this.byte();
+      v += (b & 0x7f) * mul;
+      if (b < 0x80) return v;
+      mul *= 0x80;
+    }
+  }
+
+  string(): string {
+    return decoder.decode(this.take(this.uvarint()));
+  }
+}
+
+export class BinaryCodec {
+  private sites: string[] = [];
+  private index = new Map<string, number>();
+
+  encodeOp(msg: OpMsg): Uint8Array {
+    const out: number[] = [MSG_OP, ...uuidToBytes(msg.entry_id), ...uuidToBytes(msg.request_id)];
+    let flags = FLAG_AUTHENTICATED; // 認証状態はサーバーが決める
+    if (msg.after) flags |= FLAG_AFTER;
+    if (msg.value) flags |= FLAG_VALUE;
+    if (msg.suggestion) flags |= FLAG_SUGGESTION;
+    out.push(msg.op_type, flags);
+    this.pushNode(out, msg.node_id);
+    if (msg.after) this.pushNode(out, msg.after);
+    if (msg.value) {
+      const value = encoder.encode(msg.value);
+      pushUvarint(out, value.length);
+      out.push(...value);
+    }
+    if (msg.suggestion) out.push(...uuidToBytes(msg.suggestion));
+    return new Uint8Array(out);
+  }
+
+  // サーバーからのフレームをJSONと同じ形のack・syncに戻す。
+  decodeServerMessage(data: ArrayBuffer): unknown {
+    const r = new Reader(new Uint8Array(data));
+    let msg: unknown;
+    switch (r.byte()) {
+      case MSG_ACK: {
+        const entryId = r.uuid();
+        const requestId = r.uuid();
+        msg = { type: "ack", entry_id: entryId, request_id: requestId, server_seq: r.uvarint() };
+        break;
+      }
+      case MSG_SYNC: {
+        const entryId = r.uuid();
+        const latest = r.uvarint();
+        const count = r.uvarint();
+        const ops = [];
+        for (let i = 0; i < count; i++) {
+          const requestId = r.uuid();
+          const serverSeq = r.uvarint();
+          const op = this.readOp(r);
+          // 種別0はサーバーが解釈できなかったop
+          if (op.op_type !== 0) ops.push({ request_id: requestId, server_seq: serverSeq, ...op });
+        }
+        msg = { type: "sync", entry_id: entryId, ops, latest_server_seq: latest };
+        break;
+      }
+      default:
+        throw new Error("invalid binary frame");
+    }
+    if (!r.done) throw new Error("invalid binary frame");
+    return msg;
+  }
+
+  private readOp(r: Reader) {
+    const opType = r.byte();
+    const flags = r.byte();
+    const nodeId = this.readNode(r);
+    const after = flags & FLAG_AFTER ? this.readNode(r) : undefined;
+    const value = flags & FLAG_VALUE ? r.string() : undefined;
+    const suggestion = flags & FLAG_SUGGESTION ? r.uuid() : undefined;
+    return {
+      op_type: opType,
+      node_id: nodeId,
+      after,
+      value,
+      suggestion,
+      authenticated: (flags & FLAG_AUTHENTICATED) !== 0,
+    };
+  }
+
+  private pushNode(out: number[], node: NodeIDMsg): void {
+    const i = this.index.get(node.site_id);
+    if (i !== undefined) {
+      pushUvarint(out, i + 1);
+    } else {
+      out.push(0, ...uuidToBytes(node.site_id));
+      this.define(node.site_id);
+    }
+    pushUvarint(out, node.timestamp);
+  }
+
+  private readNode(r: Reader): NodeIDMsg {
+    const ref = r.uvarint();
+    let siteId: string;
+    if (ref === 0) {
+      siteId = r.uuid();
+      this.define(siteId);
+    } else if (ref <= this.sites.length) {
+      siteId = this.sites[ref - 1];
+    } else {
+      throw new Error("invalid binary frame");
+    }
+    return { site_id: siteId, timestamp: r.uvarint() };
+  }
+
+  // 辞書に空きがあれば追加する。送信側と受信側で同じ順に呼ばれるので添字が一致する
+  private define(siteId: string): void {
+    if (this.sites.length >= MAX_SITES) return;
+    this.index.set(siteId, this.sites.length);
+    this.sites.push(siteId);
+  }
+}
@@ -5,11 +5,22 @@ function genReqId(): string {
   return crypto.randomUUID();
 }
 
+/** 採否が決まっていない提案セット。本文とは分けて表示する */
+export interface SuggestionOverlay {
+  id: string;
+  inserted: string;
+  deleted: string;
+}
+
 export interface SyncState {
   text: string;
+  suggestions: SuggestionOverlay[];
   connected: boolean;
   lastServerSeq: number;
   authenticated: boolean;
+  locked: boolean;
+  deleted: boolean;
+  authExpiresAt: string | null;
 }
 
 type SyncListener = (state: SyncState) => void;
@@ -21,9 +32,15 @@ export class SyncManager {
   private lastServerSeq = 0;
   private pendingAcks = new Map<string, Operation>();
   private confirmedReqIds = new Set<string>();
+  // 未決の提案セット → 提案deleteの対象ノード。提案deleteは採用されるまでRGAに適用しない
+  // （採用時はサーバーから通常のdeleteとして届く）。提案insertはRGAに印付きで持つ
+  private suggestions = new Map<string, NodeID[]>();
   private listeners: SyncListener[] = [];
   private removeWsHandler: (() => void) | null = null;
   private _authenticated = false;
+  private _locked = false;
+  private _deleted = false;
+  private _authExpiresAt: string | null = null;
 
   constructor(
     wsUrl: string,
@@ -156,6 +173,15 @@ export class SyncManager {
     this.notify();
   }
 
+  /** 接続を維持したまま新しいチケットで再認証する */
+  authenticate(ticket: string): void {
+    this.ws.send({
+      type: "auth",
+      request_id: genReqId(),
+      ticket,
+    });
+  }
+
   getText(): string {
     return this.rga.text();
   }
@@ -167,9 +193,17 @@ export class SyncManager {
   getState(): SyncState {
     return {
       text: this.rga.text(),
+      suggestions: [...this.suggestions].map(([id, deletes]) => ({
+        id,
+        inserted: this.rga.suggestedText(id),
+        deleted: deletes.map((n) => this.rga.valueOf(n)).join(""),
+      })),
       connected: this.ws.connected,
       lastServerSeq: this.lastServerSeq,
       authenticated: this._authenticated,
+      locked: this._locked,
+      deleted: this._deleted,
+      authExpiresAt: this._authExpiresAt,
     };
   }
 
@@ -210,9 +244,33 @@ export class SyncManager {
 
       case "auth_status":
         this._authenticated = !!data.authenticated;
+        this._authExpiresAt = data.expires_at ?? null;
         this.notify();
         break;
 
+      case "entry_lock":
+        this._locked = !!data.locked;
+        this.notify();
+        break;
+
+      case "entry_delete":
+        if (data.entry_id !== this.entryId) break;
+        this._deleted = !!data.deleted;
+        this.notify();
+        break;
+
+      case "suggestion":
+        if (data.entry_id !== this.entryId) break;
+        // 採用された提案insertは本文に含める。却下された提案insertはサーバーからのdeleteで消える
+        if (data.status === "accepted") this.rga.acceptSuggestion(data.suggestion);
+        this.suggestions.delete(data.suggestion);
+        this.notify();
+        break;
+
+      case "entry_create":
+        // 作成通知は全接続に届く。編集中のエントリには関係しない
+        break;
+
       case "error":
         console.error("WS error:", data);
         break;
@@ -244,8 +302,17 @@ export class SyncManager {
           : null,
         value: syncOp.value ?? "",
         authenticated: syncOp.authenticated ?? true,
+        suggestion: syncOp.suggestion || undefined,
       };
 
+      if (op.suggestion) {
+        const deletes = this.suggestions.get(op.suggestion) ?? [];
+        this.suggestions.set(op.suggestion, deletes);
+        if (op.opType === OpType.Delete) {
+          deletes.push(nodeId);
+          continue;
+        }
+      }
       this.rga.apply(op);
     }
 
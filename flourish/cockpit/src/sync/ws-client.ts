@@ -1,11 +1,36 @@
+import { BinaryCodec, SUBPROTOCOL_BINARY, SUBPROTOCOL_JSON, type OpMsg } from "./binary-codec";
+
 export type MessageHandler = (data: unknown) => void;
 
+// サーバーが送信キューの溢れで切断したときのクローズコード。すぐ再接続して追いつく
+const CLOSE_RESYNC = 4000;
+// 対応していないプロトコルバージョンで切断されたときのクローズコード。再接続しない
+const CLOSE_UNSUPPORTED_PROTOCOL = 4001;
+
+// クライアントが対応するプロトコルバージョン（新しい順）と使いたい能力
+const PROTOCOL_VERSIONS = [2, 1];
+const CAPABILITIES: string[] = [];
+
+// デバッグ用に localStorage.setItem("ws-encoding", "json") でJSONに切り替えられる
+function preferBinary(): boolean {
+  try {
+    return localStorage.getItem("ws-encoding") !== "json";
+  } catch {
+    return true;
+  }
+}
+
 export class WSClient {
   private ws: WebSocket | null = null;
   private url: string;
   private handlers: MessageHandler[] = [];
   private reconnectTimer: ReturnType<typeof setTimeout> | null = null;
   private _connected = false;
+  private _protocolVersion = 1;
+  private _capabilities: string[] = [];
+  // バイナリ形式の接続なら送信用・受信用の辞書。JSONならnull
+  private encoder: BinaryCodec | null = null;
+  private decoder: BinaryCodec | null = null;
 
   constructor(url: string) {
     this.url = url;
@@ -15,30 +40,67 @@ export class WSClient {
     return this._connected;
   }
 
+  get protocolVersion(): number {
+    return this._protocolVersion;
+  }
+
+  get capabilities(): string[] {
+    return this._capabilities;
+  }
+
   async connect(): Promise<void> {
     if (this.ws) return;
 
-    this.ws = new WebSocket(this.url);
+    this.ws = new WebSocket(
+      this.url,
+      preferBinary() ? [SUBPROTOCOL_BINARY, SUBPROTOCOL_JSON] : [SUBPROTOCOL_JSON],
+    );
+    this.ws.binaryType = "arraybuffer";
 
     this.ws.onopen = () => {
+      if (this.ws?.protocol === SUBPROTOCOL_BINARY) {
+        this.encoder = new BinaryCodec();
+        this.decoder = new BinaryCodec();
+      }
       this._connected = true;
       this.handlers.forEach((h) => h({ type: "__connected" }));
     };
 
     this.ws.onmessage = (event) => {
       try {
-        const data = JSON.parse(event.data);
+        const data: any =
+          event.data instanceof ArrayBuffer && this.decoder
+            ? this.decoder.decodeServerMessage(event.data)
+            : JSON.parse(event.data);
+        if (data.type === "hello") {
+          // サーバーの告知に、対応バージョンと使いたい能力を返す
+          this.send({ type: "hello", protocol_versions: PROTOCOL_VERSIONS, capabilities: CAPABILITIES });
+          return;
+        }
+        if (data.type === "hello_ack") {
+          this._protocolVersion = data.protocol_version;
+          this._capabilities = data.capabilities ?? [];
+          return;
+        }
         this.handlers.forEach((h) => h(data));
       } catch {
         // ignore parse errors
       }
     };
 
-    this.ws.onclose = () => {
+    this.ws.onclose = (event) => {
       this._connected = false;
       this.ws = null;
+      this._protocolVersion = 1;
+      this._capabilities = [];
+      this.encoder = null;
+      this.decoder = null;
       this.handlers.forEach((h) => h({ type: "__disconnected" }));
-      this.scheduleReconnect();
+      if (event.code === CLOSE_UNSUPPORTED_PROTOCOL) {
+        console.error("WS protocol unsupported by server; reload to update");
+        return;
+      }
+      this.scheduleReconnect(event.code === CLOSE_RESYNC ? 0 : 2000);
     };
 
     this.ws.onerror = () => {
@@ -57,9 +119,13 @@ export class WSClient {
   }
 
   send(data: unknown): void {
-    if (this.ws?.readyState === WebSocket.OPEN) {
-      this.ws.send(JSON.stringify(data));
+    if (this.ws?.readyState !== WebSocket.OPEN) return;
+    const msg = data as { type?: string };
+    if (this.encoder && msg.type === "op") {
+      this.ws.send(this.encoder.encodeOp(data as OpMsg));
+      return;
     }
+    this.ws.send(JSON.stringify(data));
   }
 
   onMessage(handler: MessageHandler): () => void {
@@ -69,10 +135,10 @@ export class WSClient {
     };
   }
 
-  private scheduleReconnect(): void {
+  private scheduleReconnect(delay: number): void {
     this.reconnectTimer = setTimeout(() => {
       this.reconnectTimer = null;
       this.connect();
-    }, 2000);
+    }, delay);
   }
 }
@@ -0,0 +1,69 @@
+package jsonfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// crashHook はテストでクラッシュを注入するためのフック。本番ではnil。
+// stageで指定した地点でエラーを返すと、そこで処理を打ち切る（プロセスが落ちたのと同じ状態になる）。
+var crashHook func(stage string) error
+
+func crashPoint(stage string) error {
+	if crashHook == nil {
+		return nil
+	}
+	return crashHook(stage)
+}
+
+// writeFileAtomic は同じディレクトリの一時ファイルに書き込んでfsyncし、renameで置き換える。
+// 途中で落ちても、pathには古い内容か新しい内容のどちらかが完全な形で残る。
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // rename後は存在しないので何もしない
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := crashPoint("atomic:before-sync"); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync %s: %w", tmp, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+	if err := crashPoint("atomic:before-rename"); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir はディレクトリをfsyncし、ファイルの作成・renameを永続化する。
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync dir %s: %w", dir, err)
+	}
+	return nil
+}
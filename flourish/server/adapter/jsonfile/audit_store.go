@@ -0,0 +1,167 @@
+package jsonfile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+)
+
+// AuditStore はJSONLファイルベースのAuditStore実装。
+// {dir}/audit.jsonl に追記し、保持期間の適用時のみ書き直す。
+type AuditStore struct {
+	mu      sync.RWMutex
+	path    string
+	records []domain.AuditRecord
+}
+
+// auditJSON はJSONL保存用の構造体。
+type auditJSON struct {
+	ID      string `json:"id"`
+	Time    string `json:"time"`
+	Actor   string `json:"actor"`
+	Action  string `json:"action"`
+	Target  string `json:"target,omitempty"`
+	Result  string `json:"result"`
+	TraceID string `json:"trace_id,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+func NewAuditStore(dir string) (*AuditStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create audit dir: %w", err)
+	}
+
+	s := &AuditStore{path: filepath.Join(dir, "audit.jsonl")}
+	if err := s.loadFromFile(); err != nil {
+		return nil, fmt.Errorf("load audit: %w", err)
+	}
+	return s, nil
+}
+
+func (s *AuditStore) loadFromFile() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var aj auditJSON
+		if err := json.Unmarshal(scanner.Bytes(), &aj); err != nil {
+			continue
+		}
+		id, _ := uuid.Parse(aj.ID)
+		t, _ := time.Parse(time.RFC3339Nano, aj.Time)
+		s.records = append(s.records, domain.AuditRecord{
+			ID:      id,
+			Time:    t,
+			Actor:   aj.Actor,
+			Action:  aj.Action,
+			Target:  aj.Target,
+			Result:  domain.AuditResult(aj.Result),
+			TraceID: aj.TraceID,
+			Detail:  aj.Detail,
+		})
+	}
+	return scanner.Err()
+}
+
+func marshalAudit(rec domain.AuditRecord) ([]byte, error) {
+	data, err := json.Marshal(auditJSON{
+		ID:      rec.ID.String(),
+		Time:    rec.Time.Format(time.RFC3339Nano),
+		Actor:   rec.Actor,
+		Action:  rec.Action,
+		Target:  rec.Target,
+		Result:  string(rec.Result),
+		TraceID: rec.TraceID,
+		Detail:  rec.Detail,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (s *AuditStore) Append(_ context.Context, rec domain.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := marshalAudit(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("append audit: %w", err)
+	}
+
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *AuditStore) Query(_ context.Context, q domain.AuditQuery) ([]domain.AuditRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []domain.AuditRecord
+	for _, rec := range slices.Backward(s.records) {
+		if !q.Match(rec) {
+			continue
+		}
+		result = append(result, rec)
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Prune は古い記録を除いた内容でファイルをアトミックに置き換える。
+func (s *AuditStore) Prune(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := slices.DeleteFunc(slices.Clone(s.records), func(rec domain.AuditRecord) bool {
+		return rec.Time.Before(before)
+	})
+	pruned := len(s.records) - len(kept)
+	if pruned == 0 {
+		return 0, nil
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range kept {
+		data, err := marshalAudit(rec)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(data)
+	}
+	if err := writeFileAtomic(s.path, buf.Bytes(), 0o600); err != nil {
+		return 0, fmt.Errorf("replace audit file: %w", err)
+	}
+
+	s.records = kept
+	return pruned, nil
+}
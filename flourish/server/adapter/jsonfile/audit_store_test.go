@@ -0,0 +1,57 @@
+package jsonfile_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/jsonfile"
+	"flourish/server/domain"
+)
+
+func TestAuditStore_AppendReloadPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC()
+
+	store, err := jsonfile.NewAuditStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ts := range []time.Time{now.Add(-48 * time.Hour), now} {
+		rec := domain.AuditRecord{
+			ID:     uuid.New(),
+			Time:   ts,
+			Actor:  "alice@example.com",
+			Action: "entry.delete",
+			Target: []string{"old", "new"}[i],
+			Result: domain.AuditSuccess,
+		}
+		if err := store.Append(t.Context(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded, err := jsonfile.NewAuditStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, _ := reloaded.Query(t.Context(), domain.AuditQuery{Limit: 1})
+	if len(recs) != 1 || recs[0].Target != "new" || recs[0].Result != domain.AuditSuccess {
+		t.Fatalf("reloaded: got %+v", recs)
+	}
+
+	n, err := reloaded.Prune(t.Context(), now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("pruned: got %d, want 1", n)
+	}
+
+	again, _ := jsonfile.NewAuditStore(dir)
+	recs, _ = again.Query(t.Context(), domain.AuditQuery{})
+	if len(recs) != 1 || recs[0].Target != "new" {
+		t.Errorf("after prune: got %+v", recs)
+	}
+}
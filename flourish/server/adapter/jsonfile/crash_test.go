@@ -0,0 +1,200 @@
+package jsonfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"pgregory.net/rapid"
+
+	"flourish/server/adapter/jsonfile"
+	"flourish/server/domain"
+	"flourish/server/domain/crdt"
+)
+
+var errCrash = errors.New("injected crash")
+
+// crashAt はstageに到達したらクラッシュさせるフック。
+func crashAt(stage string) func(string) error {
+	return func(s string) error {
+		if s == stage {
+			return errCrash
+		}
+		return nil
+	}
+}
+
+func appendOp(store *jsonfile.EventStore, entryID uuid.UUID) (uuid.UUID, int64, error) {
+	reqID := uuid.New()
+	seq, err := store.Append(context.Background(), domain.Event{
+		EntryID:   entryID,
+		RequestID: reqID,
+		EventType: domain.EventCRDTOp,
+		SiteID:    uuid.New(),
+		Payload:   []byte(`{"op_type":1,"value":"a"}`),
+	})
+	return reqID, seq, err
+}
+
+// TestCrash_AckedOpsSurviveTornWrite は、追記の途中（任意のバイト位置）で落ちても
+// ACK済みのopが失われず、再起動後も追記を続けられることを確かめる。
+func TestCrash_AckedOpsSurviveTornWrite(t *testing.T) {
+	rapid.Check(t, func(rt *rapid.T) {
+		dir, err := os.MkdirTemp("", "flourish-crash-")
+		if err != nil {
+			rt.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		entryID := uuid.New()
+		path := filepath.Join(dir, "events", entryID.String()+".jsonl")
+
+		store, err := jsonfile.NewEventStore(dir)
+		if err != nil {
+			rt.Fatal(err)
+		}
+		acked := map[uuid.UUID]int64{}
+		for range rapid.IntRange(0, 10).Draw(rt, "acked") {
+			reqID, seq, err := appendOp(store, entryID)
+			if err != nil {
+				rt.Fatal(err)
+			}
+			acked[reqID] = seq
+		}
+		var ackedSize int64
+		if info, err := os.Stat(path); err == nil {
+			ackedSize = info.Size()
+		}
+
+		// 次の追記はfsync前に落ちる（ACKされない）。書き込まれたバイトのうち任意の長さだけ残す
+		restore := jsonfile.SetCrashHook(crashAt("append:before-sync"))
+		_, _, err = appendOp(store, entryID)
+		restore()
+		if !errors.Is(err, errCrash) {
+			rt.Fatalf("append: got %v, want injected crash", err)
+		}
+		info, _ := os.Stat(path)
+		kept := rapid.Int64Range(ackedSize, info.Size()).Draw(rt, "kept")
+		if err := os.Truncate(path, kept); err != nil {
+			rt.Fatal(err)
+		}
+
+		reopened, err := jsonfile.NewEventStore(dir)
+		if err != nil {
+			rt.Fatal(err)
+		}
+		for _, p := range reopened.LoadProblems() {
+			if p.Kind != jsonfile.ProblemTornTail {
+				rt.Fatalf("unexpected load problem: %+v", p)
+			}
+		}
+		events, _ := reopened.ListAfter(context.Background(), entryID, 0)
+		found := map[uuid.UUID]int64{}
+		for _, ev := range events {
+			found[ev.RequestID] = ev.ServerSeq
+		}
+		for reqID, seq := range acked {
+			if found[reqID] != seq {
+				rt.Fatalf("acked op %s (seq %d) lost after crash", reqID, seq)
+			}
+		}
+
+		// 再起動後の追記は連番を続け、再度読み込んでも問題が出ない
+		_, seq, err := appendOp(reopened, entryID)
+		if err != nil {
+			rt.Fatal(err)
+		}
+		if seq != int64(len(events))+1 {
+			rt.Fatalf("seq after restart: got %d, want %d", seq, len(events)+1)
+		}
+		again, err := jsonfile.NewEventStore(dir)
+		if err != nil {
+			rt.Fatal(err)
+		}
+		if problems := again.LoadProblems(); len(problems) != 0 {
+			rt.Fatalf("problems after restart: %+v", problems)
+		}
+	})
+}
+
+// TestCrash_WholeFileStoresAreAtomic は、置き換えの途中で落ちても古い内容が完全な形で残ることを確かめる。
+func TestCrash_WholeFileStoresAreAtomic(t *testing.T) {
+	for _, stage := range []string{"atomic:before-sync", "atomic:before-rename"} {
+		t.Run(stage, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			entries, _ := jsonfile.NewEntryStore(dir)
+			rgas, _ := jsonfile.NewRGAStateStore(dir)
+			entry := domain.NewEntry()
+			entry.Text = "before"
+			entries.Save(ctx, entry)
+			rgas.SaveRGA(ctx, entry.ID, crdt.RGASnapshot{Counter: 1})
+
+			restore := jsonfile.SetCrashHook(crashAt(stage))
+			entry.Text = "after"
+			if err := entries.Save(ctx, entry); !errors.Is(err, errCrash) {
+				t.Fatalf("entry save: got %v, want injected crash", err)
+			}
+			if err := rgas.SaveRGA(ctx, entry.ID, crdt.RGASnapshot{Counter: 2}); !errors.Is(err, errCrash) {
+				t.Fatalf("rga save: got %v, want injected crash", err)
+			}
+			restore()
+
+			reloaded, err := jsonfile.NewEntryStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := reloaded.FindByID(ctx, entry.ID)
+			if err != nil || got.Text != "before" {
+				t.Errorf("entry after crash: got %q, %v; want %q", got.Text, err, "before")
+			}
+			snap, err := rgas.LoadRGA(ctx, entry.ID)
+			if err != nil || snap.Counter != 1 {
+				t.Errorf("rga after crash: got counter %d, %v; want 1", snap.Counter, err)
+			}
+			ids, _ := rgas.ListRGAEntryIDs(ctx)
+			if len(ids) != 1 {
+				t.Errorf("rga ids after crash: got %v", ids)
+			}
+		})
+	}
+}
+
+// TestCrash_FailedAppendIsNotAcked は、fsync前に失敗した追記がメモリ上の状態を進めないことを確かめる。
+func TestCrash_FailedAppendIsNotAcked(t *testing.T) {
+	dir := t.TempDir()
+	entryID := uuid.New()
+	store, _ := jsonfile.NewEventStore(dir)
+
+	restore := jsonfile.SetCrashHook(crashAt("append:before-write"))
+	_, seq, err := appendOp(store, entryID)
+	restore()
+	if !errors.Is(err, errCrash) || seq != 0 {
+		t.Fatalf("append: got seq=%d err=%v", seq, err)
+	}
+	if max, _ := store.MaxServerSeq(context.Background(), entryID); max != 0 {
+		t.Errorf("max seq after failed append: got %d, want 0", max)
+	}
+	if _, seq, _ := appendOp(store, entryID); seq != 1 {
+		t.Errorf("seq after failed append: got %d, want 1", seq)
+	}
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	for in, want := range map[string]jsonfile.SyncPolicy{
+		"":         jsonfile.SyncAlways,
+		"always":   jsonfile.SyncAlways,
+		"interval": jsonfile.SyncInterval,
+		"never":    jsonfile.SyncNever,
+	} {
+		if got, err := jsonfile.ParseSyncPolicy(in); err != nil || got != want {
+			t.Errorf("ParseSyncPolicy(%q): got %v, %v", in, got, err)
+		}
+	}
+	if _, err := jsonfile.ParseSyncPolicy("sometimes"); err == nil {
+		t.Error("unknown policy should be rejected")
+	}
+}
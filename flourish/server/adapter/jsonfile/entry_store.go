@@ -25,14 +25,20 @@ type EntryStore struct {
 
 // entryJSON はJSON保存用の構造体。
 type entryJSON struct {
-	ID        string  `json:"id"`
-	Title     string  `json:"title"`
-	Content   string  `json:"content"`
-	Thumbnail *string `json:"thumbnail,omitempty"`
-	Text      string  `json:"text"`
-	CreatedAt string  `json:"created_at"`
-	UpdatedAt string  `json:"updated_at"`
-	Deleted   bool    `json:"deleted"`
+	ID             string   `json:"id"`
+	Title          string   `json:"title"`
+	Content        string   `json:"content"`
+	Thumbnail      *string  `json:"thumbnail,omitempty"`
+	Text           string   `json:"text"`
+	Tags           []string `json:"tags,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	WordCount      int      `json:"word_count,omitempty"`
+	ReadingMinutes int      `json:"reading_minutes,omitempty"`
+	CreatedAt      string   `json:"created_at"`
+	UpdatedAt      string   `json:"updated_at"`
+	Deleted        bool     `json:"deleted"`
+	DeletedAt      string   `json:"deleted_at,omitempty"`
+	Locked         bool     `json:"locked,omitempty"`
 }
 
 func NewEntryStore(dataDir string) (*EntryStore, error) {
@@ -77,15 +83,25 @@ func (s *EntryStore) loadFromFile() error {
 		}
 		createdAt, _ := time.Parse(time.RFC3339Nano, item.CreatedAt)
 		updatedAt, _ := time.Parse(time.RFC3339Nano, item.UpdatedAt)
+		var deletedAt time.Time
+		if item.DeletedAt != "" {
+			deletedAt, _ = time.Parse(time.RFC3339Nano, item.DeletedAt)
+		}
 		s.entries[id] = domain.Entry{
-			ID:        id,
-			Title:     item.Title,
-			Content:   item.Content,
-			Thumbnail: item.Thumbnail,
-			Text:      item.Text,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
-			Deleted:   item.Deleted,
+			ID:             id,
+			Title:          item.Title,
+			Content:        item.Content,
+			Thumbnail:      item.Thumbnail,
+			Text:           item.Text,
+			Tags:           item.Tags,
+			Description:    item.Description,
+			WordCount:      item.WordCount,
+			ReadingMinutes: item.ReadingMinutes,
+			CreatedAt:      createdAt,
+			UpdatedAt:      updatedAt,
+			Deleted:        item.Deleted,
+			DeletedAt:      deletedAt,
+			Locked:         item.Locked,
 		}
 	}
 	return nil
@@ -94,15 +110,25 @@ func (s *EntryStore) loadFromFile() error {
 func (s *EntryStore) saveToFile() error {
 	items := make([]entryJSON, 0, len(s.entries))
 	for _, entry := range s.entries {
+		var deletedAt string
+		if !entry.DeletedAt.IsZero() {
+			deletedAt = entry.DeletedAt.Format(time.RFC3339Nano)
+		}
 		items = append(items, entryJSON{
-			ID:        entry.ID.String(),
-			Title:     entry.Title,
-			Content:   entry.Content,
-			Thumbnail: entry.Thumbnail,
-			Text:      entry.Text,
-			CreatedAt: entry.CreatedAt.Format(time.RFC3339Nano),
-			UpdatedAt: entry.UpdatedAt.Format(time.RFC3339Nano),
-			Deleted:   entry.Deleted,
+			ID:             entry.ID.String(),
+			Title:          entry.Title,
+			Content:        entry.Content,
+			Thumbnail:      entry.Thumbnail,
+			Text:           entry.Text,
+			Tags:           entry.Tags,
+			Description:    entry.Description,
+			WordCount:      entry.WordCount,
+			ReadingMinutes: entry.ReadingMinutes,
+			CreatedAt:      entry.CreatedAt.Format(time.RFC3339Nano),
+			UpdatedAt:      entry.UpdatedAt.Format(time.RFC3339Nano),
+			Deleted:        entry.Deleted,
+			DeletedAt:      deletedAt,
+			Locked:         entry.Locked,
 		})
 	}
 
@@ -111,7 +137,7 @@ func (s *EntryStore) saveToFile() error {
 		return err
 	}
 
-	return os.WriteFile(s.path, data, 0o644)
+	return writeFileAtomic(s.path, data, 0o644)
 }
 
 func (s *EntryStore) Save(_ context.Context, entry domain.Entry) error {
@@ -136,6 +162,23 @@ func (s *EntryStore) FindByID(_ context.Context, id uuid.UUID) (domain.Entry, er
 	return entry, nil
 }
 
+func (s *EntryStore) Update(_ context.Context, id uuid.UUID, fn func(*domain.Entry)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return domain.ErrEntryNotFound
+	}
+	if entry.Deleted {
+		return domain.ErrEntryDeleted
+	}
+	fn(&entry)
+	entry.ID = id
+	s.entries[id] = entry
+	return s.saveToFile()
+}
+
 func (s *EntryStore) List(_ context.Context) ([]domain.EntryListItem, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -161,6 +204,51 @@ func (s *EntryStore) Delete(_ context.Context, id uuid.UUID) error {
 		return domain.ErrEntryNotFound
 	}
 	entry.Deleted = true
+	entry.DeletedAt = time.Now().UTC()
+	s.entries[id] = entry
+	return s.saveToFile()
+}
+
+func (s *EntryStore) ListDeleted(_ context.Context) ([]domain.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []domain.Entry
+	for _, entry := range s.entries {
+		if entry.Deleted {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b domain.Entry) int {
+		return b.DeletedAt.Compare(a.DeletedAt)
+	})
+	return entries, nil
+}
+
+func (s *EntryStore) Restore(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return domain.ErrEntryNotFound
+	}
+	if !entry.Deleted {
+		return domain.ErrEntryNotDeleted
+	}
+	entry.Deleted = false
+	entry.DeletedAt = time.Time{}
 	s.entries[id] = entry
 	return s.saveToFile()
 }
+
+func (s *EntryStore) Purge(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[id]; !ok {
+		return domain.ErrEntryNotFound
+	}
+	delete(s.entries, id)
+	return s.saveToFile()
+}
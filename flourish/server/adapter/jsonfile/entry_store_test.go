@@ -82,3 +82,44 @@ func TestEntryStore_Delete(t *testing.T) {
 		t.Errorf("deleted entry should not appear in list: got %d", len(items))
 	}
 }
+
+func TestEntryStore_TrashRestorePurge(t *testing.T) {
+	dir := t.TempDir()
+	store, err := jsonfile.NewEntryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, purged := domain.NewEntry(), domain.NewEntry()
+	store.Save(t.Context(), kept)
+	store.Save(t.Context(), purged)
+	store.Delete(t.Context(), kept.ID)
+	store.Delete(t.Context(), purged.ID)
+
+	reloaded, err := jsonfile.NewEntryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash, _ := reloaded.ListDeleted(t.Context())
+	if len(trash) != 2 || trash[0].DeletedAt.IsZero() {
+		t.Fatalf("trash after reload: got %+v", trash)
+	}
+
+	if err := reloaded.Restore(t.Context(), kept.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Restore(t.Context(), kept.ID); err != domain.ErrEntryNotDeleted {
+		t.Errorf("restore of live entry: got %v, want ErrEntryNotDeleted", err)
+	}
+	if err := reloaded.Purge(t.Context(), purged.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := jsonfile.NewEntryStore(dir)
+	if _, err := again.FindByID(t.Context(), kept.ID); err != nil {
+		t.Errorf("restored entry: %v", err)
+	}
+	if _, err := again.FindByID(t.Context(), purged.ID); err != domain.ErrEntryNotFound {
+		t.Errorf("purged entry: got %v, want ErrEntryNotFound", err)
+	}
+}
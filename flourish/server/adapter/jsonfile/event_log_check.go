@@ -0,0 +1,178 @@
+package jsonfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// イベントログファイルの不整合の種別。
+const (
+	ProblemUnparsableLine = "unparsable_line"
+	ProblemDuplicateSeq   = "duplicate_seq"
+	ProblemSeqGap         = "seq_gap"
+	ProblemOrphanFile     = "orphan_file"
+)
+
+// EventLogProblem はイベントログファイルの不整合。Lineは1始まり（ファイル全体の問題なら0）。
+type EventLogProblem struct {
+	EntryID uuid.UUID
+	Path    string
+	Line    int
+	Kind    string
+	Detail  string
+}
+
+// CheckEventLogs は data/events/ 配下のJSONLを読み、EventStoreの読み込みでは黙って
+// 読み飛ばされる行（解析できない行・server_seqの重複・欠番）と、エントリIDでないファイルを報告する。
+func CheckEventLogs(dataDir string) ([]EventLogProblem, error) {
+	dir := filepath.Join(dataDir, "events")
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var problems []EventLogProblem
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".jsonl.bak") {
+			continue // RepairEventLogのバックアップ
+		}
+		path := filepath.Join(dir, f.Name())
+		entryID, err := uuid.Parse(strings.TrimSuffix(f.Name(), ".jsonl"))
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".jsonl") || err != nil {
+			problems = append(problems, EventLogProblem{Path: path, Kind: ProblemOrphanFile, Detail: "not an entry event log"})
+			continue
+		}
+		found, err := checkEventLog(path, entryID)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, found...)
+	}
+	return problems, nil
+}
+
+func checkEventLog(path string, entryID uuid.UUID) ([]EventLogProblem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		problems []EventLogProblem
+		seen     = make(map[int64]int) // server_seq -> 行番号
+		maxSeq   int64
+	)
+	for i, line := range splitLines(data) {
+		ej, ok := parseEventLine(line, entryID)
+		if !ok {
+			problems = append(problems, EventLogProblem{EntryID: entryID, Path: path, Line: i + 1, Kind: ProblemUnparsableLine})
+			continue
+		}
+		if first, dup := seen[ej.ServerSeq]; dup {
+			problems = append(problems, EventLogProblem{
+				EntryID: entryID, Path: path, Line: i + 1, Kind: ProblemDuplicateSeq,
+				Detail: fmt.Sprintf("server_seq=%d first seen at line %d", ej.ServerSeq, first),
+			})
+			continue
+		}
+		seen[ej.ServerSeq] = i + 1
+		maxSeq = max(maxSeq, ej.ServerSeq)
+	}
+
+	for seq := int64(1); seq <= maxSeq; seq++ {
+		if _, ok := seen[seq]; ok {
+			continue
+		}
+		from := seq
+		for seq+1 <= maxSeq {
+			if _, ok := seen[seq+1]; ok {
+				break
+			}
+			seq++
+		}
+		problems = append(problems, EventLogProblem{
+			EntryID: entryID, Path: path, Kind: ProblemSeqGap,
+			Detail: fmt.Sprintf("missing server_seq %d-%d", from, seq),
+		})
+	}
+	return problems, nil
+}
+
+// RepairEventLog は解析できない行とserver_seqが重複する行（後に現れた方）を取り除く。
+// 元のファイルは .bak として残す。欠番は失われたイベントなので修復できない。
+func RepairEventLog(path string, entryID uuid.UUID) (dropped int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	seen := make(map[int64]struct{})
+	for _, line := range splitLines(data) {
+		ej, ok := parseEventLine(line, entryID)
+		if !ok {
+			dropped++
+			continue
+		}
+		if _, dup := seen[ej.ServerSeq]; dup {
+			dropped++
+			continue
+		}
+		seen[ej.ServerSeq] = struct{}{}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if dropped == 0 {
+		return 0, nil
+	}
+
+	if err := os.WriteFile(path+".bak", data, 0o644); err != nil {
+		return 0, fmt.Errorf("backup event log: %w", err)
+	}
+	if err := writeFileAtomic(path, buf.Bytes(), 0o644); err != nil {
+		return 0, fmt.Errorf("replace event log: %w", err)
+	}
+	return dropped, nil
+}
+
+// splitLines は空行を除いた行に分割する。
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB per line
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			lines = append(lines, bytes.Clone(line))
+		}
+	}
+	return lines
+}
+
+// parseEventLine はイベント行を解析する。JSONとして壊れている、server_seqやrequest_idが不正、
+// またはファイル名と異なるエントリのイベントであればfalseを返す。
+func parseEventLine(line []byte, entryID uuid.UUID) (eventJSON, bool) {
+	var ej eventJSON
+	if err := json.Unmarshal(line, &ej); err != nil {
+		return eventJSON{}, false
+	}
+	if ej.ServerSeq <= 0 {
+		return eventJSON{}, false
+	}
+	if _, err := uuid.Parse(ej.RequestID); err != nil {
+		return eventJSON{}, false
+	}
+	if id, err := uuid.Parse(ej.EntryID); err != nil || id != entryID {
+		return eventJSON{}, false
+	}
+	return ej, true
+}
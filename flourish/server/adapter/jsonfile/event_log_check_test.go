@@ -0,0 +1,63 @@
+package jsonfile_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/jsonfile"
+)
+
+func TestCheckEventLogs(t *testing.T) {
+	dir := t.TempDir()
+	eventsDir := filepath.Join(dir, "events")
+	os.MkdirAll(eventsDir, 0o755)
+
+	entryID := uuid.New()
+	line := func(seq int) string {
+		return fmt.Sprintf(`{"entry_id":%q,"server_seq":%d,"request_id":%q,"event_type":"crdt_op","payload":{}}`+"\n", entryID, seq, uuid.New())
+	}
+	// seq 3-4 が欠番、2が重複、壊れた行が1行
+	content := line(1) + line(2) + line(2) + "not json\n" + line(5)
+	path := filepath.Join(eventsDir, entryID.String()+".jsonl")
+	os.WriteFile(path, []byte(content), 0o644)
+	os.WriteFile(filepath.Join(eventsDir, "stray.txt"), nil, 0o644)
+
+	problems, err := jsonfile.CheckEventLogs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for _, p := range problems {
+		got[p.Kind]++
+	}
+	want := map[string]int{
+		jsonfile.ProblemDuplicateSeq:   1,
+		jsonfile.ProblemUnparsableLine: 1,
+		jsonfile.ProblemSeqGap:         1,
+		jsonfile.ProblemOrphanFile:     1,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("problems: got %v, want %v", problems, want)
+	}
+
+	dropped, err := jsonfile.RepairEventLog(path, entryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 2 {
+		t.Errorf("dropped: got %d, want 2", dropped)
+	}
+	if _, err := os.Stat(path + ".bak"); err != nil {
+		t.Errorf("backup should exist: %v", err)
+	}
+
+	// 欠番だけが残る
+	problems, _ = jsonfile.CheckEventLogs(dir)
+	if len(problems) != 2 {
+		t.Errorf("after repair: got %v", problems)
+	}
+}
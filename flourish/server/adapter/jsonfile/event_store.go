@@ -1,12 +1,14 @@
 package jsonfile
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +18,37 @@ import (
 	"flourish/server/domain"
 )
 
+// SyncPolicy はイベントログの追記をfsyncする方針。
+type SyncPolicy int
+
+const (
+	// SyncAlways は追記のたびにfsyncしてから返す。ACK済みのopはクラッシュしても失われない。
+	SyncAlways SyncPolicy = iota
+	// SyncInterval は一定間隔でまとめてfsyncする。クラッシュ時に直近の間隔分のopを失いうる。
+	SyncInterval
+	// SyncNever はfsyncせずOSに任せる。
+	SyncNever
+)
+
+// ParseSyncPolicy は "always" / "interval" / "never" をSyncPolicyに変換する。
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch s {
+	case "always", "":
+		return SyncAlways, nil
+	case "interval":
+		return SyncInterval, nil
+	case "never":
+		return SyncNever, nil
+	}
+	return 0, fmt.Errorf("unknown sync policy %q", s)
+}
+
+// イベントログの読み込み時に見つかった問題の種別（ProblemUnparsableLineと併用）。
+const ProblemTornTail = "torn_tail"
+
+// ErrReadOnly は読み取り専用で開いたEventStoreへの書き込みで返す。
+var ErrReadOnly = errors.New("event store is read-only")
+
 // EventStore はJSONLファイルベースのEventStore実装。
 // data/events/{entryID}.jsonl にエントリごとのイベントを保存する。
 type EventStore struct {
@@ -24,6 +57,21 @@ type EventStore struct {
 	events map[uuid.UUID][]domain.Event
 	seqs   map[uuid.UUID]int64
 	seen   map[uuid.UUID]struct{}
+
+	policy   SyncPolicy
+	dirty    map[uuid.UUID]struct{} // SyncIntervalで未fsyncのエントリ
+	stop     chan struct{}
+	problems []EventLogProblem
+	readOnly bool                    // ファイルを一切変更しない（途切れた末尾も切り詰めない）
+	purged   map[uuid.UUID]time.Time // 完全削除したエントリ（フォロワーに削除を伝える記録）
+}
+
+// purgedFile は完全削除したエントリの記録。拡張子が.jsonlでないのでイベントログとしては読まれない。
+const purgedFile = "purged.json"
+
+type purgedJSON struct {
+	EntryID  string    `json:"entry_id"`
+	PurgedAt time.Time `json:"purged_at"`
 }
 
 func NewEventStore(dataDir string) (*EventStore, error) {
@@ -37,15 +85,120 @@ func NewEventStore(dataDir string) (*EventStore, error) {
 		events: make(map[uuid.UUID][]domain.Event),
 		seqs:   make(map[uuid.UUID]int64),
 		seen:   make(map[uuid.UUID]struct{}),
+		dirty:  make(map[uuid.UUID]struct{}),
+		purged: make(map[uuid.UUID]time.Time),
 	}
 
+	return s.load()
+}
+
+// NewReadOnlyEventStore はファイルを変更せずにイベントログを読み込む。fsckの検査で使う。
+// 途切れた末尾行は切り詰めずに読み飛ばしてLoadProblemsで報告し、書き込みはErrReadOnlyを返す。
+func NewReadOnlyEventStore(dataDir string) (*EventStore, error) {
+	s := &EventStore{
+		dir:      filepath.Join(dataDir, "events"),
+		events:   make(map[uuid.UUID][]domain.Event),
+		seqs:     make(map[uuid.UUID]int64),
+		seen:     make(map[uuid.UUID]struct{}),
+		dirty:    make(map[uuid.UUID]struct{}),
+		purged:   make(map[uuid.UUID]time.Time),
+		readOnly: true,
+	}
+	return s.load()
+}
+
+func (s *EventStore) load() (*EventStore, error) {
 	if err := s.loadAll(); err != nil {
 		return nil, fmt.Errorf("load events: %w", err)
 	}
-
+	if err := s.loadPurged(); err != nil {
+		return nil, fmt.Errorf("load %s: %w", purgedFile, err)
+	}
 	return s, nil
 }
 
+// SetSyncPolicy はfsyncの方針を設定する。SyncIntervalではintervalごとにまとめてfsyncする。
+func (s *EventStore) SetSyncPolicy(policy SyncPolicy, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.policy = policy
+	if policy == SyncInterval {
+		s.stop = make(chan struct{})
+		go s.syncLoop(interval, s.stop)
+	}
+}
+
+func (s *EventStore) syncLoop(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Sync()
+		}
+	}
+}
+
+// Sync はfsyncされていない追記をまとめてfsyncする。
+func (s *EventStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.syncDirty()
+}
+
+// syncDirty はロック保持前提。
+func (s *EventStore) syncDirty() error {
+	if len(s.dirty) == 0 {
+		return nil
+	}
+	for entryID := range s.dirty {
+		f, err := os.OpenFile(s.path(entryID), os.O_WRONLY, 0)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Purge済み
+			}
+			return err
+		}
+		err = f.Sync()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("sync event log: %w", err)
+		}
+	}
+	clear(s.dirty)
+	return syncDir(s.dir)
+}
+
+// Close はバックグラウンドのfsyncを止め、未fsyncの追記をfsyncする。
+func (s *EventStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	return s.syncDirty()
+}
+
+// LoadProblems は起動時の読み込みで見つかった問題を返す。
+// 途中で途切れた末尾行（書き込み中のクラッシュ）は切り詰めて修復済み（読み取り専用なら読み飛ばしただけ）、
+// 解析できない行は読み飛ばしている（fsck -repairで取り除ける）。
+func (s *EventStore) LoadProblems() []EventLogProblem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.problems)
+}
+
+func (s *EventStore) path(entryID uuid.UUID) string {
+	return filepath.Join(s.dir, entryID.String()+".jsonl")
+}
+
 // eventJSON はJSONL保存用の構造体。
 type eventJSON struct {
 	EntryID   string          `json:"entry_id"`
@@ -72,41 +225,71 @@ func (s *EventStore) loadAll() error {
 		if err != nil {
 			continue
 		}
-
-		f, err := os.Open(filepath.Join(s.dir, e.Name()))
-		if err != nil {
+		if err := s.loadFile(entryID); err != nil {
 			return err
 		}
+	}
 
-		scanner := bufio.NewScanner(f)
-		scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB per line
-		for scanner.Scan() {
-			var ej eventJSON
-			if err := json.Unmarshal(scanner.Bytes(), &ej); err != nil {
-				continue
-			}
-			requestID, _ := uuid.Parse(ej.RequestID)
-			siteID, _ := uuid.Parse(ej.SiteID)
-			createdAt, _ := time.Parse(time.RFC3339Nano, ej.CreatedAt)
-
-			ev := domain.Event{
-				EntryID:   entryID,
-				ServerSeq: ej.ServerSeq,
-				RequestID: requestID,
-				EventType: domain.EventType(ej.EventType),
-				SiteID:    siteID,
-				Payload:   []byte(ej.Payload),
-				CreatedAt: createdAt,
-			}
-			s.events[entryID] = append(s.events[entryID], ev)
-			s.seen[requestID] = struct{}{}
-			if ej.ServerSeq > s.seqs[entryID] {
-				s.seqs[entryID] = ej.ServerSeq
+	return nil
+}
+
+// loadFile は1エントリのJSONLを読み込む。改行で終わっていない末尾は書き込み途中で落ちた
+// 追記なので切り詰める（fsync前にACKしないので、ACK済みのopは含まれない）。
+func (s *EventStore) loadFile(entryID uuid.UUID) error {
+	path := s.path(entryID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lineNo := 0
+	for off := 0; off < len(data); {
+		i := bytes.IndexByte(data[off:], '\n')
+		if i < 0 {
+			detail := fmt.Sprintf("truncated %d bytes", len(data)-off)
+			if s.readOnly {
+				detail = fmt.Sprintf("%d bytes not terminated by newline", len(data)-off)
+			} else if err := os.Truncate(path, int64(off)); err != nil {
+				return fmt.Errorf("truncate torn tail: %w", err)
 			}
+			s.problems = append(s.problems, EventLogProblem{
+				EntryID: entryID, Path: path, Line: lineNo + 1, Kind: ProblemTornTail, Detail: detail,
+			})
+			break
 		}
-		f.Close()
-	}
+		line := bytes.TrimSpace(data[off : off+i])
+		off += i + 1
+		lineNo++
+		if len(line) == 0 {
+			continue
+		}
+
+		var ej eventJSON
+		if err := json.Unmarshal(line, &ej); err != nil {
+			s.problems = append(s.problems, EventLogProblem{
+				EntryID: entryID, Path: path, Line: lineNo, Kind: ProblemUnparsableLine, Detail: err.Error(),
+			})
+			continue
+		}
+		requestID, _ := uuid.Parse(ej.RequestID)
+		siteID, _ := uuid.Parse(ej.SiteID)
+		createdAt, _ := time.Parse(time.RFC3339Nano, ej.CreatedAt)
 
+		ev := domain.Event{
+			EntryID:   entryID,
+			ServerSeq: ej.ServerSeq,
+			RequestID: requestID,
+			EventType: domain.EventType(ej.EventType),
+			SiteID:    siteID,
+			Payload:   []byte(ej.Payload),
+			CreatedAt: createdAt,
+		}
+		s.events[entryID] = append(s.events[entryID], ev)
+		s.seen[requestID] = struct{}{}
+		if ej.ServerSeq > s.seqs[entryID] {
+			s.seqs[entryID] = ej.ServerSeq
+		}
+	}
 	return nil
 }
 
@@ -114,27 +297,55 @@ func (s *EventStore) Append(_ context.Context, event domain.Event) (int64, error
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
 	if _, exists := s.seen[event.RequestID]; exists {
 		return 0, nil
 	}
-	s.seen[event.RequestID] = struct{}{}
 
-	s.seqs[event.EntryID]++
-	seq := s.seqs[event.EntryID]
-	event.ServerSeq = seq
+	// ファイルへの追記（とfsync）が済んでからメモリ上の状態を進める
+	event.ServerSeq = s.seqs[event.EntryID] + 1
 	event.CreatedAt = time.Now().UTC()
+	if err := s.appendToFile(event); err != nil {
+		return 0, fmt.Errorf("append to file: %w", err)
+	}
+
+	s.seen[event.RequestID] = struct{}{}
+	s.seqs[event.EntryID] = event.ServerSeq
 	s.events[event.EntryID] = append(s.events[event.EntryID], event)
+	return event.ServerSeq, nil
+}
+
+// AppendReplica はリーダーのイベントをserver_seqとcreated_atを保ったまま追記する。
+func (s *EventStore) AppendReplica(_ context.Context, event domain.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// ファイルに追記
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if event.ServerSeq != s.seqs[event.EntryID]+1 {
+		return fmt.Errorf("%w: entry %s got %d after %d", domain.ErrReplicaOutOfSequence, event.EntryID, event.ServerSeq, s.seqs[event.EntryID])
+	}
+	if _, exists := s.seen[event.RequestID]; exists {
+		return fmt.Errorf("%w: duplicate request_id %s", domain.ErrReplicaOutOfSequence, event.RequestID)
+	}
 	if err := s.appendToFile(event); err != nil {
-		return 0, fmt.Errorf("append to file: %w", err)
+		return fmt.Errorf("append to file: %w", err)
 	}
 
-	return seq, nil
+	s.seen[event.RequestID] = struct{}{}
+	s.seqs[event.EntryID] = event.ServerSeq
+	s.events[event.EntryID] = append(s.events[event.EntryID], event)
+	return nil
 }
 
 func (s *EventStore) appendToFile(event domain.Event) error {
-	path := filepath.Join(s.dir, event.EntryID.String()+".jsonl")
+	path := s.path(event.EntryID)
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+
 	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
 		return err
@@ -155,8 +366,65 @@ func (s *EventStore) appendToFile(event domain.Event) error {
 	if err != nil {
 		return err
 	}
-	_, err = f.Write(append(data, '\n'))
-	return err
+	data = append(data, '\n')
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := crashPoint("append:before-write"); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		// 書きかけの行を残すと次の追記と連結されてしまうので元の長さに戻す
+		f.Truncate(info.Size())
+		return err
+	}
+	if err := crashPoint("append:before-sync"); err != nil {
+		return err
+	}
+
+	switch s.policy {
+	case SyncAlways:
+		if err := f.Sync(); err != nil {
+			f.Truncate(info.Size())
+			return fmt.Errorf("sync event log: %w", err)
+		}
+		if created {
+			return syncDir(s.dir)
+		}
+	case SyncInterval:
+		s.dirty[event.EntryID] = struct{}{}
+	}
+	return nil
+}
+
+// Purge はエントリのイベントとJSONLファイルを削除し、削除したことを記録する。
+func (s *EventStore) Purge(_ context.Context, entryID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	// 記録してから消す。記録だけ残ってもフォロワーは消えたエントリを消すだけで済む
+	if _, ok := s.purged[entryID]; !ok {
+		s.purged[entryID] = time.Now().UTC()
+		if err := s.savePurged(); err != nil {
+			delete(s.purged, entryID)
+			return fmt.Errorf("record purge: %w", err)
+		}
+	}
+	path := filepath.Join(s.dir, entryID.String()+".jsonl")
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove events file: %w", err)
+	}
+	for _, e := range s.events[entryID] {
+		delete(s.seen, e.RequestID)
+	}
+	delete(s.events, entryID)
+	delete(s.seqs, entryID)
+	delete(s.dirty, entryID)
+	return nil
 }
 
 func (s *EventStore) ListAfter(_ context.Context, entryID uuid.UUID, afterSeq int64) ([]domain.Event, error) {
@@ -191,3 +459,49 @@ func (s *EventStore) EntryIDs() []uuid.UUID {
 	}
 	return ids
 }
+
+// PurgedIDs は完全削除したエントリIDを返す。
+func (s *EventStore) PurgedIDs() []uuid.UUID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]uuid.UUID, 0, len(s.purged))
+	for id := range s.purged {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (s *EventStore) loadPurged() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, purgedFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []purgedJSON
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, p := range list {
+		if id, err := uuid.Parse(p.EntryID); err == nil {
+			s.purged[id] = p.PurgedAt
+		}
+	}
+	return nil
+}
+
+// savePurged はロック保持前提。
+func (s *EventStore) savePurged() error {
+	list := make([]purgedJSON, 0, len(s.purged))
+	for id, at := range s.purged {
+		list = append(list, purgedJSON{EntryID: id.String(), PurgedAt: at})
+	}
+	slices.SortFunc(list, func(a, b purgedJSON) int { return strings.Compare(a.EntryID, b.EntryID) })
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, purgedFile), data, 0o644)
+}
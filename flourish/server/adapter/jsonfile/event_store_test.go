@@ -2,7 +2,9 @@ package jsonfile_test
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -125,3 +127,50 @@ func TestEventStore_PersistAndReload(t *testing.T) {
 		}
 	}
 }
+
+func TestEventStore_AppendReplica(t *testing.T) {
+	dir := t.TempDir()
+	store, err := jsonfile.NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entryID := uuid.New()
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	event := func(seq int64) domain.Event {
+		return domain.Event{EntryID: entryID, ServerSeq: seq, RequestID: uuid.New(), EventType: domain.EventCRDTOp, SiteID: uuid.New(), Payload: []byte(`{"op_type":1}`), CreatedAt: createdAt}
+	}
+	if err := store.AppendReplica(t.Context(), event(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendReplica(t.Context(), event(3)); !errors.Is(err, domain.ErrReplicaOutOfSequence) {
+		t.Errorf("gap: got %v, want ErrReplicaOutOfSequence", err)
+	}
+	if err := store.AppendReplica(t.Context(), event(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := jsonfile.NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, _ := reloaded.ListAfter(t.Context(), entryID, 0)
+	if len(events) != 2 || events[1].ServerSeq != 2 || !events[1].CreatedAt.Equal(createdAt) {
+		t.Errorf("replicated events should keep server_seq and created_at: got %+v", events)
+	}
+	if seq, _ := reloaded.Append(t.Context(), domain.Event{EntryID: entryID, RequestID: uuid.New(), EventType: domain.EventCRDTOp, Payload: []byte(`{}`)}); seq != 3 {
+		t.Errorf("append after replica: got seq %d, want 3", seq)
+	}
+
+	// 完全削除の記録は再起動後も残り、フォロワーに削除を伝えられる
+	if err := reloaded.Purge(t.Context(), entryID); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err = jsonfile.NewEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := reloaded.PurgedIDs(); len(ids) != 1 || ids[0] != entryID || len(reloaded.EntryIDs()) != 0 {
+		t.Errorf("purge record after reload: got purged %v, entries %v", ids, reloaded.EntryIDs())
+	}
+}
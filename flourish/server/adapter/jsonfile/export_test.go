@@ -0,0 +1,8 @@
+package jsonfile
+
+// SetCrashHook はクラッシュ注入フックを設定し、元に戻す関数を返す。
+func SetCrashHook(hook func(stage string) error) (restore func()) {
+	prev := crashHook
+	crashHook = hook
+	return func() { crashHook = prev }
+}
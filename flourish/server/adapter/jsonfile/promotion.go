@@ -0,0 +1,44 @@
+package jsonfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// promotionFile はフォロワーがリーダーに昇格したことの記録。あればREPLICATION_LEADERを無視してリーダーとして起動する。
+const promotionFile = "promoted.json"
+
+type promotionJSON struct {
+	PromotedAt time.Time `json:"promoted_at"`
+}
+
+// MarkPromoted はデータディレクトリにリーダーへの昇格を記録する。
+func MarkPromoted(dataDir string, at time.Time) error {
+	data, err := json.Marshal(promotionJSON{PromotedAt: at.UTC()})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dataDir, promotionFile), data, 0o644)
+}
+
+// PromotedAt はデータディレクトリが昇格済みならその時刻を返す。
+func PromotedAt(dataDir string) (time.Time, bool, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, promotionFile))
+	if os.IsNotExist(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var p promotionJSON
+	if err := json.Unmarshal(data, &p); err != nil {
+		return time.Time{}, false, fmt.Errorf("parse %s: %w", promotionFile, err)
+	}
+	return p.PromotedAt, true, nil
+}
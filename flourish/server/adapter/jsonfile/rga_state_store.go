@@ -33,7 +33,7 @@ func (s *RGAStateStore) SaveRGA(_ context.Context, entryID uuid.UUID, snap crdt.
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	return writeFileAtomic(path, data, 0o644)
 }
 
 func (s *RGAStateStore) LoadRGA(_ context.Context, entryID uuid.UUID) (crdt.RGASnapshot, error) {
@@ -49,6 +49,14 @@ func (s *RGAStateStore) LoadRGA(_ context.Context, entryID uuid.UUID) (crdt.RGAS
 	return snap, nil
 }
 
+func (s *RGAStateStore) DeleteRGA(_ context.Context, entryID uuid.UUID) error {
+	path := filepath.Join(s.dir, entryID.String()+".json")
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func (s *RGAStateStore) ListRGAEntryIDs(_ context.Context) ([]uuid.UUID, error) {
 	entries, err := os.ReadDir(s.dir)
 	if err != nil {
@@ -0,0 +1,158 @@
+package jsonfile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+)
+
+// APITokenStore はJSONファイルベースのAPITokenStore実装。
+// data/tokens.json にトークンのハッシュを保存する（平文は保存しない）。
+type APITokenStore struct {
+	mu     sync.RWMutex
+	path   string
+	tokens map[uuid.UUID]domain.APIToken
+}
+
+// tokenJSON はJSON保存用の構造体。
+type tokenJSON struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Hash      string   `json:"hash"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt string   `json:"created_at"`
+}
+
+func NewAPITokenStore(dataDir string) (*APITokenStore, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+
+	s := &APITokenStore{
+		path:   filepath.Join(dataDir, "tokens.json"),
+		tokens: make(map[uuid.UUID]domain.APIToken),
+	}
+
+	if err := s.loadFromFile(); err != nil {
+		return nil, fmt.Errorf("load tokens: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *APITokenStore) loadFromFile() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var items []tokenJSON
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		id, err := uuid.Parse(item.ID)
+		if err != nil {
+			continue
+		}
+		createdAt, _ := time.Parse(time.RFC3339Nano, item.CreatedAt)
+		scopes := make([]domain.Scope, len(item.Scopes))
+		for i, sc := range item.Scopes {
+			scopes[i] = domain.Scope(sc)
+		}
+		s.tokens[id] = domain.APIToken{
+			ID:        id,
+			Name:      item.Name,
+			Hash:      item.Hash,
+			Scopes:    scopes,
+			CreatedAt: createdAt,
+		}
+	}
+	return nil
+}
+
+func (s *APITokenStore) saveToFile() error {
+	items := make([]tokenJSON, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		scopes := make([]string, len(token.Scopes))
+		for i, sc := range token.Scopes {
+			scopes[i] = string(sc)
+		}
+		items = append(items, tokenJSON{
+			ID:        token.ID.String(),
+			Name:      token.Name,
+			Hash:      token.Hash,
+			Scopes:    scopes,
+			CreatedAt: token.CreatedAt.Format(time.RFC3339Nano),
+		})
+	}
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data, 0o600)
+}
+
+func (s *APITokenStore) Save(_ context.Context, token domain.APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.Scopes = slices.Clone(token.Scopes)
+	s.tokens[token.ID] = token
+	return s.saveToFile()
+}
+
+func (s *APITokenStore) FindByHash(_ context.Context, hash string) (domain.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.tokens {
+		if token.Hash == hash {
+			return token, nil
+		}
+	}
+	return domain.APIToken{}, domain.ErrTokenNotFound
+}
+
+func (s *APITokenStore) List(_ context.Context) ([]domain.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]domain.APIToken, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token)
+	}
+	slices.SortFunc(tokens, func(a, b domain.APIToken) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (s *APITokenStore) Delete(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[id]; !ok {
+		return domain.ErrTokenNotFound
+	}
+	delete(s.tokens, id)
+	return s.saveToFile()
+}
@@ -0,0 +1,52 @@
+package jsonfile_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/jsonfile"
+	"flourish/server/domain"
+)
+
+func TestAPITokenStore_SaveAndReload(t *testing.T) {
+	dir := t.TempDir()
+	token := domain.APIToken{
+		ID:        uuid.New(),
+		Name:      "ci",
+		Hash:      "abc123",
+		Scopes:    []domain.Scope{domain.ScopeRead, domain.ScopeEdit},
+		CreatedAt: time.Now(),
+	}
+
+	store, err := jsonfile.NewAPITokenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(t.Context(), token); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := jsonfile.NewAPITokenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reloaded.FindByHash(t.Context(), "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != token.ID || got.Name != "ci" || !slices.Equal(got.Scopes, token.Scopes) {
+		t.Errorf("reloaded token: got %+v", got)
+	}
+
+	if err := reloaded.Delete(t.Context(), token.ID); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := jsonfile.NewAPITokenStore(dir)
+	if _, err := again.FindByHash(t.Context(), "abc123"); !errors.Is(err, domain.ErrTokenNotFound) {
+		t.Errorf("deleted token: got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"flourish/server/domain"
+)
+
+// AuditStore は監査記録のインメモリ実装。
+type AuditStore struct {
+	mu      sync.RWMutex
+	records []domain.AuditRecord
+}
+
+func NewAuditStore() *AuditStore {
+	return &AuditStore{}
+}
+
+func (s *AuditStore) Append(_ context.Context, rec domain.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *AuditStore) Query(_ context.Context, q domain.AuditQuery) ([]domain.AuditRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []domain.AuditRecord
+	for _, rec := range slices.Backward(s.records) {
+		if !q.Match(rec) {
+			continue
+		}
+		result = append(result, rec)
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (s *AuditStore) Prune(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.records)
+	s.records = slices.DeleteFunc(s.records, func(rec domain.AuditRecord) bool {
+		return rec.Time.Before(before)
+	})
+	return n - len(s.records), nil
+}
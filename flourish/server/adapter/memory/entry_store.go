@@ -2,7 +2,9 @@ package memory
 
 import (
 	"context"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -25,6 +27,7 @@ func (s *EntryStore) Save(_ context.Context, entry domain.Entry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	entry.Tags = slices.Clone(entry.Tags)
 	s.entries[entry.ID] = entry
 	return nil
 }
@@ -43,6 +46,24 @@ func (s *EntryStore) FindByID(_ context.Context, id uuid.UUID) (domain.Entry, er
 	return entry, nil
 }
 
+func (s *EntryStore) Update(_ context.Context, id uuid.UUID, fn func(*domain.Entry)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return domain.ErrEntryNotFound
+	}
+	if entry.Deleted {
+		return domain.ErrEntryDeleted
+	}
+	fn(&entry)
+	entry.ID = id
+	entry.Tags = slices.Clone(entry.Tags)
+	s.entries[id] = entry
+	return nil
+}
+
 func (s *EntryStore) List(_ context.Context) ([]domain.EntryListItem, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -65,6 +86,51 @@ func (s *EntryStore) Delete(_ context.Context, id uuid.UUID) error {
 		return domain.ErrEntryNotFound
 	}
 	entry.Deleted = true
+	entry.DeletedAt = time.Now().UTC()
 	s.entries[id] = entry
 	return nil
 }
+
+func (s *EntryStore) ListDeleted(_ context.Context) ([]domain.Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []domain.Entry
+	for _, entry := range s.entries {
+		if entry.Deleted {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b domain.Entry) int {
+		return b.DeletedAt.Compare(a.DeletedAt)
+	})
+	return entries, nil
+}
+
+func (s *EntryStore) Restore(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return domain.ErrEntryNotFound
+	}
+	if !entry.Deleted {
+		return domain.ErrEntryNotDeleted
+	}
+	entry.Deleted = false
+	entry.DeletedAt = time.Time{}
+	s.entries[id] = entry
+	return nil
+}
+
+func (s *EntryStore) Purge(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[id]; !ok {
+		return domain.ErrEntryNotFound
+	}
+	delete(s.entries, id)
+	return nil
+}
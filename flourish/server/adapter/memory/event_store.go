@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -16,6 +17,7 @@ type EventStore struct {
 	events map[uuid.UUID][]domain.Event // entryID -> events
 	seqs   map[uuid.UUID]int64          // entryID -> 最新seq
 	seen   map[uuid.UUID]struct{}       // request_id -> 重複検知
+	purged map[uuid.UUID]struct{}       // 完全削除したエントリ
 }
 
 func NewEventStore() *EventStore {
@@ -23,6 +25,7 @@ func NewEventStore() *EventStore {
 		events: make(map[uuid.UUID][]domain.Event),
 		seqs:   make(map[uuid.UUID]int64),
 		seen:   make(map[uuid.UUID]struct{}),
+		purged: make(map[uuid.UUID]struct{}),
 	}
 }
 
@@ -44,6 +47,36 @@ func (s *EventStore) Append(_ context.Context, event domain.Event) (int64, error
 	return seq, nil
 }
 
+// AppendReplica はリーダーのイベントをserver_seqとcreated_atを保ったまま追記する。
+func (s *EventStore) AppendReplica(_ context.Context, event domain.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if event.ServerSeq != s.seqs[event.EntryID]+1 {
+		return fmt.Errorf("%w: entry %s got %d after %d", domain.ErrReplicaOutOfSequence, event.EntryID, event.ServerSeq, s.seqs[event.EntryID])
+	}
+	if _, exists := s.seen[event.RequestID]; exists {
+		return fmt.Errorf("%w: duplicate request_id %s", domain.ErrReplicaOutOfSequence, event.RequestID)
+	}
+	s.seen[event.RequestID] = struct{}{}
+	s.seqs[event.EntryID] = event.ServerSeq
+	s.events[event.EntryID] = append(s.events[event.EntryID], event)
+	return nil
+}
+
+func (s *EventStore) Purge(_ context.Context, entryID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.events[entryID] {
+		delete(s.seen, e.RequestID)
+	}
+	delete(s.events, entryID)
+	delete(s.seqs, entryID)
+	s.purged[entryID] = struct{}{}
+	return nil
+}
+
 func (s *EventStore) ListAfter(_ context.Context, entryID uuid.UUID, afterSeq int64) ([]domain.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -64,3 +97,27 @@ func (s *EventStore) MaxServerSeq(_ context.Context, entryID uuid.UUID) (int64,
 
 	return s.seqs[entryID], nil
 }
+
+// EntryIDs はイベントのある全エントリIDを返す。
+func (s *EventStore) EntryIDs() []uuid.UUID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]uuid.UUID, 0, len(s.events))
+	for id := range s.events {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// PurgedIDs は完全削除したエントリIDを返す。
+func (s *EventStore) PurgedIDs() []uuid.UUID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]uuid.UUID, 0, len(s.purged))
+	for id := range s.purged {
+		ids = append(ids, id)
+	}
+	return ids
+}
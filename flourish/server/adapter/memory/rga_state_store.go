@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+	"flourish/server/domain/crdt"
+)
+
+// RGAStateStore はRGAスナップショットのインメモリ実装。
+type RGAStateStore struct {
+	mu     sync.RWMutex
+	states map[uuid.UUID]crdt.RGASnapshot
+}
+
+func NewRGAStateStore() *RGAStateStore {
+	return &RGAStateStore{
+		states: make(map[uuid.UUID]crdt.RGASnapshot),
+	}
+}
+
+func (s *RGAStateStore) SaveRGA(_ context.Context, entryID uuid.UUID, snap crdt.RGASnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[entryID] = snap
+	return nil
+}
+
+func (s *RGAStateStore) LoadRGA(_ context.Context, entryID uuid.UUID) (crdt.RGASnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap, ok := s.states[entryID]
+	if !ok {
+		return crdt.RGASnapshot{}, domain.ErrEntryNotFound
+	}
+	return snap, nil
+}
+
+func (s *RGAStateStore) DeleteRGA(_ context.Context, entryID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, entryID)
+	return nil
+}
+
+func (s *RGAStateStore) ListRGAEntryIDs(_ context.Context) ([]uuid.UUID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]uuid.UUID, 0, len(s.states))
+	for id := range s.states {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+)
+
+// APITokenStore はAPIトークンのインメモリ実装。
+type APITokenStore struct {
+	mu     sync.RWMutex
+	tokens map[uuid.UUID]domain.APIToken
+}
+
+func NewAPITokenStore() *APITokenStore {
+	return &APITokenStore{
+		tokens: make(map[uuid.UUID]domain.APIToken),
+	}
+}
+
+func (s *APITokenStore) Save(_ context.Context, token domain.APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.Scopes = slices.Clone(token.Scopes)
+	s.tokens[token.ID] = token
+	return nil
+}
+
+func (s *APITokenStore) FindByHash(_ context.Context, hash string) (domain.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.tokens {
+		if token.Hash == hash {
+			return token, nil
+		}
+	}
+	return domain.APIToken{}, domain.ErrTokenNotFound
+}
+
+func (s *APITokenStore) List(_ context.Context) ([]domain.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]domain.APIToken, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token)
+	}
+	slices.SortFunc(tokens, func(a, b domain.APIToken) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (s *APITokenStore) Delete(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[id]; !ok {
+		return domain.ErrTokenNotFound
+	}
+	delete(s.tokens, id)
+	return nil
+}
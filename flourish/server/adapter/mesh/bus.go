@@ -0,0 +1,339 @@
+// Package mesh は複数のflourishインスタンスをTCPで全結合するapplication.Busの実装。
+// 各インスタンスは他の全インスタンスに接続し、発行したメッセージを1行1件のJSONで送る。
+// 送れなかったメッセージは捨て、受信側のSyncServiceが次のsyncでGetDiffにより補う。
+//
+// メッセージはopの配信の合図で、opそのものの複製ではない。受信側は自分のEventStoreから差分を読むので、
+// 全インスタンスが同じイベントログ（domain.SharedEventStore）を使っている必要がある。
+//
+// 接続は合言葉で相手を確かめるだけで暗号化しない（合言葉も平文で流れる）。
+// インスタンス間はプライベートネットワークかTLSのトンネル（WireGuard・stunnelなど）でつなぐこと。
+package mesh
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+)
+
+const (
+	// maxFrameBytes は1メッセージの最大サイズ。
+	maxFrameBytes = 4 << 20
+	// writeTimeout は相手への1メッセージの書き込み期限。
+	writeTimeout = 5 * time.Second
+	// maxRedialDelay は再接続の間隔の上限。
+	maxRedialDelay = 5 * time.Second
+)
+
+// Config はメッシュの設定。
+type Config struct {
+	// ListenAddr は他のインスタンスからの接続を受け付けるアドレス。空なら127.0.0.1:7946。
+	ListenAddr string
+	// Peers は接続する他のインスタンスのアドレス。
+	Peers []string
+	// Secret はインスタンス間で共有する合言葉。必須。
+	Secret string
+	// QueueSize は相手ごとの送信待ちの上限。溢れた分は捨てる。
+	QueueSize int
+}
+
+// Stats はメッシュの配信状況。
+type Stats struct {
+	Peers     int    `json:"peers"`
+	Connected int    `json:"connected"`
+	Sent      uint64 `json:"sent"`
+	Received  uint64 `json:"received"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// Bus はTCPメッシュのBus。
+type Bus struct {
+	cfg    Config
+	origin string
+	log    *slog.Logger
+	ln     net.Listener
+
+	mu      sync.Mutex
+	handler func(application.BusMessage)
+	peers   []*peer
+	conns   map[net.Conn]struct{}
+	closed  bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	sent, received, dropped atomic.Uint64
+}
+
+// hello は接続直後に送る名乗り。
+type hello struct {
+	Origin string `json:"origin"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// DefaultListenAddr はListenAddrを指定しないときの待ち受けアドレス。
+const DefaultListenAddr = "127.0.0.1:7946"
+
+// ErrNoSecret はSecretを指定せずにNewを呼んだときに返す。
+var ErrNoSecret = errors.New("mesh: secret is required")
+
+// New はListenAddrで待ち受け、Peersへの接続を始める。Secretが空ならErrNoSecretを返す。
+func New(cfg Config, log *slog.Logger) (*Bus, error) {
+	if cfg.Secret == "" {
+		return nil, ErrNoSecret
+	}
+	if cfg.ListenAddr == "" {
+		cfg.ListenAddr = DefaultListenAddr
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	ln, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bus{
+		cfg:    cfg,
+		origin: uuid.NewString(),
+		log:    log,
+		ln:     ln,
+		conns:  make(map[net.Conn]struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	b.wg.Add(1)
+	go b.accept()
+	for _, addr := range cfg.Peers {
+		b.AddPeer(addr)
+	}
+	return b, nil
+}
+
+// Remote はapplication.RemoteBusの印。
+func (b *Bus) Remote() {}
+
+// Addr は待ち受けているアドレスを返す。
+func (b *Bus) Addr() net.Addr {
+	return b.ln.Addr()
+}
+
+// AddPeer は接続する相手を追加する。
+func (b *Bus) AddPeer(addr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	p := &peer{addr: addr, queue: make(chan []byte, b.cfg.QueueSize)}
+	b.peers = append(b.peers, p)
+	b.wg.Add(1)
+	go b.dial(p)
+}
+
+// Publish は自インスタンスに配信し、全ての相手の送信待ちに積む。
+func (b *Bus) Publish(msg application.BusMessage) {
+	msg.Origin = b.origin
+	b.deliver(msg)
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		b.log.Error("bus message marshal error", "error", err)
+		return
+	}
+	data = append(data, '\n')
+	b.mu.Lock()
+	peers := b.peers
+	b.mu.Unlock()
+	for _, p := range peers {
+		select {
+		case p.queue <- data:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+func (b *Bus) Listen(handler func(application.BusMessage)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handler = handler
+}
+
+func (b *Bus) deliver(msg application.BusMessage) {
+	b.mu.Lock()
+	handler := b.handler
+	b.mu.Unlock()
+	if handler != nil {
+		handler(msg)
+	}
+}
+
+// Stats は配信状況を返す。
+func (b *Bus) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := Stats{Peers: len(b.peers), Sent: b.sent.Load(), Received: b.received.Load(), Dropped: b.dropped.Load()}
+	for _, p := range b.peers {
+		if p.connected.Load() {
+			s.Connected++
+		}
+	}
+	return s
+}
+
+// Close は待ち受けと全ての接続を閉じる。
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.cancel()
+	err := b.ln.Close()
+	for conn := range b.conns {
+		conn.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+	return err
+}
+
+// track は接続をClose時に閉じる対象にする。閉じた後ならfalseを返す。
+func (b *Bus) track(conn net.Conn) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	b.conns[conn] = struct{}{}
+	return true
+}
+
+func (b *Bus) untrack(conn net.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.conns, conn)
+	conn.Close()
+}
+
+// accept は他のインスタンスからの接続を受け付ける。
+func (b *Bus) accept() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				b.log.Error("bus accept error", "error", err)
+			}
+			return
+		}
+		if !b.track(conn) {
+			conn.Close()
+			return
+		}
+		b.wg.Add(1)
+		go b.read(conn)
+	}
+}
+
+// read は名乗りを検証してから、届いたメッセージを自インスタンスに配信する。
+func (b *Bus) read(conn net.Conn) {
+	defer b.wg.Done()
+	defer b.untrack(conn)
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxFrameBytes)
+	if !scanner.Scan() {
+		return
+	}
+	var h hello
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil || subtle.ConstantTimeCompare([]byte(h.Secret), []byte(b.cfg.Secret)) != 1 {
+		b.log.Warn("bus peer rejected", "remoteAddr", conn.RemoteAddr())
+		return
+	}
+	b.log.Info("bus peer connected", "remoteAddr", conn.RemoteAddr(), "origin", h.Origin)
+
+	for scanner.Scan() {
+		var msg application.BusMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			b.log.Warn("bus message unmarshal error", "error", err)
+			continue
+		}
+		if msg.Origin == b.origin {
+			continue
+		}
+		b.received.Add(1)
+		b.deliver(msg)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		b.log.Warn("bus peer read error", "remoteAddr", conn.RemoteAddr(), "error", err)
+	}
+}
+
+// peer は接続する相手と送信待ち。
+type peer struct {
+	addr      string
+	queue     chan []byte
+	connected atomic.Bool
+}
+
+// dial は相手に接続して送信待ちを書き込み続ける。切れたら間隔を空けて再接続する。
+func (b *Bus) dial(p *peer) {
+	defer b.wg.Done()
+	delay := 100 * time.Millisecond
+	for {
+		var d net.Dialer
+		conn, err := d.DialContext(b.ctx, "tcp", p.addr)
+		if err == nil && b.track(conn) {
+			delay = 100 * time.Millisecond
+			p.connected.Store(true)
+			b.write(p, conn)
+			p.connected.Store(false)
+			b.untrack(conn)
+		} else if err == nil {
+			conn.Close()
+		}
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRedialDelay)
+	}
+}
+
+// write は名乗ってから送信待ちを書き込む。書き込みに失敗したら返る。
+func (b *Bus) write(p *peer, conn net.Conn) {
+	data, _ := json.Marshal(hello{Origin: b.origin, Secret: b.cfg.Secret})
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case data := <-p.queue:
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := conn.Write(data); err != nil {
+				b.log.Warn("bus peer write error", "addr", p.addr, "error", err)
+				b.dropped.Add(1)
+				return
+			}
+			b.sent.Add(1)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package mesh_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/mesh"
+	"flourish/server/application"
+)
+
+func newBus(t *testing.T, secret string) *mesh.Bus {
+	t.Helper()
+	b, err := mesh.New(mesh.Config{ListenAddr: "127.0.0.1:0", Secret: secret}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func listen(b *mesh.Bus) <-chan application.BusMessage {
+	ch := make(chan application.BusMessage, 16)
+	b.Listen(func(msg application.BusMessage) { ch <- msg })
+	return ch
+}
+
+func receive(ch <-chan application.BusMessage, timeout time.Duration) (application.BusMessage, bool) {
+	select {
+	case msg := <-ch:
+		return msg, true
+	case <-time.After(timeout):
+		return application.BusMessage{}, false
+	}
+}
+
+func TestBus_DeliversToPeersOnce(t *testing.T) {
+	a, b := newBus(t, "s3cret"), newBus(t, "s3cret")
+	a.AddPeer(b.Addr().String())
+	b.AddPeer(a.Addr().String())
+	fromA, fromB := listen(a), listen(b)
+
+	entryID := uuid.New()
+	a.Publish(application.BusMessage{Sync: &application.SyncMessage{
+		EntryID:         entryID,
+		Ops:             []application.SyncOp{{RequestID: uuid.New(), ServerSeq: 7, Payload: []byte(`{}`)}},
+		LatestServerSeq: 7,
+	}})
+
+	local, ok := receive(fromA, 2*time.Second)
+	if !ok || local.Sync == nil || local.Sync.EntryID != entryID {
+		t.Fatalf("発行したインスタンス自身にも配信すべき: got %+v", local)
+	}
+	remote, ok := receive(fromB, 2*time.Second)
+	if !ok || remote.Sync == nil || remote.Sync.EntryID != entryID || remote.Sync.LatestServerSeq != 7 || len(remote.Sync.Ops) != 1 {
+		t.Fatalf("相手のインスタンスに届くべき: got %+v", remote)
+	}
+	if remote.Origin == "" || remote.Origin != local.Origin {
+		t.Errorf("発行元が記録されるべき: local %q remote %q", local.Origin, remote.Origin)
+	}
+	if msg, ok := receive(fromA, 200*time.Millisecond); ok {
+		t.Errorf("自分の発行したメッセージは相手から戻ってこないべき: got %+v", msg)
+	}
+	if s := b.Stats(); s.Received != 1 || s.Dropped != 0 {
+		t.Errorf("stats: got %+v", s)
+	}
+}
+
+func TestNew_RequiresSecret(t *testing.T) {
+	if _, err := mesh.New(mesh.Config{ListenAddr: "127.0.0.1:0"}, slog.New(slog.NewTextHandler(io.Discard, nil))); !errors.Is(err, mesh.ErrNoSecret) {
+		t.Errorf("合言葉なしでは起動しないべき: got %v", err)
+	}
+}
+
+func TestBus_RejectsPeerWithWrongSecret(t *testing.T) {
+	a, b := newBus(t, "s3cret"), newBus(t, "other")
+	a.AddPeer(b.Addr().String())
+	fromB := listen(b)
+
+	a.Publish(application.BusMessage{Notification: &application.Notification{Type: application.NotifyEntryCreate, EntryID: uuid.New()}, All: true})
+	if msg, ok := receive(fromB, 500*time.Millisecond); ok {
+		t.Errorf("合言葉の違う相手のメッセージは受け取らないべき: got %+v", msg)
+	}
+}
@@ -0,0 +1,117 @@
+package application
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+
+	"flourish/server/domain"
+)
+
+// 監査対象のアクション。
+const (
+	AuditEntryDelete        = "entry.delete"
+	AuditEntryRestore       = "entry.restore"
+	AuditEntryPurge         = "entry.purge"
+	AuditEntryLock          = "entry.lock"
+	AuditEntryUnlock        = "entry.unlock"
+	AuditEntryTags          = "entry.tags"
+	AuditModerationAccept   = "moderation.approve"
+	AuditModerationReject   = "moderation.reject"
+	AuditSuggestionAccept   = "suggestion.accept"
+	AuditSuggestionReject   = "suggestion.reject"
+	AuditTokenCreate        = "token.create"
+	AuditTokenRevoke        = "token.revoke"
+	AuditWSTicketIssue      = "ws_ticket.issue"
+	AuditWSRevoke           = "ws.revoke"
+	AuditReplicationPromote = "replication.promote"
+	AuditLogout             = "auth.logout"
+	AuditOpRejected         = "op.unauthenticated_delete"
+)
+
+// AnonymousActor は未認証の操作者を表す。
+const AnonymousActor = "anonymous"
+
+type actorContextKey struct{}
+
+// WithActor は操作者をコンテキストに設定する。
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext はコンテキストの操作者を返す。未設定ならAnonymousActor。
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorContextKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+// AuditLog は管理操作・破壊的操作を監査ストアに追記する。
+type AuditLog struct {
+	store     domain.AuditStore
+	retention time.Duration
+	log       *slog.Logger
+}
+
+// NewAuditLog は新しいAuditLogを生成する。retentionが0以下なら記録を削除しない。
+func NewAuditLog(store domain.AuditStore, retention time.Duration, log *slog.Logger) *AuditLog {
+	return &AuditLog{store: store, retention: retention, log: log}
+}
+
+// Record は操作を記録する。操作者とトレースIDはコンテキストから取る。
+// 記録の失敗で操作自体は失敗させず、ログに残す。
+func (a *AuditLog) Record(ctx context.Context, action, target string, result domain.AuditResult, detail string) {
+	if a == nil {
+		return
+	}
+	rec := domain.AuditRecord{
+		ID:     uuid.New(),
+		Time:   time.Now().UTC(),
+		Actor:  ActorFromContext(ctx),
+		Action: action,
+		Target: target,
+		Result: result,
+		Detail: detail,
+	}
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		rec.TraceID = spanCtx.TraceID().String()
+	}
+	if err := a.store.Append(ctx, rec); err != nil {
+		a.log.Error("audit: 記録失敗", "action", action, "target", target, "error", err)
+	}
+}
+
+// Query は監査記録を新しい順に返す。
+func (a *AuditLog) Query(ctx context.Context, q domain.AuditQuery) ([]domain.AuditRecord, error) {
+	return a.store.Query(ctx, q)
+}
+
+// Prune は保持期間を過ぎた記録を削除する。
+func (a *AuditLog) Prune(ctx context.Context) (int, error) {
+	if a.retention <= 0 {
+		return 0, nil
+	}
+	return a.store.Prune(ctx, time.Now().Add(-a.retention))
+}
+
+// RunRetention はctxが終了するまでinterval毎に保持期間を適用する。
+func (a *AuditLog) RunRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := a.Prune(ctx); err != nil {
+			a.log.Error("audit: 保持期間の適用失敗", "error", err)
+		} else if n > 0 {
+			a.log.Info("audit: 保持期間を過ぎた記録を削除", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
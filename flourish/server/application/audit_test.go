@@ -0,0 +1,101 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+func TestAuditLog_RecordAndQuery(t *testing.T) {
+	store := memory.NewAuditStore()
+	audit := application.NewAuditLog(store, time.Hour, slog.Default())
+
+	traceID := trace.TraceID{1, 2, 3}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  trace.SpanID{1},
+	}))
+	ctx = application.WithActor(ctx, "alice@example.com")
+
+	audit.Record(ctx, application.AuditEntryDelete, "e1", domain.AuditSuccess, "")
+	audit.Record(context.Background(), application.AuditEntryDelete, "e2", domain.AuditDenied, "")
+
+	got, err := audit.Query(context.Background(), domain.AuditQuery{Actor: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Target != "e1" || got[0].TraceID != traceID.String() {
+		t.Fatalf("query by actor: got %+v", got)
+	}
+
+	// 新しい順に返す
+	all, _ := audit.Query(context.Background(), domain.AuditQuery{Action: application.AuditEntryDelete})
+	if len(all) != 2 || all[0].Target != "e2" || all[0].Actor != application.AnonymousActor {
+		t.Errorf("query by action: got %+v", all)
+	}
+}
+
+func TestAuditLog_Prune(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewAuditStore()
+	store.Append(ctx, domain.AuditRecord{ID: uuid.New(), Time: time.Now().Add(-2 * time.Hour), Action: "old"})
+	store.Append(ctx, domain.AuditRecord{ID: uuid.New(), Time: time.Now(), Action: "new"})
+
+	audit := application.NewAuditLog(store, time.Hour, slog.Default())
+	n, err := audit.Prune(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("pruned: got %d, want 1", n)
+	}
+	left, _ := store.Query(ctx, domain.AuditQuery{})
+	if len(left) != 1 || left[0].Action != "new" {
+		t.Errorf("remaining: got %+v", left)
+	}
+}
+
+func TestEntryProjector_AuditsRejectedDelete(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	auditStore := memory.NewAuditStore()
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), slog.Default())
+	projector.SetAuditLog(application.NewAuditLog(auditStore, 0, slog.Default()))
+
+	entry := domain.NewEntry()
+	entryStore.Save(ctx, entry)
+
+	siteID := uuid.New()
+	insert, _ := json.Marshal(map[string]any{
+		"request_id":    uuid.New().String(),
+		"op_type":       1,
+		"node_id":       map[string]any{"site_id": siteID.String(), "timestamp": 1},
+		"value":         "a",
+		"authenticated": true,
+	})
+	projector.Apply(ctx, entry.ID, insert)
+
+	del, _ := json.Marshal(map[string]any{
+		"request_id":    uuid.New().String(),
+		"op_type":       2,
+		"node_id":       map[string]any{"site_id": siteID.String(), "timestamp": 1},
+		"authenticated": false,
+	})
+	if projector.Apply(ctx, entry.ID, del) {
+		t.Fatal("unauthenticated delete of authenticated node should be rejected")
+	}
+
+	recs, _ := auditStore.Query(ctx, domain.AuditQuery{Action: application.AuditOpRejected})
+	if len(recs) != 1 || recs[0].Target != entry.ID.String() || recs[0].Result != domain.AuditDenied {
+		t.Errorf("audit records: got %+v", recs)
+	}
+}
@@ -0,0 +1,231 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+)
+
+// ErrBusNeedsSharedLog はインスタンス間のBusを、インスタンスごとに別のイベントログで使おうとしたときに返す。
+var ErrBusNeedsSharedLog = errors.New("bus across instances requires a shared event store")
+
+// BusMessage はインスタンス間で配信するsyncメッセージまたは通知。
+type BusMessage struct {
+	// Origin は発行したインスタンス。バスの実装が自分の発行したメッセージを見分けるのに使う。
+	Origin       string        `json:"origin,omitempty"`
+	Sync         *SyncMessage  `json:"sync,omitempty"`
+	Notification *Notification `json:"notification,omitempty"`
+	// All は通知を全エントリの購読者に配信する（NotifyAll）。
+	All bool `json:"all,omitempty"`
+	// Advance はsyncを配信しないイベント（タグ・ロック、projectorが拒否したopなど）のserver_seq。
+	// 受信側はそこまで配信位置を進め、抜けと取り違えないようにする。
+	Advance *SeqAdvance `json:"advance,omitempty"`
+}
+
+// SeqAdvance はsyncを配信しないイベントのserver_seq。
+type SeqAdvance struct {
+	EntryID   uuid.UUID `json:"entry_id"`
+	ServerSeq int64     `json:"server_seq"`
+}
+
+// Bus はSyncServiceの配信をインスタンス間で共有する。
+// Publishしたメッセージは自インスタンスを含む全インスタンスのListenに届く。
+// インスタンス間の配信は失われることがあり、syncの抜けは受信側がGetDiffで補う。
+// 受信側は自分のEventStoreを読むので、全インスタンスが同じイベントログを使っていることが前提。
+type Bus interface {
+	Publish(msg BusMessage)
+	// Listen は受信したメッセージを渡す関数を登録する。
+	Listen(handler func(BusMessage))
+}
+
+// RemoteBus は他のインスタンスにも配信するBus。domain.SharedEventStoreとしか組み合わせられない。
+type RemoteBus interface {
+	Bus
+	// Remote は何もしない。他のインスタンスに配信する実装であることの印。
+	Remote()
+}
+
+// LocalBus は単一インスタンス用の、プロセス内だけで配信するBus。
+type LocalBus struct {
+	mu      sync.RWMutex
+	handler func(BusMessage)
+}
+
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+func (b *LocalBus) Publish(msg BusMessage) {
+	b.mu.RLock()
+	handler := b.handler
+	b.mu.RUnlock()
+	if handler != nil {
+		handler(msg)
+	}
+}
+
+func (b *LocalBus) Listen(handler func(BusMessage)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handler = handler
+}
+
+// SetBus は配信に使うBusを変更する。購読が始まる前に呼ぶ。
+// RemoteBusはEventStoreがdomain.SharedEventStoreでなければErrBusNeedsSharedLogを返す。
+// インスタンスごとのログでは、他のインスタンスのopは自分のログに無く、server_seqも衝突するため。
+func (s *SyncService) SetBus(bus Bus) error {
+	if _, remote := bus.(RemoteBus); remote {
+		if _, shared := s.eventStore.(domain.SharedEventStore); !shared {
+			return ErrBusNeedsSharedLog
+		}
+	}
+	s.bus = bus
+	bus.Listen(s.receive)
+	return nil
+}
+
+// receive はBusから届いたメッセージをこのインスタンスの購読者に配信する。
+func (s *SyncService) receive(msg BusMessage) {
+	if msg.Advance != nil {
+		s.advance(msg.Advance.EntryID, msg.Advance.ServerSeq)
+	}
+	switch {
+	case msg.Sync != nil:
+		s.deliver(*msg.Sync)
+	case msg.Notification == nil:
+	case msg.All:
+		s.notifyAll(*msg.Notification)
+	case msg.Notification.Type == NotifyEntryUpdate:
+		s.notifyListUpdate(msg.Notification.EntryID, msg.Notification.ServerSeq)
+	default:
+		for _, sub := range s.recipients(msg.Notification.EntryID, msg.Notification.Type) {
+			sub.Notify(*msg.Notification)
+		}
+	}
+}
+
+// maxSkippedSeqs は配信位置より先に届いた、syncを配信しないserver_seqを覚えておく上限。
+const maxSkippedSeqs = 1024
+
+// entryCursor はエントリの購読者に配信済みの最新のserver_seq。
+type entryCursor struct {
+	mu   sync.Mutex
+	last int64 // 0なら未配信
+	// skipped は配信位置より先に届いた、syncを配信しないイベントのserver_seq
+	skipped map[int64]struct{}
+}
+
+// catchUp は配信位置の直後に続くsyncを配信しないserver_seqの分だけ配信位置を進める。ロック保持前提。
+func (c *entryCursor) catchUp() {
+	for {
+		if _, ok := c.skipped[c.last+1]; !ok {
+			break
+		}
+		delete(c.skipped, c.last+1)
+		c.last++
+	}
+	for seq := range c.skipped {
+		if seq <= c.last {
+			delete(c.skipped, seq)
+		}
+	}
+}
+
+// advance はsyncを配信しないイベントのserver_seqを配信済みとして扱う。
+// 先に届いた場合は覚えておき、手前のsyncを配信したときに進める。
+func (s *SyncService) advance(entryID uuid.UUID, serverSeq int64) {
+	s.mu.RLock()
+	c, ok := s.cursors[entryID]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == 0 || serverSeq <= c.last {
+		return
+	}
+	if c.skipped == nil || len(c.skipped) >= maxSkippedSeqs {
+		c.skipped = make(map[int64]struct{})
+	}
+	c.skipped[serverSeq] = struct{}{}
+	c.catchUp()
+}
+
+// deliver はsyncメッセージをserver_seq順に購読者へ配信する。
+// 配信済みのものは捨て、抜けがあれば配信済みの続きからの差分に置き換える。
+func (s *SyncService) deliver(msg SyncMessage) {
+	s.notifyListUpdate(msg.EntryID, msg.LatestServerSeq)
+
+	s.mu.Lock()
+	if len(s.subscribers[msg.EntryID]) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	c, ok := s.cursors[msg.EntryID]
+	if !ok {
+		c = &entryCursor{}
+		s.cursors[msg.EntryID] = c
+	}
+	s.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last > 0 {
+		if msg.LatestServerSeq <= c.last {
+			return
+		}
+		// 複数のopをまとめたsync（却下のdeleteなど）は、間に他の書き込みが挟まっていることがある
+		if seqBefore(msg) > c.last || !c.contiguous(msg) {
+			// 配信しないイベントの通知が落ちた場合など、抜けに見えても差分が空のことがある
+			diff, err := s.GetDiff(context.Background(), msg.EntryID, c.last)
+			if err == nil {
+				s.gapCatchUps.Add(1)
+				msg = diff
+			}
+		}
+	}
+	c.last = max(c.last, msg.LatestServerSeq)
+	c.catchUp()
+
+	s.mu.RLock()
+	subs := s.subscribers[msg.EntryID]
+	s.mu.RUnlock()
+	msg.Ops = s.decodeOps(msg.EntryID, msg.Ops)
+	for _, sub := range subs {
+		sub.Send(msg)
+	}
+}
+
+// contiguous はsyncメッセージのopのserver_seqが、syncを配信しないイベントを除いて連続しているかどうかを返す。
+// ロック保持前提。
+func (c *entryCursor) contiguous(msg SyncMessage) bool {
+	for i := 1; i < len(msg.Ops); i++ {
+		if msg.Ops[i].ServerSeq-msg.Ops[i-1].ServerSeq > maxSkippedSeqs {
+			return false
+		}
+		for seq := msg.Ops[i-1].ServerSeq + 1; seq < msg.Ops[i].ServerSeq; seq++ {
+			if _, ok := c.skipped[seq]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GapCatchUps はsyncの抜けを差分で補った回数を返す。
+func (s *SyncService) GapCatchUps() uint64 {
+	return s.gapCatchUps.Load()
+}
+
+// dropCursor は購読者のいなくなったエントリの配信位置を忘れる。ロック保持前提。
+func (s *SyncService) dropCursor(entryID uuid.UUID) {
+	if len(s.subscribers[entryID]) == 0 {
+		delete(s.subscribers, entryID)
+		delete(s.cursors, entryID)
+	}
+}
@@ -0,0 +1,274 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// pairBus は2つのインスタンスをつなぐテスト用のBus。dropがtrueの間は相手に届けない。
+type pairBus struct {
+	handler func(application.BusMessage)
+	peer    *pairBus
+	drop    bool
+}
+
+func newPairBuses() (*pairBus, *pairBus) {
+	a, b := &pairBus{}, &pairBus{}
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+func (b *pairBus) Publish(msg application.BusMessage) {
+	b.handler(msg)
+	if !b.drop {
+		b.peer.handler(msg)
+	}
+}
+
+func (b *pairBus) Listen(handler func(application.BusMessage)) { b.handler = handler }
+
+// remotePairBus は他のインスタンスに配信するBusとして振る舞うpairBus。
+type remotePairBus struct{ *pairBus }
+
+func (remotePairBus) Remote() {}
+
+// sharedEventStore は全インスタンスが同じログを使うEventStoreの代わり。
+type sharedEventStore struct{ *memory.EventStore }
+
+func (sharedEventStore) SharedAcrossInstances() {}
+
+func seqsOf(msgs []application.SyncMessage) []int64 {
+	var seqs []int64
+	for _, m := range msgs {
+		for _, op := range m.Ops {
+			seqs = append(seqs, op.ServerSeq)
+		}
+	}
+	return seqs
+}
+
+func TestSyncService_BusFansOutAcrossInstancesAndFillsGaps(t *testing.T) {
+	eventStore := sharedEventStore{memory.NewEventStore()}
+	a, b := application.NewSyncService(eventStore), application.NewSyncService(eventStore)
+	busA, busB := newPairBuses()
+	if err := a.SetBus(remotePairBus{busA}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetBus(remotePairBus{busB}); err != nil {
+		t.Fatal(err)
+	}
+
+	entryID := uuid.New()
+	sub := &mockSubscriber{}
+	b.Subscribe(entryID, sub)
+	list := &mockSubscriber{}
+	b.SubscribeList(list)
+
+	msgs := appendOps(t, a, entryID, 4)
+	a.Broadcast(entryID, msgs[0])
+	busA.drop = true
+	a.Broadcast(entryID, msgs[1]) // 相手に届かない
+	busA.drop = false
+	a.Broadcast(entryID, msgs[3])
+	a.Broadcast(entryID, msgs[2]) // 抜けを補った差分で配信済み
+
+	if got := seqsOf(sub.Messages()); !slices.Equal(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("別インスタンスの購読者にserver_seq順に届くべき: got %v", got)
+	}
+	if b.GapCatchUps() != 1 {
+		t.Errorf("抜けは差分で補うべき: got %d", b.GapCatchUps())
+	}
+
+	a.Notify(entryID, application.Notification{Type: application.NotifyEntryLock, EntryID: entryID, Locked: true})
+	a.NotifyAll(application.Notification{Type: application.NotifyEntryCreate, EntryID: uuid.New()})
+	if got := sub.Notifications(); len(got) != 2 || got[0].Type != application.NotifyEntryLock || got[1].Type != application.NotifyEntryCreate {
+		t.Errorf("通知も別インスタンスに届くべき: got %+v", got)
+	}
+	if len(list.Notifications()) == 0 || list.Notifications()[0].Type != application.NotifyEntryUpdate {
+		t.Errorf("別インスタンスの一覧の購読者にentry_updateが届くべき: got %+v", list.Notifications())
+	}
+}
+
+func TestSyncService_RemoteBusRequiresSharedEventStore(t *testing.T) {
+	// インスタンスごとのログでは、相手のopは自分のログに無くserver_seqも衝突するので配信できない
+	a, b := application.NewSyncService(memory.NewEventStore()), application.NewSyncService(memory.NewEventStore())
+	busA, busB := newPairBuses()
+	if err := a.SetBus(remotePairBus{busA}); !errors.Is(err, application.ErrBusNeedsSharedLog) {
+		t.Errorf("separate stores: got %v, want ErrBusNeedsSharedLog", err)
+	}
+	if err := b.SetBus(remotePairBus{busB}); !errors.Is(err, application.ErrBusNeedsSharedLog) {
+		t.Errorf("separate stores: got %v, want ErrBusNeedsSharedLog", err)
+	}
+
+	// 拒否されたBusは使われず、それぞれのインスタンス内の配信は変わらない
+	entryID := uuid.New()
+	sub := &mockSubscriber{}
+	b.Subscribe(entryID, sub)
+	a.Broadcast(entryID, appendOps(t, a, entryID, 1)[0])
+	if got := sub.Messages(); len(got) != 0 {
+		t.Errorf("ops from a separate log should not reach other instances: got %+v", got)
+	}
+	b.Broadcast(entryID, appendOps(t, b, entryID, 1)[0])
+	if got := seqsOf(sub.Messages()); !slices.Equal(got, []int64{1}) {
+		t.Errorf("local delivery: got %v", got)
+	}
+}
+
+func TestSyncService_LocalBusDeliversSynchronously(t *testing.T) {
+	svc := application.NewSyncService(memory.NewEventStore())
+	entryID := uuid.New()
+	sub := &mockSubscriber{}
+	svc.Subscribe(entryID, sub)
+
+	msgs := appendOps(t, svc, entryID, 2)
+	svc.Broadcast(entryID, msgs[0])
+	svc.Broadcast(entryID, msgs[0]) // 配信済み
+	svc.Broadcast(entryID, msgs[1])
+	if got := seqsOf(sub.Messages()); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("配信済みのsyncは捨てるべき: got %v", got)
+	}
+
+	// 購読者がいなくなったら配信位置を忘れ、再購読後は最初のsyncから配信する
+	svc.Unsubscribe(entryID, sub)
+	again := &mockSubscriber{}
+	svc.Subscribe(entryID, again)
+	svc.Broadcast(entryID, msgs[1])
+	if got := seqsOf(again.Messages()); !slices.Equal(got, []int64{2}) {
+		t.Errorf("再購読後の配信: got %v", got)
+	}
+}
+
+func TestSyncService_BatchWithInterleavedOpIsCaughtUp(t *testing.T) {
+	svc := application.NewSyncService(memory.NewEventStore())
+	entryID := uuid.New()
+	sub := &mockSubscriber{}
+	svc.Subscribe(entryID, sub)
+
+	msgs := appendOps(t, svc, entryID, 4)
+	svc.Broadcast(entryID, msgs[0])
+	// 2と4をまとめたsyncが、間に挟まった3の配信より先に届いても3を取りこぼさない
+	svc.Broadcast(entryID, application.SyncMessage{
+		Ops:             []application.SyncOp{msgs[1].Ops[0], msgs[3].Ops[0]},
+		LatestServerSeq: 4,
+	})
+	svc.Broadcast(entryID, msgs[2])
+	if got := seqsOf(sub.Messages()); !slices.Equal(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("got %v, want [1 2 3 4]", got)
+	}
+}
+
+func TestSyncService_NonOpEventsDoNotTriggerCatchUp(t *testing.T) {
+	ctx := context.Background()
+	svc := application.NewSyncService(memory.NewEventStore())
+	entryID := uuid.New()
+	sub := &mockSubscriber{}
+	svc.Subscribe(entryID, sub)
+
+	msgs := appendOps(t, svc, entryID, 1)
+	svc.Broadcast(entryID, msgs[0])
+	if _, err := svc.AppendEvent(ctx, entryID, uuid.New(), domain.EventTagOp, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	// opの配信より先にその後のロックのイベントが届いても、抜けとみなさない
+	late := appendOps(t, svc, entryID, 1)
+	if _, err := svc.AppendEvent(ctx, entryID, uuid.New(), domain.EventEntryLock, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	svc.Broadcast(entryID, late[0])
+	next := appendOps(t, svc, entryID, 1)
+	svc.Broadcast(entryID, next[0])
+
+	if got := seqsOf(sub.Messages()); !slices.Equal(got, []int64{1, 3, 5}) {
+		t.Errorf("got %v, want [1 3 5]", got)
+	}
+	if n := svc.GapCatchUps(); n != 0 {
+		t.Errorf("GapCatchUps: got %d, want 0", n)
+	}
+}
+
+func TestSyncService_CatchUpSkipsRejectedOps(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	projector := application.NewEntryProjector(entryStore, memory.NewRGAStateStore(), t.TempDir(), slog.Default())
+	eventStore := sharedEventStore{memory.NewEventStore()}
+	svc := application.NewSyncService(eventStore)
+	svc.SetProjector(projector)
+	busA, busB := newPairBuses()
+	if err := svc.SetBus(remotePairBus{busA}); err != nil {
+		t.Fatal(err)
+	}
+	peer := application.NewSyncService(eventStore)
+	peer.SetProjector(projector)
+	if err := peer.SetBus(remotePairBus{busB}); err != nil {
+		t.Fatal(err)
+	}
+	entryID := uuid.New()
+	now := time.Now().UTC()
+	if err := entryStore.Save(ctx, domain.Entry{ID: entryID, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	sub := &mockSubscriber{}
+	peer.Subscribe(entryID, sub)
+
+	// ローカルの経路と同じく、適用できたopだけ配信し、拒否されたopは配信位置だけ進める
+	submit := func(payload []byte) int64 {
+		t.Helper()
+		var msg struct {
+			RequestID uuid.UUID `json:"request_id"`
+		}
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatal(err)
+		}
+		ack, err := svc.HandleOp(ctx, entryID, uuid.Nil, msg.RequestID, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !projector.Apply(ctx, entryID, payload) {
+			svc.Skip(entryID, ack.ServerSeq)
+			return ack.ServerSeq
+		}
+		svc.Broadcast(entryID, application.SyncMessage{
+			Ops:             []application.SyncOp{{RequestID: msg.RequestID, ServerSeq: ack.ServerSeq, Payload: payload}},
+			LatestServerSeq: ack.ServerSeq,
+		})
+		return ack.ServerSeq
+	}
+	siteID := uuid.New()
+	insert := func(ts uint64) []byte { return makeInsertPayload(t, siteID, ts, "a", nil) }
+	// 非認証のdeleteは認証済みのノードを消せないので拒否される
+	unauthDelete, _ := json.Marshal(map[string]any{
+		"type":          "op",
+		"request_id":    uuid.New().String(),
+		"op_type":       2,
+		"node_id":       map[string]any{"site_id": siteID.String(), "timestamp": 1},
+		"authenticated": false,
+	})
+
+	submit(insert(1))
+	// 配信が落ちて差分で補っても、拒否されたdeleteは購読者に届かない
+	busA.drop = true
+	if seq := submit(unauthDelete); seq != 2 {
+		t.Fatalf("拒否されたdeleteのserver_seq: got %d, want 2", seq)
+	}
+	submit(insert(2))
+	busA.drop = false
+	submit(insert(3))
+
+	if got := seqsOf(sub.Messages()); !slices.Equal(got, []int64{1, 3, 4}) {
+		t.Errorf("got %v, want [1 3 4]", got)
+	}
+	if n := peer.GapCatchUps(); n != 1 {
+		t.Errorf("GapCatchUps: got %d, want 1", n)
+	}
+}
@@ -0,0 +1,284 @@
+package application
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// excerptRunes は一覧表示用の抜粋の最大文字数。
+const excerptRunes = 200
+
+// 1分あたりの読了速度（英単語 / CJK文字）。
+const (
+	wordsPerMinute    = 200
+	cjkRunesPerMinute = 500
+)
+
+// frontMatter はMarkdown先頭の "---" で囲まれたYAMLフロントマター。
+type frontMatter struct {
+	Title       string
+	Description string
+	Thumbnail   string
+	Tags        []string
+}
+
+// derivedFields はテキストから導出されるEntryのフィールド群。
+type derivedFields struct {
+	Title          string
+	Content        string
+	Description    string
+	Thumbnail      *string
+	Tags           []string
+	WordCount      int
+	ReadingMinutes int
+}
+
+// deriveFields はテキストからTitle/Content等を導出する。
+// フロントマターがあれば優先し、抜粋はMarkdown記法を除去した本文から作る。
+func deriveFields(text string) derivedFields {
+	fm, body := parseFrontMatter(text)
+	plain := stripMarkdown(body)
+
+	var d derivedFields
+	d.Title = fm.Title
+	if d.Title == "" {
+		d.Title = firstLineTitle(body)
+	}
+	d.Description = fm.Description
+	if fm.Thumbnail != "" {
+		thumb := fm.Thumbnail
+		d.Thumbnail = &thumb
+	}
+	d.Tags = fm.Tags
+
+	excerpt := strings.Join(strings.Fields(plain), " ")
+	if utf8.RuneCountInString(excerpt) > excerptRunes {
+		excerpt = string([]rune(excerpt)[:excerptRunes])
+	}
+	d.Content = excerpt
+
+	d.WordCount, d.ReadingMinutes = countWords(plain)
+	return d
+}
+
+// parseFrontMatter は先頭のフロントマターを解析し、残りの本文と共に返す。
+// 閉じ区切りが無い場合や、区切りの間が "key: value" の行でない場合は、
+// 先頭の "---" を水平線とみなしてフロントマター無しとして扱う。
+func parseFrontMatter(text string) (frontMatter, string) {
+	var fm frontMatter
+	if !strings.HasPrefix(text, "---\n") && !strings.HasPrefix(text, "---\r\n") {
+		return fm, text
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r\n") == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return fm, text
+	}
+
+	var listKey string
+	keys := 0
+	for _, raw := range lines[1:end] {
+		line := strings.TrimRight(raw, "\r\n")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// ブロック形式のリスト（"  - item"）
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && keys > 0 {
+			if listKey == "tags" {
+				fm.Tags = appendTag(fm.Tags, unquoteYAML(item))
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !frontMatterKeyPattern.MatchString(strings.TrimSpace(key)) {
+			return frontMatter{}, text
+		}
+		keys++
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		listKey = ""
+
+		switch key {
+		case "title":
+			fm.Title = unquoteYAML(value)
+		case "description":
+			fm.Description = unquoteYAML(value)
+		case "thumbnail":
+			fm.Thumbnail = unquoteYAML(value)
+		case "tags":
+			if value == "" {
+				listKey = key
+				continue
+			}
+			for _, tag := range parseInlineList(value) {
+				fm.Tags = appendTag(fm.Tags, tag)
+			}
+		}
+	}
+
+	if keys == 0 {
+		return frontMatter{}, text
+	}
+	return fm, strings.Join(lines[end+1:], "")
+}
+
+// parseInlineList は "[a, b]" 形式または "a, b" 形式のリストを分解する。
+func parseInlineList(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		if v := unquoteYAML(item); v != "" {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+func appendTag(tags []string, tag string) []string {
+	if tag == "" {
+		return tags
+	}
+	return append(tags, tag)
+}
+
+// unquoteYAML はスカラー値の前後の空白と引用符を取り除く。
+func unquoteYAML(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && (v[0] == '"' && v[len(v)-1] == '"' || v[0] == '\'' && v[len(v)-1] == '\'') {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// firstLineTitle は本文の最初の空でも水平線でもない行から見出し記号を除いてタイトルにする。
+func firstLineTitle(body string) string {
+	for line := range strings.SplitSeq(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || rulePattern.MatchString(line) {
+			continue
+		}
+		return strings.TrimSpace(stripInline(headingPattern.ReplaceAllString(line, "")))
+	}
+	return ""
+}
+
+var (
+	frontMatterKeyPattern = regexp.MustCompile(`^[A-Za-z_][\w-]*$`)
+	fencePattern          = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	headingPattern        = regexp.MustCompile(`^\s{0,3}#{1,6}\s*`)
+	blockquotePattern     = regexp.MustCompile(`^\s*>\s?`)
+	listPattern           = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+`)
+	rulePattern           = regexp.MustCompile(`^\s*([-*_]\s*){3,}$`)
+	imagePattern          = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkPattern           = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	refLinkPattern        = regexp.MustCompile(`\[([^\]]*)\]\[[^\]]*\]`)
+	htmlTagPattern        = regexp.MustCompile(`<[^>]+>`)
+	codeSpanPattern       = regexp.MustCompile("`([^`]+)`")
+	strikePattern         = regexp.MustCompile(`~~([^~]+)~~`)
+	// *は語中でも強調になるが、_は前後が語の外にあるときだけ強調になる（CommonMarkと同じ）
+	strongStarPattern = regexp.MustCompile(`\*\*([^*\s](?:[^*]*[^*\s])?)\*\*`)
+	emStarPattern     = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	strongUndPattern  = regexp.MustCompile(`(^|[^\p{L}\p{N}_])__([^_\s](?:[^_]*[^_\s])?)__([^\p{L}\p{N}_]|$)`)
+	emUndPattern      = regexp.MustCompile(`(^|[^\p{L}\p{N}_])_([^_\s](?:[^_]*[^_\s])?)_([^\p{L}\p{N}_]|$)`)
+)
+
+// stripMarkdown はMarkdown記法を除去したプレーンテキストを返す。
+// コードブロックは中身ごと除く（閉じていなければ末尾まで）。
+func stripMarkdown(body string) string {
+	lines := strings.Split(body, "\n")
+	out := make([]string, 0, len(lines))
+	var fence string
+	for _, line := range lines {
+		if fence != "" {
+			// 開きと同じ記号で、同じ長さ以上の行で閉じる
+			if m := fencePattern.FindStringSubmatch(line); m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) &&
+				strings.TrimSpace(line[len(m[0]):]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+		if rulePattern.MatchString(line) {
+			continue
+		}
+		line = headingPattern.ReplaceAllString(line, "")
+		line = blockquotePattern.ReplaceAllString(line, "")
+		line = listPattern.ReplaceAllString(line, "")
+		out = append(out, stripInline(line))
+	}
+	return strings.Join(out, "\n")
+}
+
+// stripInline は行内のリンク・画像・強調・HTMLタグを除去する。
+func stripInline(line string) string {
+	line = imagePattern.ReplaceAllString(line, "$1")
+	line = linkPattern.ReplaceAllString(line, "$1")
+	line = refLinkPattern.ReplaceAllString(line, "$1")
+	line = htmlTagPattern.ReplaceAllString(line, "")
+	return stripEmphasis(line)
+}
+
+// stripEmphasis は対になった強調・打ち消し・コードの記号を除去する。snake_caseなど語中の記号は残す。
+func stripEmphasis(line string) string {
+	line = codeSpanPattern.ReplaceAllString(line, "$1")
+	line = strikePattern.ReplaceAllString(line, "$1")
+	// 入れ子（***a***など）と、前後の区切り文字を共有する隣接した強調は1回では取り切れない
+	for {
+		next := strongStarPattern.ReplaceAllString(line, "$1")
+		next = emStarPattern.ReplaceAllString(next, "$1")
+		next = strongUndPattern.ReplaceAllString(next, "${1}${2}${3}")
+		next = emUndPattern.ReplaceAllString(next, "${1}${2}${3}")
+		if next == line {
+			return line
+		}
+		line = next
+	}
+}
+
+// countWords は語数と読了時間（分）を返す。
+// CJK文字は1文字を1語として数え、読了速度も別に扱う。
+func countWords(plain string) (words, minutes int) {
+	var latin, cjk int
+	inWord := false
+	for _, r := range plain {
+		switch {
+		case isCJK(r):
+			cjk++
+			inWord = false
+		case unicode.IsSpace(r) || unicode.IsPunct(r):
+			inWord = false
+		default:
+			if !inWord {
+				latin++
+				inWord = true
+			}
+		}
+	}
+
+	words = latin + cjk
+	if words == 0 {
+		return 0, 0
+	}
+	m := float64(latin)/wordsPerMinute + float64(cjk)/cjkRunesPerMinute
+	return words, max(1, int(math.Ceil(m)))
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -2,13 +2,16 @@ package application
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
-	"strings"
+	"slices"
 	"sync"
-	"unicode/utf8"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -21,22 +24,34 @@ type RGAStateStore interface {
 	SaveRGA(ctx context.Context, entryID uuid.UUID, snap crdt.RGASnapshot) error
 	LoadRGA(ctx context.Context, entryID uuid.UUID) (crdt.RGASnapshot, error)
 	ListRGAEntryIDs(ctx context.Context) ([]uuid.UUID, error)
+	DeleteRGA(ctx context.Context, entryID uuid.UUID) error
 }
 
 // EntryProjector はイベントからRGAを適用し、Entryのビューを更新する。
+// エントリの状態はシャードに分けて持ち、別シャードのエントリは並行に処理する。
+// 同じエントリへの処理はシャードのロックで直列化される。
 type EntryProjector struct {
-	rgas          map[uuid.UUID]*crdt.RGA
-	mu            sync.Mutex
+	shards        []*projectorShard
+	moderation    bool
+	mu            sync.Mutex // 設定とflush遅延の統計を保護する
 	entryStore    domain.EntryStore
 	rgaStateStore RGAStateStore
 	markdownDir   string
+	audit         *AuditLog
 	log           *slog.Logger
+
+	// 書き込み遅延（write-behind）。flushDelayが0なら適用のたびに同期的に永続化する
+	flushDelay  time.Duration
+	flushMaxOps int
+	lastLag     time.Duration
+	maxLag      time.Duration
+	flushing    atomic.Int64 // 永続化中のエントリ数
 }
 
 func NewEntryProjector(entryStore domain.EntryStore, rgaStateStore RGAStateStore, markdownDir string, log *slog.Logger) *EntryProjector {
 	os.MkdirAll(markdownDir, 0o755)
 	return &EntryProjector{
-		rgas:          make(map[uuid.UUID]*crdt.RGA),
+		shards:        newProjectorShards(DefaultProjectorShards),
 		entryStore:    entryStore,
 		rgaStateStore: rgaStateStore,
 		markdownDir:   markdownDir,
@@ -44,10 +59,47 @@ func NewEntryProjector(entryStore domain.EntryStore, rgaStateStore RGAStateStore
 	}
 }
 
-// Apply はopをRGAに適用し、Entryを更新する。適用が拒否された場合はfalseを返す。
-func (p *EntryProjector) Apply(ctx context.Context, entryID uuid.UUID, payload []byte) bool {
+// SetModeration はモデレーションモードを切り替える。
+// 有効な場合、承認されていない非認証insertは公開用のEntry.Textとmarkdownから除外される。
+func (p *EntryProjector) SetModeration(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.moderation = enabled
+}
+
+// SetAuditLog は非認証deleteの拒否を記録する監査ログを設定する。
+func (p *EntryProjector) SetAuditLog(audit *AuditLog) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.audit = audit
+}
+
+// Apply はopをRGAに適用し、Entryを更新する。適用が拒否された場合はfalseを返す。
+// 書き込み遅延が無効なら永続化まで済ませて返すが、永続化はシャードのロックを放してから行うので
+// 同じシャードの他のエントリの適用を止めない。永続化に失敗しても適用済みなのでtrueを返す（永続化は後で再試行する）。
+func (p *EntryProjector) Apply(ctx context.Context, entryID uuid.UUID, payload []byte) bool {
+	return p.ApplyAll(ctx, entryID, [][]byte{payload})[0]
+}
+
+// ApplyAll は同じエントリへの複数のopを順に適用し、opごとに適用されたかどうかを返す。
+// 書き込み遅延が無効でも永続化は最後に1回だけ行う。
+func (p *EntryProjector) ApplyAll(ctx context.Context, entryID uuid.UUID, payloads [][]byte) []bool {
+	applied := make([]bool, len(payloads))
+	dirty := false
+	for i, payload := range payloads {
+		applied[i] = p.applyInMemory(ctx, entryID, payload)
+		dirty = dirty || applied[i]
+	}
+	if delay, _ := p.writeBehind(); dirty && delay == 0 {
+		p.flushEntry(ctx, entryID)
+	}
+	return applied
+}
+
+// applyInMemory はopをRGAに適用し、エントリを永続化待ちにする。
+func (p *EntryProjector) applyInMemory(ctx context.Context, entryID uuid.UUID, payload []byte) bool {
+	s := p.lock(entryID)
+	defer s.unlock()
 
 	op, err := crdt.OperationFromPayload(payload)
 	if err != nil {
@@ -55,63 +107,132 @@ func (p *EntryProjector) Apply(ctx context.Context, entryID uuid.UUID, payload [
 		return false
 	}
 
-	rga, ok := p.rgas[entryID]
+	rga, ok := s.rgas[entryID]
 	if !ok {
 		// サーバー側RGAはゼロUUIDでよい（Tickは使わない）
 		rga = crdt.NewRGA(uuid.Nil)
-		p.rgas[entryID] = rga
+		s.rgas[entryID] = rga
 	}
 
-	// 非認証deleteによる認証ノード削除はスキップ（opはイベントストアに記録済み）
-	if op.OpType == crdt.OpDelete && !op.Authenticated && rga.IsNodeAuthenticated(op.NodeID) {
-		p.log.Warn("projector: 非認証deleteを無視", "entryID", entryID, "nodeID", op.NodeID)
+	if err := p.applyOp(entryID, rga, op); err != nil {
+		if errors.Is(err, errUnauthenticatedDelete) {
+			// 非認証deleteによる認証ノード削除はスキップ（opはイベントストアに記録済み）
+			p.log.Warn("projector: 非認証deleteを無視", "entryID", entryID, "nodeID", op.NodeID)
+			p.audit.Record(ctx, AuditOpRejected, entryID.String(), domain.AuditDenied,
+				fmt.Sprintf("node=%s:%d", op.NodeID.ReplicaID, op.NodeID.Timestamp))
+		} else {
+			p.log.Warn("projector: クローズ済み提案へのopを無視", "entryID", entryID, "suggestion", op.Suggestion)
+		}
 		return false
 	}
 
-	rga.Apply(op)
-
-	text := rga.Text()
-	title, content := deriveFields(text)
-
-	entry, err := p.entryStore.FindByID(ctx, entryID)
-	if err != nil {
+	if _, err := p.entryStore.FindByID(ctx, entryID); err != nil {
 		p.log.Error("projector: entry取得失敗", "entryID", entryID, "error", err)
 		return false
 	}
-	entry.Title = title
-	entry.Content = content
-	entry.Text = text
+	p.markDirty(entryID)
+	return true
+}
 
-	if err := p.entryStore.Save(ctx, entry); err != nil {
-		p.log.Error("projector: entry保存失敗", "entryID", entryID, "error", err)
+// ApplyTagOp はタグのOR-Setオペレーションを適用し、EntryのTagsを更新する。
+// タグの更新はAPIの応答に反映するので、書き込み遅延によらずシャードのロックの外で永続化まで済ませる。
+func (p *EntryProjector) ApplyTagOp(ctx context.Context, entryID uuid.UUID, payload []byte) bool {
+	op, err := crdt.SetOperationFromPayload(payload)
+	if err != nil {
+		p.log.Error("projector: tag payload変換失敗", "entryID", entryID, "error", err)
 		return false
 	}
 
-	if err := p.rgaStateStore.SaveRGA(ctx, entryID, rga.Export()); err != nil {
-		p.log.Error("projector: RGA状態保存失敗", "entryID", entryID, "error", err)
-	}
+	s := p.lock(entryID)
+	p.tagSet(entryID).Apply(op)
+	p.markDirty(entryID)
+	s.unlock()
 
-	p.saveMarkdown(entryID, text)
+	p.flushEntry(ctx, entryID)
 	return true
 }
 
+// PrepareTagOps は現在のタグ集合に対してadd/removeを行うオペレーションを作成する（未適用）。
+// 既に存在するタグのaddと存在しないタグのremoveは省略する。
+func (p *EntryProjector) PrepareTagOps(entryID uuid.UUID, add, remove []string) []crdt.SetOperation {
+	defer p.lock(entryID).unlock()
+
+	set := p.tagSet(entryID)
+	var ops []crdt.SetOperation
+	for _, tag := range remove {
+		if op, ok := set.Remove(domain.NormalizeTag(tag)); ok {
+			ops = append(ops, op)
+		}
+	}
+	for _, tag := range add {
+		tag = domain.NormalizeTag(tag)
+		if tag == "" || set.Contains(tag) {
+			continue
+		}
+		ops = append(ops, set.Add(tag))
+	}
+	return ops
+}
+
+// reproject は公開範囲の変化（承認・提案の採否）をEntryとmarkdownに反映する。
+// opを伴わないので書き込み遅延によらず、シャードのロックの外で永続化まで済ませる。
+func (p *EntryProjector) reproject(ctx context.Context, entryID uuid.UUID) {
+	s := p.lock(entryID)
+	if _, ok := s.rgas[entryID]; !ok {
+		s.unlock()
+		return
+	}
+	p.markDirty(entryID)
+	s.unlock()
+
+	p.flushEntry(ctx, entryID)
+}
+
+// tagSet はエントリのOR-Setを返す（無ければ作成）。ロック保持前提。
+func (p *EntryProjector) tagSet(entryID uuid.UUID) *crdt.ORSet {
+	s := p.shard(entryID)
+	set, ok := s.tagSets[entryID]
+	if !ok {
+		set = crdt.NewORSet()
+		s.tagSets[entryID] = set
+	}
+	return set
+}
+
+// tagElements はAPI経由で付与されたタグを返す。ロック保持前提。
+func (p *EntryProjector) tagElements(entryID uuid.UUID) []string {
+	set, ok := p.shard(entryID).tagSets[entryID]
+	if !ok {
+		return nil
+	}
+	return set.Elements()
+}
+
 // IsNodeAuthenticated は指定エントリのノードが認証済みかどうかを返す。
 func (p *EntryProjector) IsNodeAuthenticated(entryID uuid.UUID, nodeID crdt.NodeID) bool {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	rga, ok := p.rgas[entryID]
+	s := p.lock(entryID)
+	defer s.unlock()
+	rga, ok := s.rgas[entryID]
 	if !ok {
 		return true // RGA未ロード = 安全側
 	}
 	return rga.IsNodeAuthenticated(nodeID)
 }
 
+// PublicText は公開用のテキストを返す。RGAが無ければfalse。
+func (p *EntryProjector) PublicText(entryID uuid.UUID) (string, bool) {
+	s := p.lock(entryID)
+	defer s.unlock()
+	rga, ok := s.rgas[entryID]
+	if !ok {
+		return "", false
+	}
+	return p.publicText(entryID, rga), true
+}
+
 // Restore はEventStoreの全opからRGAを再構築し、Entryを更新する。
 // entryIDsにはEventStoreに存在する全エントリIDを渡す。
 func (p *EntryProjector) Restore(ctx context.Context, eventStore domain.EventStore, entryIDs []uuid.UUID) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	// RGAスナップショットから復元
 	snapIDs, err := p.rgaStateStore.ListRGAEntryIDs(ctx)
 	if err != nil {
@@ -129,53 +250,274 @@ func (p *EntryProjector) Restore(ctx context.Context, eventStore domain.EventSto
 			p.log.Warn("projector: RGAインポート失敗", "entryID", entryID, "error", err)
 			continue
 		}
-		p.rgas[entryID] = rga
+		s := p.lock(entryID)
+		s.rgas[entryID] = rga
+		s.unlock()
 	}
 
 	// EventStoreの全エントリのopを再生して差分適用
 	for _, entryID := range entryIDs {
-		events, err := eventStore.ListAfter(ctx, entryID, 0)
-		if err != nil {
+		if err := p.restoreFromEvents(ctx, eventStore, entryID); err != nil {
 			return err
 		}
-		rga, ok := p.rgas[entryID]
-		if !ok {
-			rga = crdt.NewRGA(uuid.Nil)
-			p.rgas[entryID] = rga
-		}
-		for _, ev := range events {
-			if ev.EventType != domain.EventCRDTOp {
-				continue
-			}
+	}
+
+	return nil
+}
+
+// Replay はエントリのイベントログを再生して投影し直す（再生は冪等）。
+// フォロワーが初めて複製したエントリや、作成・削除・復元を複製したときに使う。
+func (p *EntryProjector) Replay(ctx context.Context, eventStore domain.EventStore, entryID uuid.UUID) error {
+	return p.restoreFromEvents(ctx, eventStore, entryID)
+}
+
+// ApplyReplicated はフォロワーが複製して追記したイベントだけを投影に反映する。
+// ポーリングのたびにログ全体を再生しないよう、投影済みのエントリには新しいイベントだけを適用する。
+// まだ投影していないエントリと、作成・削除・復元を含む場合はReplayでログ全体から投影し直す。
+func (p *EntryProjector) ApplyReplicated(ctx context.Context, eventStore domain.EventStore, entryID uuid.UUID, events []domain.Event) error {
+	lifecycle := slices.ContainsFunc(events, func(ev domain.Event) bool {
+		return ev.EventType == domain.EventEntryCreate || ev.EventType == domain.EventEntryDelete
+	})
+	s := p.lock(entryID)
+	rga, ok := s.rgas[entryID]
+	if !ok || lifecycle {
+		s.unlock()
+		return p.Replay(ctx, eventStore, entryID)
+	}
+
+	var locked *bool
+	for _, ev := range events {
+		switch ev.EventType {
+		case domain.EventCRDTOp:
 			op, err := crdt.OperationFromPayload(ev.Payload)
 			if err != nil {
 				p.log.Warn("projector: op変換失敗", "entryID", entryID, "error", err)
 				continue
 			}
-			rga.Apply(op) // 冪等なので重複適用しても問題ない
+			// 拒否されたopは再生と同じく読み飛ばす
+			p.applyOp(entryID, rga, op)
+		case domain.EventTagOp:
+			op, err := crdt.SetOperationFromPayload(ev.Payload)
+			if err != nil {
+				p.log.Warn("projector: tag op変換失敗", "entryID", entryID, "error", err)
+				continue
+			}
+			p.tagSet(entryID).Apply(op)
+		case domain.EventModeration:
+			if err := p.applyDecision(entryID, ev.Payload); err != nil {
+				p.log.Warn("projector: moderation変換失敗", "entryID", entryID, "error", err)
+			}
+		case domain.EventSuggestion:
+			if err := p.applySuggestionDecision(entryID, ev.Payload); err != nil {
+				p.log.Warn("projector: suggestion変換失敗", "entryID", entryID, "error", err)
+			}
+		case domain.EventEntryLock:
+			var change lockChange
+			if err := json.Unmarshal(ev.Payload, &change); err != nil {
+				p.log.Warn("projector: lock変換失敗", "entryID", entryID, "error", err)
+				continue
+			}
+			locked = &change.Locked
 		}
+	}
+	p.markDirty(entryID)
+	s.unlock()
 
-		text := rga.Text()
-		title, content := deriveFields(text)
+	if locked != nil {
+		if err := p.entryStore.Update(ctx, entryID, func(e *domain.Entry) { e.Locked = *locked }); err != nil {
+			return err
+		}
+	}
+	p.flushEntry(ctx, entryID)
+	return nil
+}
 
-		entry, err := p.entryStore.FindByID(ctx, entryID)
+// restoreFromEvents はエントリのopを再生して投影する。
+// 再生はシャードのロック下で行い、永続化はflushと同じくエントリごとのロックだけで行う。
+func (p *EntryProjector) restoreFromEvents(ctx context.Context, eventStore domain.EventStore, entryID uuid.UUID) error {
+	events, err := eventStore.ListAfter(ctx, entryID, 0)
+	if err != nil {
+		return err
+	}
+
+	mu := p.flushLock(entryID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	s := p.lock(entryID)
+	rga, ok := s.rgas[entryID]
+	if !ok {
+		rga = crdt.NewRGA(uuid.Nil)
+		s.rgas[entryID] = rga
+	}
+	var (
+		locked    *bool
+		created   *entryCreation
+		deletion  *deletionChange
+		deletedAt time.Time
+	)
+	for _, ev := range events {
+		if ev.EventType == domain.EventEntryCreate {
+			var c entryCreation
+			if err := json.Unmarshal(ev.Payload, &c); err != nil {
+				p.log.Warn("projector: entry_create変換失敗", "entryID", entryID, "error", err)
+				continue
+			}
+			created = &c
+			continue
+		}
+		if ev.EventType == domain.EventEntryDelete {
+			var change deletionChange
+			if err := json.Unmarshal(ev.Payload, &change); err != nil {
+				p.log.Warn("projector: entry_delete変換失敗", "entryID", entryID, "error", err)
+				continue
+			}
+			deletion = &change
+			deletedAt = ev.CreatedAt
+			continue
+		}
+		if ev.EventType == domain.EventEntryLock {
+			var change lockChange
+			if err := json.Unmarshal(ev.Payload, &change); err != nil {
+				p.log.Warn("projector: lock変換失敗", "entryID", entryID, "error", err)
+				continue
+			}
+			locked = &change.Locked
+			continue
+		}
+		if ev.EventType == domain.EventTagOp {
+			tagOp, err := crdt.SetOperationFromPayload(ev.Payload)
+			if err != nil {
+				p.log.Warn("projector: tag op変換失敗", "entryID", entryID, "error", err)
+				continue
+			}
+			p.tagSet(entryID).Apply(tagOp)
+			continue
+		}
+		if ev.EventType == domain.EventModeration {
+			if err := p.applyDecision(entryID, ev.Payload); err != nil {
+				p.log.Warn("projector: moderation変換失敗", "entryID", entryID, "error", err)
+			}
+			continue
+		}
+		if ev.EventType == domain.EventSuggestion {
+			if err := p.applySuggestionDecision(entryID, ev.Payload); err != nil {
+				p.log.Warn("projector: suggestion変換失敗", "entryID", entryID, "error", err)
+			}
+			continue
+		}
+		if ev.EventType != domain.EventCRDTOp {
+			continue
+		}
+		op, err := crdt.OperationFromPayload(ev.Payload)
 		if err != nil {
-			p.log.Warn("projector: entry取得失敗、スキップ", "entryID", entryID, "error", err)
+			p.log.Warn("projector: op変換失敗", "entryID", entryID, "error", err)
 			continue
 		}
-		entry.Title = title
-		entry.Content = content
-		entry.Text = text
-		if err := p.entryStore.Save(ctx, entry); err != nil {
-			return err
+		// 冪等なので重複適用しても問題ない。拒否されたopはライブの適用と同じく読み飛ばす（監査ログには記録済み）
+		p.applyOp(entryID, rga, op)
+	}
+
+	text := p.publicText(entryID, rga)
+	tags := p.tagElements(entryID)
+	snap := rga.Export()
+	// 全体を永続化するので、永続化待ちはここで引き取る
+	pf, pending := s.pending[entryID]
+	if pending {
+		if pf.timer != nil {
+			pf.timer.Stop()
+		}
+		delete(s.pending, entryID)
+	}
+	s.unlock()
+
+	entry, ok := p.restoreEntry(ctx, entryID, events, created, deletion)
+	if !ok {
+		return nil
+	}
+	applyDerivedFields(&entry, text, tags)
+	if locked != nil {
+		entry.Locked = *locked
+	}
+	if deletion != nil {
+		entry.Deleted = deletion.Deleted
+		entry.DeletedAt = time.Time{}
+		if deletion.Deleted {
+			entry.DeletedAt = deletedAt
+		}
+	}
+	if err := p.entryStore.Save(ctx, entry); err != nil {
+		if pending {
+			p.retryFlush(entryID, pf, err)
+		}
+		return err
+	}
+	if err := p.rgaStateStore.SaveRGA(ctx, entryID, snap); err != nil {
+		p.log.Warn("projector: RGA状態保存失敗", "entryID", entryID, "error", err)
+	}
+
+	p.saveMarkdown(entryID, text)
+	return nil
+}
+
+// restoreEntry はRestoreで投影先となるEntryを返す。エントリごとのflushのロック保持前提。
+// EntryStoreに無ければイベントログから作り直し（作成イベントの無い古いエントリは最初のイベントの時刻を使う）、
+// ゴミ箱にあるエントリはイベントログ上で復元済みの場合だけ対象にする。
+func (p *EntryProjector) restoreEntry(ctx context.Context, entryID uuid.UUID, events []domain.Event, created *entryCreation, deletion *deletionChange) (domain.Entry, bool) {
+	entry, err := p.entryStore.FindByID(ctx, entryID)
+	switch {
+	case err == nil:
+		return entry, true
+	case errors.Is(err, domain.ErrEntryNotFound) && len(events) > 0:
+		createdAt := events[0].CreatedAt
+		if created != nil {
+			createdAt = created.CreatedAt
 		}
-		if err := p.rgaStateStore.SaveRGA(ctx, entryID, rga.Export()); err != nil {
-			p.log.Warn("projector: RGA状態保存失敗", "entryID", entryID, "error", err)
+		return domain.Entry{ID: entryID, CreatedAt: createdAt, UpdatedAt: events[len(events)-1].CreatedAt}, true
+	case errors.Is(err, domain.ErrEntryDeleted) && deletion != nil && !deletion.Deleted:
+		// 復元イベントの記録後、EntryStoreへの反映前に止まっていた
+		if err := p.entryStore.Restore(ctx, entryID); err != nil {
+			p.log.Warn("projector: entry復元失敗、スキップ", "entryID", entryID, "error", err)
+			return domain.Entry{}, false
 		}
+		return p.restoreEntry(ctx, entryID, events, created, nil)
+	case errors.Is(err, domain.ErrEntryDeleted):
+		return domain.Entry{}, false
+	default:
+		p.log.Warn("projector: entry取得失敗、スキップ", "entryID", entryID, "error", err)
+		return domain.Entry{}, false
+	}
+}
 
-		p.saveMarkdown(entryID, text)
+// Forget はエントリの投影状態（メモリ上のRGA・タグ・提案、RGAスナップショット、markdown）を破棄する。
+// エントリの完全削除で使う。書き込み中のflushを待ってから消すので、破棄した投影が書き戻されることはない。
+func (p *EntryProjector) Forget(ctx context.Context, entryID uuid.UUID) error {
+	mu := p.flushLock(entryID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	s := p.lock(entryID)
+	if pf, ok := s.pending[entryID]; ok {
+		if pf.timer != nil {
+			pf.timer.Stop()
+		}
+		delete(s.pending, entryID)
 	}
+	delete(s.rgas, entryID)
+	delete(s.tagSets, entryID)
+	delete(s.approved, entryID)
+	delete(s.suggestions, entryID)
+	delete(s.rejected, entryID)
+	delete(s.flushLocks, entryID)
+	s.unlock()
 
+	if err := p.rgaStateStore.DeleteRGA(ctx, entryID); err != nil {
+		return fmt.Errorf("delete rga state: %w", err)
+	}
+	path := filepath.Join(p.markdownDir, entryID.String()+".md")
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove markdown: %w", err)
+	}
 	return nil
 }
 
@@ -186,15 +528,33 @@ func (p *EntryProjector) saveMarkdown(entryID uuid.UUID, text string) {
 	}
 }
 
-// deriveFields はテキストからTitle/Contentを導出する。
-func deriveFields(text string) (title, content string) {
-	lines := strings.SplitN(text, "\n", 2)
-	title = lines[0]
-	if utf8.RuneCountInString(text) > 200 {
-		runes := []rune(text)
-		content = string(runes[:200])
-	} else {
-		content = text
+// applyDerivedFields はテキストと導出フィールドをEntryに反映する。
+// Tagsはフロントマターのタグと、API経由で付与されたタグ（apiTags）の和集合になる。
+func applyDerivedFields(entry *domain.Entry, text string, apiTags []string) {
+	d := deriveFields(text)
+	entry.Tags = mergeTags(d.Tags, apiTags)
+	entry.Title = d.Title
+	entry.Content = d.Content
+	entry.Text = text
+	entry.Description = d.Description
+	// フロントマターに無ければ、取り込み時などに設定済みのサムネイルを残す
+	if d.Thumbnail != nil {
+		entry.Thumbnail = d.Thumbnail
+	}
+	entry.WordCount = d.WordCount
+	entry.ReadingMinutes = d.ReadingMinutes
+}
+
+// mergeTags はタグ列を正規化して重複を除き、出現順に連結する。
+func mergeTags(lists ...[]string) []string {
+	var tags []string
+	for _, list := range lists {
+		for _, tag := range list {
+			tag = domain.NormalizeTag(tag)
+			if tag != "" && !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
 	}
-	return
+	return tags
 }
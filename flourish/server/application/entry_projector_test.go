@@ -3,11 +3,13 @@ package application_test
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 
+	"flourish/server/adapter/memory"
 	"flourish/server/application"
 	"flourish/server/domain"
 	"flourish/server/domain/crdt"
@@ -35,6 +37,16 @@ func (s *mockEntryStore) FindByID(_ context.Context, id uuid.UUID) (domain.Entry
 	return e, nil
 }
 
+func (s *mockEntryStore) Update(_ context.Context, id uuid.UUID, fn func(*domain.Entry)) error {
+	e, ok := s.entries[id]
+	if !ok {
+		return domain.ErrEntryNotFound
+	}
+	fn(&e)
+	s.entries[id] = e
+	return nil
+}
+
 func (s *mockEntryStore) List(_ context.Context) ([]domain.EntryListItem, error) {
 	return nil, nil
 }
@@ -43,6 +55,19 @@ func (s *mockEntryStore) Delete(_ context.Context, id uuid.UUID) error {
 	return nil
 }
 
+func (s *mockEntryStore) ListDeleted(_ context.Context) ([]domain.Entry, error) {
+	return nil, nil
+}
+
+func (s *mockEntryStore) Restore(_ context.Context, id uuid.UUID) error {
+	return nil
+}
+
+func (s *mockEntryStore) Purge(_ context.Context, id uuid.UUID) error {
+	delete(s.entries, id)
+	return nil
+}
+
 // mockRGAStateStore はテスト用のRGAStateStore。
 type mockRGAStateStore struct {
 	states map[uuid.UUID]crdt.RGASnapshot
@@ -65,6 +90,11 @@ func (s *mockRGAStateStore) LoadRGA(_ context.Context, entryID uuid.UUID) (crdt.
 	return snap, nil
 }
 
+func (s *mockRGAStateStore) DeleteRGA(_ context.Context, entryID uuid.UUID) error {
+	delete(s.states, entryID)
+	return nil
+}
+
 func (s *mockRGAStateStore) ListRGAEntryIDs(_ context.Context) ([]uuid.UUID, error) {
 	ids := make([]uuid.UUID, 0, len(s.states))
 	for id := range s.states {
@@ -195,3 +225,177 @@ func TestEntryProjector_TitleFromFirstLine(t *testing.T) {
 		t.Errorf("Text: got %q, want %q", entry.Text, "AB\nCD")
 	}
 }
+
+// applyText はtextを1文字ずつ末尾に挿入するopをprojectorに適用する。
+func applyText(t *testing.T, projector *application.EntryProjector, entryID, siteID uuid.UUID, text string) {
+	t.Helper()
+	var after *struct {
+		SiteID    uuid.UUID
+		Timestamp uint64
+	}
+	var ts uint64
+	for _, r := range text {
+		ts++
+		projector.Apply(context.Background(), entryID, makeInsertPayload(t, siteID, ts, string(r), after))
+		after = &struct {
+			SiteID    uuid.UUID
+			Timestamp uint64
+		}{siteID, ts}
+	}
+}
+
+func TestEntryProjector_FrontMatter(t *testing.T) {
+	entryStore := newMockEntryStore()
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), slog.Default())
+
+	entryID := uuid.New()
+	entryStore.entries[entryID] = domain.Entry{ID: entryID}
+
+	text := "---\ntitle: \"Front Title\"\ndescription: 概要です\nthumbnail: /img/a.png\ntags: [go, crdt]\n---\n# Heading\n\nSee [the docs](https://example.com) for **more**.\n"
+	applyText(t, projector, entryID, uuid.New(), text)
+
+	entry := entryStore.entries[entryID]
+	if entry.Title != "Front Title" {
+		t.Errorf("Title: got %q, want %q", entry.Title, "Front Title")
+	}
+	if entry.Description != "概要です" {
+		t.Errorf("Description: got %q", entry.Description)
+	}
+	if entry.Thumbnail == nil || *entry.Thumbnail != "/img/a.png" {
+		t.Errorf("Thumbnail: got %v", entry.Thumbnail)
+	}
+	if entry.Content != "Heading See the docs for more." {
+		t.Errorf("Content: got %q", entry.Content)
+	}
+	if entry.Text != text {
+		t.Errorf("Text should keep raw markdown: got %q", entry.Text)
+	}
+	if entry.WordCount != 6 {
+		t.Errorf("WordCount: got %d, want 6", entry.WordCount)
+	}
+	if entry.ReadingMinutes != 1 {
+		t.Errorf("ReadingMinutes: got %d, want 1", entry.ReadingMinutes)
+	}
+}
+
+func TestEntryProjector_ContentKeepsIntrawordMarkers(t *testing.T) {
+	entryStore := newMockEntryStore()
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), slog.Default())
+
+	entryID := uuid.New()
+	thumb := "/img/imported.png"
+	entryStore.entries[entryID] = domain.Entry{ID: entryID, Thumbnail: &thumb}
+
+	applyText(t, projector, entryID, uuid.New(), "Use snake_case_name and 2*3 with `go test`, _em_ __strong__ ***both*** ~~old~~")
+
+	entry := entryStore.entries[entryID]
+	if want := "Use snake_case_name and 2*3 with go test, em strong both old"; entry.Content != want {
+		t.Errorf("Content: got %q, want %q", entry.Content, want)
+	}
+	// フロントマターにサムネイルが無ければ既存の値を残す
+	if entry.Thumbnail == nil || *entry.Thumbnail != thumb {
+		t.Errorf("Thumbnail: got %v, want %q", entry.Thumbnail, thumb)
+	}
+}
+
+func TestEntryProjector_TitleStripsHeading(t *testing.T) {
+	entryStore := newMockEntryStore()
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), slog.Default())
+
+	entryID := uuid.New()
+	entryStore.entries[entryID] = domain.Entry{ID: entryID}
+
+	applyText(t, projector, entryID, uuid.New(), "## 日本語の見出し\n本文")
+
+	entry := entryStore.entries[entryID]
+	if entry.Title != "日本語の見出し" {
+		t.Errorf("Title: got %q, want %q", entry.Title, "日本語の見出し")
+	}
+	if entry.WordCount != 9 {
+		t.Errorf("WordCount: got %d, want 9", entry.WordCount)
+	}
+}
+
+func TestEntryProjector_ContentSkipsCodeBlocks(t *testing.T) {
+	entryStore := newMockEntryStore()
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), slog.Default())
+
+	entryID := uuid.New()
+	entryStore.entries[entryID] = domain.Entry{ID: entryID}
+
+	// コードブロックは中身ごと抜粋と語数から除く。閉じていないブロックは末尾まで続く
+	applyText(t, projector, entryID, uuid.New(), "# Title\n\n```go\nfunc main() {}\n~~~\n```\nafter one\n~~~~\nx\n~~~\n~~~~\nafter two\n```\nnever closed")
+
+	entry := entryStore.entries[entryID]
+	if want := "Title after one after two"; entry.Content != want {
+		t.Errorf("Content: got %q, want %q", entry.Content, want)
+	}
+	if entry.WordCount != 5 {
+		t.Errorf("WordCount: got %d, want 5", entry.WordCount)
+	}
+}
+
+func TestEntryProjector_LeadingRuleIsNotFrontMatter(t *testing.T) {
+	entryStore := newMockEntryStore()
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), slog.Default())
+
+	entryID := uuid.New()
+	entryStore.entries[entryID] = domain.Entry{ID: entryID}
+
+	// 水平線で始まる文書は、次の水平線までの段落を失わない
+	applyText(t, projector, entryID, uuid.New(), "---\nFirst section\n---\nSecond section")
+
+	entry := entryStore.entries[entryID]
+	if entry.Title != "First section" {
+		t.Errorf("Title: got %q, want %q", entry.Title, "First section")
+	}
+	if entry.Content != "First section Second section" {
+		t.Errorf("Content: got %q, want %q", entry.Content, "First section Second section")
+	}
+}
+
+func TestEntryProjector_ReplaySkipsUnauthenticatedDelete(t *testing.T) {
+	ctx := context.Background()
+	entryStore := newMockEntryStore()
+	entryID, siteID := uuid.New(), uuid.New()
+	entryStore.entries[entryID] = domain.Entry{ID: entryID, CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC()}
+
+	insert, _ := json.Marshal(map[string]any{
+		"request_id":    uuid.New().String(),
+		"op_type":       1,
+		"node_id":       map[string]any{"site_id": siteID.String(), "timestamp": 1},
+		"value":         "a",
+		"authenticated": true,
+	})
+	del, _ := json.Marshal(map[string]any{
+		"request_id":    uuid.New().String(),
+		"op_type":       2,
+		"node_id":       map[string]any{"site_id": siteID.String(), "timestamp": 1},
+		"authenticated": false,
+	})
+
+	// ライブの適用では拒否される
+	live := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), slog.Default())
+	live.Apply(ctx, entryID, insert)
+	if live.Apply(ctx, entryID, del) {
+		t.Fatal("unauthenticated delete of authenticated node should be rejected")
+	}
+
+	// イベントログには記録されているので、再生でも同じく読み飛ばす（fsck・rebuild-projections・フォロワー）
+	eventStore := memory.NewEventStore()
+	for _, payload := range [][]byte{insert, del} {
+		if _, err := eventStore.Append(ctx, domain.Event{EntryID: entryID, RequestID: uuid.New(), EventType: domain.EventCRDTOp, Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replayed := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), slog.Default())
+	if err := replayed.Replay(ctx, eventStore, entryID); err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := replayed.PublicText(entryID); text != "a" {
+		t.Errorf("replayed text: got %q, want %q", text, "a")
+	}
+	if entry := entryStore.entries[entryID]; entry.Text != "a" {
+		t.Errorf("replayed projection: got %q, want %q", entry.Text, "a")
+	}
+}
@@ -0,0 +1,51 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+)
+
+// entryCreation はEventEntryCreateとしてイベントログに記録されるエントリの作成。
+type entryCreation struct {
+	RequestID uuid.UUID `json:"request_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EntryService はエントリの作成をイベントログに記録し、購読者に通知する。
+// 削除・復元はTrashServiceが担う。
+type EntryService struct {
+	syncService *SyncService
+	entryStore  domain.EntryStore
+}
+
+func NewEntryService(syncService *SyncService, entryStore domain.EntryStore) *EntryService {
+	return &EntryService{syncService: syncService, entryStore: entryStore}
+}
+
+// Create は空のエントリを作成する。イベントログへの記録を先に行うので、
+// entries.jsonはイベントログだけから再構築できる。
+func (s *EntryService) Create(ctx context.Context) (domain.Entry, error) {
+	entry := domain.NewEntry()
+
+	creation := entryCreation{RequestID: uuid.New(), CreatedAt: entry.CreatedAt}
+	payload, err := json.Marshal(creation)
+	if err != nil {
+		return domain.Entry{}, fmt.Errorf("marshal creation: %w", err)
+	}
+	if _, err := s.syncService.AppendEvent(ctx, entry.ID, creation.RequestID, domain.EventEntryCreate, payload); err != nil {
+		return domain.Entry{}, err
+	}
+	if err := s.entryStore.Save(ctx, entry); err != nil {
+		return domain.Entry{}, err
+	}
+
+	// 作成されたばかりのエントリには購読者がいないので、接続中の全購読者に知らせる
+	s.syncService.NotifyAll(Notification{Type: NotifyEntryCreate, EntryID: entry.ID})
+	return entry, nil
+}
@@ -0,0 +1,80 @@
+package application_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+func TestEntryService_Create(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	eventStore := memory.NewEventStore()
+	syncService := application.NewSyncService(eventStore)
+	entries := application.NewEntryService(syncService, entryStore)
+
+	// 別エントリの購読者にも作成が通知される
+	sub := &mockSubscriber{}
+	syncService.Subscribe(uuid.New(), sub)
+	syncService.Subscribe(uuid.New(), sub)
+
+	entry, err := entries.Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entryStore.FindByID(ctx, entry.ID); err != nil {
+		t.Fatalf("created entry: %v", err)
+	}
+	events, _ := eventStore.ListAfter(ctx, entry.ID, 0)
+	if len(events) != 1 || events[0].EventType != domain.EventEntryCreate {
+		t.Fatalf("events: got %+v", events)
+	}
+
+	notes := sub.Notifications()
+	if len(notes) != 1 || notes[0].Type != application.NotifyEntryCreate || notes[0].EntryID != entry.ID {
+		t.Errorf("notifications: got %+v", notes)
+	}
+}
+
+func TestEntryProjector_RebuildFromEventLog(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	eventStore := memory.NewEventStore()
+	syncService := application.NewSyncService(eventStore)
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	entries := application.NewEntryService(syncService, entryStore)
+	trash := application.NewTrashService(syncService, projector, entryStore, eventStore, 0, nil)
+
+	live, _ := entries.Create(ctx)
+	deleted, _ := entries.Create(ctx)
+	siteID := uuid.New()
+	payload := makeInsertPayload(t, siteID, 1, "#", nil)
+	syncService.HandleOp(ctx, live.ID, siteID, uuid.New(), payload)
+	projector.Apply(ctx, live.ID, payload)
+	trash.Delete(ctx, deleted.ID)
+
+	// entries.jsonもRGAスナップショットも無い状態から再構築する
+	rebuiltStore := memory.NewEntryStore()
+	rebuilt := application.NewEntryProjector(rebuiltStore, newMockRGAStateStore(), t.TempDir(), nil)
+	if err := rebuilt.Restore(ctx, eventStore, []uuid.UUID{live.ID, deleted.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := rebuiltStore.FindByID(ctx, live.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != "#" || !got.CreatedAt.Equal(live.CreatedAt) {
+		t.Errorf("rebuilt entry: got text=%q createdAt=%v, want %q %v", got.Text, got.CreatedAt, "#", live.CreatedAt)
+	}
+
+	trashed, _ := rebuiltStore.ListDeleted(ctx)
+	if len(trashed) != 1 || trashed[0].ID != deleted.ID || trashed[0].DeletedAt.IsZero() {
+		t.Errorf("rebuilt trash: got %+v", trashed)
+	}
+}
@@ -0,0 +1,43 @@
+package application
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShardOf はエントリを担当するシャードの番号を返す。
+func ShardOf(p *EntryProjector, entryID uuid.UUID) int {
+	s := p.shard(entryID)
+	for i, shard := range p.shards {
+		if shard == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetFlushRetryDelay は永続化に失敗したエントリを再びflushするまでの待ち時間を変更し、元に戻す関数を返す。
+func SetFlushRetryDelay(d time.Duration) (restore func()) {
+	prev := flushRetryDelay
+	flushRetryDelay = d
+	return func() { flushRetryDelay = prev }
+}
+
+// FlushLocks は永続化を直列化するロックを持つエントリの数を返す。
+func FlushLocks(p *EntryProjector) int {
+	n := 0
+	for _, s := range p.shards {
+		s.lock()
+		n += len(s.flushLocks)
+		s.unlock()
+	}
+	return n
+}
+
+// CachedOps は解釈済みのopを覚えている数を返す。
+func CachedOps(s *SyncService) int {
+	s.ops.mu.Lock()
+	defer s.ops.mu.Unlock()
+	return s.ops.size
+}
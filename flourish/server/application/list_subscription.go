@@ -0,0 +1,123 @@
+package application
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultListUpdateInterval はエントリ一覧の購読者へのentry_updateを間引く間隔。
+const DefaultListUpdateInterval = time.Second
+
+// listed は通知がエントリ一覧の購読者にも届く種別かどうかを返す。
+func (t NotificationType) listed() bool {
+	return t == NotifyEntryCreate || t == NotifyEntryUpdate || t == NotifyEntryDelete
+}
+
+// listUpdate はエントリごとのentry_updateの間引き状態。
+type listUpdate struct {
+	seq   int64
+	dirty bool // 間引き中に更新があった
+}
+
+// SubscribeList はエントリ一覧の変化（作成・更新・削除）を購読する。
+func (s *SyncService) SubscribeList(sub Subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.listSubscribers[sub]; !ok {
+		s.listSubscribers[sub] = struct{}{}
+	}
+}
+
+// UnsubscribeList はエントリ一覧の購読を解除する。
+func (s *SyncService) UnsubscribeList(sub Subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listSubscribers, sub)
+}
+
+// SetListUpdateInterval はentry_updateを間引く間隔を変更する。0なら間引かない。
+func (s *SyncService) SetListUpdateInterval(d time.Duration) {
+	s.listMu.Lock()
+	defer s.listMu.Unlock()
+	s.listInterval = d
+}
+
+// recipients はエントリの購読者と、listedな通知ならエントリ一覧の購読者を重複なく返す。
+func (s *SyncService) recipients(entryID uuid.UUID, t NotificationType) []Subscriber {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subs := s.subscribers[entryID]
+	if !t.listed() || len(s.listSubscribers) == 0 {
+		return subs
+	}
+	seen := make(map[Subscriber]struct{}, len(subs)+len(s.listSubscribers))
+	all := make([]Subscriber, 0, len(subs)+len(s.listSubscribers))
+	for _, sub := range subs {
+		seen[sub] = struct{}{}
+		all = append(all, sub)
+	}
+	for sub := range s.listSubscribers {
+		if _, ok := seen[sub]; !ok {
+			all = append(all, sub)
+		}
+	}
+	return all
+}
+
+// notifyListUpdate はエントリ一覧の購読者にentry_updateを送る。
+// 間隔内の更新は最後の1回にまとめ、間隔の終わりに送る。
+func (s *SyncService) notifyListUpdate(entryID uuid.UUID, seq int64) {
+	s.mu.RLock()
+	listening := len(s.listSubscribers) > 0
+	s.mu.RUnlock()
+	if !listening {
+		return
+	}
+
+	s.listMu.Lock()
+	if u, ok := s.listUpdates[entryID]; ok {
+		u.seq = max(u.seq, seq)
+		u.dirty = true
+		s.listMu.Unlock()
+		return
+	}
+	interval := s.listInterval
+	if interval > 0 {
+		s.listUpdates[entryID] = &listUpdate{seq: seq}
+		time.AfterFunc(interval, func() { s.flushListUpdate(entryID, interval) })
+	}
+	s.listMu.Unlock()
+
+	s.notifyList(Notification{Type: NotifyEntryUpdate, EntryID: entryID, ServerSeq: seq})
+}
+
+// flushListUpdate は間引いた更新があれば送り、次の間隔も間引く。無ければ間引きを終える。
+func (s *SyncService) flushListUpdate(entryID uuid.UUID, interval time.Duration) {
+	s.listMu.Lock()
+	u := s.listUpdates[entryID]
+	if u == nil || !u.dirty {
+		delete(s.listUpdates, entryID)
+		s.listMu.Unlock()
+		return
+	}
+	seq := u.seq
+	u.dirty = false
+	time.AfterFunc(interval, func() { s.flushListUpdate(entryID, interval) })
+	s.listMu.Unlock()
+
+	s.notifyList(Notification{Type: NotifyEntryUpdate, EntryID: entryID, ServerSeq: seq})
+}
+
+func (s *SyncService) notifyList(n Notification) {
+	s.mu.RLock()
+	subs := make([]Subscriber, 0, len(s.listSubscribers))
+	for sub := range s.listSubscribers {
+		subs = append(subs, sub)
+	}
+	s.mu.RUnlock()
+
+	for _, sub := range subs {
+		sub.Notify(n)
+	}
+}
@@ -0,0 +1,76 @@
+package application_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+)
+
+func TestSyncService_ListSubscriptionReceivesEntryChanges(t *testing.T) {
+	svc := application.NewSyncService(memory.NewEventStore())
+	entryID := uuid.New()
+	list, both, other := &mockSubscriber{}, &mockSubscriber{}, &mockSubscriber{}
+	svc.SubscribeList(list)
+	svc.SubscribeList(both)
+	svc.Subscribe(entryID, both)
+	svc.Subscribe(uuid.New(), other)
+
+	svc.NotifyAll(application.Notification{Type: application.NotifyEntryCreate, EntryID: entryID})
+	svc.Notify(entryID, application.Notification{Type: application.NotifyEntryDelete, EntryID: entryID, Deleted: true})
+	svc.Notify(entryID, application.Notification{Type: application.NotifyEntryLock, EntryID: entryID, Locked: true})
+
+	types := func(s *mockSubscriber) []application.NotificationType {
+		var ts []application.NotificationType
+		for _, n := range s.Notifications() {
+			ts = append(ts, n.Type)
+		}
+		return ts
+	}
+	if got := types(list); !slices.Equal(got, []application.NotificationType{application.NotifyEntryCreate, application.NotifyEntryDelete}) {
+		t.Errorf("一覧の購読者には作成・削除だけが届くべき: got %v", got)
+	}
+	if got := types(both); !slices.Equal(got, []application.NotificationType{application.NotifyEntryCreate, application.NotifyEntryDelete, application.NotifyEntryLock}) {
+		t.Errorf("両方を購読していても1回ずつ届くべき: got %v", got)
+	}
+	if got := types(other); !slices.Equal(got, []application.NotificationType{application.NotifyEntryCreate}) {
+		t.Errorf("他のエントリの購読者には作成だけが届くべき: got %v", got)
+	}
+
+	svc.UnsubscribeList(list)
+	svc.NotifyAll(application.Notification{Type: application.NotifyEntryCreate, EntryID: uuid.New()})
+	if n := len(list.Notifications()); n != 2 {
+		t.Errorf("解除後は届かないべき: got %d", n)
+	}
+}
+
+func TestSyncService_ListUpdatesAreThrottled(t *testing.T) {
+	svc := application.NewSyncService(memory.NewEventStore())
+	svc.SetListUpdateInterval(50 * time.Millisecond)
+	entryID := uuid.New()
+	list := &mockSubscriber{}
+	svc.SubscribeList(list)
+
+	for seq := int64(1); seq <= 3; seq++ {
+		svc.Broadcast(entryID, application.SyncMessage{EntryID: entryID, LatestServerSeq: seq})
+	}
+	if got := list.Notifications(); len(got) != 1 || got[0].Type != application.NotifyEntryUpdate || got[0].ServerSeq != 1 {
+		t.Fatalf("最初の更新はすぐに届くべき: got %+v", got)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(list.Notifications()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	got := list.Notifications()
+	if len(got) != 2 || got[1].ServerSeq != 3 {
+		t.Errorf("間隔内の更新は最新の1回にまとめるべき: got %+v", got)
+	}
+	if len(list.Messages()) != 0 {
+		t.Error("一覧の購読者にsyncは届かないべき")
+	}
+}
@@ -0,0 +1,136 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+func TestLockService_SetLocked(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	eventStore := memory.NewEventStore()
+	syncService := application.NewSyncService(eventStore)
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	locks := application.NewLockService(syncService, entryStore)
+	tags := application.NewTagService(syncService, projector, entryStore)
+
+	entry := domain.NewEntry()
+	entryStore.Save(ctx, entry)
+
+	sub := &mockSubscriber{}
+	syncService.Subscribe(entry.ID, sub)
+	defer syncService.Unsubscribe(entry.ID, sub)
+
+	if err := locks.SetLocked(ctx, entry.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if !locks.IsLocked(ctx, entry.ID) {
+		t.Error("entry should be locked")
+	}
+
+	// 同じ状態への変更はイベントを記録しない
+	if err := locks.SetLocked(ctx, entry.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	events, _ := eventStore.ListAfter(ctx, entry.ID, 0)
+	if len(events) != 1 || events[0].EventType != domain.EventEntryLock {
+		t.Fatalf("events: got %+v", events)
+	}
+
+	notes := sub.Notifications()
+	if len(notes) != 1 || notes[0].Type != application.NotifyEntryLock || !notes[0].Locked {
+		t.Errorf("notifications: got %+v", notes)
+	}
+
+	// ロック中はタグ変更も拒否される
+	if _, err := tags.Update(ctx, entry.ID, []string{"go"}, nil); !errors.Is(err, domain.ErrEntryLocked) {
+		t.Errorf("tag update: got %v, want ErrEntryLocked", err)
+	}
+
+	// イベントログから復元してもロックは維持される
+	stored, _ := entryStore.FindByID(ctx, entry.ID)
+	stored.Locked = false
+	entryStore.Save(ctx, stored)
+	restored := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	if err := restored.Restore(ctx, eventStore, []uuid.UUID{entry.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if !locks.IsLocked(ctx, entry.ID) {
+		t.Error("lock should survive restore")
+	}
+
+	if err := locks.SetLocked(ctx, entry.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tags.Update(ctx, entry.ID, []string{"go"}, nil); err != nil {
+		t.Errorf("tag update after unlock: %v", err)
+	}
+}
+
+func TestLockService_NotFound(t *testing.T) {
+	locks := application.NewLockService(application.NewSyncService(memory.NewEventStore()), memory.NewEntryStore())
+	if err := locks.SetLocked(context.Background(), uuid.New(), true); !errors.Is(err, domain.ErrEntryNotFound) {
+		t.Errorf("got %v, want ErrEntryNotFound", err)
+	}
+}
+
+// slowEventStore は追記に時間のかかるEventStore。
+type slowEventStore struct{ *memory.EventStore }
+
+func (s slowEventStore) Append(ctx context.Context, event domain.Event) (int64, error) {
+	time.Sleep(time.Millisecond)
+	return s.EventStore.Append(ctx, event)
+}
+
+func TestLockService_ConcurrentChangesMatchLog(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	eventStore := slowEventStore{memory.NewEventStore()}
+	locks := application.NewLockService(application.NewSyncService(eventStore), entryStore)
+	entry := domain.NewEntry()
+	entryStore.Save(ctx, entry)
+
+	// 同時にロックしても記録は1件で、交互の変更の後もログの最後の状態が保存される
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := locks.SetLocked(ctx, entry.ID, i%2 == 0); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	events, _ := eventStore.ListAfter(ctx, entry.ID, 0)
+	var last *bool
+	for _, ev := range events {
+		var change struct {
+			Locked bool `json:"locked"`
+		}
+		if err := json.Unmarshal(ev.Payload, &change); err != nil {
+			t.Fatal(err)
+		}
+		if last != nil && *last == change.Locked {
+			t.Fatalf("同じ状態への変更が続けて記録された: server_seq=%d", ev.ServerSeq)
+		}
+		last = &change.Locked
+	}
+	if last == nil {
+		t.Fatal("ロックの変更が記録されていない")
+	}
+	if got := locks.IsLocked(ctx, entry.ID); got != *last {
+		t.Errorf("IsLocked: got %v, want %v（ログの最後の状態）", got, *last)
+	}
+}
@@ -0,0 +1,294 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+	"flourish/server/domain/crdt"
+)
+
+// moderationContextRunes は差分表示で前後に付ける文脈の文字数。
+const moderationContextRunes = 20
+
+// ModerationAction はモデレーション判断の種別。
+type ModerationAction string
+
+const (
+	ModerationApprove ModerationAction = "approve"
+)
+
+// moderationDecision はEventModerationとしてイベントログに記録される承認内容。
+// 却下は通常のdelete opとして記録するため、ここには現れない。
+type moderationDecision struct {
+	RequestID uuid.UUID        `json:"request_id"`
+	Action    ModerationAction `json:"action"`
+	NodeIDs   []crdt.NodeID    `json:"node_ids"`
+}
+
+// Contribution は連続した未承認の非認証insertのまとまり。
+type Contribution struct {
+	NodeIDs []crdt.NodeID
+	Text    string
+	// Offset はProposedText上の開始位置（rune単位）。
+	Offset int
+	Before string
+	After  string
+}
+
+// PendingView はエントリの未承認コントリビューションと差分表示用のテキスト。
+type PendingView struct {
+	EntryID       uuid.UUID
+	PublicText    string
+	ProposedText  string
+	Contributions []Contribution
+}
+
+// publicText は公開用のテキストを返す。ロック保持前提。
+// 未承認の非認証insert（モデレーション有効時）と未採用の提案insertを除外する。
+func (p *EntryProjector) publicText(entryID uuid.UUID, rga *crdt.RGA) string {
+	hidden := p.suggestedNodes(entryID, rga)
+	if p.moderation {
+		maps.Copy(hidden, p.pendingNodes(entryID, rga))
+	}
+	if len(hidden) == 0 {
+		return rga.Text()
+	}
+	return rga.TextFiltered(hidden)
+}
+
+// pendingNodes は未承認の非認証ノードの集合を返す。ロック保持前提。
+// 提案insertは提案として扱うためモデレーション対象に含めない。
+func (p *EntryProjector) pendingNodes(entryID uuid.UUID, rga *crdt.RGA) map[crdt.NodeID]struct{} {
+	approved := p.shard(entryID).approved[entryID]
+	pending := make(map[crdt.NodeID]struct{})
+	for _, n := range rga.VisibleNodes() {
+		if n.Authenticated || n.Suggestion != uuid.Nil {
+			continue
+		}
+		if _, ok := approved[n.ID]; ok {
+			continue
+		}
+		pending[n.ID] = struct{}{}
+	}
+	return pending
+}
+
+// Pending はエントリの未承認コントリビューションを返す。RGAが無ければfalse。
+func (p *EntryProjector) Pending(entryID uuid.UUID) (PendingView, bool) {
+	s := p.lock(entryID)
+	defer s.unlock()
+
+	rga, ok := s.rgas[entryID]
+	if !ok {
+		return PendingView{}, false
+	}
+
+	pending := p.pendingNodes(entryID, rga)
+	nodes := rga.VisibleNodes()
+	proposed := make([]rune, len(nodes))
+	for i, n := range nodes {
+		proposed[i] = n.Value
+	}
+
+	view := PendingView{
+		EntryID:      entryID,
+		PublicText:   p.publicText(entryID, rga),
+		ProposedText: string(proposed),
+	}
+
+	for i := 0; i < len(nodes); {
+		if _, ok := pending[nodes[i].ID]; !ok {
+			i++
+			continue
+		}
+		start := i
+		var ids []crdt.NodeID
+		for i < len(nodes) {
+			if _, ok := pending[nodes[i].ID]; !ok {
+				break
+			}
+			ids = append(ids, nodes[i].ID)
+			i++
+		}
+		view.Contributions = append(view.Contributions, Contribution{
+			NodeIDs: ids,
+			Text:    string(proposed[start:i]),
+			Offset:  start,
+			Before:  string(proposed[max(0, start-moderationContextRunes):start]),
+			After:   string(proposed[i:min(len(proposed), i+moderationContextRunes)]),
+		})
+	}
+	return view, true
+}
+
+// ApplyModeration は承認イベントを適用し、Entryを再投影する。
+func (p *EntryProjector) ApplyModeration(ctx context.Context, entryID uuid.UUID, payload []byte) bool {
+	s := p.lock(entryID)
+	err := p.applyDecision(entryID, payload)
+	s.unlock()
+	if err != nil {
+		p.log.Error("projector: moderation変換失敗", "entryID", entryID, "error", err)
+		return false
+	}
+
+	p.reproject(ctx, entryID)
+	return true
+}
+
+// applyDecision は承認内容を承認済み集合に反映する。ロック保持前提。
+func (p *EntryProjector) applyDecision(entryID uuid.UUID, payload []byte) error {
+	var d moderationDecision
+	if err := json.Unmarshal(payload, &d); err != nil {
+		return fmt.Errorf("unmarshal moderation: %w", err)
+	}
+	if d.Action != ModerationApprove {
+		return fmt.Errorf("unknown moderation action: %q", d.Action)
+	}
+	s := p.shard(entryID)
+	approved, ok := s.approved[entryID]
+	if !ok {
+		approved = make(map[crdt.NodeID]struct{})
+		s.approved[entryID] = approved
+	}
+	for _, id := range d.NodeIDs {
+		approved[id] = struct{}{}
+	}
+	return nil
+}
+
+// ModerationService は非認証編集の承認・却下を担う。
+type ModerationService struct {
+	syncService *SyncService
+	projector   *EntryProjector
+}
+
+func NewModerationService(syncService *SyncService, projector *EntryProjector) *ModerationService {
+	return &ModerationService{syncService: syncService, projector: projector}
+}
+
+// Pending はエントリの未承認コントリビューションを返す。
+func (s *ModerationService) Pending(entryID uuid.UUID) (PendingView, error) {
+	view, ok := s.projector.Pending(entryID)
+	if !ok {
+		return PendingView{}, domain.ErrEntryNotFound
+	}
+	return view, nil
+}
+
+// Approve は指定ノード（空なら全未承認ノード）を承認し、公開テキストに反映する。
+func (s *ModerationService) Approve(ctx context.Context, entryID uuid.UUID, nodeIDs []crdt.NodeID) error {
+	if err := ensureUnlocked(ctx, s.projector.entryStore, entryID); err != nil {
+		return err
+	}
+	nodeIDs, err := s.resolve(entryID, nodeIDs)
+	if err != nil || len(nodeIDs) == 0 {
+		return err
+	}
+
+	d := moderationDecision{RequestID: uuid.New(), Action: ModerationApprove, NodeIDs: nodeIDs}
+	payload, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("marshal moderation: %w", err)
+	}
+	seq, err := s.syncService.AppendEvent(ctx, entryID, d.RequestID, domain.EventModeration, payload)
+	if err != nil {
+		return err
+	}
+	if seq > 0 {
+		s.projector.ApplyModeration(ctx, entryID, payload)
+		s.syncService.Notify(entryID, Notification{Type: NotifyPublicText, ServerSeq: seq})
+	}
+	return nil
+}
+
+// Reject は指定ノード（空なら全未承認ノード）を認証済みのdelete opとして削除し、購読者に配信する。
+func (s *ModerationService) Reject(ctx context.Context, entryID uuid.UUID, nodeIDs []crdt.NodeID) error {
+	if err := ensureUnlocked(ctx, s.projector.entryStore, entryID); err != nil {
+		return err
+	}
+	nodeIDs, err := s.resolve(entryID, nodeIDs)
+	if err != nil {
+		return err
+	}
+
+	return deleteNodes(ctx, s.syncService, s.projector, entryID, nodeIDs)
+}
+
+// deleteNodes はサーバー発行の認証済みdelete opをWSと同じ流れ（永続化→projector適用→配信）で処理する。
+// 大きな挿入を却下しても投影の永続化と配信が1回で済むよう、全opを記録してからまとめて適用し、1つのsyncで配信する。
+// 記録の途中で失敗しても、記録済みのopは適用・配信してからエラーを返す。
+func deleteNodes(ctx context.Context, syncService *SyncService, projector *EntryProjector, entryID uuid.UUID, nodeIDs []crdt.NodeID) error {
+	var (
+		ops       []SyncOp
+		payloads  [][]byte
+		appendErr error
+	)
+	for _, id := range nodeIDs {
+		op := crdt.Operation{
+			RequestID:     uuid.New(),
+			OpType:        crdt.OpDelete,
+			NodeID:        id,
+			Authenticated: true,
+		}
+		payload, err := crdt.MarshalPayload(op)
+		if err != nil {
+			appendErr = fmt.Errorf("marshal delete op: %w", err)
+			break
+		}
+		ack, err := syncService.HandleOp(ctx, entryID, uuid.Nil, op.RequestID, payload)
+		if err != nil {
+			appendErr = err
+			break
+		}
+		if ack.ServerSeq == 0 {
+			continue
+		}
+		ops = append(ops, SyncOp{RequestID: op.RequestID, ServerSeq: ack.ServerSeq, Payload: payload, Op: &op})
+		payloads = append(payloads, payload)
+	}
+	if len(ops) == 0 {
+		return appendErr
+	}
+
+	applied := projector.ApplyAll(ctx, entryID, payloads)
+	var accepted []SyncOp
+	for i, op := range ops {
+		if applied[i] {
+			accepted = append(accepted, op)
+		} else {
+			syncService.Skip(entryID, op.ServerSeq)
+		}
+	}
+	if len(accepted) > 0 {
+		syncService.Broadcast(entryID, SyncMessage{
+			EntryID:         entryID,
+			Ops:             accepted,
+			LatestServerSeq: accepted[len(accepted)-1].ServerSeq,
+		})
+	}
+	return appendErr
+}
+
+// resolve は対象ノードを未承認ノードに限定する。nodeIDsが空なら全未承認ノードを返す。
+func (s *ModerationService) resolve(entryID uuid.UUID, nodeIDs []crdt.NodeID) ([]crdt.NodeID, error) {
+	view, err := s.Pending(entryID)
+	if err != nil {
+		return nil, err
+	}
+	var pending []crdt.NodeID
+	for _, c := range view.Contributions {
+		pending = append(pending, c.NodeIDs...)
+	}
+	if len(nodeIDs) == 0 {
+		return pending, nil
+	}
+	return slices.DeleteFunc(slices.Clone(nodeIDs), func(id crdt.NodeID) bool {
+		return !slices.Contains(pending, id)
+	}), nil
+}
@@ -0,0 +1,188 @@
+package application_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+	"flourish/server/domain/crdt"
+)
+
+// submit はopをWSと同じ流れ（永続化→projector適用）で処理する。
+func submit(t *testing.T, svc *application.SyncService, projector *application.EntryProjector, entryID uuid.UUID, op crdt.Operation) {
+	t.Helper()
+	payload, err := crdt.MarshalPayload(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, err := svc.HandleOp(context.Background(), entryID, op.NodeID.ReplicaID, op.RequestID, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.ServerSeq > 0 {
+		projector.Apply(context.Background(), entryID, payload)
+	}
+}
+
+func setupModeration(t *testing.T) (*application.ModerationService, *memory.EntryStore, *memory.EventStore, uuid.UUID) {
+	t.Helper()
+	entryStore := memory.NewEntryStore()
+	eventStore := memory.NewEventStore()
+	syncService := application.NewSyncService(eventStore)
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	projector.SetModeration(true)
+
+	entry := domain.NewEntry()
+	entryStore.Save(context.Background(), entry)
+
+	// 認証済み "ab" の後に非認証 "X" を挿入
+	admin := crdt.NewRGA(uuid.New())
+	a := admin.Insert(nil, 'a')
+	b := admin.Insert(&a.NodeID, 'b')
+	a.Authenticated, b.Authenticated = true, true
+	submit(t, syncService, projector, entry.ID, a)
+	submit(t, syncService, projector, entry.ID, b)
+
+	guest := crdt.Operation{
+		RequestID: uuid.New(),
+		OpType:    crdt.OpInsert,
+		NodeID:    crdt.NodeID{ReplicaID: uuid.New(), Timestamp: 3},
+		After:     &b.NodeID,
+		Value:     'X',
+	}
+	submit(t, syncService, projector, entry.ID, guest)
+
+	return application.NewModerationService(syncService, projector), entryStore, eventStore, entry.ID
+}
+
+func TestModeration_PendingHiddenFromProjection(t *testing.T) {
+	svc, entryStore, _, entryID := setupModeration(t)
+
+	entry, _ := entryStore.FindByID(context.Background(), entryID)
+	if entry.Text != "ab" {
+		t.Errorf("未承認insertは公開テキストに含まれないべき: got %q", entry.Text)
+	}
+
+	view, err := svc.Pending(entryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.ProposedText != "abX" || view.PublicText != "ab" {
+		t.Errorf("diff: public=%q proposed=%q", view.PublicText, view.ProposedText)
+	}
+	if len(view.Contributions) != 1 || view.Contributions[0].Text != "X" || view.Contributions[0].Before != "ab" {
+		t.Errorf("contributions: got %+v", view.Contributions)
+	}
+}
+
+func TestModeration_Approve(t *testing.T) {
+	svc, entryStore, eventStore, entryID := setupModeration(t)
+	ctx := context.Background()
+
+	if err := svc.Approve(ctx, entryID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, _ := entryStore.FindByID(ctx, entryID)
+	if entry.Text != "abX" {
+		t.Errorf("承認後は公開テキストに含まれるべき: got %q", entry.Text)
+	}
+	view, _ := svc.Pending(entryID)
+	if len(view.Contributions) != 0 {
+		t.Errorf("承認後のpendingは0件: got %d", len(view.Contributions))
+	}
+
+	// 承認はイベントログから復元できる
+	restored := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	restored.SetModeration(true)
+	if err := restored.Restore(ctx, eventStore, []uuid.UUID{entryID}); err != nil {
+		t.Fatal(err)
+	}
+	entry, _ = entryStore.FindByID(ctx, entryID)
+	if entry.Text != "abX" {
+		t.Errorf("復元後も承認が維持されるべき: got %q", entry.Text)
+	}
+}
+
+func TestModeration_RejectAppliesDeleteOps(t *testing.T) {
+	svc, entryStore, eventStore, entryID := setupModeration(t)
+	ctx := context.Background()
+
+	if err := svc.Reject(ctx, entryID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	view, _ := svc.Pending(entryID)
+	if view.ProposedText != "ab" || len(view.Contributions) != 0 {
+		t.Errorf("却下後はノードが削除されるべき: proposed=%q contributions=%d", view.ProposedText, len(view.Contributions))
+	}
+
+	events, _ := eventStore.ListAfter(ctx, entryID, 3)
+	if len(events) != 1 || events[0].EventType != domain.EventCRDTOp {
+		t.Fatalf("却下はdelete opとして記録されるべき: got %+v", events)
+	}
+	op, err := crdt.OperationFromPayload(events[0].Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.OpType != crdt.OpDelete || !op.Authenticated {
+		t.Errorf("認証済みdeleteであるべき: got %+v", op)
+	}
+
+	entry, _ := entryStore.FindByID(ctx, entryID)
+	if entry.Text != "ab" {
+		t.Errorf("Text: got %q", entry.Text)
+	}
+}
+
+// countingEntryStore はUpdateの回数を数えるEntryStore。
+type countingEntryStore struct {
+	*memory.EntryStore
+	updates atomic.Int32
+}
+
+func (s *countingEntryStore) Update(ctx context.Context, id uuid.UUID, fn func(*domain.Entry)) error {
+	s.updates.Add(1)
+	return s.EntryStore.Update(ctx, id, fn)
+}
+
+func TestModeration_RejectBatchesDeleteOps(t *testing.T) {
+	ctx := context.Background()
+	entryStore := &countingEntryStore{EntryStore: memory.NewEntryStore()}
+	syncService := application.NewSyncService(memory.NewEventStore())
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	projector.SetModeration(true)
+	entry := domain.NewEntry()
+	entryStore.Save(ctx, entry)
+
+	guest := crdt.NewRGA(uuid.New())
+	var after *crdt.NodeID
+	for _, r := range "hello" {
+		op := guest.Insert(after, r)
+		submit(t, syncService, projector, entry.ID, op)
+		after = &op.NodeID
+	}
+	sub := &mockSubscriber{}
+	syncService.Subscribe(entry.ID, sub)
+	entryStore.updates.Store(0)
+
+	// 却下するノードの数によらず、投影の永続化と配信は1回で済む
+	if err := application.NewModerationService(syncService, projector).Reject(ctx, entry.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := entryStore.updates.Load(); n != 1 {
+		t.Errorf("Entryの更新回数: got %d, want 1", n)
+	}
+	msgs := sub.Messages()
+	if len(msgs) != 1 || len(msgs[0].Ops) != 5 || msgs[0].LatestServerSeq != 10 {
+		t.Fatalf("5件のdeleteを1つのsyncで配信するべき: got %+v", msgs)
+	}
+	if got, _ := projector.PublicText(entry.ID); got != "" {
+		t.Errorf("PublicText: got %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package application
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain/crdt"
+)
+
+// opCacheSize は解釈済みのopを覚えておく数の上限。超えたらエントリ単位で捨てる。
+const opCacheSize = 1 << 16
+
+// opCache はイベントのpayloadを解釈したopを、エントリとserver_seqごとに覚える。
+// 記録したイベントは変わらないので、GetDiffや配信のたびに同じpayloadを解釈し直さずに済む。
+type opCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]map[int64]cachedOp
+	size    int
+}
+
+// cachedOp は解釈済みのop。解釈できないpayloadならopはnil。
+type cachedOp struct {
+	requestID uuid.UUID
+	op        *crdt.Operation
+}
+
+func newOpCache() *opCache {
+	return &opCache{entries: make(map[uuid.UUID]map[int64]cachedOp)}
+}
+
+// get はopのpayloadを解釈したものを返す。覚えていなければ解釈して覚える。
+// server_seqが同じでもrequest_idが違えば（完全削除後の再利用など）解釈し直す。
+func (c *opCache) get(entryID uuid.UUID, op SyncOp) *crdt.Operation {
+	c.mu.Lock()
+	cached, ok := c.entries[entryID][op.ServerSeq]
+	c.mu.Unlock()
+	if ok && cached.requestID == op.RequestID {
+		return cached.op
+	}
+
+	decoded := op.Op
+	if decoded == nil {
+		decoded = decodeOp(op.Payload)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size >= opCacheSize {
+		c.evict()
+	}
+	ops, ok := c.entries[entryID]
+	if !ok {
+		ops = make(map[int64]cachedOp)
+		c.entries[entryID] = ops
+	}
+	if _, exists := ops[op.ServerSeq]; !exists {
+		c.size++
+	}
+	ops[op.ServerSeq] = cachedOp{requestID: op.RequestID, op: decoded}
+	return decoded
+}
+
+// evict は上限の4分の3を下回るまでエントリ単位で捨てる。ロック保持前提。
+func (c *opCache) evict() {
+	for id, ops := range c.entries {
+		if c.size < opCacheSize*3/4 {
+			return
+		}
+		c.size -= len(ops)
+		delete(c.entries, id)
+	}
+}
+
+// forget はエントリの解釈済みのopを捨てる。完全削除で使う。
+func (c *opCache) forget(entryID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size -= len(c.entries[entryID])
+	delete(c.entries, entryID)
+}
+
+// decodeOps はOpが未設定のopに解釈済みのopを設定する。呼び出し元のスライスは変更しない。
+func (s *SyncService) decodeOps(entryID uuid.UUID, ops []SyncOp) []SyncOp {
+	if !slices.ContainsFunc(ops, func(op SyncOp) bool { return op.Op == nil }) {
+		return ops
+	}
+	decoded := slices.Clone(ops)
+	for i := range decoded {
+		if decoded[i].Op == nil {
+			decoded[i].Op = s.ops.get(entryID, decoded[i])
+		}
+	}
+	return decoded
+}
+
+func decodeOp(payload []byte) *crdt.Operation {
+	op, err := crdt.OperationFromPayload(payload)
+	if err != nil {
+		return nil
+	}
+	return &op
+}
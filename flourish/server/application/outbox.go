@@ -0,0 +1,308 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrSubscriberOverflow は購読者の送信キューが溢れて切断したことを表す。
+// クライアントは再接続してsync_requestで追いつく必要がある。
+var ErrSubscriberOverflow = errors.New("subscriber queue overflow")
+
+// OverflowPolicy は送信キューが溢れたときの扱い。
+type OverflowPolicy string
+
+const (
+	// OverflowCoalesce は溢れたsyncを捨て、キューが空いたら1回のsyncでまとめて追いつかせる。
+	OverflowCoalesce OverflowPolicy = "coalesce"
+	// OverflowDisconnect は溢れたら切断し、再同期を促す。
+	OverflowDisconnect OverflowPolicy = "disconnect"
+)
+
+// ParseOverflowPolicy は文字列からOverflowPolicyを返す。空文字はOverflowCoalesce。
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch OverflowPolicy(s) {
+	case "", OverflowCoalesce:
+		return OverflowCoalesce, nil
+	case OverflowDisconnect:
+		return OverflowDisconnect, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy: %q", s)
+}
+
+// OutboxConfig は購読者ごとの送信キューの設定。
+type OutboxConfig struct {
+	// Capacity はキューに溜められるメッセージ数。
+	Capacity int
+	// WriteTimeout は1メッセージの書き込みの期限。超えたら切断する。
+	WriteTimeout time.Duration
+	Policy       OverflowPolicy
+}
+
+// DefaultOutboxConfig は送信キューの既定の設定。
+var DefaultOutboxConfig = OutboxConfig{Capacity: 256, WriteTimeout: 10 * time.Second, Policy: OverflowCoalesce}
+
+// QueueStats は購読者の送信キューの状況。
+type QueueStats struct {
+	Subscribers int
+	// Queued は全キューに溜まっているメッセージ数。MaxDepthは最も溜まっているキューの数。
+	Queued   int
+	MaxDepth int
+	Capacity int
+	// 以下は起動以来の累計。
+	Coalesced   uint64 // 追いつき用syncにまとめるため捨てたメッセージ
+	Overflows   uint64
+	Disconnects uint64 // 溢れ・書き込み失敗による切断
+}
+
+// Outbox は購読者ごとの送信キューと書き込みgoroutine。
+// Broadcast・Notifyを呼び出し元で待たせないよう、Send・Notifyはキューに積むだけで返る。
+type Outbox struct {
+	service *SyncService
+	cfg     OutboxConfig
+	deliver func(ctx context.Context, msg any) error
+	onClose func(err error)
+
+	queue  chan any
+	wake   chan struct{}
+	done   chan struct{}
+	exited chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	lagging map[uuid.UUID]int64 // 溢れたsyncを捨てて追いつき待ちのエントリと、捨てた最初のopの直前のserver_seq
+	notes   []Notification      // 溢れた通知（種別・エントリごとに最新のみ）
+}
+
+// NewOutbox は購読者の送信キューを作り、書き込みgoroutineを開始する。
+// deliverはSyncMessage・Notification・Pushされた値を実際に書き込む。
+// onCloseは溢れ（ErrSubscriberOverflow）や書き込み失敗で閉じたときに別goroutineで呼ばれる。
+func (s *SyncService) NewOutbox(deliver func(ctx context.Context, msg any) error, onClose func(err error)) *Outbox {
+	s.mu.Lock()
+	cfg := s.outboxConfig
+	s.mu.Unlock()
+
+	o := &Outbox{
+		service: s,
+		cfg:     cfg,
+		deliver: deliver,
+		onClose: onClose,
+		queue:   make(chan any, max(cfg.Capacity, 1)),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+		lagging: make(map[uuid.UUID]int64),
+	}
+	s.mu.Lock()
+	s.outboxes[o] = struct{}{}
+	s.mu.Unlock()
+	go o.run()
+	return o
+}
+
+// Send はsyncメッセージをキューに積む。
+func (o *Outbox) Send(msg SyncMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	if after, ok := o.lagging[msg.EntryID]; ok {
+		// 追いつき用のsyncに含まれるので積まない
+		o.lagging[msg.EntryID] = min(after, seqBefore(msg))
+		o.service.coalesced.Add(1)
+		return
+	}
+	select {
+	case o.queue <- msg:
+	default:
+		o.overflow(func() { o.lagging[msg.EntryID] = seqBefore(msg) })
+	}
+}
+
+// seqBefore はsyncメッセージに含まれる最初のopの直前のserver_seqを返す。
+func seqBefore(msg SyncMessage) int64 {
+	seq := msg.LatestServerSeq
+	for _, op := range msg.Ops {
+		seq = min(seq, op.ServerSeq)
+	}
+	return max(seq-1, 0)
+}
+
+// Notify は通知をキューに積む。
+func (o *Outbox) Notify(n Notification) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	select {
+	case o.queue <- n:
+	default:
+		o.overflow(func() {
+			for i, pending := range o.notes {
+				if pending.Type == n.Type && pending.EntryID == n.EntryID && pending.Suggestion == n.Suggestion {
+					o.notes[i] = n
+					return
+				}
+			}
+			o.notes = append(o.notes, n)
+		})
+	}
+}
+
+// Push はACK・エラーなど接続自身への応答をキューに積む。キューが空くまで待つ。
+func (o *Outbox) Push(msg any) {
+	select {
+	case o.queue <- msg:
+	case <-o.done:
+	}
+}
+
+// Close は書き込みgoroutineを止める。onCloseは呼ばない。
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closeLocked()
+}
+
+// Wait は書き込みgoroutineの終了を待つ。Closeの後、書き込み先を手放す前に呼ぶ。
+func (o *Outbox) Wait() {
+	<-o.exited
+}
+
+// overflow はキューが溢れたときの処理。ロック保持前提。coalesceはキューに積めなかった分を追いつき待ちに記録する。
+func (o *Outbox) overflow(coalesce func()) {
+	o.service.overflows.Add(1)
+	if o.cfg.Policy == OverflowDisconnect {
+		o.fail(ErrSubscriberOverflow)
+		return
+	}
+	coalesce()
+	o.service.coalesced.Add(1)
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// fail は異常時に閉じてonCloseを呼ぶ。ロック保持前提。
+func (o *Outbox) fail(err error) {
+	if o.closed {
+		return
+	}
+	o.closeLocked()
+	o.service.disconnects.Add(1)
+	if o.onClose != nil {
+		go o.onClose(err)
+	}
+}
+
+// closeLocked はキューを閉じる。ロック保持前提。
+func (o *Outbox) closeLocked() {
+	if o.closed {
+		return
+	}
+	o.closed = true
+	close(o.done)
+	o.service.mu.Lock()
+	delete(o.service.outboxes, o)
+	o.service.mu.Unlock()
+}
+
+func (o *Outbox) run() {
+	defer close(o.exited)
+	for {
+		select {
+		case <-o.done:
+			return
+		case msg := <-o.queue:
+			if !o.write(msg) {
+				return
+			}
+		case <-o.wake:
+		}
+		if len(o.queue) == 0 && !o.catchUp() {
+			return
+		}
+	}
+}
+
+// write は1メッセージを期限付きで書き込む。失敗したら閉じてfalseを返す。
+func (o *Outbox) write(msg any) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), o.cfg.WriteTimeout)
+	defer cancel()
+	if err := o.deliver(ctx, msg); err != nil {
+		o.mu.Lock()
+		o.fail(err)
+		o.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// catchUp は追いつき待ちのエントリに捨てたopから1回のsyncを送り、溜めた通知を送る。
+func (o *Outbox) catchUp() bool {
+	o.mu.Lock()
+	if len(o.lagging) == 0 && len(o.notes) == 0 {
+		o.mu.Unlock()
+		return true
+	}
+	// 先に追いつき待ちを解除し、以降のSendは通常どおり積む（重複はクライアント側で冪等に扱われる）
+	after := maps.Clone(o.lagging)
+	clear(o.lagging)
+	notes := o.notes
+	o.notes = nil
+	o.mu.Unlock()
+
+	for entryID, seq := range after {
+		diff, err := o.service.GetDiff(context.Background(), entryID, seq)
+		if err != nil {
+			o.mu.Lock()
+			o.fail(fmt.Errorf("catch up %s: %w", entryID, err))
+			o.mu.Unlock()
+			return false
+		}
+		if !o.write(diff) {
+			return false
+		}
+	}
+	for _, n := range notes {
+		if !o.write(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetOutboxConfig は以降に作る送信キューの設定を変更する。
+func (s *SyncService) SetOutboxConfig(cfg OutboxConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outboxConfig = cfg
+}
+
+// QueueStats は購読者の送信キューの状況を返す。
+func (s *SyncService) QueueStats() QueueStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := QueueStats{
+		Subscribers: len(s.outboxes),
+		Capacity:    s.outboxConfig.Capacity,
+		Coalesced:   s.coalesced.Load(),
+		Overflows:   s.overflows.Load(),
+		Disconnects: s.disconnects.Load(),
+	}
+	for o := range s.outboxes {
+		depth := len(o.queue)
+		stats.Queued += depth
+		stats.MaxDepth = max(stats.MaxDepth, depth)
+	}
+	return stats
+}
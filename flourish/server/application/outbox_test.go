@@ -0,0 +1,198 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+)
+
+// slowClient は最初の書き込みをreleaseが閉じられるまで止める送信先。
+type slowClient struct {
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+
+	mu        sync.Mutex
+	delivered []any
+	closed    chan error
+}
+
+func newSlowClient() *slowClient {
+	return &slowClient{entered: make(chan struct{}), release: make(chan struct{}), closed: make(chan error, 1)}
+}
+
+func (c *slowClient) deliver(ctx context.Context, msg any) error {
+	c.once.Do(func() {
+		close(c.entered)
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delivered = append(c.delivered, msg)
+	return nil
+}
+
+func (c *slowClient) onClose(err error) { c.closed <- err }
+
+func (c *slowClient) Delivered() []any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.delivered)
+}
+
+// appendOps はエントリにn件のopを記録し、それぞれのsyncメッセージを返す。
+func appendOps(t *testing.T, svc *application.SyncService, entryID uuid.UUID, n int) []application.SyncMessage {
+	t.Helper()
+	msgs := make([]application.SyncMessage, n)
+	for i := range n {
+		reqID := uuid.New()
+		ack, err := svc.HandleOp(context.Background(), entryID, uuid.New(), reqID, []byte(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs[i] = application.SyncMessage{
+			EntryID:         entryID,
+			Ops:             []application.SyncOp{{RequestID: reqID, ServerSeq: ack.ServerSeq, Payload: []byte(`{}`)}},
+			LatestServerSeq: ack.ServerSeq,
+		}
+	}
+	return msgs
+}
+
+func TestOutbox_CoalescesOverflowIntoCatchUpSync(t *testing.T) {
+	svc := application.NewSyncService(memory.NewEventStore())
+	svc.SetOutboxConfig(application.OutboxConfig{Capacity: 2, WriteTimeout: 5 * time.Second, Policy: application.OverflowCoalesce})
+	entryID := uuid.New()
+	msgs := appendOps(t, svc, entryID, 10)
+
+	client := newSlowClient()
+	out := svc.NewOutbox(client.deliver, client.onClose)
+	defer out.Close()
+
+	out.Send(msgs[0])
+	<-client.entered
+	for _, msg := range msgs[1:] {
+		out.Send(msg) // 呼び出し元を待たせない
+	}
+	out.Notify(application.Notification{Type: application.NotifyEntryLock, EntryID: entryID, Locked: true})
+	out.Notify(application.Notification{Type: application.NotifyEntryLock, EntryID: entryID, Locked: false})
+
+	stats := svc.QueueStats()
+	if stats.Subscribers != 1 || stats.Queued != 2 || stats.MaxDepth != 2 || stats.Coalesced == 0 {
+		t.Errorf("溢れ中の状況: %+v", stats)
+	}
+
+	close(client.release)
+	deadline := time.Now().Add(2 * time.Second)
+	var seqs []int64
+	var notes []application.Notification
+	for {
+		seqs, notes = nil, nil
+		for _, msg := range client.Delivered() {
+			switch m := msg.(type) {
+			case application.SyncMessage:
+				for _, op := range m.Ops {
+					seqs = append(seqs, op.ServerSeq)
+				}
+			case application.Notification:
+				notes = append(notes, m)
+			}
+		}
+		if len(notes) > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	slices.Sort(seqs)
+	seqs = slices.Compact(seqs)
+	if want := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; !slices.Equal(seqs, want) {
+		t.Errorf("追いつき後の配信済みop: got %v, want %v", seqs, want)
+	}
+	if n := len(client.Delivered()); n != 5 {
+		t.Errorf("配信数: got %d, want 5（キューの3件・追いつきsync・通知）", n)
+	}
+	if len(notes) != 1 || notes[0].Locked {
+		t.Errorf("溢れた通知は最新のみ配信されるべき: %+v", notes)
+	}
+}
+
+func TestOutbox_DisconnectPolicy(t *testing.T) {
+	svc := application.NewSyncService(memory.NewEventStore())
+	svc.SetOutboxConfig(application.OutboxConfig{Capacity: 1, WriteTimeout: 5 * time.Second, Policy: application.OverflowDisconnect})
+	entryID := uuid.New()
+	msgs := appendOps(t, svc, entryID, 3)
+
+	client := newSlowClient()
+	out := svc.NewOutbox(client.deliver, client.onClose)
+	defer close(client.release)
+
+	out.Send(msgs[0])
+	<-client.entered
+	out.Send(msgs[1])
+	out.Send(msgs[2])
+
+	select {
+	case err := <-client.closed:
+		if !errors.Is(err, application.ErrSubscriberOverflow) {
+			t.Errorf("切断理由: got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("溢れたら切断されるべき")
+	}
+	stats := svc.QueueStats()
+	if stats.Subscribers != 0 || stats.Overflows != 1 || stats.Disconnects != 1 {
+		t.Errorf("切断後の状況: %+v", stats)
+	}
+}
+
+func TestOutbox_WriteTimeoutCloses(t *testing.T) {
+	svc := application.NewSyncService(memory.NewEventStore())
+	svc.SetOutboxConfig(application.OutboxConfig{Capacity: 4, WriteTimeout: 20 * time.Millisecond, Policy: application.OverflowCoalesce})
+
+	client := newSlowClient()
+	out := svc.NewOutbox(client.deliver, client.onClose)
+	defer close(client.release)
+
+	out.Push("hello")
+	select {
+	case err := <-client.closed:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("切断理由: got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("書き込み期限を過ぎたら切断されるべき")
+	}
+	// 閉じた後のPushは待たずに返る
+	out.Push("ignored")
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for in, want := range map[string]application.OverflowPolicy{
+		"":           application.OverflowCoalesce,
+		"coalesce":   application.OverflowCoalesce,
+		"disconnect": application.OverflowDisconnect,
+	} {
+		got, err := application.ParseOverflowPolicy(in)
+		if err != nil || got != want {
+			t.Errorf("ParseOverflowPolicy(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := application.ParseOverflowPolicy("drop"); err == nil {
+		t.Error("未知の方針はエラーになるべき")
+	}
+}
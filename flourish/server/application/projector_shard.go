@@ -0,0 +1,87 @@
+package application
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain/crdt"
+)
+
+// DefaultProjectorShards はEntryProjectorのシャード数の既定値。
+const DefaultProjectorShards = 16
+
+// projectorShard はEntryProjectorのシャード。担当エントリの投影状態とロックを持つ。
+type projectorShard struct {
+	mu     sync.Mutex
+	queued atomic.Int64 // ロック待ちとロック下で実行中の処理の数
+
+	rgas        map[uuid.UUID]*crdt.RGA
+	tagSets     map[uuid.UUID]*crdt.ORSet
+	approved    map[uuid.UUID]map[crdt.NodeID]struct{} // モデレーションで承認済みの非認証ノード
+	suggestions map[uuid.UUID]map[uuid.UUID]*suggestionSet
+	pending     map[uuid.UUID]*pendingFlush
+	flushLocks  map[uuid.UUID]*sync.Mutex
+	rejected    map[uuid.UUID]map[uuid.UUID]struct{} // 適用を拒否したopのrequest_id
+}
+
+func newProjectorShards(n int) []*projectorShard {
+	shards := make([]*projectorShard, max(n, 1))
+	for i := range shards {
+		shards[i] = &projectorShard{
+			rgas:        make(map[uuid.UUID]*crdt.RGA),
+			tagSets:     make(map[uuid.UUID]*crdt.ORSet),
+			approved:    make(map[uuid.UUID]map[crdt.NodeID]struct{}),
+			suggestions: make(map[uuid.UUID]map[uuid.UUID]*suggestionSet),
+			pending:     make(map[uuid.UUID]*pendingFlush),
+			flushLocks:  make(map[uuid.UUID]*sync.Mutex),
+			rejected:    make(map[uuid.UUID]map[uuid.UUID]struct{}),
+		}
+	}
+	return shards
+}
+
+func (s *projectorShard) lock() *projectorShard {
+	s.queued.Add(1)
+	s.mu.Lock()
+	return s
+}
+
+func (s *projectorShard) unlock() {
+	s.mu.Unlock()
+	s.queued.Add(-1)
+}
+
+// ShardStats はシャードの処理待ちの状況。
+type ShardStats struct {
+	Shard int
+	// QueueDepth はロック待ちとロック下で実行中の処理の数。永続化はロックの外で行うので含まない
+	// （永続化の状況はFlushStatsのPendingとFlushingで見る）。
+	QueueDepth int64
+}
+
+// SetShards はシャード数を変更する。投影状態を持つ前（Restoreより前）に呼ぶこと。
+func (p *EntryProjector) SetShards(n int) {
+	p.shards = newProjectorShards(n)
+}
+
+// ShardStats はシャードごとの処理待ちの数を返す。
+func (p *EntryProjector) ShardStats() []ShardStats {
+	stats := make([]ShardStats, len(p.shards))
+	for i, s := range p.shards {
+		stats[i] = ShardStats{Shard: i, QueueDepth: s.queued.Load()}
+	}
+	return stats
+}
+
+// shard はエントリを担当するシャードを返す。
+func (p *EntryProjector) shard(entryID uuid.UUID) *projectorShard {
+	return p.shards[binary.BigEndian.Uint64(entryID[8:])%uint64(len(p.shards))]
+}
+
+// lock はエントリを担当するシャードをロックして返す。解放はunlockで行う。
+func (p *EntryProjector) lock(entryID uuid.UUID) *projectorShard {
+	return p.shard(entryID).lock()
+}
@@ -0,0 +1,173 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// blockingEntryStore は指定エントリの更新をreleaseが閉じられるまで止めるEntryStore。
+type blockingEntryStore struct {
+	*memory.EntryStore
+	blocked uuid.UUID
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingEntryStore) Update(ctx context.Context, id uuid.UUID, fn func(*domain.Entry)) error {
+	if id == s.blocked {
+		s.once.Do(func() { close(s.entered) })
+		<-s.release
+	}
+	return s.EntryStore.Update(ctx, id, fn)
+}
+
+func TestEntryProjector_ShardsProcessConcurrently(t *testing.T) {
+	ctx := context.Background()
+	slowID := uuid.New()
+	store := &blockingEntryStore{
+		EntryStore: memory.NewEntryStore(),
+		blocked:    slowID,
+		entered:    make(chan struct{}),
+		release:    make(chan struct{}),
+	}
+	projector := application.NewEntryProjector(store, memory.NewRGAStateStore(), t.TempDir(), slog.Default())
+	projector.SetShards(4)
+
+	// 遅いエントリとは別のシャードに入るエントリと、同じシャードに入るエントリを選ぶ
+	fastID, neighborID := uuid.New(), uuid.New()
+	for application.ShardOf(projector, fastID) == application.ShardOf(projector, slowID) {
+		fastID = uuid.New()
+	}
+	for application.ShardOf(projector, neighborID) != application.ShardOf(projector, slowID) {
+		neighborID = uuid.New()
+	}
+	now := time.Now().UTC()
+	for _, id := range []uuid.UUID{slowID, fastID, neighborID} {
+		if err := store.Save(ctx, domain.Entry{ID: id, CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		projector.Apply(ctx, slowID, makeInsertPayload(t, uuid.New(), 1, "a", nil))
+	}()
+	<-store.entered
+
+	// 永続化はシャードのロックの外で行うので、処理待ちではなく永続化待ちとして見える
+	if depth := projector.ShardStats()[application.ShardOf(projector, slowID)].QueueDepth; depth != 0 {
+		t.Errorf("遅いエントリのシャードの処理待ち: got %d, want 0", depth)
+	}
+	if flushing := projector.FlushStats().Flushing; flushing != 1 {
+		t.Errorf("永続化中: got %d, want 1", flushing)
+	}
+
+	for _, id := range []uuid.UUID{fastID, neighborID} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			projector.Apply(ctx, id, makeInsertPayload(t, uuid.New(), 1, "b", nil))
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("エントリ%sの適用が遅いエントリの書き込みに止められた", id)
+		}
+	}
+
+	close(store.release)
+	<-slowDone
+	for _, s := range projector.ShardStats() {
+		if s.QueueDepth != 0 {
+			t.Errorf("shard %d: QueueDepth=%d, want 0", s.Shard, s.QueueDepth)
+		}
+	}
+}
+
+func TestEntryProjector_SameEntryIsSerialized(t *testing.T) {
+	projector, entryStore, _, entryID := newWriteBehindProjector(t, 0, 0)
+	projector.SetShards(4)
+
+	// 同じエントリへの並行な適用が取りこぼされないこと
+	siteID := uuid.New()
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			projector.Apply(context.Background(), entryID, makeInsertPayload(t, siteID, uint64(i+1), "x", nil))
+		}()
+	}
+	wg.Wait()
+
+	entry, err := entryStore.FindByID(context.Background(), entryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len([]rune(entry.Text)); got != 50 {
+		t.Errorf("len(Text): got %d, want 50", got)
+	}
+}
+
+func TestEntryProjector_TagOpPersistsOutsideShardLock(t *testing.T) {
+	ctx := context.Background()
+	slowID := uuid.New()
+	store := &blockingEntryStore{
+		EntryStore: memory.NewEntryStore(),
+		blocked:    slowID,
+		entered:    make(chan struct{}),
+		release:    make(chan struct{}),
+	}
+	projector := application.NewEntryProjector(store, memory.NewRGAStateStore(), t.TempDir(), slog.Default())
+	projector.SetShards(4)
+	neighborID := uuid.New()
+	for application.ShardOf(projector, neighborID) != application.ShardOf(projector, slowID) {
+		neighborID = uuid.New()
+	}
+	now := time.Now().UTC()
+	for _, id := range []uuid.UUID{slowID, neighborID} {
+		if err := store.Save(ctx, domain.Entry{ID: id, CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ops := projector.PrepareTagOps(slowID, []string{"go"}, nil)
+	payload, _ := json.Marshal(ops[0])
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		projector.ApplyTagOp(ctx, slowID, payload)
+	}()
+	<-store.entered
+
+	// タグの永続化中も、同じシャードの他のエントリの適用は止まらない
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		projector.Apply(ctx, neighborID, makeInsertPayload(t, uuid.New(), 1, "b", nil))
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("タグの永続化が同じシャードの適用を止めた")
+	}
+
+	close(store.release)
+	<-slowDone
+	if entry, _ := store.FindByID(ctx, slowID); !slices.Equal(entry.Tags, []string{"go"}) {
+		t.Errorf("Tags: got %v, want [go]", entry.Tags)
+	}
+}
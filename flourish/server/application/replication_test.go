@@ -0,0 +1,271 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// replicaNode はテスト用の1台分のサーバー。
+type replicaNode struct {
+	entryStore  *memory.EntryStore
+	eventStore  *memory.EventStore
+	syncService *application.SyncService
+	projector   *application.EntryProjector
+}
+
+func newReplicaNode(t *testing.T) *replicaNode {
+	n := &replicaNode{entryStore: memory.NewEntryStore(), eventStore: memory.NewEventStore()}
+	n.syncService = application.NewSyncService(n.eventStore)
+	n.projector = application.NewEntryProjector(n.entryStore, newMockRGAStateStore(), t.TempDir(), slog.Default())
+	return n
+}
+
+func TestReplicator_TailsLeaderAndProjects(t *testing.T) {
+	ctx := context.Background()
+	leader, follower := newReplicaNode(t), newReplicaNode(t)
+	replicator := application.NewReplicator(application.NewLogSource(leader.eventStore), follower.eventStore, follower.entryStore, follower.syncService, follower.projector, slog.Default())
+
+	entry, err := application.NewEntryService(leader.syncService, leader.entryStore).Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siteID := uuid.New()
+	if _, err := leader.syncService.HandleOp(ctx, entry.ID, siteID, uuid.New(), makeInsertPayload(t, siteID, 1, "a", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := replicator.Sync(ctx); err != nil || n != 2 {
+		t.Fatalf("sync: got %d, %v", n, err)
+	}
+
+	sub := &mockSubscriber{}
+	follower.syncService.Subscribe(entry.ID, sub)
+	if _, err := leader.syncService.HandleOp(ctx, entry.ID, siteID, uuid.New(), makeInsertPayload(t, siteID, 2, "b", &struct {
+		SiteID    uuid.UUID
+		Timestamp uint64
+	}{siteID, 1})); err != nil {
+		t.Fatal(err)
+	}
+	if err := application.NewLockService(leader.syncService, leader.entryStore).SetLocked(ctx, entry.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := replicator.Sync(ctx); err != nil || n != 2 {
+		t.Fatalf("second sync: got %d, %v", n, err)
+	}
+
+	want, _ := leader.eventStore.ListAfter(ctx, entry.ID, 0)
+	got, _ := follower.eventStore.ListAfter(ctx, entry.ID, 0)
+	if len(got) != len(want) {
+		t.Fatalf("events: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ServerSeq != want[i].ServerSeq || got[i].RequestID != want[i].RequestID || !got[i].CreatedAt.Equal(want[i].CreatedAt) {
+			t.Errorf("event %d should be replicated verbatim: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	projected, err := follower.entryStore.FindByID(ctx, entry.ID)
+	if err != nil || projected.Text != "ab" || !projected.Locked {
+		t.Errorf("follower projection: got %+v, %v", projected, err)
+	}
+	if msgs := sub.Messages(); len(msgs) != 1 || len(msgs[0].Ops) != 1 || msgs[0].Ops[0].ServerSeq != 3 {
+		t.Errorf("follower subscribers should receive replicated ops: got %+v", msgs)
+	}
+	if notes := sub.Notifications(); len(notes) != 1 || notes[0].Type != application.NotifyEntryLock || !notes[0].Locked {
+		t.Errorf("follower subscribers should receive replicated lock: got %+v", notes)
+	}
+
+	// リーダーで完全削除されたエントリはフォロワーからも消える
+	trash := application.NewTrashService(leader.syncService, leader.projector, leader.entryStore, leader.eventStore, 0, slog.Default())
+	if err := trash.Delete(ctx, entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := trash.Purge(ctx, entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replicator.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := follower.entryStore.FindByID(ctx, entry.ID); !errors.Is(err, domain.ErrEntryNotFound) {
+		t.Errorf("purged entry should be removed from follower: got %v", err)
+	}
+	if stats := replicator.Stats(); stats.Replicated != 4 || stats.Purged != 1 || stats.Errors != 0 {
+		t.Errorf("stats: got %+v", stats)
+	}
+}
+
+func TestReplicator_PurgesOnlyWhatLeaderPurged(t *testing.T) {
+	ctx := context.Background()
+	leader, follower := newReplicaNode(t), newReplicaNode(t)
+	replicator := application.NewReplicator(application.NewLogSource(leader.eventStore), follower.eventStore, follower.entryStore, follower.syncService, follower.projector, slog.Default())
+
+	// データディレクトリを取り違えた空のリーダーに追従しても、フォロワーのデータは消さない
+	local := uuid.New()
+	appendOps(t, follower.syncService, local, 1)
+	if _, err := replicator.Sync(ctx); !errors.Is(err, domain.ErrLeaderLogEmpty) {
+		t.Errorf("empty leader: got %v, want ErrLeaderLogEmpty", err)
+	}
+	if seq, _ := follower.eventStore.MaxServerSeq(ctx, local); seq != 1 {
+		t.Errorf("follower entry should survive an empty leader: got seq %d", seq)
+	}
+	if stats := replicator.Stats(); stats.Diverged != 1 || stats.Purged != 0 {
+		t.Errorf("stats after empty leader: got %+v", stats)
+	}
+
+	// リーダーに無いだけのエントリは削除せず、divergenceとして残す
+	appendOps(t, leader.syncService, uuid.New(), 1)
+	if _, err := replicator.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if seq, _ := follower.eventStore.MaxServerSeq(ctx, local); seq != 1 {
+		t.Errorf("entry without a purge record should be kept: got seq %d", seq)
+	}
+	if stats := replicator.Stats(); stats.Diverged != 1 || stats.Purged != 0 {
+		t.Errorf("stats after unknown entry: got %+v", stats)
+	}
+
+	// リーダーが完全削除を記録していれば削除する
+	if err := leader.eventStore.Purge(ctx, local); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replicator.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if seq, _ := follower.eventStore.MaxServerSeq(ctx, local); seq != 0 {
+		t.Errorf("entry purged on leader should be removed: got seq %d", seq)
+	}
+	if stats := replicator.Stats(); stats.Diverged != 0 || stats.Purged != 1 {
+		t.Errorf("stats after purge: got %+v", stats)
+	}
+}
+
+func TestReplicator_PromoteStopsReplication(t *testing.T) {
+	ctx := context.Background()
+	leader, follower := newReplicaNode(t), newReplicaNode(t)
+	replicator := application.NewReplicator(application.NewLogSource(leader.eventStore), follower.eventStore, follower.entryStore, follower.syncService, follower.projector, slog.Default())
+	promoted := 0
+	replicator.SetOnPromote(func() error { promoted++; return nil })
+
+	if !replicator.ReadOnly() {
+		t.Error("follower should be read-only until promoted")
+	}
+	if err := replicator.Promote(); err != nil {
+		t.Fatal(err)
+	}
+	if replicator.ReadOnly() || promoted != 1 {
+		t.Errorf("promote: read-only %v, hook called %d times", replicator.ReadOnly(), promoted)
+	}
+	appendOps(t, leader.syncService, uuid.New(), 1)
+	if n, err := replicator.Sync(ctx); err != nil || n != 0 {
+		t.Errorf("promoted server should not replicate: got %d, %v", n, err)
+	}
+}
+
+func TestCompareLogs_ReportsDivergence(t *testing.T) {
+	ctx := context.Background()
+	leader, follower := newReplicaNode(t), newReplicaNode(t)
+	replicator := application.NewReplicator(application.NewLogSource(leader.eventStore), follower.eventStore, follower.entryStore, follower.syncService, follower.projector, slog.Default())
+
+	inSync, behind, forked := uuid.New(), uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{inSync, behind, forked} {
+		appendOps(t, leader.syncService, id, 2)
+	}
+	if _, err := replicator.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	appendOps(t, leader.syncService, behind, 1)
+	appendOps(t, follower.syncService, forked, 1) // フォロワーで直接書き込まれた
+	missing := uuid.New()
+	appendOps(t, leader.syncService, missing, 1)
+
+	found, err := application.CompareLogs(ctx, application.NewLogSource(leader.eventStore), application.NewLogSource(follower.eventStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[uuid.UUID]string)
+	for _, d := range found {
+		kinds[d.EntryID] = d.Kind
+	}
+	want := map[uuid.UUID]string{behind: application.DivergenceBehind, forked: application.DivergenceAhead, missing: application.DivergenceMissing}
+	if len(kinds) != len(want) {
+		t.Fatalf("divergences: got %v", found)
+	}
+	for id, kind := range want {
+		if kinds[id] != kind {
+			t.Errorf("entry %s: got %q, want %q", id, kinds[id], kind)
+		}
+	}
+
+	// 同じserver_seqに別のイベントが入っていれば不一致
+	appendOps(t, leader.syncService, forked, 1)
+	found, _ = application.CompareLogs(ctx, application.NewLogSource(leader.eventStore), application.NewLogSource(follower.eventStore))
+	i := slices.IndexFunc(found, func(d application.Divergence) bool { return d.EntryID == forked })
+	if i < 0 || found[i].Kind != application.DivergenceMismatch || found[i].ServerSeq != 3 || !found[i].Broken() {
+		t.Errorf("forked entry should be reported as mismatch: got %v", found)
+	}
+}
+
+// fullReadCountingStore はログ全体の読み出し（afterSeq=0のListAfter）を数えるEventStore。
+type fullReadCountingStore struct {
+	*memory.EventStore
+	fullReads int
+}
+
+func (s *fullReadCountingStore) ListAfter(ctx context.Context, entryID uuid.UUID, afterSeq int64) ([]domain.Event, error) {
+	if afterSeq == 0 {
+		s.fullReads++
+	}
+	return s.EventStore.ListAfter(ctx, entryID, afterSeq)
+}
+
+func TestReplicator_AppliesOnlyNewEvents(t *testing.T) {
+	ctx := context.Background()
+	leader, follower := newReplicaNode(t), newReplicaNode(t)
+	store := &fullReadCountingStore{EventStore: follower.eventStore}
+	replicator := application.NewReplicator(application.NewLogSource(leader.eventStore), store, follower.entryStore, follower.syncService, follower.projector, slog.Default())
+
+	entry, err := application.NewEntryService(leader.syncService, leader.entryStore).Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siteID := uuid.New()
+	var after *struct {
+		SiteID    uuid.UUID
+		Timestamp uint64
+	}
+	for i, v := range []string{"a", "b", "c"} {
+		ts := uint64(i + 1)
+		if _, err := leader.syncService.HandleOp(ctx, entry.ID, siteID, uuid.New(), makeInsertPayload(t, siteID, ts, v, after)); err != nil {
+			t.Fatal(err)
+		}
+		after = &struct {
+			SiteID    uuid.UUID
+			Timestamp uint64
+		}{siteID, ts}
+		if _, err := replicator.Sync(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := application.NewLockService(leader.syncService, leader.entryStore).SetLocked(ctx, entry.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replicator.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 作成を含む最初の複製だけログ全体から投影し、以降は新しいイベントだけを適用する
+	if store.fullReads != 1 {
+		t.Errorf("ログ全体の再生回数: got %d, want 1", store.fullReads)
+	}
+	projected, err := follower.entryStore.FindByID(ctx, entry.ID)
+	if err != nil || projected.Text != "abc" || !projected.Locked {
+		t.Errorf("follower projection: got %+v, %v", projected, err)
+	}
+}
@@ -0,0 +1,296 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+	"flourish/server/domain/crdt"
+)
+
+// SuggestionStatus は提案セットの状態。
+type SuggestionStatus string
+
+const (
+	SuggestionOpen     SuggestionStatus = "open"
+	SuggestionAccepted SuggestionStatus = "accepted"
+	SuggestionRejected SuggestionStatus = "rejected"
+)
+
+// suggestionSet はsuggestionフラグ付きopのまとまり。
+// 提案insertはRGAに挿入されるが採用まで公開テキストから除外され、
+// 提案deleteは採用されるまでRGAに適用せず対象ノードだけを保持する。
+type suggestionSet struct {
+	status  SuggestionStatus
+	deletes []crdt.NodeID
+}
+
+// suggestionDecision はEventSuggestionとしてイベントログに記録される採否。
+type suggestionDecision struct {
+	RequestID  uuid.UUID        `json:"request_id"`
+	Suggestion uuid.UUID        `json:"suggestion"`
+	Status     SuggestionStatus `json:"status"`
+}
+
+// SuggestionView は提案セットの表示用情報。
+type SuggestionView struct {
+	ID       uuid.UUID
+	Status   SuggestionStatus
+	Inserted string
+	Deleted  string
+	// Offset は提案を含む全文上の最初の変更位置（rune単位）。変更が無ければ-1。
+	Offset        int
+	InsertNodeIDs []crdt.NodeID
+	DeleteNodeIDs []crdt.NodeID
+}
+
+// opの適用を拒否した理由
+var (
+	errUnauthenticatedDelete = errors.New("unauthenticated delete of authenticated node")
+	errSuggestionClosed      = errors.New("suggestion already closed")
+)
+
+// applyOp はopをRGAまたは提案セットに適用する。ロック保持前提。
+// ライブの適用と再生の両方がここを通るので、拒否の判定はどちらでも同じになる。
+// 非認証deleteによる認証ノードの削除はerrUnauthenticatedDelete、
+// クローズ済みの提案セットに属するopはerrSuggestionClosedを返して適用しない。
+// 拒否したopは記録し、GetDiffの差分から除く。
+func (p *EntryProjector) applyOp(entryID uuid.UUID, rga *crdt.RGA, op crdt.Operation) error {
+	err := p.applyOpToState(entryID, rga, op)
+	if err != nil {
+		s := p.shard(entryID)
+		if s.rejected[entryID] == nil {
+			s.rejected[entryID] = make(map[uuid.UUID]struct{})
+		}
+		s.rejected[entryID][op.RequestID] = struct{}{}
+	}
+	return err
+}
+
+// applyOpToState はapplyOpの判定と適用を行う。ロック保持前提。
+func (p *EntryProjector) applyOpToState(entryID uuid.UUID, rga *crdt.RGA, op crdt.Operation) error {
+	if op.Suggestion == uuid.Nil {
+		if op.OpType == crdt.OpDelete && !op.Authenticated && rga.IsNodeAuthenticated(op.NodeID) {
+			return errUnauthenticatedDelete
+		}
+		rga.Apply(op)
+		return nil
+	}
+
+	// 提案deleteは対象を消さずに提案セットに記録するだけなので認証チェックの対象外
+	set := p.suggestionSet(entryID, op.Suggestion)
+	if set.status != SuggestionOpen {
+		return errSuggestionClosed
+	}
+	if op.OpType == crdt.OpDelete {
+		if !slices.Contains(set.deletes, op.NodeID) {
+			set.deletes = append(set.deletes, op.NodeID)
+		}
+		return nil
+	}
+	rga.Apply(op)
+	return nil
+}
+
+// rejectedOps は適用を拒否したopのrequest_idの複製を返す。
+func (p *EntryProjector) rejectedOps(entryID uuid.UUID) map[uuid.UUID]struct{} {
+	s := p.lock(entryID)
+	defer s.unlock()
+	return maps.Clone(s.rejected[entryID])
+}
+
+// suggestionSet はエントリの提案セットを返す（無ければopenで作成）。ロック保持前提。
+func (p *EntryProjector) suggestionSet(entryID, suggestionID uuid.UUID) *suggestionSet {
+	s := p.shard(entryID)
+	sets, ok := s.suggestions[entryID]
+	if !ok {
+		sets = make(map[uuid.UUID]*suggestionSet)
+		s.suggestions[entryID] = sets
+	}
+	set, ok := sets[suggestionID]
+	if !ok {
+		set = &suggestionSet{status: SuggestionOpen}
+		sets[suggestionID] = set
+	}
+	return set
+}
+
+// suggestedNodes は採用されていない提案insertのノード集合を返す。ロック保持前提。
+func (p *EntryProjector) suggestedNodes(entryID uuid.UUID, rga *crdt.RGA) map[crdt.NodeID]struct{} {
+	hidden := make(map[crdt.NodeID]struct{})
+	sets := p.shard(entryID).suggestions[entryID]
+	if len(sets) == 0 {
+		return hidden
+	}
+	for _, n := range rga.VisibleNodes() {
+		if n.Suggestion == uuid.Nil {
+			continue
+		}
+		if set, ok := sets[n.Suggestion]; ok && set.status == SuggestionAccepted {
+			continue
+		}
+		hidden[n.ID] = struct{}{}
+	}
+	return hidden
+}
+
+// Suggestions はエントリの提案セット一覧を返す。
+func (p *EntryProjector) Suggestions(entryID uuid.UUID) []SuggestionView {
+	s := p.lock(entryID)
+	defer s.unlock()
+
+	sets := s.suggestions[entryID]
+	if len(sets) == 0 {
+		return nil
+	}
+	var nodes []crdt.NodeInfo
+	if rga, ok := s.rgas[entryID]; ok {
+		nodes = rga.VisibleNodes()
+	}
+
+	views := make([]SuggestionView, 0, len(sets))
+	for id, set := range sets {
+		v := SuggestionView{ID: id, Status: set.status, Offset: -1, DeleteNodeIDs: slices.Clone(set.deletes)}
+		var inserted, deleted []rune
+		for i, n := range nodes {
+			switch {
+			case n.Suggestion == id:
+				inserted = append(inserted, n.Value)
+				v.InsertNodeIDs = append(v.InsertNodeIDs, n.ID)
+			case slices.Contains(set.deletes, n.ID):
+				deleted = append(deleted, n.Value)
+			default:
+				continue
+			}
+			if v.Offset < 0 {
+				v.Offset = i
+			}
+		}
+		v.Inserted = string(inserted)
+		v.Deleted = string(deleted)
+		views = append(views, v)
+	}
+	slices.SortFunc(views, func(a, b SuggestionView) int {
+		return a.Offset - b.Offset
+	})
+	return views
+}
+
+// ApplySuggestionDecision は採否イベントを適用し、Entryを再投影する。
+func (p *EntryProjector) ApplySuggestionDecision(ctx context.Context, entryID uuid.UUID, payload []byte) bool {
+	s := p.lock(entryID)
+	err := p.applySuggestionDecision(entryID, payload)
+	s.unlock()
+	if err != nil {
+		p.log.Error("projector: suggestion変換失敗", "entryID", entryID, "error", err)
+		return false
+	}
+
+	p.reproject(ctx, entryID)
+	return true
+}
+
+// applySuggestionDecision は採否を提案セットに反映する。ロック保持前提。
+func (p *EntryProjector) applySuggestionDecision(entryID uuid.UUID, payload []byte) error {
+	var d suggestionDecision
+	if err := json.Unmarshal(payload, &d); err != nil {
+		return fmt.Errorf("unmarshal suggestion: %w", err)
+	}
+	if d.Status != SuggestionAccepted && d.Status != SuggestionRejected {
+		return fmt.Errorf("unknown suggestion status: %q", d.Status)
+	}
+	p.suggestionSet(entryID, d.Suggestion).status = d.Status
+	return nil
+}
+
+// SuggestionService は提案セットの採用・却下を担う。
+type SuggestionService struct {
+	syncService *SyncService
+	projector   *EntryProjector
+}
+
+func NewSuggestionService(syncService *SyncService, projector *EntryProjector) *SuggestionService {
+	return &SuggestionService{syncService: syncService, projector: projector}
+}
+
+// List はエントリの提案セット一覧を返す。
+func (s *SuggestionService) List(entryID uuid.UUID) []SuggestionView {
+	return s.projector.Suggestions(entryID)
+}
+
+// Accept は提案を採用する。提案insertは公開テキストに昇格し、提案deleteは認証済みdelete opとして適用される。
+func (s *SuggestionService) Accept(ctx context.Context, entryID, suggestionID uuid.UUID) error {
+	view, err := s.close(ctx, entryID, suggestionID, SuggestionAccepted)
+	if err != nil {
+		return err
+	}
+	return deleteNodes(ctx, s.syncService, s.projector, entryID, view.DeleteNodeIDs)
+}
+
+// Reject は提案を却下する。提案insertは認証済みdelete opでトゥームストーン化される。
+func (s *SuggestionService) Reject(ctx context.Context, entryID, suggestionID uuid.UUID) error {
+	view, err := s.close(ctx, entryID, suggestionID, SuggestionRejected)
+	if err != nil {
+		return err
+	}
+	return deleteNodes(ctx, s.syncService, s.projector, entryID, view.InsertNodeIDs)
+}
+
+// close は提案セットの採否をイベントログに記録して適用し、クローズ前の内容を返す。
+func (s *SuggestionService) close(ctx context.Context, entryID, suggestionID uuid.UUID, status SuggestionStatus) (SuggestionView, error) {
+	if err := ensureUnlocked(ctx, s.projector.entryStore, entryID); err != nil {
+		return SuggestionView{}, err
+	}
+	views := s.List(entryID)
+	idx := slices.IndexFunc(views, func(v SuggestionView) bool { return v.ID == suggestionID })
+	if idx < 0 {
+		return SuggestionView{}, domain.ErrSuggestionNotFound
+	}
+	view := views[idx]
+	if view.Status != SuggestionOpen {
+		return SuggestionView{}, domain.ErrSuggestionClosed
+	}
+
+	d := suggestionDecision{RequestID: uuid.New(), Suggestion: suggestionID, Status: status}
+	payload, err := json.Marshal(d)
+	if err != nil {
+		return SuggestionView{}, fmt.Errorf("marshal suggestion: %w", err)
+	}
+	seq, err := s.syncService.AppendEvent(ctx, entryID, d.RequestID, domain.EventSuggestion, payload)
+	if err != nil {
+		return SuggestionView{}, err
+	}
+	if seq > 0 {
+		s.projector.ApplySuggestionDecision(ctx, entryID, payload)
+		s.syncService.Notify(entryID, Notification{Type: NotifySuggestion, Suggestion: suggestionID, Status: status})
+		s.syncService.Notify(entryID, Notification{Type: NotifyPublicText, ServerSeq: seq})
+	}
+	return view, nil
+}
+
+// SuggestionDecisions はafterSeqより後に記録された提案セットの採否を、suggestion通知として返す。
+// 購読を始めたクライアントはsyncの後にこれを受け取り、採否の決まった提案を片付ける。
+func (s *SyncService) SuggestionDecisions(ctx context.Context, entryID uuid.UUID, afterSeq int64) ([]Notification, error) {
+	events, err := s.eventStore.ListAfter(ctx, entryID, afterSeq)
+	if err != nil {
+		return nil, err
+	}
+	var notes []Notification
+	for _, ev := range events {
+		if ev.EventType != domain.EventSuggestion {
+			continue
+		}
+		var d suggestionDecision
+		if err := json.Unmarshal(ev.Payload, &d); err != nil {
+			continue
+		}
+		notes = append(notes, Notification{Type: NotifySuggestion, EntryID: entryID, Suggestion: d.Suggestion, Status: d.Status})
+	}
+	return notes, nil
+}
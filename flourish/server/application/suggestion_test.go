@@ -0,0 +1,147 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+	"flourish/server/domain/crdt"
+)
+
+// setupSuggestion は "abc" の本文に、"b" の削除と "X" の挿入からなる提案セットを作る。
+func setupSuggestion(t *testing.T) (*application.SuggestionService, *memory.EntryStore, *memory.EventStore, uuid.UUID, uuid.UUID) {
+	t.Helper()
+	entryStore := memory.NewEntryStore()
+	eventStore := memory.NewEventStore()
+	syncService := application.NewSyncService(eventStore)
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+
+	entry := domain.NewEntry()
+	entryStore.Save(context.Background(), entry)
+
+	author := crdt.NewRGA(uuid.New())
+	a := author.Insert(nil, 'a')
+	b := author.Insert(&a.NodeID, 'b')
+	c := author.Insert(&b.NodeID, 'c')
+	for _, op := range []crdt.Operation{a, b, c} {
+		op.Authenticated = true
+		submit(t, syncService, projector, entry.ID, op)
+	}
+
+	suggestionID := uuid.New()
+	reviewer := uuid.New()
+	submit(t, syncService, projector, entry.ID, crdt.Operation{
+		RequestID:     uuid.New(),
+		OpType:        crdt.OpDelete,
+		NodeID:        b.NodeID,
+		Authenticated: true,
+		Suggestion:    suggestionID,
+	})
+	submit(t, syncService, projector, entry.ID, crdt.Operation{
+		RequestID:     uuid.New(),
+		OpType:        crdt.OpInsert,
+		NodeID:        crdt.NodeID{ReplicaID: reviewer, Timestamp: 10},
+		After:         &a.NodeID,
+		Value:         'X',
+		Authenticated: true,
+		Suggestion:    suggestionID,
+	})
+
+	return application.NewSuggestionService(syncService, projector), entryStore, eventStore, entry.ID, suggestionID
+}
+
+func TestSuggestion_ExcludedFromText(t *testing.T) {
+	svc, entryStore, _, entryID, suggestionID := setupSuggestion(t)
+
+	entry, _ := entryStore.FindByID(context.Background(), entryID)
+	if entry.Text != "abc" {
+		t.Errorf("提案は本文に反映されないべき: got %q", entry.Text)
+	}
+
+	views := svc.List(entryID)
+	if len(views) != 1 {
+		t.Fatalf("提案セットが1件であるべき: got %d", len(views))
+	}
+	v := views[0]
+	if v.ID != suggestionID || v.Status != application.SuggestionOpen || v.Inserted != "X" || v.Deleted != "b" || v.Offset != 1 {
+		t.Errorf("view: got %+v", v)
+	}
+}
+
+func TestSuggestion_Accept(t *testing.T) {
+	svc, entryStore, eventStore, entryID, suggestionID := setupSuggestion(t)
+	ctx := context.Background()
+
+	if err := svc.Accept(ctx, entryID, suggestionID); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, _ := entryStore.FindByID(ctx, entryID)
+	if entry.Text != "aXc" {
+		t.Errorf("採用後は提案が本文に反映されるべき: got %q", entry.Text)
+	}
+	if err := svc.Accept(ctx, entryID, suggestionID); !errors.Is(err, domain.ErrSuggestionClosed) {
+		t.Errorf("二重採用はErrSuggestionClosed: got %v", err)
+	}
+
+	// 採用はイベントログから復元できる
+	restored := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	if err := restored.Restore(ctx, eventStore, []uuid.UUID{entryID}); err != nil {
+		t.Fatal(err)
+	}
+	entry, _ = entryStore.FindByID(ctx, entryID)
+	if entry.Text != "aXc" {
+		t.Errorf("復元後: got %q", entry.Text)
+	}
+}
+
+func TestSuggestion_Reject(t *testing.T) {
+	svc, entryStore, _, entryID, suggestionID := setupSuggestion(t)
+	ctx := context.Background()
+
+	if err := svc.Reject(ctx, entryID, suggestionID); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, _ := entryStore.FindByID(ctx, entryID)
+	if entry.Text != "abc" {
+		t.Errorf("却下後は元の本文のまま: got %q", entry.Text)
+	}
+	views := svc.List(entryID)
+	if len(views) != 1 || views[0].Status != application.SuggestionRejected || views[0].Inserted != "" {
+		t.Errorf("却下された提案insertはトゥームストーン化されるべき: got %+v", views)
+	}
+
+	if err := svc.Reject(ctx, entryID, uuid.New()); !errors.Is(err, domain.ErrSuggestionNotFound) {
+		t.Errorf("存在しない提案はErrSuggestionNotFound: got %v", err)
+	}
+}
+
+func TestSuggestion_DecisionsForSubscribers(t *testing.T) {
+	svc, _, eventStore, entryID, suggestionID := setupSuggestion(t)
+	ctx := context.Background()
+	syncService := application.NewSyncService(eventStore)
+
+	if notes, _ := syncService.SuggestionDecisions(ctx, entryID, 0); len(notes) != 0 {
+		t.Errorf("未決の提案には採否が無いべき: got %+v", notes)
+	}
+	if err := svc.Accept(ctx, entryID, suggestionID); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := syncService.SuggestionDecisions(ctx, entryID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Type != application.NotifySuggestion || notes[0].Suggestion != suggestionID || notes[0].Status != application.SuggestionAccepted {
+		t.Errorf("購読開始時に採否を伝えるべき: got %+v", notes)
+	}
+	latest, _ := eventStore.MaxServerSeq(ctx, entryID)
+	if notes, _ := syncService.SuggestionDecisions(ctx, entryID, latest); len(notes) != 0 {
+		t.Errorf("配信済みの採否は返さないべき: got %+v", notes)
+	}
+}
@@ -3,6 +3,8 @@ package application
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
@@ -10,6 +12,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"flourish/server/domain"
+	"flourish/server/domain/crdt"
 )
 
 var tracer = otel.Tracer("flourish/sync")
@@ -17,6 +20,37 @@ var tracer = otel.Tracer("flourish/sync")
 // Subscriber はsyncメッセージの受信者。
 type Subscriber interface {
 	Send(msg SyncMessage)
+	// Notify はop以外のエントリ状態の変化を受け取る。
+	Notify(n Notification)
+}
+
+// NotificationType はNotificationの種別。
+type NotificationType string
+
+const (
+	NotifyEntryLock   NotificationType = "entry_lock"
+	NotifyEntryCreate NotificationType = "entry_create"
+	NotifyEntryDelete NotificationType = "entry_delete"
+	// NotifyEntryUpdate はエントリ一覧の購読者だけに届く、opによる更新の通知。
+	NotifyEntryUpdate NotificationType = "entry_update"
+	// NotifySuggestion は提案セットの採否の通知。クライアントは提案opを表示用に分けて持ち、採否で片付ける。
+	NotifySuggestion NotificationType = "suggestion"
+	// NotifyPublicText は承認や提案の採否で、新しいopを伴わずに公開用テキストが変わったことの通知。
+	// 投影に反映してから送るので、受け取った購読者は公開用テキストを読み直せばよい。
+	NotifyPublicText NotificationType = "public_text"
+)
+
+// Notification はop以外のエントリ状態の変化（ロック・削除など）の通知。
+type Notification struct {
+	Type    NotificationType `json:"type"`
+	EntryID uuid.UUID        `json:"entry_id"`
+	Locked  bool             `json:"locked,omitempty"`
+	Deleted bool             `json:"deleted,omitempty"`
+	// ServerSeq はentry_update・public_textのときの最新のserver_seq。
+	ServerSeq int64 `json:"server_seq,omitempty"`
+	// Suggestion・Status はsuggestionのときの提案セットと採否。
+	Suggestion uuid.UUID        `json:"suggestion,omitempty"`
+	Status     SuggestionStatus `json:"status,omitempty"`
 }
 
 // SyncOp はsyncメッセージ内の個別オペレーション。
@@ -24,6 +58,9 @@ type SyncOp struct {
 	RequestID uuid.UUID `json:"request_id"`
 	ServerSeq int64     `json:"server_seq"`
 	Payload   []byte    `json:"payload"`
+	// Op はPayloadを解釈したop。購読者ごとに再解釈しないよう、配信前に一度だけ設定する。
+	// 解釈できないpayloadならnil。
+	Op *crdt.Operation `json:"-"`
 }
 
 // SyncMessage はクライアントに配信するsyncメッセージ。
@@ -45,13 +82,50 @@ type SyncService struct {
 	eventStore  domain.EventStore
 	mu          sync.RWMutex
 	subscribers map[uuid.UUID][]Subscriber // entryID -> subscribers
+
+	// 配信はbusを通して全インスタンスに届け、エントリごとにserver_seq順に購読者へ渡す
+	bus         Bus
+	cursors     map[uuid.UUID]*entryCursor
+	gapCatchUps atomic.Uint64
+	ops         *opCache
+
+	// projector はGetDiffから拒否されたopを除くのに使う（nilなら除かない）
+	projector *EntryProjector
+
+	// エントリ一覧の購読者（muで保護）とentry_updateの間引き状態
+	listSubscribers map[Subscriber]struct{}
+	listMu          sync.Mutex
+	listInterval    time.Duration
+	listUpdates     map[uuid.UUID]*listUpdate
+
+	// 購読者ごとの送信キュー
+	outboxConfig OutboxConfig
+	outboxes     map[*Outbox]struct{}
+	coalesced    atomic.Uint64
+	overflows    atomic.Uint64
+	disconnects  atomic.Uint64
 }
 
 func NewSyncService(eventStore domain.EventStore) *SyncService {
-	return &SyncService{
-		eventStore:  eventStore,
-		subscribers: make(map[uuid.UUID][]Subscriber),
+	s := &SyncService{
+		eventStore:      eventStore,
+		subscribers:     make(map[uuid.UUID][]Subscriber),
+		cursors:         make(map[uuid.UUID]*entryCursor),
+		ops:             newOpCache(),
+		listSubscribers: make(map[Subscriber]struct{}),
+		listInterval:    DefaultListUpdateInterval,
+		listUpdates:     make(map[uuid.UUID]*listUpdate),
+		outboxConfig:    DefaultOutboxConfig,
+		outboxes:        make(map[*Outbox]struct{}),
 	}
+	s.bus = NewLocalBus()
+	s.bus.Listen(s.receive)
+	return s
+}
+
+// SetProjector はopを適用するprojectorを設定する。GetDiffはprojectorが拒否したopを返さなくなる。
+func (s *SyncService) SetProjector(projector *EntryProjector) {
+	s.projector = projector
 }
 
 // Subscribe はエントリのsyncメッセージを購読する。
@@ -72,6 +146,7 @@ func (s *SyncService) Unsubscribe(entryID uuid.UUID, sub Subscriber) {
 			break
 		}
 	}
+	s.dropCursor(entryID)
 }
 
 // HandleOp はopを受信し、重複検知→永続化→ACK返却する。broadcastは別途Broadcastを呼ぶ。
@@ -111,14 +186,84 @@ func (s *SyncService) HandleOp(ctx context.Context, entryID, siteID, requestID u
 	}, nil
 }
 
-// Broadcast はsyncメッセージを全subscriberに配信する。
+// AppendEvent はCRDT op以外のイベントを永続化し、server_seqを返す（重複時は0）。
+func (s *SyncService) AppendEvent(ctx context.Context, entryID, requestID uuid.UUID, eventType domain.EventType, payload []byte) (int64, error) {
+	ctx, span := tracer.Start(ctx, "SyncService.AppendEvent",
+		trace.WithAttributes(
+			attribute.String("osot.entry_id", entryID.String()),
+			attribute.String("osot.request_id", requestID.String()),
+			attribute.String("osot.event_type", string(eventType)),
+		),
+	)
+	defer span.End()
+
+	serverSeq, err := s.eventStore.Append(ctx, domain.Event{
+		EntryID:   entryID,
+		RequestID: requestID,
+		EventType: eventType,
+		Payload:   payload,
+	})
+	if err != nil {
+		span.RecordError(err)
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int64("osot.server_seq", serverSeq))
+	if serverSeq > 0 {
+		// syncを配信しないイベントなので、購読者への配信位置だけ進める
+		msg := BusMessage{Advance: &SeqAdvance{EntryID: entryID, ServerSeq: serverSeq}}
+		// タグ・承認・提案の採否は一覧の表示を変えるので、一覧の購読者に伝える
+		if eventType == domain.EventTagOp || eventType == domain.EventModeration || eventType == domain.EventSuggestion {
+			msg.Notification = &Notification{Type: NotifyEntryUpdate, EntryID: entryID, ServerSeq: serverSeq}
+		}
+		s.bus.Publish(msg)
+	}
+	return serverSeq, nil
+}
+
+// Skip はprojectorが拒否して配信しないopのserver_seqを全インスタンスに伝え、購読者への配信位置を進める。
+func (s *SyncService) Skip(entryID uuid.UUID, serverSeq int64) {
+	s.bus.Publish(BusMessage{Advance: &SeqAdvance{EntryID: entryID, ServerSeq: serverSeq}})
+}
+
+// Broadcast はsyncメッセージを全インスタンスの購読者に配信する。
+// Subscriberは送信キュー（Outbox）に積むだけで返り、遅いクライアントが他の配信を止めないようにする。
 func (s *SyncService) Broadcast(entryID uuid.UUID, msg SyncMessage) {
+	msg.EntryID = entryID
+	s.bus.Publish(BusMessage{Sync: &msg})
+}
+
+// Notify は通知を全インスタンスの購読者に配信する。作成・削除などはエントリ一覧の購読者にも配信する。
+func (s *SyncService) Notify(entryID uuid.UUID, n Notification) {
+	n.EntryID = entryID
+	s.bus.Publish(BusMessage{Notification: &n})
+}
+
+// NotifyAll は通知を全インスタンスの、全エントリの購読者とエントリ一覧の購読者に1回ずつ配信する。
+func (s *SyncService) NotifyAll(n Notification) {
+	s.bus.Publish(BusMessage{Notification: &n, All: true})
+}
+
+// notifyAll はこのインスタンスの全エントリの購読者とエントリ一覧の購読者に1回ずつ通知する。
+func (s *SyncService) notifyAll(n Notification) {
 	s.mu.RLock()
-	subs := s.subscribers[entryID]
+	seen := make(map[Subscriber]struct{})
+	var subs []Subscriber
+	for sub := range s.listSubscribers {
+		seen[sub] = struct{}{}
+		subs = append(subs, sub)
+	}
+	for _, list := range s.subscribers {
+		for _, sub := range list {
+			if _, ok := seen[sub]; !ok {
+				seen[sub] = struct{}{}
+				subs = append(subs, sub)
+			}
+		}
+	}
 	s.mu.RUnlock()
 
 	for _, sub := range subs {
-		sub.Send(msg)
+		sub.Notify(n)
 	}
 }
 
@@ -138,13 +283,26 @@ func (s *SyncService) GetDiff(ctx context.Context, entryID uuid.UUID, afterSeq i
 		return SyncMessage{}, err
 	}
 
-	ops := make([]SyncOp, len(events))
-	for i, e := range events {
-		ops[i] = SyncOp{
+	// CRDT op以外（タグ等）はクライアントのRGAに適用できないので除外する。
+	// projectorが拒否したopも、ライブの配信と同じく送らない
+	var rejected map[uuid.UUID]struct{}
+	if s.projector != nil {
+		rejected = s.projector.rejectedOps(entryID)
+	}
+	ops := make([]SyncOp, 0, len(events))
+	for _, e := range events {
+		if e.EventType != domain.EventCRDTOp {
+			continue
+		}
+		if _, ok := rejected[e.RequestID]; ok {
+			continue
+		}
+		ops = append(ops, SyncOp{
 			RequestID: e.RequestID,
 			ServerSeq: e.ServerSeq,
 			Payload:   e.Payload,
-		}
+			Op:        s.ops.get(entryID, SyncOp{RequestID: e.RequestID, ServerSeq: e.ServerSeq, Payload: e.Payload}),
+		})
 	}
 
 	maxSeq, err := s.eventStore.MaxServerSeq(ctx, entryID)
@@ -15,8 +15,9 @@ import (
 )
 
 type mockSubscriber struct {
-	mu       sync.Mutex
-	messages []application.SyncMessage
+	mu            sync.Mutex
+	messages      []application.SyncMessage
+	notifications []application.Notification
 }
 
 func (s *mockSubscriber) Send(msg application.SyncMessage) {
@@ -25,6 +26,18 @@ func (s *mockSubscriber) Send(msg application.SyncMessage) {
 	s.messages = append(s.messages, msg)
 }
 
+func (s *mockSubscriber) Notify(n application.Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = append(s.notifications, n)
+}
+
+func (s *mockSubscriber) Notifications() []application.Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]application.Notification{}, s.notifications...)
+}
+
 func (s *mockSubscriber) Messages() []application.SyncMessage {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -145,3 +158,75 @@ func TestSyncService_GetDiff(t *testing.T) {
 		t.Errorf("LatestServerSeqは3であるべき: got %d", msg.LatestServerSeq)
 	}
 }
+
+func TestSyncService_BroadcastDecodesOpsOnce(t *testing.T) {
+	svc := application.NewSyncService(memory.NewEventStore())
+	entryID := uuid.New()
+	a, b := &mockSubscriber{}, &mockSubscriber{}
+	svc.Subscribe(entryID, a)
+	svc.Subscribe(entryID, b)
+
+	op := crdt.Operation{RequestID: uuid.New(), OpType: crdt.OpInsert, NodeID: crdt.NodeID{ReplicaID: uuid.New(), Timestamp: 1}, Value: 'x', Authenticated: true}
+	payload, _ := crdt.MarshalPayload(op)
+	ack, err := svc.HandleOp(context.Background(), entryID, op.NodeID.ReplicaID, op.RequestID, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := []application.SyncOp{{RequestID: op.RequestID, ServerSeq: ack.ServerSeq, Payload: payload}}
+	svc.Broadcast(entryID, application.SyncMessage{EntryID: entryID, Ops: ops, LatestServerSeq: ack.ServerSeq})
+
+	got := a.Messages()[0].Ops[0].Op
+	if got == nil || got.Value != 'x' || got.NodeID != op.NodeID {
+		t.Fatalf("配信前にopが解釈されるべき: got %+v", got)
+	}
+	if b.Messages()[0].Ops[0].Op != got {
+		t.Error("購読者間で解釈結果を共有すべき")
+	}
+	if ops[0].Op != nil {
+		t.Error("呼び出し元のスライスは変更しないべき")
+	}
+
+	diff, err := svc.GetDiff(context.Background(), entryID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Ops[0].Op == nil || diff.Ops[0].Op.RequestID != op.RequestID {
+		t.Errorf("差分のopも解釈済みであるべき: got %+v", diff.Ops[0].Op)
+	}
+	// 配信で解釈したopを差分でも使い、payloadを解釈し直さない
+	if diff.Ops[0].Op != got {
+		t.Error("差分は配信時の解釈結果を再利用すべき")
+	}
+}
+
+func TestSyncService_GetDiffReusesDecodedOps(t *testing.T) {
+	ctx := context.Background()
+	eventStore := memory.NewEventStore()
+	entryStore := memory.NewEntryStore()
+	svc := application.NewSyncService(eventStore)
+	entry := domain.NewEntry()
+	entryStore.Save(ctx, entry)
+
+	op := crdt.Operation{RequestID: uuid.New(), OpType: crdt.OpInsert, NodeID: crdt.NodeID{ReplicaID: uuid.New(), Timestamp: 1}, Value: 'x', Authenticated: true}
+	payload, _ := crdt.MarshalPayload(op)
+	if _, err := svc.HandleOp(ctx, entry.ID, op.NodeID.ReplicaID, op.RequestID, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := svc.GetDiff(ctx, entry.ID, 0)
+	second, _ := svc.GetDiff(ctx, entry.ID, 0)
+	if first.Ops[0].Op == nil || second.Ops[0].Op != first.Ops[0].Op {
+		t.Error("同じイベントのopは1度だけ解釈するべき")
+	}
+
+	// 完全削除したエントリの解釈結果は捨てる
+	projector := application.NewEntryProjector(entryStore, memory.NewRGAStateStore(), t.TempDir(), nil)
+	trash := application.NewTrashService(svc, projector, entryStore, eventStore, 0, nil)
+	entryStore.Delete(ctx, entry.ID)
+	if err := trash.Purge(ctx, entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := application.CachedOps(svc); n != 0 {
+		t.Errorf("完全削除後の解釈済みop: got %d, want 0", n)
+	}
+}
@@ -0,0 +1,98 @@
+package application
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+)
+
+// TagCount はタグと、そのタグを持つエントリ数。
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// TagService はタグの編集と集計を担う。
+// タグ編集はOR-Setオペレーションとしてイベントログに記録してからprojectorに適用する。
+type TagService struct {
+	syncService *SyncService
+	projector   *EntryProjector
+	entryStore  domain.EntryStore
+}
+
+func NewTagService(syncService *SyncService, projector *EntryProjector, entryStore domain.EntryStore) *TagService {
+	return &TagService{
+		syncService: syncService,
+		projector:   projector,
+		entryStore:  entryStore,
+	}
+}
+
+// Update はエントリのタグを追加・削除し、更新後のタグ一覧を返す。ロック中はErrEntryLockedを返す。
+// フロントマターに書かれたタグは本文を編集しない限り残るため、削除しようとするとErrTagInFrontMatterを返す。
+func (s *TagService) Update(ctx context.Context, entryID uuid.UUID, add, remove []string) ([]string, error) {
+	if err := ensureUnlocked(ctx, s.entryStore, entryID); err != nil {
+		return nil, err
+	}
+	if text, ok := s.projector.PublicText(entryID); ok && len(remove) > 0 {
+		fmTags := mergeTags(deriveFields(text).Tags)
+		for _, tag := range remove {
+			if slices.Contains(fmTags, domain.NormalizeTag(tag)) {
+				return nil, domain.ErrTagInFrontMatter
+			}
+		}
+	}
+
+	for _, op := range s.projector.PrepareTagOps(entryID, add, remove) {
+		payload, err := json.Marshal(op)
+		if err != nil {
+			return nil, fmt.Errorf("marshal tag op: %w", err)
+		}
+		seq, err := s.syncService.AppendEvent(ctx, entryID, op.RequestID, domain.EventTagOp, payload)
+		if err != nil {
+			return nil, err
+		}
+		if seq > 0 {
+			s.projector.ApplyTagOp(ctx, entryID, payload)
+		}
+	}
+
+	entry, err := s.entryStore.FindByID(ctx, entryID)
+	if err != nil {
+		return nil, err
+	}
+	return entry.Tags, nil
+}
+
+// Counts は削除済みを除く全エントリのタグ別件数を、件数降順・タグ名昇順で返す。
+func (s *TagService) Counts(ctx context.Context) ([]TagCount, error) {
+	items, err := s.entryStore.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, item := range items {
+		for _, tag := range item.Tags {
+			counts[tag]++
+		}
+	}
+
+	result := make([]TagCount, 0, len(counts))
+	for tag, n := range counts {
+		result = append(result, TagCount{Tag: tag, Count: n})
+	}
+	slices.SortFunc(result, func(a, b TagCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+	return result, nil
+}
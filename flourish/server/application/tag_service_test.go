@@ -0,0 +1,114 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+func TestTagService_UpdateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	eventStore := memory.NewEventStore()
+	syncService := application.NewSyncService(eventStore)
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	svc := application.NewTagService(syncService, projector, entryStore)
+
+	entry := domain.NewEntry()
+	entryStore.Save(ctx, entry)
+
+	tags, err := svc.Update(ctx, entry.ID, []string{" Go ", "crdt"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tags, []string{"crdt", "go"}) {
+		t.Errorf("tags: got %v", tags)
+	}
+
+	tags, err = svc.Update(ctx, entry.ID, nil, []string{"CRDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tags, []string{"go"}) {
+		t.Errorf("tags after remove: got %v", tags)
+	}
+
+	// タグ操作はtag_opイベントとして記録され、syncには含まれない
+	events, _ := eventStore.ListAfter(ctx, entry.ID, 0)
+	if len(events) != 3 {
+		t.Fatalf("events: got %d, want 3", len(events))
+	}
+	for _, ev := range events {
+		if ev.EventType != domain.EventTagOp {
+			t.Errorf("EventType: got %q", ev.EventType)
+		}
+	}
+	diff, _ := syncService.GetDiff(ctx, entry.ID, 0)
+	if len(diff.Ops) != 0 || diff.LatestServerSeq != 3 {
+		t.Errorf("diff: ops=%d latest=%d", len(diff.Ops), diff.LatestServerSeq)
+	}
+
+	// イベントログから復元できる
+	restored := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	stored, _ := entryStore.FindByID(ctx, entry.ID)
+	stored.Tags = nil
+	entryStore.Save(ctx, stored)
+	if err := restored.Restore(ctx, eventStore, []uuid.UUID{entry.ID}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := entryStore.FindByID(ctx, entry.ID)
+	if !slices.Equal(got.Tags, []string{"go"}) {
+		t.Errorf("restored tags: got %v", got.Tags)
+	}
+}
+
+func TestTagService_FrontMatterTagsCannotBeRemoved(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	syncService := application.NewSyncService(memory.NewEventStore())
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	svc := application.NewTagService(syncService, projector, entryStore)
+
+	entry := domain.NewEntry()
+	entryStore.Save(ctx, entry)
+	applyText(t, projector, entry.ID, uuid.New(), "---\ntags: [go]\n---\nbody")
+	if _, err := svc.Update(ctx, entry.ID, []string{"crdt"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Update(ctx, entry.ID, nil, []string{"crdt", "Go"}); !errors.Is(err, domain.ErrTagInFrontMatter) {
+		t.Errorf("front matter tag removal: got %v, want ErrTagInFrontMatter", err)
+	}
+	got, _ := entryStore.FindByID(ctx, entry.ID)
+	if !slices.Equal(got.Tags, []string{"go", "crdt"}) {
+		t.Errorf("rejected update should not remove any tag: got %v", got.Tags)
+	}
+}
+
+func TestTagService_Counts(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	svc := application.NewTagService(nil, nil, entryStore)
+
+	for _, tags := range [][]string{{"go", "crdt"}, {"go"}, {"misc"}} {
+		e := domain.NewEntry()
+		e.Tags = tags
+		entryStore.Save(ctx, e)
+	}
+
+	counts, err := svc.Counts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []application.TagCount{{Tag: "go", Count: 2}, {Tag: "crdt", Count: 1}, {Tag: "misc", Count: 1}}
+	if !slices.Equal(counts, want) {
+		t.Errorf("counts: got %v, want %v", counts, want)
+	}
+}
@@ -0,0 +1,80 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+)
+
+// APITokenPrefix はAPIトークンの接頭辞。JWTと区別するために使う。
+const APITokenPrefix = "flo_"
+
+// APITokenService はAPIトークンの発行・一覧・失効・検証を担う。
+type APITokenService struct {
+	store domain.APITokenStore
+}
+
+func NewAPITokenService(store domain.APITokenStore) *APITokenService {
+	return &APITokenService{store: store}
+}
+
+// Issue はトークンを発行し、平文のトークンと保存内容を返す。平文はここでしか得られない。
+func (s *APITokenService) Issue(ctx context.Context, name string, scopes []domain.Scope) (string, domain.APIToken, error) {
+	if len(scopes) == 0 {
+		return "", domain.APIToken{}, domain.ErrInvalidScope
+	}
+	for _, sc := range scopes {
+		if !sc.Valid() {
+			return "", domain.APIToken{}, fmt.Errorf("%w: %q", domain.ErrInvalidScope, sc)
+		}
+	}
+
+	b := make([]byte, 32)
+	rand.Read(b)
+	secret := APITokenPrefix + hex.EncodeToString(b)
+
+	token := domain.APIToken{
+		ID:        uuid.New(),
+		Name:      strings.TrimSpace(name),
+		Hash:      hashToken(secret),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.Save(ctx, token); err != nil {
+		return "", domain.APIToken{}, err
+	}
+	return secret, token, nil
+}
+
+// List は発行済みトークンを返す。
+func (s *APITokenService) List(ctx context.Context) ([]domain.APIToken, error) {
+	return s.store.List(ctx)
+}
+
+// Revoke はトークンを失効させる。
+func (s *APITokenService) Revoke(ctx context.Context, id uuid.UUID) error {
+	return s.store.Delete(ctx, id)
+}
+
+// Authenticate は平文のトークンを検証し、対応するトークンを返す。
+func (s *APITokenService) Authenticate(ctx context.Context, secret string) (domain.APIToken, error) {
+	if !strings.HasPrefix(secret, APITokenPrefix) {
+		return domain.APIToken{}, domain.ErrTokenNotFound
+	}
+	return s.store.FindByHash(ctx, hashToken(secret))
+}
+
+// hashToken はトークンのSHA-256ハッシュを返す。トークンは十分なエントロピーを持つためソルトは不要。
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
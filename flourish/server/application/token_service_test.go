@@ -0,0 +1,63 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+func TestAPITokenService_IssueAuthenticateRevoke(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewAPITokenStore()
+	svc := application.NewAPITokenService(store)
+
+	secret, token, err := svc.Issue(ctx, " ci ", []domain.Scope{domain.ScopeEdit, domain.ScopeRead, domain.ScopeEdit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, application.APITokenPrefix) {
+		t.Errorf("secret should have prefix: got %q", secret)
+	}
+	if token.Name != "ci" || len(token.Scopes) != 2 {
+		t.Errorf("token: got %+v", token)
+	}
+
+	// 保存されるのはハッシュのみ
+	stored, _ := store.List(ctx)
+	if len(stored) != 1 || stored[0].Hash == "" || strings.Contains(stored[0].Hash, secret) {
+		t.Errorf("stored token should only keep hash: %+v", stored)
+	}
+
+	got, err := svc.Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != token.ID || !got.Allows(domain.ScopeRead) || got.Allows(domain.ScopeAdmin) {
+		t.Errorf("authenticated token: got %+v", got)
+	}
+
+	if _, err := svc.Authenticate(ctx, secret+"x"); !errors.Is(err, domain.ErrTokenNotFound) {
+		t.Errorf("wrong secret: got %v", err)
+	}
+
+	if err := svc.Revoke(ctx, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Authenticate(ctx, secret); !errors.Is(err, domain.ErrTokenNotFound) {
+		t.Errorf("revoked token: got %v", err)
+	}
+}
+
+func TestAPITokenService_InvalidScope(t *testing.T) {
+	svc := application.NewAPITokenService(memory.NewAPITokenStore())
+	for _, scopes := range [][]domain.Scope{nil, {"owner"}} {
+		if _, _, err := svc.Issue(context.Background(), "ci", scopes); !errors.Is(err, domain.ErrInvalidScope) {
+			t.Errorf("scopes %v: got %v, want ErrInvalidScope", scopes, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+)
+
+// deletionChange はEventEntryDeleteとしてイベントログに記録される削除状態の変更。
+// Deleted=falseはゴミ箱からの復元を表す。
+type deletionChange struct {
+	RequestID uuid.UUID `json:"request_id"`
+	Deleted   bool      `json:"deleted"`
+}
+
+// TrashService はエントリのゴミ箱（論理削除・復元・完全削除）を担う。
+type TrashService struct {
+	syncService *SyncService
+	projector   *EntryProjector
+	entryStore  domain.EntryStore
+	eventStore  domain.EventStore
+	retention   time.Duration
+	log         *slog.Logger
+}
+
+// NewTrashService は新しいTrashServiceを生成する。retentionが0なら自動の完全削除は行わない。
+func NewTrashService(syncService *SyncService, projector *EntryProjector, entryStore domain.EntryStore, eventStore domain.EventStore, retention time.Duration, log *slog.Logger) *TrashService {
+	return &TrashService{
+		syncService: syncService,
+		projector:   projector,
+		entryStore:  entryStore,
+		eventStore:  eventStore,
+		retention:   retention,
+		log:         log,
+	}
+}
+
+// IsDeleted はエントリがゴミ箱にあるかどうかを返す。
+func (s *TrashService) IsDeleted(ctx context.Context, entryID uuid.UUID) bool {
+	_, err := s.entryStore.FindByID(ctx, entryID)
+	return errors.Is(err, domain.ErrEntryDeleted)
+}
+
+// PurgeAt は保持期間が過ぎて完全削除される時刻を返す。保持期間が無ければゼロ値。
+func (s *TrashService) PurgeAt(entry domain.Entry) time.Time {
+	if s.retention <= 0 || entry.DeletedAt.IsZero() {
+		return time.Time{}
+	}
+	return entry.DeletedAt.Add(s.retention)
+}
+
+// Delete はエントリをゴミ箱に入れ、イベントログに記録して購読者に通知する。
+func (s *TrashService) Delete(ctx context.Context, entryID uuid.UUID) error {
+	if _, err := s.entryStore.FindByID(ctx, entryID); err != nil {
+		return err
+	}
+	if err := s.appendChange(ctx, entryID, true); err != nil {
+		return err
+	}
+	if err := s.entryStore.Delete(ctx, entryID); err != nil {
+		return err
+	}
+	s.syncService.Notify(entryID, Notification{Type: NotifyEntryDelete, EntryID: entryID, Deleted: true})
+	return nil
+}
+
+// Restore はゴミ箱のエントリを復元し、イベントログに記録して購読者に通知する。
+func (s *TrashService) Restore(ctx context.Context, entryID uuid.UUID) error {
+	if _, err := s.entryStore.FindByID(ctx, entryID); err == nil {
+		return domain.ErrEntryNotDeleted
+	} else if !errors.Is(err, domain.ErrEntryDeleted) {
+		return err
+	}
+	if err := s.appendChange(ctx, entryID, false); err != nil {
+		return err
+	}
+	if err := s.entryStore.Restore(ctx, entryID); err != nil {
+		return err
+	}
+	// ゴミ箱にある間に永続化できなかった投影を反映する
+	s.projector.flushEntry(ctx, entryID)
+	s.syncService.Notify(entryID, Notification{Type: NotifyEntryDelete, EntryID: entryID, Deleted: false})
+	return nil
+}
+
+// List はゴミ箱のエントリを削除日時の新しい順に返す。
+func (s *TrashService) List(ctx context.Context) ([]domain.Entry, error) {
+	return s.entryStore.ListDeleted(ctx)
+}
+
+// Purge はゴミ箱のエントリを、イベント・RGAスナップショット・markdownごと完全削除する。
+// 途中で失敗してもエントリはゴミ箱に残るので、再実行できる。
+func (s *TrashService) Purge(ctx context.Context, entryID uuid.UUID) error {
+	if _, err := s.entryStore.FindByID(ctx, entryID); err == nil {
+		return domain.ErrEntryNotDeleted
+	} else if !errors.Is(err, domain.ErrEntryDeleted) {
+		return err
+	}
+	if err := s.eventStore.Purge(ctx, entryID); err != nil {
+		return fmt.Errorf("purge events: %w", err)
+	}
+	s.syncService.ops.forget(entryID)
+	if err := s.projector.Forget(ctx, entryID); err != nil {
+		return fmt.Errorf("purge projection: %w", err)
+	}
+	return s.entryStore.Purge(ctx, entryID)
+}
+
+// PurgeExpired は保持期間を過ぎたゴミ箱のエントリを完全削除し、削除した件数を返す。
+func (s *TrashService) PurgeExpired(ctx context.Context) (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	entries, err := s.entryStore.ListDeleted(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	n := 0
+	for _, entry := range entries {
+		if now.Before(s.PurgeAt(entry)) {
+			continue
+		}
+		if err := s.Purge(ctx, entry.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// RunRetention はctxが終了するまでinterval毎に保持期間を過ぎたエントリを完全削除する。
+func (s *TrashService) RunRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.PurgeExpired(ctx); err != nil {
+			s.log.Error("trash: 保持期間の適用失敗", "error", err)
+		} else if n > 0 {
+			s.log.Info("trash: 保持期間を過ぎたエントリを完全削除", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// appendChange は削除状態の変更をイベントログに記録する。
+func (s *TrashService) appendChange(ctx context.Context, entryID uuid.UUID, deleted bool) error {
+	change := deletionChange{RequestID: uuid.New(), Deleted: deleted}
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("marshal deletion: %w", err)
+	}
+	_, err = s.syncService.AppendEvent(ctx, entryID, change.RequestID, domain.EventEntryDelete, payload)
+	return err
+}
@@ -0,0 +1,124 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+func TestTrashService_DeleteRestore(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	eventStore := memory.NewEventStore()
+	syncService := application.NewSyncService(eventStore)
+	projector := application.NewEntryProjector(entryStore, newMockRGAStateStore(), t.TempDir(), nil)
+	trash := application.NewTrashService(syncService, projector, entryStore, eventStore, 0, slog.Default())
+
+	entry := domain.NewEntry()
+	entryStore.Save(ctx, entry)
+
+	sub := &mockSubscriber{}
+	syncService.Subscribe(entry.ID, sub)
+	defer syncService.Unsubscribe(entry.ID, sub)
+
+	if err := trash.Delete(ctx, entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !trash.IsDeleted(ctx, entry.ID) {
+		t.Error("entry should be in trash")
+	}
+	if err := trash.Delete(ctx, entry.ID); !errors.Is(err, domain.ErrEntryDeleted) {
+		t.Errorf("second delete: got %v, want ErrEntryDeleted", err)
+	}
+	if err := trash.Restore(ctx, entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if trash.IsDeleted(ctx, entry.ID) {
+		t.Error("entry should be restored")
+	}
+
+	events, _ := eventStore.ListAfter(ctx, entry.ID, 0)
+	if len(events) != 2 || events[0].EventType != domain.EventEntryDelete || events[1].EventType != domain.EventEntryDelete {
+		t.Fatalf("events: got %+v", events)
+	}
+
+	notes := sub.Notifications()
+	if len(notes) != 2 || notes[0].Type != application.NotifyEntryDelete || !notes[0].Deleted || notes[1].Deleted {
+		t.Errorf("notifications: got %+v", notes)
+	}
+
+	// 非CRDTイベントなのでクライアントへの差分には含まれない
+	diff, _ := syncService.GetDiff(ctx, entry.ID, 0)
+	if len(diff.Ops) != 0 {
+		t.Errorf("diff ops: got %d, want 0", len(diff.Ops))
+	}
+}
+
+func TestTrashService_PurgeExpired(t *testing.T) {
+	ctx := context.Background()
+	entryStore := memory.NewEntryStore()
+	eventStore := memory.NewEventStore()
+	rgaStore := newMockRGAStateStore()
+	markdownDir := t.TempDir()
+	syncService := application.NewSyncService(eventStore)
+	projector := application.NewEntryProjector(entryStore, rgaStore, markdownDir, nil)
+	trash := application.NewTrashService(syncService, projector, entryStore, eventStore, time.Hour, slog.Default())
+
+	expired, fresh := domain.NewEntry(), domain.NewEntry()
+	for _, e := range []domain.Entry{expired, fresh} {
+		entryStore.Save(ctx, e)
+		syncService.HandleOp(ctx, e.ID, uuid.New(), uuid.New(), makeInsertPayload(t, uuid.New(), 1, "a", nil))
+		projector.Apply(ctx, e.ID, makeInsertPayload(t, uuid.New(), 1, "a", nil))
+		trash.Delete(ctx, e.ID)
+	}
+	// 保持期間を過ぎたことにする
+	stored, _ := entryStore.ListDeleted(ctx)
+	for _, e := range stored {
+		if e.ID == expired.ID {
+			e.DeletedAt = time.Now().Add(-2 * time.Hour)
+			entryStore.Save(ctx, e)
+		}
+	}
+
+	if err := trash.Purge(ctx, uuid.New()); !errors.Is(err, domain.ErrEntryNotFound) {
+		t.Errorf("purge unknown: got %v, want ErrEntryNotFound", err)
+	}
+
+	n, err := trash.PurgeExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("purged: got %d, want 1", n)
+	}
+
+	if _, err := entryStore.FindByID(ctx, expired.ID); !errors.Is(err, domain.ErrEntryNotFound) {
+		t.Errorf("expired entry: got %v, want ErrEntryNotFound", err)
+	}
+	if events, _ := eventStore.ListAfter(ctx, expired.ID, 0); len(events) != 0 {
+		t.Errorf("expired events: got %d, want 0", len(events))
+	}
+	if _, ok := rgaStore.states[expired.ID]; ok {
+		t.Error("expired RGA snapshot should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(markdownDir, expired.ID.String()+".md")); !os.IsNotExist(err) {
+		t.Errorf("expired markdown should be removed: %v", err)
+	}
+
+	if !trash.IsDeleted(ctx, fresh.ID) {
+		t.Error("fresh entry should stay in trash")
+	}
+	if events, _ := eventStore.ListAfter(ctx, fresh.ID, 0); len(events) == 0 {
+		t.Error("fresh events should be kept")
+	}
+}
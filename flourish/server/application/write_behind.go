@@ -0,0 +1,210 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/domain"
+	"flourish/server/domain/crdt"
+)
+
+// flushRetryDelay は永続化に失敗したエントリを再びflushするまでの最短の待ち時間。
+var flushRetryDelay = time.Second
+
+// pendingFlush は永続化待ちのエントリ。
+type pendingFlush struct {
+	since time.Time // 最初の未永続化opを適用した時刻
+	ops   int
+	timer *time.Timer
+}
+
+// FlushStats は書き込み遅延の状況。
+type FlushStats struct {
+	// Pending は永続化待ちのエントリ数。
+	Pending int
+	// Flushing は永続化中のエントリ数。
+	Flushing int
+	// Lag は永続化待ちのうち最も古い変更からの経過時間。
+	Lag time.Duration
+	// LastLag は直近のflushで永続化した変更が待っていた時間。
+	LastLag time.Duration
+	// MaxLag は起動以来のLastLagの最大値。
+	MaxLag time.Duration
+}
+
+// SetWriteBehind はopの適用をメモリ上で済ませ、Entry・RGAスナップショット・markdownの永続化を
+// エントリ単位でまとめて遅延させる。最初の未永続化opからdelay経過するか、maxOps件たまるとflushする。
+// delayが0なら無効（適用のたびに、シャードのロックの外で同期的に永続化する）。
+// jsonfileのEntryStoreは全エントリを1ファイルに書くので、無効のままではシャードを増やしても
+// 永続化はストアのロックで1件ずつになる。多くのエントリが並行に編集されるなら有効にする。
+// opはACK前にイベントログへ記録済みなので、flush前に落ちても起動時のRestoreで投影は復元される。
+func (p *EntryProjector) SetWriteBehind(delay time.Duration, maxOps int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushDelay = delay
+	p.flushMaxOps = maxOps
+}
+
+// writeBehind は書き込み遅延の設定を返す。
+func (p *EntryProjector) writeBehind() (delay time.Duration, maxOps int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.flushDelay, p.flushMaxOps
+}
+
+// markDirty はエントリを永続化待ちにする。シャードのロック保持前提。
+// 書き込み遅延が無効ならタイマーは張らず、呼び出し元がロックを放してからflushする。
+func (p *EntryProjector) markDirty(entryID uuid.UUID) {
+	delay, maxOps := p.writeBehind()
+	s := p.shard(entryID)
+	pf, ok := s.pending[entryID]
+	if !ok {
+		pf = &pendingFlush{since: time.Now()}
+		if delay > 0 {
+			pf.timer = time.AfterFunc(delay, func() { p.flushEntry(context.Background(), entryID) })
+		}
+		s.pending[entryID] = pf
+	}
+	pf.ops++
+	if pf.timer != nil && maxOps > 0 && pf.ops >= maxOps && pf.timer.Stop() {
+		go p.flushEntry(context.Background(), entryID)
+	}
+}
+
+// flushEntry は永続化待ちのエントリを永続化する。状態の読み出しだけをシャードのロック下で行い、
+// ファイルへの書き込みはエントリごとのロックで直列化する。Entryを更新できなければ永続化待ちに戻す。
+// 永続化待ちでなければ、先に始まったflushが書き込み済みなので何もしない。
+// RGAの無いエントリ（本文のopが無くタグだけ付いたもの）はタグだけを更新する。
+func (p *EntryProjector) flushEntry(ctx context.Context, entryID uuid.UUID) {
+	mu := p.flushLock(entryID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	s := p.lock(entryID)
+	pf, ok := s.pending[entryID]
+	if !ok {
+		s.unlock()
+		return
+	}
+	if pf.timer != nil {
+		pf.timer.Stop()
+	}
+	delete(s.pending, entryID)
+	p.flushing.Add(1)
+	defer p.flushing.Add(-1)
+	rga, hasRGA := s.rgas[entryID]
+	var (
+		text string
+		snap crdt.RGASnapshot
+	)
+	if hasRGA {
+		text = p.publicText(entryID, rga)
+		snap = rga.Export()
+	}
+	tags := p.tagElements(entryID)
+	s.unlock()
+
+	// ロックやゴミ箱への移動はflushと並行して起こるので、投影するフィールドだけを更新する
+	if err := p.entryStore.Update(ctx, entryID, func(entry *domain.Entry) {
+		if !hasRGA {
+			text = entry.Text
+		}
+		applyDerivedFields(entry, text, tags)
+	}); err != nil {
+		p.log.Warn("projector: flush対象のentry更新失敗", "entryID", entryID, "error", err)
+		p.retryFlush(entryID, pf, err)
+		return
+	}
+	if hasRGA {
+		if err := p.rgaStateStore.SaveRGA(ctx, entryID, snap); err != nil {
+			p.log.Error("projector: RGA状態保存失敗", "entryID", entryID, "error", err)
+		}
+		p.saveMarkdown(entryID, text)
+	}
+
+	lag := time.Since(pf.since)
+	p.mu.Lock()
+	p.lastLag = lag
+	p.maxLag = max(p.maxLag, lag)
+	p.mu.Unlock()
+}
+
+// retryFlush はEntryを更新できなかったエントリを永続化待ちに戻す。エントリごとのflushのロック保持前提
+// （Forgetはこのロックを待つので、戻した後に破棄されることはあっても、破棄の後に戻すことはない）。
+// ゴミ箱にあるエントリは復元時に（TrashService.Restore）、それ以外は少し待ってから再びflushする。
+// 完全削除されたエントリは諦める。
+func (p *EntryProjector) retryFlush(entryID uuid.UUID, failed *pendingFlush, err error) {
+	if errors.Is(err, domain.ErrEntryNotFound) {
+		return
+	}
+	s := p.lock(entryID)
+	defer s.unlock()
+	pf, ok := s.pending[entryID]
+	if !ok {
+		pf = &pendingFlush{since: failed.since}
+		s.pending[entryID] = pf
+	}
+	if failed.since.Before(pf.since) {
+		pf.since = failed.since
+	}
+	pf.ops += failed.ops
+	if pf.timer == nil && !errors.Is(err, domain.ErrEntryDeleted) {
+		delay, _ := p.writeBehind()
+		pf.timer = time.AfterFunc(max(delay, flushRetryDelay), func() { p.flushEntry(context.Background(), entryID) })
+	}
+}
+
+// flushLock はエントリの永続化を直列化するロックを返す。完全削除（Forget）で破棄される。
+func (p *EntryProjector) flushLock(entryID uuid.UUID) *sync.Mutex {
+	s := p.lock(entryID)
+	defer s.unlock()
+	mu, ok := s.flushLocks[entryID]
+	if !ok {
+		mu = &sync.Mutex{}
+		s.flushLocks[entryID] = mu
+	}
+	return mu
+}
+
+// Flush は永続化待ちの全エントリを永続化する。シャットダウン時に呼ぶ。
+func (p *EntryProjector) Flush(ctx context.Context) {
+	for _, id := range p.pendingIDs() {
+		p.flushEntry(ctx, id)
+	}
+}
+
+// pendingIDs は永続化待ちのエントリIDを返す。
+func (p *EntryProjector) pendingIDs() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, s := range p.shards {
+		s.lock()
+		for id := range s.pending {
+			ids = append(ids, id)
+		}
+		s.unlock()
+	}
+	return ids
+}
+
+// FlushStats は書き込み遅延の状況を返す。
+func (p *EntryProjector) FlushStats() FlushStats {
+	var stats FlushStats
+	for _, s := range p.shards {
+		s.lock()
+		stats.Pending += len(s.pending)
+		for _, pf := range s.pending {
+			stats.Lag = max(stats.Lag, time.Since(pf.since))
+		}
+		s.unlock()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats.Flushing = int(p.flushing.Load())
+	stats.LastLag = p.lastLag
+	stats.MaxLag = p.maxLag
+	return stats
+}
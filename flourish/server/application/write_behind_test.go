@@ -0,0 +1,252 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+func newWriteBehindProjector(t *testing.T, delay time.Duration, maxOps int) (*application.EntryProjector, *memory.EntryStore, *memory.RGAStateStore, uuid.UUID) {
+	t.Helper()
+	entryStore := memory.NewEntryStore()
+	rgaStore := memory.NewRGAStateStore()
+	projector := application.NewEntryProjector(entryStore, rgaStore, t.TempDir(), slog.Default())
+	projector.SetWriteBehind(delay, maxOps)
+
+	entryID := uuid.New()
+	now := time.Now().UTC()
+	if err := entryStore.Save(context.Background(), domain.Entry{ID: entryID, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	return projector, entryStore, rgaStore, entryID
+}
+
+// waitText はエントリの本文がwantになるまで待つ。
+func waitText(t *testing.T, store *memory.EntryStore, entryID uuid.UUID, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entry, err := store.FindByID(context.Background(), entryID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Text == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Text: got %q, want %q", entry.Text, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWriteBehind_CoalescesUntilFlush(t *testing.T) {
+	projector, entryStore, rgaStore, entryID := newWriteBehindProjector(t, time.Hour, 0)
+
+	applyText(t, projector, entryID, uuid.New(), "Hi")
+
+	entry, _ := entryStore.FindByID(context.Background(), entryID)
+	if entry.Text != "" {
+		t.Errorf("flush前に永続化されるべきでない: got %q", entry.Text)
+	}
+	if _, err := rgaStore.LoadRGA(context.Background(), entryID); err == nil {
+		t.Error("flush前にRGAスナップショットが保存されるべきでない")
+	}
+	if stats := projector.FlushStats(); stats.Pending != 1 {
+		t.Errorf("Pending: got %d, want 1", stats.Pending)
+	}
+
+	projector.Flush(context.Background())
+
+	entry, _ = entryStore.FindByID(context.Background(), entryID)
+	if entry.Text != "Hi" || entry.Title != "Hi" {
+		t.Errorf("flush後: got text=%q title=%q", entry.Text, entry.Title)
+	}
+	if _, err := rgaStore.LoadRGA(context.Background(), entryID); err != nil {
+		t.Errorf("flush後にRGAスナップショットが保存されるべき: %v", err)
+	}
+	if stats := projector.FlushStats(); stats.Pending != 0 || stats.Lag != 0 {
+		t.Errorf("flush後の状況: %+v", stats)
+	}
+}
+
+func TestWriteBehind_FlushesAfterDelay(t *testing.T) {
+	projector, entryStore, _, entryID := newWriteBehindProjector(t, 10*time.Millisecond, 0)
+
+	applyText(t, projector, entryID, uuid.New(), "abc")
+
+	waitText(t, entryStore, entryID, "abc")
+	if stats := projector.FlushStats(); stats.LastLag <= 0 || stats.MaxLag < stats.LastLag {
+		t.Errorf("flush遅延が記録されるべき: %+v", stats)
+	}
+}
+
+func TestWriteBehind_FlushesAtMaxOps(t *testing.T) {
+	projector, entryStore, _, entryID := newWriteBehindProjector(t, time.Hour, 3)
+
+	applyText(t, projector, entryID, uuid.New(), "abc")
+
+	waitText(t, entryStore, entryID, "abc")
+}
+
+func TestWriteBehind_UnknownEntryIsRejected(t *testing.T) {
+	projector, _, _, _ := newWriteBehindProjector(t, time.Hour, 0)
+
+	if projector.Apply(context.Background(), uuid.New(), makeInsertPayload(t, uuid.New(), 1, "x", nil)) {
+		t.Error("存在しないエントリへの適用は失敗するべき")
+	}
+	if stats := projector.FlushStats(); stats.Pending != 0 {
+		t.Errorf("Pending: got %d, want 0", stats.Pending)
+	}
+}
+
+func TestWriteBehind_FlushKeepsConcurrentLockAndTrash(t *testing.T) {
+	ctx := context.Background()
+	projector, entryStore, _, entryID := newWriteBehindProjector(t, time.Hour, 0)
+	eventStore := memory.NewEventStore()
+	syncService := application.NewSyncService(eventStore)
+	locks := application.NewLockService(syncService, entryStore)
+	trash := application.NewTrashService(syncService, projector, entryStore, eventStore, 0, slog.Default())
+
+	// flushと並行してロックされても、flushがロック前の状態で上書きしないこと
+	siteID := uuid.New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 200 {
+			projector.Apply(ctx, entryID, makeInsertPayload(t, siteID, uint64(i+1), "x", nil))
+			projector.Flush(ctx)
+		}
+	}()
+	if err := locks.SetLocked(ctx, entryID, true); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if entry, _ := entryStore.FindByID(ctx, entryID); !entry.Locked || len(entry.Text) != 200 {
+		t.Errorf("flush後もロックと本文が両方残るべき: locked=%v len=%d", entry.Locked, len(entry.Text))
+	}
+
+	// ゴミ箱に入ったエントリをflushで復活させないこと
+	projector.Apply(ctx, entryID, makeInsertPayload(t, siteID, 201, "y", nil))
+	if err := entryStore.Delete(ctx, entryID); err != nil {
+		t.Fatal(err)
+	}
+	projector.Flush(ctx)
+	if _, err := entryStore.FindByID(ctx, entryID); !errors.Is(err, domain.ErrEntryDeleted) {
+		t.Errorf("flushで削除が取り消されないべき: got %v", err)
+	}
+
+	// ゴミ箱にある間に永続化できなかった投影は、復元時に反映される
+	if stats := projector.FlushStats(); stats.Pending != 1 {
+		t.Errorf("Pending: got %d, want 1", stats.Pending)
+	}
+	if err := trash.Restore(ctx, entryID); err != nil {
+		t.Fatal(err)
+	}
+	waitText(t, entryStore, entryID, "y"+strings.Repeat("x", 200))
+}
+
+// failingEntryStore は更新を指定回数だけ失敗させるEntryStore。
+type failingEntryStore struct {
+	*memory.EntryStore
+	failures atomic.Int32
+}
+
+func (s *failingEntryStore) Update(ctx context.Context, id uuid.UUID, fn func(*domain.Entry)) error {
+	if s.failures.Add(-1) >= 0 {
+		return errors.New("disk full")
+	}
+	return s.EntryStore.Update(ctx, id, fn)
+}
+
+func TestWriteBehind_RetriesFailedFlush(t *testing.T) {
+	t.Cleanup(application.SetFlushRetryDelay(10 * time.Millisecond))
+	ctx := context.Background()
+	store := &failingEntryStore{EntryStore: memory.NewEntryStore()}
+	projector := application.NewEntryProjector(store, memory.NewRGAStateStore(), t.TempDir(), slog.Default())
+	entryID := uuid.New()
+	now := time.Now().UTC()
+	if err := store.Save(ctx, domain.Entry{ID: entryID, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 書き込み遅延が無効でも、永続化の失敗は適用の失敗ではない（opは記録済みなので配信する）
+	store.failures.Store(2)
+	if !projector.Apply(ctx, entryID, makeInsertPayload(t, uuid.New(), 1, "x", nil)) {
+		t.Fatal("永続化に失敗しても適用は成功を返すべき")
+	}
+	if stats := projector.FlushStats(); stats.Pending != 1 {
+		t.Errorf("失敗したエントリは永続化待ちに戻るべき: Pending=%d", stats.Pending)
+	}
+	waitText(t, store.EntryStore, entryID, "x")
+	if stats := projector.FlushStats(); stats.Pending != 0 {
+		t.Errorf("Pending: got %d, want 0", stats.Pending)
+	}
+}
+
+func TestWriteBehind_ForgetBetweenApplyAndFlush(t *testing.T) {
+	ctx := context.Background()
+	entryID := uuid.New()
+	store := &blockingEntryStore{
+		EntryStore: memory.NewEntryStore(),
+		blocked:    entryID,
+		entered:    make(chan struct{}),
+		release:    make(chan struct{}),
+	}
+	rgaStore := memory.NewRGAStateStore()
+	projector := application.NewEntryProjector(store, rgaStore, t.TempDir(), slog.Default())
+	now := time.Now().UTC()
+	if err := store.Save(ctx, domain.Entry{ID: entryID, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1件目のflushが書き込み中の間に2件目を適用すると、2件目はflush待ちのまま永続化待ちに残る
+	var wg sync.WaitGroup
+	siteID := uuid.New()
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			projector.Apply(ctx, entryID, makeInsertPayload(t, siteID, uint64(i+1), "x", nil))
+		}()
+		if i == 0 {
+			<-store.entered
+		}
+	}
+	for deadline := time.Now().Add(2 * time.Second); projector.FlushStats().Pending == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("2件目が永続化待ちにならない")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 書き込み遅延が無効でタイマーの無い永続化待ちを、完全削除で破棄できること
+	forgotten := make(chan error, 1)
+	go func() { forgotten <- projector.Forget(ctx, entryID) }()
+	close(store.release)
+	if err := <-forgotten; err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if stats := projector.FlushStats(); stats.Pending != 0 {
+		t.Errorf("Pending: got %d, want 0", stats.Pending)
+	}
+	// 書き込み中だったflushを待ってから破棄するので、破棄した投影は書き戻されない
+	if _, err := rgaStore.LoadRGA(ctx, entryID); err == nil {
+		t.Error("完全削除したエントリのRGAスナップショットが残っている")
+	}
+	if n := application.FlushLocks(projector); n != 0 {
+		t.Errorf("完全削除したエントリのflushのロックは破棄されるべき: got %d", n)
+	}
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minRefreshInterval は未知のkidによるJWKS再取得の最短間隔。
+const minRefreshInterval = 10 * time.Second
+
+// KeySet はJWKSエンドポイントの公開鍵をTTL付きでキャッシュする。
+// TTL切れ、または未知のkid（鍵ローテーション）で再取得する。取得はロックの外で1件だけ行い、
+// 同時に来た要求はその結果を待つ。TTL切れでも手元に鍵があれば取得を待たずにそれを使う。
+type KeySet struct {
+	url      string
+	ttl      time.Duration
+	client   *http.Client
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	fetched  time.Time
+	inflight *jwksFetch
+}
+
+// jwksFetch は実行中のJWKS取得。doneが閉じたらerrが確定する。
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewKeySet は新しいKeySetを生成する。鍵は初回のKey呼び出し時（またはRefresh）に取得する。
+func NewKeySet(url string, ttl time.Duration) *KeySet {
+	return &KeySet{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key はkidに対応する公開鍵を返す。
+func (s *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	age := time.Since(s.fetched)
+	expired := s.keys == nil || age >= s.ttl
+	key, ok := lookupKey(s.keys, kid)
+	if ok && !expired {
+		s.mu.Unlock()
+		return key, nil
+	}
+
+	// 未知のkidによる連続取得は抑制する
+	if !expired && age < min(s.ttl, minRefreshInterval) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("unknown kid: %s", kid)
+	}
+	f := s.startRefresh()
+	s.mu.Unlock()
+	// 手元の鍵で検証できるなら、更新は裏で済ませる（取得に失敗しても手元の鍵で継続する）
+	if ok {
+		return key, nil
+	}
+
+	<-f.done
+	if f.err != nil {
+		return nil, fmt.Errorf("refresh JWKS: %w", f.err)
+	}
+	s.mu.Lock()
+	key, ok = lookupKey(s.keys, kid)
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown kid: %s", kid)
+	}
+	return key, nil
+}
+
+// Refresh はJWKSを即時に取得する。起動時に取得できることを確認するために使う。
+func (s *KeySet) Refresh() error {
+	s.mu.Lock()
+	f := s.startRefresh()
+	s.mu.Unlock()
+	<-f.done
+	return f.err
+}
+
+// startRefresh はJWKSの取得を始める。取得中なら新たに始めずにそれを返す。ロック保持前提。
+func (s *KeySet) startRefresh() *jwksFetch {
+	if s.inflight != nil {
+		return s.inflight
+	}
+	f := &jwksFetch{done: make(chan struct{})}
+	s.inflight = f
+	go func() {
+		keys, err := s.fetch()
+		s.mu.Lock()
+		if err == nil {
+			s.keys = keys
+			s.fetched = time.Now()
+		}
+		s.inflight = nil
+		f.err = err
+		s.mu.Unlock()
+		close(f.done)
+	}()
+	return f
+}
+
+// fetch はJWKSを取得する。キャッシュには触れないのでロックの外で呼ぶ。
+func (s *KeySet) fetch() (map[string]crypto.PublicKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch certs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("certs endpoint returned %d", resp.StatusCode)
+	}
+
+	var jwks jwksResponse
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+	return jwks.publicKeys(), nil
+}
+
+// lookupKey はkidで鍵を引く。kidが空で鍵が1つだけならそれを返す。
+func lookupKey(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// ParseJWKS はJWKS形式のJSONから署名用の公開鍵を取り出す。
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var jwks jwksResponse
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+	keys := jwks.publicKeys()
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no usable keys in JWKS")
+	}
+	return keys, nil
+}
+
+type jwksResponse struct {
+	Keys []jwkKey `json:"keys"`
+}
+
+type jwkKey struct {
+	KID string `json:"kid"`
+	KTY string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKeys は署名用のRSA/EC鍵をkidごとに返す。解釈できない鍵は無視する。
+func (j jwksResponse) publicKeys() map[string]crypto.PublicKey {
+	keys := make(map[string]crypto.PublicKey, len(j.Keys))
+	for _, k := range j.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var (
+			pub crypto.PublicKey
+			err error
+		)
+		switch k.KTY {
+		case "RSA":
+			pub, err = parseRSAPublicKey(k)
+		case "EC":
+			pub, err = parseECPublicKey(k)
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		keys[k.KID] = pub
+	}
+	return keys
+}
+
+func parseRSAPublicKey(k jwkKey) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("decode n: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("decode e: %w", err)
+	}
+
+	n := new(big.Int).SetBytes(nBytes)
+	e := new(big.Int).SetBytes(eBytes)
+
+	return &rsa.PublicKey{
+		N: n,
+		E: int(e.Int64()),
+	}, nil
+}
+
+func parseECPublicKey(k jwkKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported crv: %s", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("decode x: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("decode y: %w", err)
+	}
+
+	// 非圧縮形式（0x04 || X || Y）に組み立てて検証付きでパースする
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x) > size || len(y) > size {
+		return nil, fmt.Errorf("invalid coordinate length")
+	}
+	point := make([]byte, 1+2*size)
+	point[0] = 4
+	copy(point[1+size-len(x):1+size], x)
+	copy(point[1+2*size-len(y):], y)
+	return ecdsa.ParseUncompressedPublicKey(curve, point)
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OIDCVerifier は任意のOpenID ConnectプロバイダーのIDトークン/アクセストークンを検証する。
+// JWKSのURLはissuerのディスカバリー文書から取得する。
+type OIDCVerifier struct {
+	audience string
+	issuer   string
+	keys     *KeySet
+}
+
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// NewOIDCVerifier はディスカバリーを行い、新しいOIDCVerifierを生成する。
+// ttlはJWKSのキャッシュ期間。
+func NewOIDCVerifier(ctx context.Context, issuer, audience string, ttl time.Duration) (*OIDCVerifier, error) {
+	doc, err := discover(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	// ディスカバリー文書のissuerは設定値と一致しなければならない（OpenID Connect Discovery 1.0 §4.3）
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("issuer mismatch: got %q, want %q", doc.Issuer, issuer)
+	}
+	if doc.JWKSURI == "" {
+		return nil, fmt.Errorf("jwks_uri is missing in discovery document")
+	}
+
+	v := &OIDCVerifier{
+		audience: audience,
+		issuer:   issuer,
+		keys:     NewKeySet(doc.JWKSURI, ttl),
+	}
+	if err := v.keys.Refresh(); err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	return v, nil
+}
+
+// Verify はJWTを検証してクレームを返す。
+func (v *OIDCVerifier) Verify(tokenStr string) (*Claims, error) {
+	return verifyJWT(tokenStr, v.keys.Key, v.issuer, v.audience)
+}
+
+func discover(ctx context.Context, issuer string) (discoveryDocument, error) {
+	url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return discoveryDocument{}, fmt.Errorf("discovery request: %w", err)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return discoveryDocument{}, fmt.Errorf("fetch discovery: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return discoveryDocument{}, fmt.Errorf("discovery endpoint returned %d", resp.StatusCode)
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return discoveryDocument{}, fmt.Errorf("decode discovery: %w", err)
+	}
+	return doc, nil
+}
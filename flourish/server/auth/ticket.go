@@ -3,58 +3,118 @@ package auth
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// Grant はWSチケットが付与する権限。
+type Grant struct {
+	// Identity は発行元で検証済みの利用者（メールアドレスなど）。
+	Identity string
+	// Entries は認証済み編集を許可するエントリ。空なら全エントリ。
+	Entries []uuid.UUID
+	// ClientIP は発行を要求したクライアントのIP。空ならIPを束縛しない。
+	ClientIP string
+	// CredentialExpiresAt は発行元の認証情報（JWTなど）の期限で、WS接続はこの時刻に認証を失う。ゼロなら期限なし。
+	CredentialExpiresAt time.Time
+}
+
+// NormalizeIdentity は利用者の比較に使う正規形を返す。メールアドレスは大文字小文字を区別しない。
+func NormalizeIdentity(identity string) string {
+	return strings.ToLower(strings.TrimSpace(identity))
+}
+
+// AllowsEntry はエントリへの認証済み編集が許可されているかどうかを返す。
+func (g Grant) AllowsEntry(entryID uuid.UUID) bool {
+	return len(g.Entries) == 0 || slices.Contains(g.Entries, entryID)
+}
+
 // TicketStore はWSチケットのin-memory管理を行う。
 type TicketStore struct {
 	mu      sync.Mutex
-	tickets map[string]time.Time
+	tickets map[string]ticket
 	ttl     time.Duration
 }
 
+// ticket はチケット自体の期限と付与する権限を持つ。
+type ticket struct {
+	expiresAt time.Time
+	grant     Grant
+}
+
 // NewTicketStore は新しいTicketStoreを生成する。
 func NewTicketStore(ttl time.Duration) *TicketStore {
 	return &TicketStore{
-		tickets: make(map[string]time.Time),
+		tickets: make(map[string]ticket),
 		ttl:     ttl,
 	}
 }
 
-// Issue は新しいチケットを発行する。
-func (s *TicketStore) Issue() string {
+// Issue は新しいチケットを発行する。利用者はNormalizeIdentityで正規化して記録する。
+func (s *TicketStore) Issue(grant Grant) string {
 	b := make([]byte, 32)
 	rand.Read(b)
-	ticket := hex.EncodeToString(b)
+	id := hex.EncodeToString(b)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.cleanup()
-	s.tickets[ticket] = time.Now().Add(s.ttl)
-	return ticket
+	grant.Identity = NormalizeIdentity(grant.Identity)
+	grant.Entries = slices.Clone(grant.Entries)
+	s.tickets[id] = ticket{
+		expiresAt: time.Now().Add(s.ttl),
+		grant:     grant,
+	}
+	return id
 }
 
-// Redeem はチケットを消費する。有効なら true、無効/期限切れなら false。
-func (s *TicketStore) Redeem(ticket string) bool {
+// Redeem はチケットを消費し、付与された権限を返す。
+// 無効/期限切れ、または発行時とクライアントIPが異なる場合は false。
+func (s *TicketStore) Redeem(id, clientIP string) (Grant, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.cleanup()
-	exp, ok := s.tickets[ticket]
+	t, ok := s.tickets[id]
 	if !ok {
-		return false
+		return Grant{}, false
+	}
+	delete(s.tickets, id)
+	if !time.Now().Before(t.expiresAt) {
+		return Grant{}, false
+	}
+	if t.grant.ClientIP != "" && t.grant.ClientIP != clientIP {
+		return Grant{}, false
+	}
+	return t.grant, true
+}
+
+// RevokeIdentity は利用者に発行済みの未使用チケットを無効化し、無効化した数を返す。
+func (s *TicketStore) RevokeIdentity(identity string) int {
+	identity = NormalizeIdentity(identity)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for k, t := range s.tickets {
+		if t.grant.Identity == identity {
+			delete(s.tickets, k)
+			n++
+		}
 	}
-	delete(s.tickets, ticket)
-	return time.Now().Before(exp)
+	return n
 }
 
 // cleanup は期限切れチケットを削除する。ロック保持前提。
 func (s *TicketStore) cleanup() {
 	now := time.Now()
-	for k, exp := range s.tickets {
-		if now.After(exp) {
+	for k, t := range s.tickets {
+		if now.After(t.expiresAt) {
 			delete(s.tickets, k)
 		}
 	}
@@ -0,0 +1,58 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/auth"
+)
+
+func TestTicketStore_Redeem(t *testing.T) {
+	s := auth.NewTicketStore(time.Minute)
+	entryID := uuid.New()
+	exp := time.Now().Add(time.Hour)
+
+	ticket := s.Issue(auth.Grant{Identity: "alice@example.com", Entries: []uuid.UUID{entryID}, ClientIP: "192.0.2.1", CredentialExpiresAt: exp})
+
+	if _, ok := s.Redeem(ticket, "192.0.2.99"); ok {
+		t.Error("ticket redeemed from another IP should be rejected")
+	}
+	// IP不一致でも消費される（使い回し防止）
+	if _, ok := s.Redeem(ticket, "192.0.2.1"); ok {
+		t.Error("ticket should be single-use")
+	}
+
+	ticket = s.Issue(auth.Grant{Identity: "alice@example.com", Entries: []uuid.UUID{entryID}, ClientIP: "192.0.2.1", CredentialExpiresAt: exp})
+	grant, ok := s.Redeem(ticket, "192.0.2.1")
+	if !ok {
+		t.Fatal("ticket should be redeemable")
+	}
+	if grant.Identity != "alice@example.com" || !grant.CredentialExpiresAt.Equal(exp) {
+		t.Errorf("grant: got %+v", grant)
+	}
+	if !grant.AllowsEntry(entryID) || grant.AllowsEntry(uuid.New()) {
+		t.Error("grant should only allow listed entries")
+	}
+	if !(auth.Grant{}).AllowsEntry(uuid.New()) {
+		t.Error("grant without entries should allow all entries")
+	}
+}
+
+func TestTicketStore_RevokeIdentity(t *testing.T) {
+	s := auth.NewTicketStore(time.Minute)
+	alice := s.Issue(auth.Grant{Identity: "Alice@Example.com"})
+	bob := s.Issue(auth.Grant{Identity: "bob@example.com"})
+
+	// 大文字小文字の違いは同じ利用者として扱う
+	if n := s.RevokeIdentity("alice@example.com"); n != 1 {
+		t.Errorf("revoked: got %d, want 1", n)
+	}
+	if _, ok := s.Redeem(alice, ""); ok {
+		t.Error("revoked ticket should be rejected")
+	}
+	if _, ok := s.Redeem(bob, ""); !ok {
+		t.Error("other identity's ticket should remain valid")
+	}
+}
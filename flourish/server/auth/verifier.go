@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Verifier はリクエストに付与されたJWTを検証する。
+type Verifier interface {
+	Verify(tokenStr string) (*Claims, error)
+}
+
+// Claims は検証済みJWTのクレーム。
+type Claims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"`
+}
+
+// signingMethods は受け付ける署名アルゴリズム（RS/ESのみ。HSやnoneは拒否する）。
+var signingMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// verifyJWT は署名・issuer・audience・有効期限を検証してクレームを返す。
+// issuer/audienceが空なら該当の検証を省略する。
+func verifyJWT(tokenStr string, key func(kid string) (crypto.PublicKey, error), issuer, audience string) (*Claims, error) {
+	opts := []jwt.ParserOption{jwt.WithValidMethods(signingMethods), jwt.WithExpirationRequired()}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return key(kid)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
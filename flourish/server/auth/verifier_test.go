@@ -0,0 +1,255 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"flourish/server/auth"
+)
+
+// idp はディスカバリーとJWKSを提供するテスト用のOIDCプロバイダー。
+type idp struct {
+	srv     *httptest.Server
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetches atomic.Int32
+}
+
+func newIDP(t *testing.T, keys map[string]crypto.PublicKey) *idp {
+	t.Helper()
+	p := &idp{keys: keys}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   p.srv.URL,
+			"jwks_uri": p.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.fetches.Add(1)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		w.Write(jwks(t, p.keys))
+	})
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+func (p *idp) rotate(keys map[string]crypto.PublicKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+}
+
+func jwks(t *testing.T, keys map[string]crypto.PublicKey) []byte {
+	t.Helper()
+	enc := base64.RawURLEncoding.EncodeToString
+	var out []map[string]string
+	for kid, key := range keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			out = append(out, map[string]string{
+				"kid": kid, "kty": "RSA", "use": "sig",
+				"n": enc(k.N.Bytes()), "e": enc(big.NewInt(int64(k.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			b, err := k.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			size := (len(b) - 1) / 2
+			out = append(out, map[string]string{
+				"kid": kid, "kty": "EC", "crv": k.Curve.Params().Name,
+				"x": enc(b[1 : 1+size]), "y": enc(b[1+size:]),
+			})
+		}
+	}
+	data, _ := json.Marshal(map[string]any{"keys": out})
+	return data
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, iss, aud string, exp time.Time) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    iss,
+			Audience:  jwt.ClaimStrings{aud},
+			Subject:   "user-1",
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+		Email: "user@example.com",
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestOIDCVerifier_Verify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p := newIDP(t, map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey})
+
+	v, err := auth.NewOIDCVerifier(t.Context(), p.srv.URL, "flourish", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := p.srv.URL
+	exp := time.Now().Add(time.Hour)
+
+	valid := map[string]string{
+		"RS256": sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, iss, "flourish", exp),
+		"ES256": sign(t, jwt.SigningMethodES256, "ec", ecKey, iss, "flourish", exp),
+	}
+	for name, token := range valid {
+		claims, err := v.Verify(token)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if claims.Email != "user@example.com" || claims.Subject != "user-1" {
+			t.Errorf("%s: claims: got %+v", name, claims)
+		}
+	}
+
+	invalid := map[string]string{
+		"wrong audience":   sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, iss, "other", exp),
+		"wrong issuer":     sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, "https://evil.example", "flourish", exp),
+		"expired":          sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, iss, "flourish", time.Now().Add(-time.Minute)),
+		"unknown kid":      sign(t, jwt.SigningMethodRS256, "missing", rsaKey, iss, "flourish", exp),
+		"kid/alg mismatch": sign(t, jwt.SigningMethodES256, "rsa", ecKey, iss, "flourish", exp),
+		"hmac":             sign(t, jwt.SigningMethodHS256, "rsa", []byte("secret"), iss, "flourish", exp),
+	}
+	for name, token := range invalid {
+		if _, err := v.Verify(token); err == nil {
+			t.Errorf("%s: should be rejected", name)
+		}
+	}
+}
+
+func TestOIDCVerifier_CacheAndRotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p := newIDP(t, map[string]crypto.PublicKey{"old": &oldKey.PublicKey})
+
+	ttl := 50 * time.Millisecond
+	v, err := auth.NewOIDCVerifier(t.Context(), p.srv.URL, "flourish", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour)
+	oldToken := sign(t, jwt.SigningMethodRS256, "old", oldKey, p.srv.URL, "flourish", exp)
+
+	// TTL内はキャッシュを使う
+	for range 3 {
+		if _, err := v.Verify(oldToken); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := p.fetches.Load(); got != 1 {
+		t.Errorf("JWKS fetches within TTL: got %d, want 1", got)
+	}
+
+	// 鍵をローテーションすると、TTL経過後に新しい鍵で検証でき、古い鍵は使えなくなる
+	p.rotate(map[string]crypto.PublicKey{"new": &newKey.PublicKey})
+	time.Sleep(2 * ttl)
+
+	newToken := sign(t, jwt.SigningMethodRS256, "new", newKey, p.srv.URL, "flourish", exp)
+	if _, err := v.Verify(newToken); err != nil {
+		t.Errorf("rotated key: %v", err)
+	}
+	if _, err := v.Verify(oldToken); err == nil {
+		t.Error("old key should be rejected after rotation")
+	}
+}
+
+func TestOIDCVerifier_ServesCachedKeyWhileRefreshing(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p := newIDP(t, map[string]crypto.PublicKey{"k": &key.PublicKey})
+
+	ttl := 50 * time.Millisecond
+	v, err := auth.NewOIDCVerifier(t.Context(), p.srv.URL, "flourish", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, jwt.SigningMethodRS256, "k", key, p.srv.URL, "flourish", time.Now().Add(time.Hour))
+	time.Sleep(2 * ttl)
+
+	// JWKSの応答を止めても、TTL切れの手元の鍵で待たずに検証でき、取得は1件にまとまる
+	p.mu.Lock()
+	start := time.Now()
+	for range 5 {
+		if _, err := v.Verify(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("verify waited for the JWKS refresh: %v", elapsed)
+	}
+	for deadline := time.Now().Add(2 * time.Second); p.fetches.Load() < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	p.mu.Unlock()
+	if got := p.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetches: got %d, want 2", got)
+	}
+}
+
+func TestOIDCVerifier_IssuerMismatch(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p := newIDP(t, map[string]crypto.PublicKey{"k": &key.PublicKey})
+
+	if _, err := auth.NewOIDCVerifier(t.Context(), p.srv.URL+"/", "flourish", time.Hour); err == nil {
+		t.Error("issuer differing from discovery document should fail")
+	}
+}
+
+func TestStaticVerifier(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwks(t, map[string]crypto.PublicKey{"dev": &key.PublicKey}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// issuer/audience未設定なら検証を省略する
+	v, err := auth.LoadStaticVerifier(path, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour)
+
+	// 鍵が1つならkid無しのトークンも受け付ける
+	if _, err := v.Verify(sign(t, jwt.SigningMethodES384, "", key, "dev", "any", exp)); err != nil {
+		t.Errorf("kid-less token: %v", err)
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, err := v.Verify(sign(t, jwt.SigningMethodES384, "dev", other, "dev", "any", exp)); err == nil {
+		t.Error("token signed by another key should be rejected")
+	}
+
+	if _, err := auth.ParseJWKS([]byte(`{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`)); err == nil {
+		t.Error("JWKS without usable keys should fail")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"flourish/server/fsck"
+)
+
+// runFsck はfsckサブコマンドを実行し、終了コードを返す。
+// 不整合が無い（または全て修復した）なら0、残っていれば1、実行に失敗したら2。
+func runFsck(args []string, dataDir string, log *slog.Logger) int {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "修復できる不整合を修復する")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err := fsck.Run(context.Background(), dataDir, fsck.Options{
+		Repair:     *repair,
+		Moderation: os.Getenv("MODERATION") == "true",
+	}, log)
+	if err != nil {
+		log.Error("fsckエラー", "error", err)
+		return 2
+	}
+
+	for _, p := range report.Problems {
+		fmt.Println(p)
+	}
+	fmt.Printf("%d problem(s), %d unrepaired\n", len(report.Problems), report.Unrepaired())
+	if report.Unrepaired() > 0 {
+		return 1
+	}
+	return 0
+}
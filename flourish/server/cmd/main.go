@@ -2,13 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"flourish/server"
 	"flourish/server/adapter/jsonfile"
+	"flourish/server/adapter/mesh"
 	"flourish/server/application"
 	"flourish/server/auth"
 	"flourish/server/handler"
@@ -58,9 +64,37 @@ func main() {
 	} else {
 		log = logger.New(cfg)
 	}
-	logger.PrintBanner(cfg, addr, "")
-
 	dataDir := envOrDefault("DATA_DIR", "data")
+	// MODERATION=trueで非認証insertを承認まで非公開にする。投影の再構築も同じ設定で行う
+	moderation := os.Getenv("MODERATION") == "true"
+
+	// rebuild-projections: イベントログから投影を作り直して終了する（サーバー停止中に実行する）
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-projections" {
+		if err := rebuildProjections(context.Background(), dataDir, moderation, log); err != nil {
+			log.Error("投影の再構築エラー", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// create-token -name NAME [-scopes admin]: APIトークンを発行して秘密を表示する（サーバー停止中に実行する）。
+	// JWTの検証器が無い構成では、最初のトークンをこれで発行する
+	if len(os.Args) > 1 && os.Args[1] == "create-token" {
+		os.Exit(runCreateToken(os.Args[2:], dataDir, log))
+	}
+	// fsck [-repair]: データディレクトリの整合性を検査する（サーバー停止中に実行する）
+	if len(os.Args) > 1 && os.Args[1] == "fsck" {
+		os.Exit(runFsck(os.Args[2:], dataDir, log))
+	}
+	// promote: フォロワーのデータディレクトリをリーダーに昇格させる（サーバー停止中に実行する）
+	if len(os.Args) > 1 && os.Args[1] == "promote" {
+		os.Exit(runPromote(dataDir, log))
+	}
+	// replication-check [-leader URL] [-follower URL]: リーダーとフォロワーのイベントログを比べる
+	if len(os.Args) > 1 && os.Args[1] == "replication-check" {
+		os.Exit(runReplicationCheck(os.Args[2:], dataDir, log))
+	}
+
+	logger.PrintBanner(cfg, addr, "")
 
 	entryStore, err := jsonfile.NewEntryStore(dataDir)
 	if err != nil {
@@ -72,15 +106,119 @@ func main() {
 		log.Error("event store初期化エラー", "error", err)
 		os.Exit(1)
 	}
+	for _, p := range eventStore.LoadProblems() {
+		if p.Kind == jsonfile.ProblemTornTail {
+			log.Warn("イベントログ末尾の書きかけの行を切り詰めました", "path", p.Path, "detail", p.Detail)
+		} else {
+			log.Error("イベントログに読めない行があります（fsck -repairで修復できます）", "path", p.Path, "line", p.Line, "error", p.Detail)
+		}
+	}
+	// イベントログのfsync方針（EVENT_FSYNC=always|interval|never、既定はalways）
+	syncPolicy, err := jsonfile.ParseSyncPolicy(os.Getenv("EVENT_FSYNC"))
+	if err != nil {
+		log.Error("EVENT_FSYNCが不正", "error", err)
+		os.Exit(1)
+	}
+	syncInterval, err := time.ParseDuration(envOrDefault("EVENT_FSYNC_INTERVAL", "1s"))
+	if err != nil {
+		log.Error("EVENT_FSYNC_INTERVALが不正", "error", err)
+		os.Exit(1)
+	}
+	eventStore.SetSyncPolicy(syncPolicy, syncInterval)
+	defer eventStore.Close()
 	rgaStateStore, err := jsonfile.NewRGAStateStore(dataDir)
 	if err != nil {
 		log.Error("rga state store初期化エラー", "error", err)
 		os.Exit(1)
 	}
 
+	// 監査ログ（AUDIT_DIRで本体とは別の保存先を指定できる）
+	auditStore, err := jsonfile.NewAuditStore(envOrDefault("AUDIT_DIR", dataDir))
+	if err != nil {
+		log.Error("audit store初期化エラー", "error", err)
+		os.Exit(1)
+	}
+	retentionDays, err := strconv.Atoi(envOrDefault("AUDIT_RETENTION_DAYS", "90"))
+	if err != nil {
+		log.Error("AUDIT_RETENTION_DAYSが不正", "error", err)
+		os.Exit(1)
+	}
+	auditLog := application.NewAuditLog(auditStore, time.Duration(retentionDays)*24*time.Hour, log)
+	go auditLog.RunRetention(context.Background(), 24*time.Hour)
+
 	syncService := application.NewSyncService(eventStore)
+	// 購読者ごとの送信キュー（SUBSCRIBER_OVERFLOW=coalesce|disconnect）
+	outboxConfig := application.DefaultOutboxConfig
+	if outboxConfig.Capacity, err = strconv.Atoi(envOrDefault("SUBSCRIBER_QUEUE_SIZE", strconv.Itoa(outboxConfig.Capacity))); err != nil {
+		log.Error("SUBSCRIBER_QUEUE_SIZEが不正", "error", err)
+		os.Exit(1)
+	}
+	if outboxConfig.WriteTimeout, err = time.ParseDuration(envOrDefault("SUBSCRIBER_WRITE_TIMEOUT", outboxConfig.WriteTimeout.String())); err != nil {
+		log.Error("SUBSCRIBER_WRITE_TIMEOUTが不正", "error", err)
+		os.Exit(1)
+	}
+	if outboxConfig.Policy, err = application.ParseOverflowPolicy(os.Getenv("SUBSCRIBER_OVERFLOW")); err != nil {
+		log.Error("SUBSCRIBER_OVERFLOWが不正", "error", err)
+		os.Exit(1)
+	}
+	syncService.SetOutboxConfig(outboxConfig)
+	// エントリ一覧の購読者へのentry_updateを間引く間隔
+	listUpdateInterval, err := time.ParseDuration(envOrDefault("LIST_UPDATE_INTERVAL", application.DefaultListUpdateInterval.String()))
+	if err != nil {
+		log.Error("LIST_UPDATE_INTERVALが不正", "error", err)
+		os.Exit(1)
+	}
+	syncService.SetListUpdateInterval(listUpdateInterval)
+	// 複数インスタンス間のfan-out（BUS=local|tcp）。tcpは全インスタンスが同じイベントログを共有する
+	// EventStoreでしか使えない。jsonfileはインスタンスごとのログなので拒否する
+	// （複数台で配信するならREPLICATION_LEADERでフォロワーを立てる）。
+	switch bus := envOrDefault("BUS", "local"); bus {
+	case "local":
+	case "tcp":
+		meshBus, err := newMeshBus(log)
+		if err != nil {
+			log.Error("BUSの初期化エラー", "error", err)
+			os.Exit(1)
+		}
+		defer meshBus.Close()
+		if err := syncService.SetBus(meshBus); err != nil {
+			log.Error("BUS=tcpはこのEventStoreでは使えません", "error", err)
+			meshBus.Close()
+			os.Exit(1)
+		}
+		log.Info("mesh bus listening", "addr", meshBus.Addr().String())
+	default:
+		log.Error("BUSが不正", "value", bus)
+		os.Exit(1)
+	}
 	markdownDir := filepath.Join(dataDir, "markdown")
 	projector := application.NewEntryProjector(entryStore, rgaStateStore, markdownDir, log)
+	// 投影のシャード数（別シャードのエントリは並行に投影する）
+	shards, err := strconv.Atoi(envOrDefault("PROJECTOR_SHARDS", strconv.Itoa(application.DefaultProjectorShards)))
+	if err != nil || shards < 1 {
+		log.Error("PROJECTOR_SHARDSが不正", "value", os.Getenv("PROJECTOR_SHARDS"))
+		os.Exit(1)
+	}
+	projector.SetShards(shards)
+	projector.SetAuditLog(auditLog)
+	if moderation {
+		projector.SetModeration(true)
+		log.Info("モデレーション有効（非認証insertは承認まで非公開）")
+	}
+	// 投影の書き込み遅延（PROJECTION_FLUSH_DELAY=0で無効、適用のたびに永続化する）。
+	// entries.jsonへの書き込みは1件ずつなので、並行に編集されるエントリが多いなら有効にする
+	flushDelay, err := time.ParseDuration(envOrDefault("PROJECTION_FLUSH_DELAY", "0"))
+	if err != nil {
+		log.Error("PROJECTION_FLUSH_DELAYが不正", "error", err)
+		os.Exit(1)
+	}
+	flushMaxOps, err := strconv.Atoi(envOrDefault("PROJECTION_FLUSH_MAX_OPS", "100"))
+	if err != nil {
+		log.Error("PROJECTION_FLUSH_MAX_OPSが不正", "error", err)
+		os.Exit(1)
+	}
+	projector.SetWriteBehind(flushDelay, flushMaxOps)
+	syncService.SetProjector(projector)
 
 	// 起動時にEventStoreからRGA復元
 	entryIDs := eventStore.EntryIDs()
@@ -89,27 +227,88 @@ func main() {
 		os.Exit(1)
 	}
 
-	// 認証セットアップ（CF_ACCESS_TEAM_DOMAIN + CF_ACCESS_AUDIENCE が設定されている場合のみ有効）
+	// 認証セットアップ（CF Access → OIDC → 静的鍵の順に、設定されているものを使う）。
+	// いずれも無くても、APIトークンが発行済みならトークンだけで認証する
 	var authHandler *handler.Auth
-	cfTeamDomain := os.Getenv("CF_ACCESS_TEAM_DOMAIN")
-	cfAudience := os.Getenv("CF_ACCESS_AUDIENCE")
-	if cfTeamDomain != "" && cfAudience != "" {
-		cfAccess, err := auth.NewCFAccessVerifier(cfTeamDomain, cfAudience)
+	verifier, logoutURL, err := newVerifier(log)
+	if err != nil {
+		log.Error("認証初期化エラー", "error", err)
+		os.Exit(1)
+	}
+	tokenStore, err := jsonfile.NewAPITokenStore(dataDir)
+	if err != nil {
+		log.Error("token store初期化エラー", "error", err)
+		os.Exit(1)
+	}
+	tokenService := application.NewAPITokenService(tokenStore)
+	// 発行済みのAPIトークンがあれば、JWTの検証器が無くてもトークンで認証する（無視すると誰でも管理操作ができてしまう）
+	tokens, err := tokenService.List(context.Background())
+	if err != nil {
+		log.Error("token一覧取得エラー", "error", err)
+		os.Exit(1)
+	}
+	if verifier != nil || len(tokens) > 0 {
+		if verifier == nil {
+			log.Info("JWT認証無効（CF_ACCESS_*/OIDC_*/AUTH_STATIC_JWKS未設定）、APIトークンのみで認証", "tokens", len(tokens))
+		}
+		ticketStore := auth.NewTicketStore(1 * time.Minute)
+		authHandler = handler.NewAuth(verifier, ticketStore, tokenService, logoutURL)
+		authHandler.SetCloseWSOnExpiry(os.Getenv("WS_CLOSE_ON_AUTH_EXPIRY") == "true")
+		trustedProxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
 		if err != nil {
-			log.Error("CF Access初期化エラー", "error", err)
+			log.Error("TRUSTED_PROXIESが不正", "error", err)
 			os.Exit(1)
 		}
-		ticketStore := auth.NewTicketStore(1 * time.Minute)
-		authHandler = handler.NewAuth(cfAccess, ticketStore, cfTeamDomain)
-		log.Info("CF Access認証有効", "teamDomain", cfTeamDomain)
+		authHandler.SetTrustedProxies(trustedProxies)
+	} else {
+		log.Info("認証無効（CF_ACCESS_*/OIDC_*/AUTH_STATIC_JWKS未設定、APIトークンも無し）。トークンで認証するにはcreate-tokenで発行する")
+	}
+
+	tagService := application.NewTagService(syncService, projector, entryStore)
+	moderationService := application.NewModerationService(syncService, projector)
+	suggestionService := application.NewSuggestionService(syncService, projector)
+	lockService := application.NewLockService(syncService, entryStore)
+
+	// ゴミ箱の保持期間（TRASH_RETENTION_DAYS=0で自動の完全削除を無効化）
+	trashDays, err := strconv.Atoi(envOrDefault("TRASH_RETENTION_DAYS", "30"))
+	if err != nil {
+		log.Error("TRASH_RETENTION_DAYSが不正", "error", err)
+		os.Exit(1)
+	}
+	trashService := application.NewTrashService(syncService, projector, entryStore, eventStore, time.Duration(trashDays)*24*time.Hour, log)
+	// フォロワー（REPLICATION_LEADER=リーダーのURL）はリーダーのイベントログを複製し、昇格するまで読み取り専用。
+	// 完全削除もリーダーから複製するので、ゴミ箱の保持期間は昇格してから適用する
+	replicator, err := newReplicator(dataDir, eventStore, entryStore, syncService, projector, log)
+	if err != nil {
+		log.Error("レプリケーション設定が不正", "error", err)
+		os.Exit(1)
+	}
+	if replicator != nil {
+		replicator.SetOnPromote(func() error {
+			if err := jsonfile.MarkPromoted(dataDir, time.Now()); err != nil {
+				return err
+			}
+			go trashService.RunRetention(context.Background(), 24*time.Hour)
+			return nil
+		})
 	} else {
-		log.Info("認証無効（CF_ACCESS_TEAM_DOMAIN/CF_ACCESS_AUDIENCE未設定）")
+		go trashService.RunRetention(context.Background(), 24*time.Hour)
+	}
+
+	wsConfig, err := newWSConfig()
+	if err != nil {
+		log.Error("WebSocket設定が不正", "error", err)
+		os.Exit(1)
 	}
 
-	router := server.NewRouter(log, entryStore, syncService, projector, authHandler)
+	entryService := application.NewEntryService(syncService, entryStore)
+	router := server.NewRouter(log, entryStore, syncService, entryService, projector, tagService, moderationService, suggestionService, lockService, trashService, tokenService, auditLog, authHandler, wsConfig, application.NewLogSource(eventStore), replicator)
 	srv := server.New(addr, router, log)
 
-	if err := srv.Run(); err != nil {
+	err = srv.Run()
+	// 書き込み遅延中の投影を永続化してから終了する
+	projector.Flush(context.Background())
+	if err != nil {
 		log.Error("server error", "error", err)
 		os.Exit(1)
 	}
@@ -121,3 +320,112 @@ func envOrDefault(key, defaultVal string) string {
 	}
 	return defaultVal
 }
+
+// newMeshBus は環境変数からTCPメッシュのBusを生成する。
+// BUS_PEERSはカンマ区切りの他のインスタンスのBUS_LISTEN_ADDR。BUS_SECRETは必須。
+// BUS_LISTEN_ADDRの既定はループバックで、他のホストとつなぐときは暗号化されないので
+// プライベートネットワークかTLSのトンネルの内側のアドレスを指定する。
+func newMeshBus(log *slog.Logger) (*mesh.Bus, error) {
+	cfg := mesh.Config{
+		ListenAddr: envOrDefault("BUS_LISTEN_ADDR", mesh.DefaultListenAddr),
+		Secret:     os.Getenv("BUS_SECRET"),
+	}
+	if cfg.Secret == "" {
+		return nil, errors.New("BUS_SECRET is required for BUS=tcp")
+	}
+	for _, peer := range strings.Split(os.Getenv("BUS_PEERS"), ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			cfg.Peers = append(cfg.Peers, peer)
+		}
+	}
+	var err error
+	if cfg.QueueSize, err = strconv.Atoi(envOrDefault("BUS_QUEUE_SIZE", "1024")); err != nil {
+		return nil, fmt.Errorf("BUS_QUEUE_SIZE: %w", err)
+	}
+	return mesh.New(cfg, log)
+}
+
+// parseTrustedProxies はカンマ区切りのCIDRまたはIPアドレスを解析する。
+func parseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(v); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// newWSConfig は環境変数からWebSocket接続の設定を生成する。
+// WS_ALLOWED_ORIGINSはカンマ区切りのOriginパターンで、未設定なら同一オリジンのみ許可する。
+func newWSConfig() (handler.WSConfig, error) {
+	cfg := handler.DefaultWSConfig
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+		}
+	}
+	var err error
+	if cfg.PingInterval, err = time.ParseDuration(envOrDefault("WS_PING_INTERVAL", cfg.PingInterval.String())); err != nil {
+		return cfg, fmt.Errorf("WS_PING_INTERVAL: %w", err)
+	}
+	if cfg.IdleTimeout, err = time.ParseDuration(envOrDefault("WS_IDLE_TIMEOUT", cfg.IdleTimeout.String())); err != nil {
+		return cfg, fmt.Errorf("WS_IDLE_TIMEOUT: %w", err)
+	}
+	if cfg.MaxMessageBytes, err = strconv.ParseInt(envOrDefault("WS_MAX_MESSAGE_BYTES", strconv.FormatInt(cfg.MaxMessageBytes, 10)), 10, 64); err != nil {
+		return cfg, fmt.Errorf("WS_MAX_MESSAGE_BYTES: %w", err)
+	}
+	if cfg.OpRate, err = strconv.ParseFloat(envOrDefault("WS_OP_RATE", strconv.FormatFloat(cfg.OpRate, 'f', -1, 64)), 64); err != nil {
+		return cfg, fmt.Errorf("WS_OP_RATE: %w", err)
+	}
+	if cfg.OpBurst, err = strconv.Atoi(envOrDefault("WS_OP_BURST", strconv.Itoa(cfg.OpBurst))); err != nil {
+		return cfg, fmt.Errorf("WS_OP_BURST: %w", err)
+	}
+	if cfg.MaxSubscriptions, err = strconv.Atoi(envOrDefault("WS_MAX_SUBSCRIPTIONS", strconv.Itoa(cfg.MaxSubscriptions))); err != nil {
+		return cfg, fmt.Errorf("WS_MAX_SUBSCRIPTIONS: %w", err)
+	}
+	return cfg, nil
+}
+
+// newVerifier は環境変数からJWT検証器を生成する。いずれも未設定ならnilを返す。
+func newVerifier(log *slog.Logger) (auth.Verifier, string, error) {
+	if teamDomain, audience := os.Getenv("CF_ACCESS_TEAM_DOMAIN"), os.Getenv("CF_ACCESS_AUDIENCE"); teamDomain != "" && audience != "" {
+		v, err := auth.NewCFAccessVerifier(teamDomain, audience)
+		if err != nil {
+			return nil, "", fmt.Errorf("cf access: %w", err)
+		}
+		log.Info("CF Access認証有効", "teamDomain", teamDomain)
+		return v, fmt.Sprintf("https://%s.cloudflareaccess.com/cdn-cgi/access/logout", teamDomain), nil
+	}
+
+	if issuer, audience := os.Getenv("OIDC_ISSUER"), os.Getenv("OIDC_AUDIENCE"); issuer != "" && audience != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		v, err := auth.NewOIDCVerifier(ctx, issuer, audience, time.Hour)
+		if err != nil {
+			return nil, "", fmt.Errorf("oidc: %w", err)
+		}
+		log.Info("OIDC認証有効", "issuer", issuer)
+		return v, os.Getenv("OIDC_LOGOUT_URL"), nil
+	}
+
+	if path := os.Getenv("AUTH_STATIC_JWKS"); path != "" {
+		v, err := auth.LoadStaticVerifier(path, os.Getenv("AUTH_STATIC_ISSUER"), os.Getenv("AUTH_STATIC_AUDIENCE"))
+		if err != nil {
+			return nil, "", fmt.Errorf("static keys: %w", err)
+		}
+		log.Warn("静的鍵による認証有効（開発用）", "jwks", path)
+		return v, "", nil
+	}
+
+	return nil, "", nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"flourish/server/adapter/jsonfile"
+	"flourish/server/application"
+)
+
+// rebuiltPaths はrebuild-projectionsで作り直す、dataDir配下の投影結果。
+var rebuiltPaths = []string{"entries.json", "rga_states", "markdown"}
+
+// rebuildProjections はイベントログだけからentries.json・RGAスナップショット・markdownを作り直す。
+// 作業用ディレクトリに投影してから差し替えるので、途中で失敗しても既存の投影は残る。
+// moderationはサーバーと同じ設定を渡す（異なると承認待ちの非認証insertが公開される）。
+func rebuildProjections(ctx context.Context, dataDir string, moderation bool, log *slog.Logger) error {
+	eventStore, err := jsonfile.NewEventStore(dataDir)
+	if err != nil {
+		return fmt.Errorf("event store: %w", err)
+	}
+	defer eventStore.Close()
+
+	tmp, err := os.MkdirTemp(dataDir, "rebuild-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	entryStore, err := jsonfile.NewEntryStore(tmp)
+	if err != nil {
+		return fmt.Errorf("entry store: %w", err)
+	}
+	rgaStateStore, err := jsonfile.NewRGAStateStore(tmp)
+	if err != nil {
+		return fmt.Errorf("rga state store: %w", err)
+	}
+	projector := application.NewEntryProjector(entryStore, rgaStateStore, filepath.Join(tmp, "markdown"), log)
+	projector.SetModeration(moderation)
+
+	entryIDs := eventStore.EntryIDs()
+	if err := projector.Restore(ctx, eventStore, entryIDs); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	for _, name := range rebuiltPaths {
+		if err := os.RemoveAll(filepath.Join(dataDir, name)); err != nil {
+			return err
+		}
+		// エントリが1件も無ければentries.jsonは作られない
+		if _, err := os.Stat(filepath.Join(tmp, name)); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(filepath.Join(tmp, name), filepath.Join(dataDir, name)); err != nil {
+			return err
+		}
+	}
+	log.Info("投影を再構築しました", "entries", len(entryIDs))
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"flourish/server/adapter/jsonfile"
+	"flourish/server/application"
+	"flourish/server/handler"
+)
+
+// newReplicator はREPLICATION_LEADERが設定されていればフォロワーとしてリーダーの追従を始める。
+// 未設定か、データディレクトリが昇格済みならnil（リーダーとして起動する）。
+func newReplicator(dataDir string, eventStore *jsonfile.EventStore, entryStore *jsonfile.EntryStore, syncService *application.SyncService, projector *application.EntryProjector, log *slog.Logger) (*application.Replicator, error) {
+	leaderURL := os.Getenv("REPLICATION_LEADER")
+	if leaderURL == "" {
+		return nil, nil
+	}
+	promotedAt, promoted, err := jsonfile.PromotedAt(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if promoted {
+		log.Warn("昇格済みのためREPLICATION_LEADERを無視してリーダーとして起動します", "promotedAt", promotedAt)
+		return nil, nil
+	}
+	interval, err := time.ParseDuration(envOrDefault("REPLICATION_INTERVAL", "1s"))
+	if err != nil {
+		return nil, fmt.Errorf("REPLICATION_INTERVAL: %w", err)
+	}
+
+	client := handler.NewLeaderClient(leaderURL, os.Getenv("REPLICATION_TOKEN"), &http.Client{Timeout: 30 * time.Second})
+	replicator := application.NewReplicator(client, eventStore, entryStore, syncService, projector, log)
+	go replicator.Run(context.Background(), interval)
+	log.Info("フォロワーとして起動（昇格するまで読み取り専用）", "leader", leaderURL, "interval", interval)
+	return replicator, nil
+}
+
+// runPromote はpromoteサブコマンドを実行し、終了コードを返す。
+// 稼働中のフォロワーは POST /api/admin/replication/promote で再起動せずに昇格できる。
+func runPromote(dataDir string, log *slog.Logger) int {
+	if err := jsonfile.MarkPromoted(dataDir, time.Now()); err != nil {
+		log.Error("昇格エラー", "error", err)
+		return 2
+	}
+	fmt.Println("promoted: 次の起動からREPLICATION_LEADERを無視してリーダーとして動作します")
+	return 0
+}
+
+// runReplicationCheck はreplication-checkサブコマンドを実行し、終了コードを返す。
+// -followerを省略するとこのデータディレクトリのイベントログと比べる（サーバー停止中に実行する）。
+// 複製が壊れていなければ0（追いついていないだけの差異は表示のみ）、壊れていれば1、実行に失敗したら2。
+func runReplicationCheck(args []string, dataDir string, log *slog.Logger) int {
+	fs := flag.NewFlagSet("replication-check", flag.ContinueOnError)
+	leaderURL := fs.String("leader", os.Getenv("REPLICATION_LEADER"), "リーダーのURL")
+	followerURL := fs.String("follower", "", "フォロワーのURL（省略時はDATA_DIRのイベントログ）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *leaderURL == "" {
+		log.Error("リーダーのURLが未指定（-leaderまたはREPLICATION_LEADER）")
+		return 2
+	}
+	token := os.Getenv("REPLICATION_TOKEN")
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	var follower application.ReplicationSource
+	if *followerURL != "" {
+		follower = handler.NewLeaderClient(*followerURL, token, httpClient)
+	} else {
+		eventStore, err := jsonfile.NewEventStore(dataDir)
+		if err != nil {
+			log.Error("event store初期化エラー", "error", err)
+			return 2
+		}
+		defer eventStore.Close()
+		follower = application.NewLogSource(eventStore)
+	}
+
+	found, err := application.CompareLogs(context.Background(), handler.NewLeaderClient(*leaderURL, token, httpClient), follower)
+	if err != nil {
+		log.Error("replication-checkエラー", "error", err)
+		return 2
+	}
+	broken := 0
+	for _, d := range found {
+		fmt.Println(d)
+		if d.Broken() {
+			broken++
+		}
+	}
+	fmt.Printf("%d difference(s), %d diverged\n", len(found), broken)
+	if broken > 0 {
+		return 1
+	}
+	return 0
+}
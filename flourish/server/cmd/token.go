@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"flourish/server/adapter/jsonfile"
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// runCreateToken はcreate-tokenサブコマンドを実行し、終了コードを返す。
+// JWTの検証器が無いとトークン発行のAPIが使えないので、最初のトークンはこれで発行する。
+// 秘密は標準出力に1度だけ表示する。発行後にサーバーを起動すると、トークンで認証するようになる。
+func runCreateToken(args []string, dataDir string, log *slog.Logger) int {
+	fs := flag.NewFlagSet("create-token", flag.ContinueOnError)
+	name := fs.String("name", "", "トークンの名前")
+	scopes := fs.String("scopes", string(domain.ScopeAdmin), "権限（read, edit, adminをカンマ区切り）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if strings.TrimSpace(*name) == "" {
+		log.Error("create-token: -nameを指定してください")
+		return 2
+	}
+
+	tokenStore, err := jsonfile.NewAPITokenStore(dataDir)
+	if err != nil {
+		log.Error("token store初期化エラー", "error", err)
+		return 1
+	}
+	var parsed []domain.Scope
+	for _, s := range strings.Split(*scopes, ",") {
+		parsed = append(parsed, domain.Scope(strings.TrimSpace(s)))
+	}
+	secret, token, err := application.NewAPITokenService(tokenStore).Issue(context.Background(), *name, parsed)
+	if err != nil {
+		log.Error("トークン発行エラー", "error", err)
+		return 1
+	}
+	log.Info("APIトークンを発行しました", "id", token.ID, "name", token.Name, "scopes", token.Scopes)
+	fmt.Println(secret)
+	return 0
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditResult は監査対象の操作の結果。
+type AuditResult string
+
+const (
+	AuditSuccess AuditResult = "success"
+	AuditDenied  AuditResult = "denied"
+	AuditFailure AuditResult = "failure"
+)
+
+// AuditRecord は管理操作・破壊的操作の監査記録。追記のみで更新しない。
+type AuditRecord struct {
+	ID      uuid.UUID
+	Time    time.Time
+	Actor   string
+	Action  string
+	Target  string
+	Result  AuditResult
+	TraceID string
+	Detail  string
+}
+
+// AuditQuery は監査記録の検索条件。空のフィールドは条件に含めない。
+type AuditQuery struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// Match は記録が条件に一致するかどうかを返す（Limitは見ない）。
+func (q AuditQuery) Match(rec AuditRecord) bool {
+	switch {
+	case q.Actor != "" && rec.Actor != q.Actor:
+		return false
+	case q.Action != "" && rec.Action != q.Action:
+		return false
+	case q.Target != "" && rec.Target != q.Target:
+		return false
+	case !q.Since.IsZero() && rec.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !rec.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
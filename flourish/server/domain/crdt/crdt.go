@@ -12,12 +12,14 @@ import (
 
 // payloadMsg はIncomingMessageのPayloadから必要フィールドを抽出する構造体。
 type payloadMsg struct {
+	Type          string      `json:"type,omitempty"`
 	RequestID     string      `json:"request_id"`
 	OpType        int         `json:"op_type"`
 	NodeID        *payloadNID `json:"node_id"`
 	After         *payloadNID `json:"after"`
-	Value         string      `json:"value"`
+	Value         string      `json:"value,omitempty"`
 	Authenticated *bool       `json:"authenticated,omitempty"`
+	Suggestion    string      `json:"suggestion,omitempty"`
 }
 
 type payloadNID struct {
@@ -72,6 +74,14 @@ func OperationFromPayload(payload []byte) (Operation, error) {
 		op.Value = r
 	}
 
+	if msg.Suggestion != "" {
+		suggestion, err := uuid.Parse(msg.Suggestion)
+		if err != nil {
+			return Operation{}, fmt.Errorf("parse suggestion: %w", err)
+		}
+		op.Suggestion = suggestion
+	}
+
 	// authenticated: 明示的にfalseが指定されない限りtrue（既存データ互換）
 	if msg.Authenticated != nil {
 		op.Authenticated = *msg.Authenticated
@@ -82,6 +92,29 @@ func OperationFromPayload(payload []byte) (Operation, error) {
 	return op, nil
 }
 
+// MarshalPayload はOperationFromPayloadと対になる、WSのopメッセージ形式のJSONを生成する。
+// サーバー自身が発行するop（モデレーションの却下など）をイベントストアに記録するために使う。
+func MarshalPayload(op Operation) ([]byte, error) {
+	auth := op.Authenticated
+	msg := payloadMsg{
+		Type:          "op",
+		RequestID:     op.RequestID.String(),
+		OpType:        int(op.OpType),
+		NodeID:        &payloadNID{SiteID: op.NodeID.ReplicaID.String(), Timestamp: op.NodeID.Timestamp},
+		Authenticated: &auth,
+	}
+	if op.After != nil {
+		msg.After = &payloadNID{SiteID: op.After.ReplicaID.String(), Timestamp: op.After.Timestamp}
+	}
+	if op.OpType == OpInsert {
+		msg.Value = string(op.Value)
+	}
+	if op.Suggestion != uuid.Nil {
+		msg.Suggestion = op.Suggestion.String()
+	}
+	return json.Marshal(msg)
+}
+
 // RGASnapshot はRGAの永続化用構造体。
 type RGASnapshot struct {
 	ReplicaID string         `json:"replica_id"`
@@ -98,6 +131,7 @@ type NodeSnapshot struct {
 	Value         string  `json:"value"`
 	Deleted       bool    `json:"deleted"`
 	Authenticated *bool   `json:"authenticated,omitempty"`
+	Suggestion    string  `json:"suggestion,omitempty"`
 }
 
 // Export はRGAをシリアライズ可能なスナップショットに変換する。
@@ -112,6 +146,9 @@ func (r *RGA) Export() RGASnapshot {
 			Deleted:       n.deleted,
 			Authenticated: &auth,
 		}
+		if n.suggestion != uuid.Nil {
+			nodes[i].Suggestion = n.suggestion.String()
+		}
 	}
 
 	seen := make([]string, 0, len(r.seen))
@@ -156,12 +193,19 @@ func ImportRGA(snap RGASnapshot) (*RGA, error) {
 		if ns.Authenticated != nil {
 			auth = *ns.Authenticated
 		}
+		var suggestion uuid.UUID
+		if ns.Suggestion != "" {
+			if suggestion, err = uuid.Parse(ns.Suggestion); err != nil {
+				return nil, fmt.Errorf("parse suggestion: %w", err)
+			}
+		}
 		rga.nodes[i] = &node{
 			id:            ns.ID,
 			after:         ns.After,
 			value:         r,
 			deleted:       ns.Deleted,
 			authenticated: auth,
+			suggestion:    suggestion,
 		}
 		rga.index[ns.ID] = i
 	}
@@ -203,6 +247,7 @@ type Operation struct {
 	After         *NodeID   `json:"after"`
 	Value         rune      `json:"value"`
 	Authenticated bool      `json:"authenticated"`
+	Suggestion    uuid.UUID `json:"suggestion,omitzero"` // 提案セットID（ゼロ値なら通常の編集）
 }
 
 // LamportClock はイベントの順序付けのための論理時計。
@@ -249,6 +294,7 @@ type node struct {
 	value         rune
 	deleted       bool
 	authenticated bool
+	suggestion    uuid.UUID
 }
 
 // NewRGA は指定されたサイトの新しい空のRGAを作成する。
@@ -340,6 +386,7 @@ func (r *RGA) applyInsert(op Operation) {
 		after:         op.After,
 		value:         op.Value,
 		authenticated: op.Authenticated,
+		suggestion:    op.Suggestion,
 	}
 
 	// 挿入位置を決定（afterノードの直後から探索開始）
@@ -458,6 +505,26 @@ func (r *RGA) TextFiltered(exclude map[NodeID]struct{}) string {
 	return sb.String()
 }
 
+// NodeInfo は可視ノードの情報。
+type NodeInfo struct {
+	ID            NodeID
+	Value         rune
+	Authenticated bool
+	Suggestion    uuid.UUID
+}
+
+// VisibleNodes は削除されていないノードを文書順に返す。
+func (r *RGA) VisibleNodes() []NodeInfo {
+	nodes := make([]NodeInfo, 0, len(r.nodes))
+	for _, n := range r.nodes {
+		if n.deleted {
+			continue
+		}
+		nodes = append(nodes, NodeInfo{ID: n.id, Value: n.value, Authenticated: n.authenticated, Suggestion: n.suggestion})
+	}
+	return nodes
+}
+
 // NodeCount はトゥームストーン含む全ノード数を返す。
 func (r *RGA) NodeCount() int {
 	return len(r.nodes)
@@ -158,3 +158,39 @@ func TestRGA_ExportImport_Idempotency(t *testing.T) {
 		t.Errorf("Text: got %q, want %q", restored.Text(), "x")
 	}
 }
+
+func TestMarshalPayload_RoundTripSuggestion(t *testing.T) {
+	after := crdt.NodeID{ReplicaID: uuid.New(), Timestamp: 1}
+	op := crdt.Operation{
+		RequestID:  uuid.New(),
+		OpType:     crdt.OpInsert,
+		NodeID:     crdt.NodeID{ReplicaID: uuid.New(), Timestamp: 2},
+		After:      &after,
+		Value:      'あ',
+		Suggestion: uuid.New(),
+	}
+
+	payload, err := crdt.MarshalPayload(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := crdt.OperationFromPayload(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Suggestion != op.Suggestion || got.Value != op.Value || *got.After != after {
+		t.Errorf("roundtrip mismatch: got %+v, want %+v", got, op)
+	}
+
+	// スナップショット経由でもノードの提案IDが保持される
+	rga := crdt.NewRGA(uuid.New())
+	rga.Apply(crdt.Operation{RequestID: uuid.New(), OpType: crdt.OpInsert, NodeID: after, Value: 'a'})
+	rga.Apply(got)
+	restored, err := crdt.ImportRGA(rga.Export())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes := restored.VisibleNodes(); nodes[1].Suggestion != op.Suggestion {
+		t.Errorf("snapshot should keep suggestion: got %v", nodes[1].Suggestion)
+	}
+}
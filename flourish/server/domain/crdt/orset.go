@@ -0,0 +1,141 @@
+package crdt
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// SetOpType はOR-Setオペレーションの種別を表す。
+type SetOpType int
+
+const (
+	SetAdd    SetOpType = 1
+	SetRemove SetOpType = 2
+)
+
+// SetOperation はOR-Setに対するオペレーション。
+// Addは一意なTagを持ち、Removeは発行時点で観測していたTagの集合を持つ。
+type SetOperation struct {
+	RequestID uuid.UUID   `json:"request_id"`
+	OpType    SetOpType   `json:"op_type"`
+	Element   string      `json:"element"`
+	Tag       uuid.UUID   `json:"tag,omitzero"`
+	Removes   []uuid.UUID `json:"removes,omitempty"`
+}
+
+// SetOperationFromPayload はJSONバイト列からSetOperationを生成する。
+func SetOperationFromPayload(payload []byte) (SetOperation, error) {
+	var op SetOperation
+	if err := json.Unmarshal(payload, &op); err != nil {
+		return SetOperation{}, fmt.Errorf("unmarshal payload: %w", err)
+	}
+	if op.RequestID == uuid.Nil {
+		return SetOperation{}, fmt.Errorf("request_id is required")
+	}
+	switch op.OpType {
+	case SetAdd:
+		if op.Tag == uuid.Nil {
+			return SetOperation{}, fmt.Errorf("tag is required for add")
+		}
+	case SetRemove:
+	default:
+		return SetOperation{}, fmt.Errorf("unknown op_type: %d", op.OpType)
+	}
+	return op, nil
+}
+
+// ORSet はObserved-Remove Setを実装する。
+// 並行なaddとremoveではaddが勝つ（removeは観測済みのTagしか消さない）。
+type ORSet struct {
+	adds    map[string]map[uuid.UUID]struct{} // element -> 生存中のTag
+	removed map[uuid.UUID]struct{}            // 削除済みTag（add到着前のremoveにも対応）
+	seen    map[uuid.UUID]struct{}
+}
+
+// NewORSet は空のOR-Setを作成する。
+func NewORSet() *ORSet {
+	return &ORSet{
+		adds:    make(map[string]map[uuid.UUID]struct{}),
+		removed: make(map[uuid.UUID]struct{}),
+		seen:    make(map[uuid.UUID]struct{}),
+	}
+}
+
+// Apply はオペレーションを適用する。既に適用済みの場合はfalseを返す（冪等性）。
+func (s *ORSet) Apply(op SetOperation) bool {
+	if _, exists := s.seen[op.RequestID]; exists {
+		return false
+	}
+	s.seen[op.RequestID] = struct{}{}
+
+	switch op.OpType {
+	case SetAdd:
+		if _, ok := s.removed[op.Tag]; ok {
+			return true
+		}
+		tags, ok := s.adds[op.Element]
+		if !ok {
+			tags = make(map[uuid.UUID]struct{})
+			s.adds[op.Element] = tags
+		}
+		tags[op.Tag] = struct{}{}
+	case SetRemove:
+		tags := s.adds[op.Element]
+		for _, tag := range op.Removes {
+			s.removed[tag] = struct{}{}
+			delete(tags, tag)
+		}
+		if len(tags) == 0 {
+			delete(s.adds, op.Element)
+		}
+	}
+	return true
+}
+
+// Add は追加オペレーションを作成する。適用は呼び出し側が行う。
+func (s *ORSet) Add(element string) SetOperation {
+	return SetOperation{
+		RequestID: uuid.New(),
+		OpType:    SetAdd,
+		Element:   element,
+		Tag:       uuid.New(),
+	}
+}
+
+// Remove は現在観測しているTagを削除するオペレーションを作成する。
+// 要素が存在しない場合はfalseを返す。適用は呼び出し側が行う。
+func (s *ORSet) Remove(element string) (SetOperation, bool) {
+	tags, ok := s.adds[element]
+	if !ok {
+		return SetOperation{}, false
+	}
+	removes := make([]uuid.UUID, 0, len(tags))
+	for tag := range tags {
+		removes = append(removes, tag)
+	}
+	return SetOperation{
+		RequestID: uuid.New(),
+		OpType:    SetRemove,
+		Element:   element,
+		Removes:   removes,
+	}, true
+}
+
+// Contains は要素が集合に含まれるかどうかを返す。
+func (s *ORSet) Contains(element string) bool {
+	_, ok := s.adds[element]
+	return ok
+}
+
+// Elements は集合の要素を辞書順で返す。
+func (s *ORSet) Elements() []string {
+	elems := make([]string, 0, len(s.adds))
+	for e := range s.adds {
+		elems = append(elems, e)
+	}
+	slices.Sort(elems)
+	return elems
+}
@@ -0,0 +1,130 @@
+package crdt_test
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"pgregory.net/rapid"
+
+	"flourish/server/domain/crdt"
+)
+
+func TestORSet_AddRemove(t *testing.T) {
+	s := crdt.NewORSet()
+
+	s.Apply(s.Add("go"))
+	s.Apply(s.Add("crdt"))
+	if got := s.Elements(); !slices.Equal(got, []string{"crdt", "go"}) {
+		t.Errorf("Elements: got %v", got)
+	}
+
+	rm, ok := s.Remove("go")
+	if !ok {
+		t.Fatal("Remove should find existing element")
+	}
+	s.Apply(rm)
+	if s.Contains("go") {
+		t.Error("go should be removed")
+	}
+
+	if _, ok := s.Remove("missing"); ok {
+		t.Error("Remove of missing element should return false")
+	}
+}
+
+func TestORSet_ConcurrentAddWins(t *testing.T) {
+	a := crdt.NewORSet()
+	b := crdt.NewORSet()
+
+	add1 := a.Add("go")
+	a.Apply(add1)
+	b.Apply(add1)
+
+	// aはgoを削除、bは同時にgoを再追加
+	rm, _ := a.Remove("go")
+	add2 := b.Add("go")
+	a.Apply(rm)
+	b.Apply(add2)
+
+	a.Apply(add2)
+	b.Apply(rm)
+
+	if !a.Contains("go") || !b.Contains("go") {
+		t.Errorf("concurrent add should win: a=%v b=%v", a.Elements(), b.Elements())
+	}
+}
+
+func TestORSet_RemoveBeforeAdd(t *testing.T) {
+	origin := crdt.NewORSet()
+	add := origin.Add("go")
+	origin.Apply(add)
+	rm, _ := origin.Remove("go")
+
+	// removeが先に到着しても、後から来たaddは復活しない
+	s := crdt.NewORSet()
+	s.Apply(rm)
+	s.Apply(add)
+	if s.Contains("go") {
+		t.Error("add observed by an earlier remove must stay removed")
+	}
+}
+
+func TestORSet_Idempotent(t *testing.T) {
+	s := crdt.NewORSet()
+	add := s.Add("go")
+	if !s.Apply(add) {
+		t.Error("first apply should return true")
+	}
+	if s.Apply(add) {
+		t.Error("duplicate apply should return false")
+	}
+}
+
+func TestSetOperationFromPayload(t *testing.T) {
+	op := crdt.NewORSet().Add("go")
+	payload, _ := json.Marshal(op)
+
+	got, err := crdt.SetOperationFromPayload(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RequestID != op.RequestID || got.Tag != op.Tag || got.Element != "go" {
+		t.Errorf("roundtrip mismatch: got %+v, want %+v", got, op)
+	}
+
+	if _, err := crdt.SetOperationFromPayload([]byte(`{"request_id":"` + op.RequestID.String() + `","op_type":1}`)); err == nil {
+		t.Error("add without tag should fail")
+	}
+}
+
+// TestORSet_Convergence は任意順序で適用しても同じ集合に収束することを検証する。
+func TestORSet_Convergence(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		elems := []string{"a", "b", "c"}
+		origin := crdt.NewORSet()
+		var ops []crdt.SetOperation
+		n := rapid.IntRange(1, 20).Draw(t, "n")
+		for range n {
+			e := rapid.SampledFrom(elems).Draw(t, "elem")
+			if rapid.Bool().Draw(t, "add") {
+				op := origin.Add(e)
+				origin.Apply(op)
+				ops = append(ops, op)
+			} else if op, ok := origin.Remove(e); ok {
+				origin.Apply(op)
+				ops = append(ops, op)
+			}
+		}
+
+		perm := rapid.Permutation(ops).Draw(t, "perm")
+		replica := crdt.NewORSet()
+		for _, op := range perm {
+			replica.Apply(op)
+		}
+
+		if !slices.Equal(origin.Elements(), replica.Elements()) {
+			t.Fatalf("diverged: origin=%v replica=%v", origin.Elements(), replica.Elements())
+		}
+	})
+}
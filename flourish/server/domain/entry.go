@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,24 +10,34 @@ import (
 
 // Entry はブログエントリを表す。
 type Entry struct {
-	ID        uuid.UUID
-	Title     string
-	Content   string
-	Thumbnail *string
-	Text      string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Deleted   bool
+	ID             uuid.UUID
+	Title          string
+	Content        string
+	Thumbnail      *string
+	Text           string
+	Tags           []string
+	Description    string
+	WordCount      int
+	ReadingMinutes int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Deleted        bool
+	DeletedAt      time.Time // ゴミ箱に入った時刻（Deleted時のみ）
+	Locked         bool
 }
 
 // EntryListItem は一覧表示用のエントリ。textフィールドを除外する。
 type EntryListItem struct {
-	ID        uuid.UUID
-	Title     string
-	Content   string
-	Thumbnail *string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             uuid.UUID
+	Title          string
+	Content        string
+	Thumbnail      *string
+	Tags           []string
+	Description    string
+	WordCount      int
+	ReadingMinutes int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
 // NewEntry は空のエントリを新規作成する。
@@ -44,11 +56,25 @@ func NewEntry() Entry {
 // ToListItem はEntryListItemに変換する。
 func (e Entry) ToListItem() EntryListItem {
 	return EntryListItem{
-		ID:        e.ID,
-		Title:     e.Title,
-		Content:   e.Content,
-		Thumbnail: e.Thumbnail,
-		CreatedAt: e.CreatedAt,
-		UpdatedAt: e.UpdatedAt,
+		ID:             e.ID,
+		Title:          e.Title,
+		Content:        e.Content,
+		Thumbnail:      e.Thumbnail,
+		Tags:           e.Tags,
+		Description:    e.Description,
+		WordCount:      e.WordCount,
+		ReadingMinutes: e.ReadingMinutes,
+		CreatedAt:      e.CreatedAt,
+		UpdatedAt:      e.UpdatedAt,
 	}
 }
+
+// HasTag はエントリが指定タグを持つかどうかを返す。
+func (e EntryListItem) HasTag(tag string) bool {
+	return slices.Contains(e.Tags, NormalizeTag(tag))
+}
+
+// NormalizeTag はタグを比較用の正規形（前後空白除去・小文字化）に変換する。
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
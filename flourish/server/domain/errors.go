@@ -3,6 +3,19 @@ package domain
 import "errors"
 
 var (
-	ErrEntryNotFound = errors.New("entry not found")
-	ErrEntryDeleted  = errors.New("entry deleted")
+	ErrEntryNotFound   = errors.New("entry not found")
+	ErrEntryDeleted    = errors.New("entry deleted")
+	ErrEntryLocked     = errors.New("entry locked")
+	ErrEntryNotDeleted = errors.New("entry not deleted")
+
+	ErrTagInFrontMatter = errors.New("tag is set in front matter")
+
+	ErrSuggestionNotFound = errors.New("suggestion not found")
+	ErrSuggestionClosed   = errors.New("suggestion already closed")
+
+	ErrTokenNotFound = errors.New("api token not found")
+	ErrInvalidScope  = errors.New("invalid scope")
+
+	ErrReplicaOutOfSequence = errors.New("replicated event out of sequence")
+	ErrLeaderLogEmpty       = errors.New("leader event log is empty")
 )
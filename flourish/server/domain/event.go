@@ -13,6 +13,10 @@ const (
 	EventCRDTOp      EventType = "crdt_op"
 	EventEntryCreate EventType = "entry_create"
 	EventEntryDelete EventType = "entry_delete"
+	EventTagOp       EventType = "tag_op"
+	EventModeration  EventType = "moderation"
+	EventSuggestion  EventType = "suggestion"
+	EventEntryLock   EventType = "entry_lock"
 )
 
 // Event はイベントストアに保存されるイベントを表す。
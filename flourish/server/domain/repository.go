@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -17,6 +18,33 @@ type EventStore interface {
 
 	// MaxServerSeq は指定エントリの最大server_seqを返す。
 	MaxServerSeq(ctx context.Context, entryID uuid.UUID) (int64, error)
+
+	// Purge は指定エントリの全イベントを物理削除する。
+	Purge(ctx context.Context, entryID uuid.UUID) error
+}
+
+// SharedEventStore は複数のサーバーインスタンスが同じイベントログを読み書きするEventStore。
+// server_seqの採番が全インスタンスで1つになるので、インスタンス間のBusで配信できる。
+type SharedEventStore interface {
+	EventStore
+
+	// SharedAcrossInstances は何もしない。共有できる実装であることの印。
+	SharedAcrossInstances()
+}
+
+// ReplicaEventStore はリーダーのイベントをそのまま複製できるEventStore。
+type ReplicaEventStore interface {
+	EventStore
+
+	// AppendReplica はserver_seqとcreated_atを保ったままイベントを追記する。
+	// server_seqがエントリの最大server_seq+1でなければErrReplicaOutOfSequenceを返す。
+	AppendReplica(ctx context.Context, event Event) error
+
+	// EntryIDs はイベントのある全エントリIDを返す。
+	EntryIDs() []uuid.UUID
+
+	// PurgedIDs はPurgeで完全削除したエントリIDを返す。フォロワーはこの記録にあるエントリだけを削除する。
+	PurgedIDs() []uuid.UUID
 }
 
 // EntryStore はエントリのCRUD操作を担う。
@@ -27,9 +55,50 @@ type EntryStore interface {
 	// FindByID はIDでエントリを取得する。存在しない場合はErrEntryNotFoundを返す。
 	FindByID(ctx context.Context, id uuid.UUID) (Entry, error)
 
+	// Update はエントリを読み出してfnで変更し、保存する。読み出しから保存までを他の書き込みと直列化するので、
+	// fnが触れないフィールド（ロックや削除状態など）の同時の変更を上書きしない。
+	// 存在しない場合はErrEntryNotFound、削除済みならErrEntryDeletedを返し、fnは呼ばない。
+	Update(ctx context.Context, id uuid.UUID, fn func(*Entry)) error
+
 	// List は全エントリの一覧を取得する（削除済みを除く）。
 	List(ctx context.Context) ([]EntryListItem, error)
 
-	// Delete はエントリを論理削除する。
+	// Delete はエントリを論理削除する（ゴミ箱に入れる）。
 	Delete(ctx context.Context, id uuid.UUID) error
+
+	// ListDeleted は論理削除済みのエントリを削除日時の新しい順に取得する。
+	ListDeleted(ctx context.Context) ([]Entry, error)
+
+	// Restore は論理削除を取り消す。削除済みでなければErrEntryNotDeletedを返す。
+	Restore(ctx context.Context, id uuid.UUID) error
+
+	// Purge はエントリを物理削除する。存在しない場合はErrEntryNotFoundを返す。
+	Purge(ctx context.Context, id uuid.UUID) error
+}
+
+// APITokenStore はAPIトークンの永続化を担う。
+type APITokenStore interface {
+	// Save はトークンを保存する。
+	Save(ctx context.Context, token APIToken) error
+
+	// FindByHash はハッシュでトークンを取得する。存在しない場合はErrTokenNotFoundを返す。
+	FindByHash(ctx context.Context, hash string) (APIToken, error)
+
+	// List は全トークンを作成日時の昇順で取得する。
+	List(ctx context.Context) ([]APIToken, error)
+
+	// Delete はトークンを削除する。存在しない場合はErrTokenNotFoundを返す。
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// AuditStore は監査記録の追記と検索を担う。本体のストアとは別の保存先に置ける。
+type AuditStore interface {
+	// Append は記録を追記する。
+	Append(ctx context.Context, rec AuditRecord) error
+
+	// Query は条件に一致する記録を新しい順に取得する。
+	Query(ctx context.Context, q AuditQuery) ([]AuditRecord, error)
+
+	// Prune は指定時刻より前の記録を削除し、削除した件数を返す（保持期間の適用）。
+	Prune(ctx context.Context, before time.Time) (int, error)
 }
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scope はAPIトークンの権限。read < edit < admin の順に上位が下位を包含する。
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeEdit  Scope = "edit"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeRank = map[Scope]int{ScopeRead: 1, ScopeEdit: 2, ScopeAdmin: 3}
+
+// Valid は既知のスコープかどうかを返す。
+func (s Scope) Valid() bool {
+	_, ok := scopeRank[s]
+	return ok
+}
+
+// Allows はこのスコープがrequiredを満たすかどうかを返す。
+func (s Scope) Allows(required Scope) bool {
+	return s.Valid() && scopeRank[s] >= scopeRank[required]
+}
+
+// APIToken は自動化クライアント用の長期トークン。平文は発行時にのみ返し、ハッシュだけを保存する。
+type APIToken struct {
+	ID        uuid.UUID
+	Name      string
+	Hash      string
+	Scopes    []Scope
+	CreatedAt time.Time
+}
+
+// Allows はトークンがrequiredを満たすスコープを持つかどうかを返す。
+func (t APIToken) Allows(required Scope) bool {
+	return slices.ContainsFunc(t.Scopes, func(s Scope) bool { return s.Allows(required) })
+}
@@ -0,0 +1,431 @@
+// Package fsck はデータディレクトリ（entries.json・rga_states・events・markdown）の整合性を検査し、修復する。
+// イベントログを正として各エントリを新しいRGAに再生し、保存済みのスナップショット・投影・markdownと比べる。
+package fsck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/jsonfile"
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+	"flourish/server/domain/crdt"
+)
+
+// 投影側の不整合の種別。イベントログ自体の不整合はjsonfile.Problem*を使う。
+const (
+	ProblemSnapshotMissing    = "snapshot_missing"
+	ProblemSnapshotUnreadable = "snapshot_unreadable"
+	ProblemSnapshotMismatch   = "snapshot_mismatch"
+	ProblemProjectionMissing  = "projection_missing"
+	ProblemProjectionMismatch = "projection_mismatch"
+	ProblemMarkdownMissing    = "markdown_missing"
+	ProblemMarkdownMismatch   = "markdown_mismatch"
+	ProblemEventsMissing      = "events_missing"
+	ProblemOrphanSnapshot     = "orphan_snapshot"
+	ProblemOrphanMarkdown     = "orphan_markdown"
+)
+
+// Problem は検出した不整合。Repairedは修復を行った場合にtrueになる。
+type Problem struct {
+	Kind     string
+	EntryID  uuid.UUID
+	Path     string
+	Detail   string
+	Repaired bool
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	b.WriteString(p.Kind)
+	if p.EntryID != uuid.Nil {
+		b.WriteString(" entry=" + p.EntryID.String())
+	}
+	if p.Path != "" {
+		b.WriteString(" path=" + p.Path)
+	}
+	if p.Detail != "" {
+		b.WriteString(" " + p.Detail)
+	}
+	if p.Repaired {
+		b.WriteString(" (repaired)")
+	}
+	return b.String()
+}
+
+// Options はfsckの動作設定。
+type Options struct {
+	// Repair が真なら、修復できる不整合を修復する。
+	Repair bool
+	// Moderation はサーバーと同じモデレーション設定（公開テキストの算出に影響する）。
+	Moderation bool
+}
+
+// Report はfsckの結果。
+type Report struct {
+	Problems []Problem
+}
+
+// Unrepaired は修復されずに残った不整合の数を返す。
+func (r Report) Unrepaired() int {
+	n := 0
+	for _, p := range r.Problems {
+		if !p.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// Run はdataDirを検査する。サーバー停止中に実行すること。
+func Run(ctx context.Context, dataDir string, opts Options, log *slog.Logger) (Report, error) {
+	var report Report
+
+	// 1. イベントログ自体の検査（修復は再生より先に行う）
+	logProblems, err := jsonfile.CheckEventLogs(dataDir)
+	if err != nil {
+		return report, fmt.Errorf("check event logs: %w", err)
+	}
+	repairedLogs := make(map[string]bool)
+	for _, lp := range logProblems {
+		p := Problem{Kind: lp.Kind, EntryID: lp.EntryID, Path: lp.Path, Detail: lp.Detail}
+		if lp.Line > 0 {
+			p.Detail = strings.TrimSpace(fmt.Sprintf("line=%d %s", lp.Line, lp.Detail))
+		}
+		if opts.Repair {
+			switch lp.Kind {
+			case jsonfile.ProblemUnparsableLine, jsonfile.ProblemDuplicateSeq:
+				if !repairedLogs[lp.Path] {
+					if _, err := jsonfile.RepairEventLog(lp.Path, lp.EntryID); err != nil {
+						return report, fmt.Errorf("repair %s: %w", lp.Path, err)
+					}
+					repairedLogs[lp.Path] = true
+				}
+				p.Repaired = true
+			}
+		}
+		report.Problems = append(report.Problems, p)
+	}
+
+	// 2. イベントログを新しいRGAに再生する。検査だけなら途切れた末尾も切り詰めずに読む
+	openEventStore := jsonfile.NewReadOnlyEventStore
+	if opts.Repair {
+		openEventStore = jsonfile.NewEventStore
+	}
+	eventStore, err := openEventStore(dataDir)
+	if err != nil {
+		return report, fmt.Errorf("event store: %w", err)
+	}
+	for _, lp := range eventStore.LoadProblems() {
+		reported := slices.ContainsFunc(logProblems, func(p jsonfile.EventLogProblem) bool {
+			return p.Path == lp.Path && p.Line == lp.Line
+		})
+		if lp.Kind != jsonfile.ProblemTornTail || reported {
+			continue
+		}
+		report.Problems = append(report.Problems, Problem{
+			Kind: lp.Kind, EntryID: lp.EntryID, Path: lp.Path,
+			Detail: fmt.Sprintf("line=%d %s", lp.Line, lp.Detail), Repaired: opts.Repair,
+		})
+	}
+	entryIDs := eventStore.EntryIDs()
+	slices.SortFunc(entryIDs, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+
+	mdTmp, err := os.MkdirTemp("", "flourish-fsck-")
+	if err != nil {
+		return report, err
+	}
+	defer os.RemoveAll(mdTmp)
+	freshEntries := memory.NewEntryStore()
+	freshRGAs := memory.NewRGAStateStore()
+	projector := application.NewEntryProjector(freshEntries, freshRGAs, mdTmp, log)
+	projector.SetModeration(opts.Moderation)
+	if err := projector.Restore(ctx, eventStore, entryIDs); err != nil {
+		return report, fmt.Errorf("replay: %w", err)
+	}
+
+	// 3. 保存済みの投影と比べる
+	c := &checker{
+		dataDir:      dataDir,
+		opts:         opts,
+		freshEntries: freshEntries,
+		freshRGAs:    freshRGAs,
+	}
+	if c.entryStore, err = jsonfile.NewEntryStore(dataDir); err != nil {
+		return report, fmt.Errorf("entry store: %w", err)
+	}
+	if c.rgaStore, err = jsonfile.NewRGAStateStore(dataDir); err != nil {
+		return report, fmt.Errorf("rga state store: %w", err)
+	}
+
+	for _, id := range entryIDs {
+		problems, err := c.checkEntry(ctx, id)
+		if err != nil {
+			return report, err
+		}
+		report.Problems = append(report.Problems, problems...)
+	}
+
+	orphans, err := c.checkOrphans(ctx, entryIDs)
+	if err != nil {
+		return report, err
+	}
+	report.Problems = append(report.Problems, orphans...)
+	return report, nil
+}
+
+type checker struct {
+	dataDir      string
+	opts         Options
+	entryStore   *jsonfile.EntryStore
+	rgaStore     *jsonfile.RGAStateStore
+	freshEntries *memory.EntryStore
+	freshRGAs    *memory.RGAStateStore
+}
+
+func (c *checker) markdownPath(id uuid.UUID) string {
+	return filepath.Join(c.dataDir, "markdown", id.String()+".md")
+}
+
+// checkEntry は1エントリのスナップショット・投影・markdownを再生結果と比べる。
+func (c *checker) checkEntry(ctx context.Context, id uuid.UUID) ([]Problem, error) {
+	fresh, ok := findEntry(ctx, c.freshEntries, id)
+	if !ok {
+		return nil, nil // 投影対象にならないイベントログ（Restoreがスキップしたもの）
+	}
+	freshSnap, _ := c.freshRGAs.LoadRGA(ctx, id)
+
+	var problems []Problem
+
+	// RGAスナップショット
+	snapPath := filepath.Join(c.dataDir, "rga_states", id.String()+".json")
+	stored, err := c.rgaStore.LoadRGA(ctx, id)
+	var snapProblem *Problem
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if len(freshSnap.Nodes) > 0 {
+			snapProblem = &Problem{Kind: ProblemSnapshotMissing}
+		}
+	case err != nil:
+		snapProblem = &Problem{Kind: ProblemSnapshotUnreadable, Detail: err.Error()}
+	default:
+		if detail := diffSnapshots(stored, freshSnap); detail != "" {
+			snapProblem = &Problem{Kind: ProblemSnapshotMismatch, Detail: detail}
+		}
+	}
+	if snapProblem != nil {
+		snapProblem.EntryID, snapProblem.Path = id, snapPath
+		if c.opts.Repair {
+			if err := c.rgaStore.SaveRGA(ctx, id, freshSnap); err != nil {
+				return nil, fmt.Errorf("repair snapshot %s: %w", id, err)
+			}
+			snapProblem.Repaired = true
+		}
+		problems = append(problems, *snapProblem)
+	}
+
+	// entries.jsonの投影
+	var projProblem *Problem
+	current, ok := findEntry(ctx, c.entryStore, id)
+	if !ok {
+		projProblem = &Problem{Kind: ProblemProjectionMissing}
+	} else if detail := diffEntries(current, fresh); detail != "" {
+		projProblem = &Problem{Kind: ProblemProjectionMismatch, Detail: detail}
+	}
+	if projProblem != nil {
+		projProblem.EntryID, projProblem.Path = id, filepath.Join(c.dataDir, "entries.json")
+		if c.opts.Repair {
+			repaired := fresh
+			if ok {
+				repaired.CreatedAt, repaired.UpdatedAt = current.CreatedAt, current.UpdatedAt
+			}
+			if err := c.entryStore.Save(ctx, repaired); err != nil {
+				return nil, fmt.Errorf("repair entry %s: %w", id, err)
+			}
+			projProblem.Repaired = true
+		}
+		problems = append(problems, *projProblem)
+	}
+
+	// markdown
+	var mdProblem *Problem
+	mdPath := c.markdownPath(id)
+	data, err := os.ReadFile(mdPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if fresh.Text != "" {
+			mdProblem = &Problem{Kind: ProblemMarkdownMissing}
+		}
+	case err != nil:
+		return nil, err
+	case string(data) != fresh.Text:
+		mdProblem = &Problem{Kind: ProblemMarkdownMismatch, Detail: fmt.Sprintf("len=%d want=%d", len(data), len(fresh.Text))}
+	}
+	if mdProblem != nil {
+		mdProblem.EntryID, mdProblem.Path = id, mdPath
+		if c.opts.Repair {
+			if err := os.MkdirAll(filepath.Dir(mdPath), 0o755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(mdPath, []byte(fresh.Text), 0o644); err != nil {
+				return nil, fmt.Errorf("repair markdown %s: %w", id, err)
+			}
+			mdProblem.Repaired = true
+		}
+		problems = append(problems, *mdProblem)
+	}
+
+	return problems, nil
+}
+
+// checkOrphans はイベントログの無いスナップショット・markdownと、本文があるのにイベントログの無いエントリを報告する。
+func (c *checker) checkOrphans(ctx context.Context, entryIDs []uuid.UUID) ([]Problem, error) {
+	var problems []Problem
+
+	snapIDs, err := c.rgaStore.ListRGAEntryIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range snapIDs {
+		if slices.Contains(entryIDs, id) {
+			continue
+		}
+		p := Problem{Kind: ProblemOrphanSnapshot, EntryID: id, Path: filepath.Join(c.dataDir, "rga_states", id.String()+".json")}
+		if c.opts.Repair {
+			if err := c.rgaStore.DeleteRGA(ctx, id); err != nil {
+				return nil, err
+			}
+			p.Repaired = true
+		}
+		problems = append(problems, p)
+	}
+
+	mdFiles, err := os.ReadDir(filepath.Join(c.dataDir, "markdown"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, f := range mdFiles {
+		id, err := uuid.Parse(strings.TrimSuffix(f.Name(), ".md"))
+		if err == nil && slices.Contains(entryIDs, id) {
+			continue
+		}
+		path := filepath.Join(c.dataDir, "markdown", f.Name())
+		p := Problem{Kind: ProblemOrphanMarkdown, EntryID: id, Path: path}
+		if c.opts.Repair && !f.IsDir() {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+			p.Repaired = true
+		}
+		problems = append(problems, p)
+	}
+
+	// 作成イベント導入前のエントリは、編集されていなければイベントログを持たない
+	items, err := c.entryStore.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if slices.Contains(entryIDs, item.ID) {
+			continue
+		}
+		entry, err := c.entryStore.FindByID(ctx, item.ID)
+		if err != nil || entry.Text == "" {
+			continue
+		}
+		problems = append(problems, Problem{
+			Kind: ProblemEventsMissing, EntryID: item.ID, Path: filepath.Join(c.dataDir, "entries.json"),
+			Detail: "entry has text but no event log",
+		})
+	}
+	return problems, nil
+}
+
+// findEntry はゴミ箱のエントリも含めてエントリを探す。
+func findEntry(ctx context.Context, store domain.EntryStore, id uuid.UUID) (domain.Entry, bool) {
+	entry, err := store.FindByID(ctx, id)
+	if err == nil {
+		return entry, true
+	}
+	if !errors.Is(err, domain.ErrEntryDeleted) {
+		return domain.Entry{}, false
+	}
+	deleted, err := store.ListDeleted(ctx)
+	if err != nil {
+		return domain.Entry{}, false
+	}
+	for _, e := range deleted {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return domain.Entry{}, false
+}
+
+// diffEntries は投影の違いを説明する。一致すれば空文字列。
+func diffEntries(got, want domain.Entry) string {
+	var diffs []string
+	if got.Text != want.Text {
+		diffs = append(diffs, "text")
+	}
+	if !slices.Equal(got.Tags, want.Tags) {
+		diffs = append(diffs, "tags")
+	}
+	if got.Locked != want.Locked {
+		diffs = append(diffs, "locked")
+	}
+	if got.Deleted != want.Deleted {
+		diffs = append(diffs, "deleted")
+	}
+	if len(diffs) == 0 {
+		return ""
+	}
+	return "fields=" + strings.Join(diffs, ",")
+}
+
+type nodeState struct {
+	value   string
+	deleted bool
+}
+
+// diffSnapshots はスナップショットのノード集合の違いを説明する。一致すれば空文字列。
+// CounterやSeenは再生の順序で変わりうるので比べない。
+func diffSnapshots(got, want crdt.RGASnapshot) string {
+	gotNodes, wantNodes := snapshotNodes(got), snapshotNodes(want)
+	var missing, extra, changed int
+	for id, w := range wantNodes {
+		g, ok := gotNodes[id]
+		switch {
+		case !ok:
+			missing++
+		case g != w:
+			changed++
+		}
+	}
+	for id := range gotNodes {
+		if _, ok := wantNodes[id]; !ok {
+			extra++
+		}
+	}
+	if missing+extra+changed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("missing=%d extra=%d changed=%d", missing, extra, changed)
+}
+
+func snapshotNodes(snap crdt.RGASnapshot) map[crdt.NodeID]nodeState {
+	nodes := make(map[crdt.NodeID]nodeState, len(snap.Nodes))
+	for _, n := range snap.Nodes {
+		nodes[n.ID] = nodeState{value: n.Value, deleted: n.Deleted}
+	}
+	return nodes
+}
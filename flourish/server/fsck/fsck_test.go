@@ -0,0 +1,167 @@
+package fsck_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/jsonfile"
+	"flourish/server/application"
+	"flourish/server/fsck"
+)
+
+// setupDataDir はエントリを1件作成して2文字を入力したデータディレクトリを返す。
+func setupDataDir(t *testing.T) (string, uuid.UUID) {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	entryStore, _ := jsonfile.NewEntryStore(dir)
+	eventStore, _ := jsonfile.NewEventStore(dir)
+	rgaStore, _ := jsonfile.NewRGAStateStore(dir)
+	syncService := application.NewSyncService(eventStore)
+	projector := application.NewEntryProjector(entryStore, rgaStore, filepath.Join(dir, "markdown"), nil)
+
+	entry, err := application.NewEntryService(syncService, entryStore).Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siteID := uuid.New()
+	for i, v := range []string{"h", "i"} {
+		msg := map[string]any{
+			"request_id": uuid.New().String(),
+			"op_type":    1,
+			"node_id":    map[string]any{"site_id": siteID.String(), "timestamp": i + 1},
+			"value":      v,
+		}
+		if i > 0 {
+			msg["after"] = map[string]any{"site_id": siteID.String(), "timestamp": i}
+		}
+		payload, _ := json.Marshal(msg)
+		syncService.HandleOp(ctx, entry.ID, siteID, uuid.New(), payload)
+		projector.Apply(ctx, entry.ID, payload)
+	}
+	return dir, entry.ID
+}
+
+func kinds(report fsck.Report) []string {
+	var ks []string
+	for _, p := range report.Problems {
+		ks = append(ks, p.Kind)
+	}
+	slices.Sort(ks)
+	return ks
+}
+
+func TestRun_Clean(t *testing.T) {
+	dir, _ := setupDataDir(t)
+	report, err := fsck.Run(context.Background(), dir, fsck.Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) != 0 {
+		t.Errorf("problems: got %v", report.Problems)
+	}
+}
+
+func TestRun_DetectAndRepair(t *testing.T) {
+	ctx := context.Background()
+	dir, entryID := setupDataDir(t)
+
+	// 壊れた行と重複したserver_seqをイベントログに追加
+	logPath := filepath.Join(dir, "events", entryID.String()+".jsonl")
+	data, _ := os.ReadFile(logPath)
+	lines := splitLines(data)
+	f, _ := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString("{broken\n")
+	f.Write(append(lines[len(lines)-1], '\n'))
+	f.Close()
+
+	// 投影を古い状態にし、markdownを消し、孤立したスナップショットを置く
+	entryStore, _ := jsonfile.NewEntryStore(dir)
+	entry, _ := entryStore.FindByID(ctx, entryID)
+	entry.Text = "h"
+	entryStore.Save(ctx, entry)
+	os.Remove(filepath.Join(dir, "markdown", entryID.String()+".md"))
+	rgaStore, _ := jsonfile.NewRGAStateStore(dir)
+	snap, _ := rgaStore.LoadRGA(ctx, entryID)
+	rgaStore.SaveRGA(ctx, uuid.New(), snap)
+
+	report, err := fsck.Run(ctx, dir, fsck.Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		fsck.ProblemMarkdownMissing,
+		fsck.ProblemOrphanSnapshot,
+		fsck.ProblemProjectionMismatch,
+		jsonfile.ProblemDuplicateSeq,
+		jsonfile.ProblemUnparsableLine,
+	}
+	slices.Sort(want)
+	if got := kinds(report); !slices.Equal(got, want) {
+		t.Fatalf("kinds: got %v, want %v", got, want)
+	}
+	if report.Unrepaired() != len(want) {
+		t.Errorf("unrepaired: got %d, want %d", report.Unrepaired(), len(want))
+	}
+
+	repaired, err := fsck.Run(ctx, dir, fsck.Options{Repair: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repaired.Unrepaired() != 0 {
+		t.Errorf("unrepaired after repair: %v", repaired.Problems)
+	}
+
+	after, err := fsck.Run(ctx, dir, fsck.Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Problems) != 0 {
+		t.Errorf("problems after repair: got %v", after.Problems)
+	}
+	md, _ := os.ReadFile(filepath.Join(dir, "markdown", entryID.String()+".md"))
+	if string(md) != "hi" {
+		t.Errorf("markdown: got %q, want %q", md, "hi")
+	}
+}
+
+func TestRun_CheckOnlyLeavesTornTail(t *testing.T) {
+	ctx := context.Background()
+	dir, entryID := setupDataDir(t)
+
+	// 改行の無い末尾行（追記中のクラッシュ）を残す
+	logPath := filepath.Join(dir, "events", entryID.String()+".jsonl")
+	data, _ := os.ReadFile(logPath)
+	torn := bytes.TrimSuffix(data, []byte("\n"))
+	os.WriteFile(logPath, torn, 0o644)
+
+	report, err := fsck.Run(ctx, dir, fsck.Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := kinds(report); !slices.Contains(got, jsonfile.ProblemTornTail) {
+		t.Errorf("torn tail should be reported: got %v", got)
+	}
+	if after, _ := os.ReadFile(logPath); !bytes.Equal(after, torn) {
+		t.Error("check-only run should not modify the event log")
+	}
+
+	if _, err := fsck.Run(ctx, dir, fsck.Options{Repair: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(logPath); !bytes.Equal(after, torn[:bytes.LastIndexByte(torn, '\n')+1]) {
+		t.Errorf("repair should truncate the torn tail: got %q", after)
+	}
+}
+
+func splitLines(data []byte) [][]byte {
+	return bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+}
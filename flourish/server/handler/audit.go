@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditRecordResponse は監査記録のレスポンス。
+type AuditRecordResponse struct {
+	ID      string `json:"id"`
+	Time    string `json:"time"`
+	Actor   string `json:"actor"`
+	Action  string `json:"action"`
+	Target  string `json:"target,omitempty"`
+	Result  string `json:"result"`
+	TraceID string `json:"trace_id,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// AuditListResponse は監査記録一覧レスポンス。
+type AuditListResponse struct {
+	Records []AuditRecordResponse `json:"records"`
+}
+
+// AuditLog は監査記録のHTTPハンドラー。
+type AuditLog struct {
+	audit *application.AuditLog
+}
+
+func NewAuditLog(audit *application.AuditLog) *AuditLog {
+	return &AuditLog{audit: audit}
+}
+
+// List は GET /api/admin/audit ハンドラー。
+// actor, action, target, since, until（RFC3339）, limit で絞り込む。
+func (h *AuditLog) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := domain.AuditQuery{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+		Limit:  defaultAuditLimit,
+	}
+	var err error
+	if v := query.Get("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+	}
+	if v := query.Get("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+		q.Limit = min(q.Limit, maxAuditLimit)
+	}
+
+	records, err := h.audit.Query(r.Context(), q)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	resp := AuditListResponse{Records: make([]AuditRecordResponse, 0, len(records))}
+	for _, rec := range records {
+		resp.Records = append(resp.Records, AuditRecordResponse{
+			ID:      rec.ID.String(),
+			Time:    rec.Time.Format(time.RFC3339Nano),
+			Actor:   rec.Actor,
+			Action:  rec.Action,
+			Target:  rec.Target,
+			Result:  string(rec.Result),
+			TraceID: rec.TraceID,
+			Detail:  rec.Detail,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// statusWriter はレスポンスのステータスコードを記録する。
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Audit はハンドラーの結果をステータスコードから判定して監査ログに記録するミドルウェア。
+// Middlewareの内側・RequireAuthの外側に置くと、権限不足も denied として記録される。
+// targetParamは対象を表すパスパラメータ名（空なら対象なし）。
+func Audit(audit *application.AuditLog, action, targetParam string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		var target string
+		if targetParam != "" {
+			target = r.PathValue(targetParam)
+		}
+		result := domain.AuditSuccess
+		switch {
+		case sw.status == http.StatusUnauthorized || sw.status == http.StatusForbidden:
+			result = domain.AuditDenied
+		case sw.status >= 400:
+			result = domain.AuditFailure
+		}
+		audit.Record(r.Context(), action, target, result, "status="+strconv.Itoa(sw.status))
+	})
+}
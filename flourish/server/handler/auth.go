@@ -1,79 +1,230 @@
 package handler
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
+	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+
+	"flourish/server/application"
 	"flourish/server/auth"
+	"flourish/server/domain"
 )
 
 type contextKey string
 
 const authContextKey contextKey = "authenticated"
 
+// principal は認証済みの主体。expiresAtは認証情報の期限（ゼロなら期限なし）。
+type principal struct {
+	identity  string
+	scopes    []domain.Scope
+	expiresAt time.Time
+}
+
 // IsAuthenticated はコンテキストから認証状態を取得する。
 func IsAuthenticated(ctx context.Context) bool {
-	v, _ := ctx.Value(authContextKey).(bool)
-	return v
+	p, _ := ctx.Value(authContextKey).(principal)
+	return len(p.scopes) > 0
+}
+
+// HasScope はコンテキストの認証主体がrequiredを満たすスコープを持つかどうかを返す。
+func HasScope(ctx context.Context, required domain.Scope) bool {
+	p, _ := ctx.Value(authContextKey).(principal)
+	return slices.ContainsFunc(p.scopes, func(s domain.Scope) bool { return s.Allows(required) })
 }
 
 // Auth は認証関連のハンドラー。
 type Auth struct {
-	cfAccess   *auth.CFAccessVerifier
-	tickets    *auth.TicketStore
-	teamDomain string
+	verifier  auth.Verifier
+	tickets   *auth.TicketStore
+	tokens    *application.APITokenService
+	logoutURL string
+
+	closeWSOnExpiry bool
+	trustedProxies  []netip.Prefix
 }
 
-// NewAuth は新しいAuthハンドラーを生成する。
-func NewAuth(cfAccess *auth.CFAccessVerifier, tickets *auth.TicketStore, teamDomain string) *Auth {
+// NewAuth は新しいAuthハンドラーを生成する。logoutURLが空ならログアウト後は"/"に戻す。
+// verifierがnilならJWTは受け付けずAPIトークンだけで認証し、tokensがnilならAPIトークンは受け付けない。
+func NewAuth(verifier auth.Verifier, tickets *auth.TicketStore, tokens *application.APITokenService, logoutURL string) *Auth {
 	return &Auth{
-		cfAccess:   cfAccess,
-		tickets:    tickets,
-		teamDomain: teamDomain,
+		verifier:  verifier,
+		tickets:   tickets,
+		tokens:    tokens,
+		logoutURL: logoutURL,
 	}
 }
 
-// WSTicket はCF Access検証後にWSチケットを発行する。
+// SetCloseWSOnExpiry は認証情報の期限切れ時に、WS接続を非認証へ降格する代わりに閉じるかどうかを設定する。
+func (a *Auth) SetCloseWSOnExpiry(enabled bool) {
+	a.closeWSOnExpiry = enabled
+}
+
+// SetTrustedProxies はCf-Connecting-Ipを信用するリバースプロキシのアドレスを設定する。
+// CF Accessで認証するときは、プロキシに関わらずCloudflareのヘッダーを信用する。
+func (a *Auth) SetTrustedProxies(prefixes []netip.Prefix) {
+	a.trustedProxies = prefixes
+}
+
+// WSTicketRequest はWSチケット発行リクエスト。ボディは省略できる。
+type WSTicketRequest struct {
+	// Entries は認証済み編集を許可するエントリ。空なら全エントリ。
+	Entries []string `json:"entries"`
+}
+
+// WSTicket は認証後に、利用者とクライアントIPに束縛したWSチケットを発行する。編集スコープが必要。
 func (a *Auth) WSTicket(w http.ResponseWriter, r *http.Request) {
-	if !IsAuthenticated(r.Context()) {
+	if !HasScope(r.Context(), domain.ScopeEdit) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	ticket := a.tickets.Issue()
+	var req WSTicketRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+	}
+	entries := make([]uuid.UUID, 0, len(req.Entries))
+	for _, s := range req.Entries {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+		entries = append(entries, id)
+	}
+
+	p, _ := r.Context().Value(authContextKey).(principal)
+	ticket := a.tickets.Issue(auth.Grant{
+		Identity:            p.identity,
+		Entries:             entries,
+		ClientIP:            a.clientIP(r),
+		CredentialExpiresAt: p.expiresAt,
+	})
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"ticket": ticket})
 }
 
-// CFAccessMiddleware はCF Access JWTを検証してコンテキストに認証状態を設定するミドルウェア。
-func (a *Auth) CFAccessMiddleware(next http.Handler) http.Handler {
+// Middleware はJWTまたはAPIトークンを検証してコンテキストに認証状態を設定するミドルウェア。
+// JWTで認証された利用者は全スコープを持つ。
+func (a *Auth) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authenticated := false
+		var p principal
 
-		tokenStr := r.Header.Get("Cf-Access-Jwt-Assertion")
-		if tokenStr == "" {
-			if cookie, err := r.Cookie("CF_Authorization"); err == nil {
-				tokenStr = cookie.Value
+		if tokenStr := bearerToken(r); tokenStr != "" {
+			if strings.HasPrefix(tokenStr, application.APITokenPrefix) {
+				p = a.tokenPrincipal(r.Context(), tokenStr)
+			} else if claims, ok := a.verifyJWT(tokenStr); ok {
+				p.identity = cmp.Or(claims.Email, claims.Subject)
+				p.scopes = []domain.Scope{domain.ScopeAdmin}
+				if claims.ExpiresAt != nil {
+					p.expiresAt = claims.ExpiresAt.Time
+				}
 			}
 		}
 
-		if tokenStr != "" {
-			if _, err := a.cfAccess.Verify(tokenStr); err == nil {
-				authenticated = true
-			}
+		ctx := context.WithValue(r.Context(), authContextKey, p)
+		if p.identity != "" {
+			ctx = application.WithActor(ctx, p.identity)
 		}
-
-		ctx := context.WithValue(r.Context(), authContextKey, authenticated)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// RequireAuth はCFAccessMiddlewareの後に使い、未認証なら403を返すミドルウェア。
-func RequireAuth(next http.Handler) http.Handler {
+// verifyJWT はJWTを検証する。検証器が無ければ常に失敗する。
+func (a *Auth) verifyJWT(tokenStr string) (*auth.Claims, bool) {
+	if a.verifier == nil {
+		return nil, false
+	}
+	claims, err := a.verifier.Verify(tokenStr)
+	return claims, err == nil
+}
+
+// tokenPrincipal はAPIトークンの主体を返す。無効なトークンならゼロ値。
+func (a *Auth) tokenPrincipal(ctx context.Context, secret string) principal {
+	if a.tokens == nil {
+		return principal{}
+	}
+	token, err := a.tokens.Authenticate(ctx, secret)
+	if err != nil {
+		return principal{}
+	}
+	return principal{identity: "token:" + token.Name, scopes: token.Scopes}
+}
+
+// AuthenticateToken はWS接続用に、APIトークンが編集スコープを持つかどうかと、その利用者を返す。
+func (a *Auth) AuthenticateToken(ctx context.Context, secret string) (string, bool) {
+	if !strings.HasPrefix(secret, application.APITokenPrefix) {
+		return "", false
+	}
+	p := a.tokenPrincipal(ctx, secret)
+	ok := slices.ContainsFunc(p.scopes, func(s domain.Scope) bool {
+		return s.Allows(domain.ScopeEdit)
+	})
+	return p.identity, ok
+}
+
+// clientIP はクライアントのIPを返す。CF Accessで認証しているか、接続元が信用するプロキシなら
+// Cf-Connecting-Ipを使う。それ以外では誰でも偽装できるのでヘッダーは無視する。
+func (a *Auth) clientIP(r *http.Request) string {
+	remote := remoteIP(r)
+	if ip := r.Header.Get("Cf-Connecting-Ip"); ip != "" && a.trustsProxy(remote) {
+		return ip
+	}
+	return remote
+}
+
+// trustsProxy はaddrからの転送ヘッダーを信用するかどうかを返す。
+func (a *Auth) trustsProxy(addr string) bool {
+	if _, ok := a.verifier.(*auth.CFAccessVerifier); ok {
+		return true
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(a.trustedProxies, func(p netip.Prefix) bool { return p.Contains(ip.Unmap()) })
+}
+
+// remoteIP は接続元のIPを返す。
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// bearerToken はCF Accessのヘッダー、Authorizationヘッダー、CF_Authorization Cookieの順にJWTを取り出す。
+func bearerToken(r *http.Request) string {
+	if tokenStr := r.Header.Get("Cf-Access-Jwt-Assertion"); tokenStr != "" {
+		return tokenStr
+	}
+	if tokenStr, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return tokenStr
+	}
+	if cookie, err := r.Cookie("CF_Authorization"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// RequireAuth はMiddlewareの後に使い、scopeを満たさなければ403を返すミドルウェア。
+func RequireAuth(scope domain.Scope, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !IsAuthenticated(r.Context()) {
+		if !HasScope(r.Context(), scope) {
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
@@ -81,12 +232,17 @@ func RequireAuth(next http.Handler) http.Handler {
 	})
 }
 
-// RedeemTicket はチケットを検証・消費する。
-func (a *Auth) RedeemTicket(ticket string) bool {
-	return a.tickets.Redeem(ticket)
+// RedeemTicket はチケットを検証・消費し、付与された権限を返す。
+func (a *Auth) RedeemTicket(ticket, clientIP string) (auth.Grant, bool) {
+	return a.tickets.Redeem(ticket, clientIP)
 }
 
-// Logout はCF_Authorization Cookieを削除し、CF Accessのログアウトにリダイレクトする。
+// RevokeTickets は利用者の未使用チケットを無効化する。
+func (a *Auth) RevokeTickets(identity string) int {
+	return a.tickets.RevokeIdentity(identity)
+}
+
+// Logout はCF_Authorization Cookieを削除し、IdPのログアウトにリダイレクトする。
 func (a *Auth) Logout(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:   "CF_Authorization",
@@ -94,6 +250,9 @@ func (a *Auth) Logout(w http.ResponseWriter, r *http.Request) {
 		Path:   "/",
 		MaxAge: -1,
 	})
-	logoutURL := fmt.Sprintf("https://%s.cloudflareaccess.com/cdn-cgi/access/logout", a.teamDomain)
+	logoutURL := a.logoutURL
+	if logoutURL == "" {
+		logoutURL = "/"
+	}
 	http.Redirect(w, r, logoutURL, http.StatusFound)
 }
@@ -0,0 +1,140 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/auth"
+	"flourish/server/domain"
+	"flourish/server/handler"
+)
+
+func TestAuth_APITokenScopes(t *testing.T) {
+	tokens := application.NewAPITokenService(memory.NewAPITokenStore())
+	a := handler.NewAuth(auth.NewStaticVerifier("", "", nil), auth.NewTicketStore(0), tokens, "")
+
+	readToken, _, _ := tokens.Issue(context.Background(), "reader", []domain.Scope{domain.ScopeRead})
+	adminToken, _, _ := tokens.Issue(context.Background(), "admin", []domain.Scope{domain.ScopeAdmin})
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name  string
+		token string
+		scope domain.Scope
+		want  int
+	}{
+		{"read token on read", readToken, domain.ScopeRead, http.StatusOK},
+		{"read token on edit", readToken, domain.ScopeEdit, http.StatusForbidden},
+		{"admin token on edit", adminToken, domain.ScopeEdit, http.StatusOK},
+		{"unknown token", application.APITokenPrefix + "deadbeef", domain.ScopeRead, http.StatusForbidden},
+		{"no token", "", domain.ScopeRead, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/tokens", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			a.Middleware(handler.RequireAuth(tt.scope, ok)).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status: got %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudit_RecordsResult(t *testing.T) {
+	tokens := application.NewAPITokenService(memory.NewAPITokenStore())
+	a := handler.NewAuth(auth.NewStaticVerifier("", "", nil), auth.NewTicketStore(0), tokens, "")
+	auditLog := application.NewAuditLog(memory.NewAuditStore(), 0, slog.Default())
+
+	readToken, _, _ := tokens.Issue(context.Background(), "reader", []domain.Scope{domain.ScopeRead})
+	adminToken, _, _ := tokens.Issue(context.Background(), "ops", []domain.Scope{domain.ScopeAdmin})
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/admin/entries/{id}/delete", a.Middleware(handler.Audit(auditLog, application.AuditEntryDelete, "id",
+		handler.RequireAuth(domain.ScopeAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))))
+
+	for _, token := range []string{readToken, adminToken} {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/entries/e1/delete", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.NewAuditLog(auditLog).List(rec, httptest.NewRequest(http.MethodGet, "/api/admin/audit?target=e1", nil))
+	var body handler.AuditListResponse
+	json.NewDecoder(rec.Body).Decode(&body)
+	if len(body.Records) != 2 {
+		t.Fatalf("records: got %+v", body.Records)
+	}
+	// 新しい順
+	if body.Records[0].Actor != "token:ops" || body.Records[0].Result != "success" {
+		t.Errorf("admin delete: got %+v", body.Records[0])
+	}
+	if body.Records[1].Actor != "token:reader" || body.Records[1].Result != "denied" {
+		t.Errorf("reader delete: got %+v", body.Records[1])
+	}
+}
+
+func TestAuth_WSTicketIgnoresSpoofedClientIP(t *testing.T) {
+	tokens := application.NewAPITokenService(memory.NewAPITokenStore())
+	a := handler.NewAuth(auth.NewStaticVerifier("", "", nil), auth.NewTicketStore(time.Minute), tokens, "")
+	editToken, _, _ := tokens.Issue(context.Background(), "editor", []domain.Scope{domain.ScopeEdit})
+
+	issue := func() string {
+		req := httptest.NewRequest(http.MethodPost, "/api/ws-ticket", nil)
+		req.RemoteAddr = "192.0.2.10:5000"
+		req.Header.Set("Authorization", "Bearer "+editToken)
+		req.Header.Set("Cf-Connecting-Ip", "203.0.113.7")
+		rec := httptest.NewRecorder()
+		a.Middleware(http.HandlerFunc(a.WSTicket)).ServeHTTP(rec, req)
+		var body map[string]string
+		json.NewDecoder(rec.Body).Decode(&body)
+		return body["ticket"]
+	}
+
+	// 信用するプロキシでなければヘッダーを無視し、接続元に束縛する
+	if _, ok := a.RedeemTicket(issue(), "192.0.2.10"); !ok {
+		t.Error("チケットは接続元のIPに束縛されるべき")
+	}
+	a.SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")})
+	if _, ok := a.RedeemTicket(issue(), "203.0.113.7"); !ok {
+		t.Error("信用するプロキシ経由ならCf-Connecting-Ipに束縛されるべき")
+	}
+}
+
+func TestAuth_TokensWithoutJWTVerifier(t *testing.T) {
+	tokens := application.NewAPITokenService(memory.NewAPITokenStore())
+	a := handler.NewAuth(nil, auth.NewTicketStore(0), tokens, "")
+	adminToken, _, _ := tokens.Issue(context.Background(), "ops", []domain.Scope{domain.ScopeAdmin})
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tt := range []struct {
+		token string
+		want  int
+	}{
+		{adminToken, http.StatusOK},
+		{"eyJhbGciOiJIUzI1NiJ9.e30.sig", http.StatusForbidden},
+		{application.APITokenPrefix + "deadbeef", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/tokens", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rec := httptest.NewRecorder()
+		a.Middleware(handler.RequireAuth(domain.ScopeAdmin, ok)).ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("token %q: got %d, want %d", tt.token, rec.Code, tt.want)
+		}
+	}
+}
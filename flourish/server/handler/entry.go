@@ -3,10 +3,12 @@ package handler
 import (
 	"errors"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 
+	"flourish/server/application"
 	"flourish/server/domain"
 )
 
@@ -20,12 +22,16 @@ type EntryCreatedResponse struct {
 
 // EntryListItemResponse は一覧表示用のエントリレスポンス。
 type EntryListItemResponse struct {
-	ID        string  `json:"id"`
-	Title     string  `json:"title"`
-	Content   string  `json:"content"`
-	Thumbnail *string `json:"thumbnail"`
-	CreatedAt string  `json:"created_at"`
-	UpdatedAt string  `json:"updated_at"`
+	ID                 string   `json:"id"`
+	Title              string   `json:"title"`
+	Content            string   `json:"content"`
+	Thumbnail          *string  `json:"thumbnail"`
+	Tags               []string `json:"tags"`
+	Description        string   `json:"description"`
+	WordCount          int      `json:"word_count"`
+	ReadingTimeMinutes int      `json:"reading_time_minutes"`
+	CreatedAt          string   `json:"created_at"`
+	UpdatedAt          string   `json:"updated_at"`
 }
 
 // EntryListResponse はエントリ一覧レスポンス。
@@ -35,27 +41,33 @@ type EntryListResponse struct {
 
 // EntryDetailResponse はエントリ詳細レスポンス。
 type EntryDetailResponse struct {
-	ID        string  `json:"id"`
-	Title     string  `json:"title"`
-	Content   string  `json:"content"`
-	Text      string  `json:"text"`
-	Thumbnail *string `json:"thumbnail"`
-	CreatedAt string  `json:"created_at"`
-	UpdatedAt string  `json:"updated_at"`
+	ID                 string   `json:"id"`
+	Title              string   `json:"title"`
+	Content            string   `json:"content"`
+	Text               string   `json:"text"`
+	Thumbnail          *string  `json:"thumbnail"`
+	Tags               []string `json:"tags"`
+	Description        string   `json:"description"`
+	WordCount          int      `json:"word_count"`
+	ReadingTimeMinutes int      `json:"reading_time_minutes"`
+	Locked             bool     `json:"locked"`
+	CreatedAt          string   `json:"created_at"`
+	UpdatedAt          string   `json:"updated_at"`
 }
 
-// Entry はエントリのHTTPハンドラー。
+// Entry はエントリのHTTPハンドラー。削除はTrashが担う。
 type Entry struct {
-	store domain.EntryStore
+	store   domain.EntryStore
+	service *application.EntryService
 }
 
-func NewEntry(store domain.EntryStore) *Entry {
-	return &Entry{store: store}
+func NewEntry(store domain.EntryStore, service *application.EntryService) *Entry {
+	return &Entry{store: store, service: service}
 }
 
 func (h *Entry) Create(w http.ResponseWriter, r *http.Request) {
-	entry := domain.NewEntry()
-	if err := h.store.Save(r.Context(), entry); err != nil {
+	entry, err := h.service.Create(r.Context())
+	if err != nil {
 		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
 		return
 	}
@@ -68,6 +80,7 @@ func (h *Entry) Create(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// List は GET /api/entries ハンドラー。?tag= で絞り込める。
 func (h *Entry) List(w http.ResponseWriter, r *http.Request) {
 	items, err := h.store.List(r.Context())
 	if err != nil {
@@ -75,41 +88,31 @@ func (h *Entry) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		items = slices.DeleteFunc(items, func(item domain.EntryListItem) bool {
+			return !item.HasTag(tag)
+		})
+	}
+
 	entries := make([]EntryListItemResponse, len(items))
 	for i, item := range items {
 		entries[i] = EntryListItemResponse{
-			ID:        item.ID.String(),
-			Title:     item.Title,
-			Content:   item.Content,
-			Thumbnail: item.Thumbnail,
-			CreatedAt: item.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: item.UpdatedAt.Format(time.RFC3339),
+			ID:                 item.ID.String(),
+			Title:              item.Title,
+			Content:            item.Content,
+			Thumbnail:          item.Thumbnail,
+			Tags:               nonNilTags(item.Tags),
+			Description:        item.Description,
+			WordCount:          item.WordCount,
+			ReadingTimeMinutes: item.ReadingMinutes,
+			CreatedAt:          item.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:          item.UpdatedAt.Format(time.RFC3339),
 		}
 	}
 
 	writeJSON(w, http.StatusOK, EntryListResponse{Entries: entries})
 }
 
-func (h *Entry) Delete(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
-		return
-	}
-
-	if err := h.store.Delete(r.Context(), id); err != nil {
-		if errors.Is(err, domain.ErrEntryNotFound) {
-			writeProblem(w, http.StatusNotFound, "error:entry_not_found", "Entry Not Found")
-			return
-		}
-		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
 // Get は GET /api/entries/{id} ハンドラー。
 func (h *Entry) Get(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
@@ -130,12 +133,25 @@ func (h *Entry) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusOK, EntryDetailResponse{
-		ID:        entry.ID.String(),
-		Title:     entry.Title,
-		Content:   entry.Content,
-		Text:      entry.Text,
-		Thumbnail: entry.Thumbnail,
-		CreatedAt: entry.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: entry.UpdatedAt.Format(time.RFC3339),
+		ID:                 entry.ID.String(),
+		Title:              entry.Title,
+		Content:            entry.Content,
+		Text:               entry.Text,
+		Thumbnail:          entry.Thumbnail,
+		Tags:               nonNilTags(entry.Tags),
+		Description:        entry.Description,
+		WordCount:          entry.WordCount,
+		ReadingTimeMinutes: entry.ReadingMinutes,
+		Locked:             entry.Locked,
+		CreatedAt:          entry.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:          entry.UpdatedAt.Format(time.RFC3339),
 	})
 }
+
+// nonNilTags はJSONで null ではなく [] を返すためにnilを空スライスに変換する。
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
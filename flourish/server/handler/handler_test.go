@@ -3,17 +3,25 @@ package handler_test
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"flourish/server/adapter/jsonfile"
 	"flourish/server/adapter/memory"
+	"flourish/server/application"
 	"flourish/server/domain"
 	"flourish/server/handler"
 )
 
 func TestHealth(t *testing.T) {
-	h := handler.NewHealth()
+	h := handler.NewHealth(nil, nil)
 	req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
 	rec := httptest.NewRecorder()
 
@@ -32,7 +40,7 @@ func TestHealth(t *testing.T) {
 
 func TestEntryHandler_Create(t *testing.T) {
 	store := memory.NewEntryStore()
-	h := handler.NewEntry(store)
+	h := handler.NewEntry(store, application.NewEntryService(application.NewSyncService(memory.NewEventStore()), store))
 
 	req := httptest.NewRequest(http.MethodPost, "/api/entries", nil)
 	rec := httptest.NewRecorder()
@@ -59,7 +67,7 @@ func TestEntryHandler_List(t *testing.T) {
 	entry.Title = "テスト"
 	store.Save(context.Background(), entry)
 
-	h := handler.NewEntry(store)
+	h := handler.NewEntry(store, application.NewEntryService(application.NewSyncService(memory.NewEventStore()), store))
 	req := httptest.NewRequest(http.MethodGet, "/api/entries", nil)
 	rec := httptest.NewRecorder()
 
@@ -76,13 +84,25 @@ func TestEntryHandler_List(t *testing.T) {
 	}
 }
 
-func TestEntryHandler_Delete(t *testing.T) {
+func newTestTrash(t *testing.T, store domain.EntryStore) *handler.Trash {
+	t.Helper()
+	eventStore := memory.NewEventStore()
+	rgaStore, err := jsonfile.NewRGAStateStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	projector := application.NewEntryProjector(store, rgaStore, t.TempDir(), slog.Default())
+	syncService := application.NewSyncService(eventStore)
+	return handler.NewTrash(application.NewTrashService(syncService, projector, store, eventStore, 0, slog.Default()))
+}
+
+func TestTrashHandler_Delete(t *testing.T) {
 	store := memory.NewEntryStore()
 	entry := domain.NewEntry()
 	store.Save(context.Background(), entry)
 
-	h := handler.NewEntry(store)
-	req := httptest.NewRequest(http.MethodDelete, "/api/entries/"+entry.ID.String(), nil)
+	h := newTestTrash(t, store)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/entries/"+entry.ID.String()+"/delete", nil)
 	req.SetPathValue("id", entry.ID.String())
 	rec := httptest.NewRecorder()
 
@@ -93,11 +113,11 @@ func TestEntryHandler_Delete(t *testing.T) {
 	}
 }
 
-func TestEntryHandler_Delete_NotFound(t *testing.T) {
+func TestTrashHandler_Delete_NotFound(t *testing.T) {
 	store := memory.NewEntryStore()
-	h := handler.NewEntry(store)
+	h := newTestTrash(t, store)
 
-	req := httptest.NewRequest(http.MethodDelete, "/api/entries/00000000-0000-0000-0000-000000000001", nil)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/entries/00000000-0000-0000-0000-000000000001/delete", nil)
 	req.SetPathValue("id", "00000000-0000-0000-0000-000000000001")
 	rec := httptest.NewRecorder()
 
@@ -113,3 +133,134 @@ func TestEntryHandler_Delete_NotFound(t *testing.T) {
 		t.Errorf("error_typeが'error:entry_not_found'であるべき: got %q", body.Type)
 	}
 }
+
+func TestTrashHandler_ListAndRestore(t *testing.T) {
+	store := memory.NewEntryStore()
+	entry := domain.NewEntry()
+	entry.Title = "消すエントリ"
+	store.Save(context.Background(), entry)
+	h := newTestTrash(t, store)
+
+	call := func(fn http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.SetPathValue("id", entry.ID.String())
+		rec := httptest.NewRecorder()
+		fn(rec, req)
+		return rec
+	}
+
+	if rec := call(h.Restore); rec.Code != http.StatusConflict {
+		t.Errorf("ゴミ箱に無いエントリの復元は409であるべき: got %d", rec.Code)
+	}
+	call(h.Delete)
+
+	rec := httptest.NewRecorder()
+	h.List(rec, httptest.NewRequest(http.MethodGet, "/api/admin/trash", nil))
+	var body handler.TrashListResponse
+	json.NewDecoder(rec.Body).Decode(&body)
+	if len(body.Entries) != 1 || body.Entries[0].Title != "消すエントリ" || body.Entries[0].DeletedAt == "" {
+		t.Fatalf("ゴミ箱にエントリが1件あるべき: got %+v", body.Entries)
+	}
+
+	if rec := call(h.Restore); rec.Code != http.StatusNoContent {
+		t.Errorf("復元は204であるべき: got %d", rec.Code)
+	}
+	if _, err := store.FindByID(context.Background(), entry.ID); err != nil {
+		t.Errorf("復元後は取得できるべき: %v", err)
+	}
+}
+
+func TestEntryHandler_List_FilterByTag(t *testing.T) {
+	store := memory.NewEntryStore()
+	tagged := domain.NewEntry()
+	tagged.Tags = []string{"go"}
+	store.Save(context.Background(), tagged)
+	store.Save(context.Background(), domain.NewEntry())
+
+	h := handler.NewEntry(store, application.NewEntryService(application.NewSyncService(memory.NewEventStore()), store))
+	req := httptest.NewRequest(http.MethodGet, "/api/entries?tag=Go", nil)
+	rec := httptest.NewRecorder()
+
+	h.List(rec, req)
+
+	var body handler.EntryListResponse
+	json.NewDecoder(rec.Body).Decode(&body)
+	if len(body.Entries) != 1 || body.Entries[0].ID != tagged.ID.String() {
+		t.Errorf("タグ付きエントリのみ返すべき: got %+v", body.Entries)
+	}
+}
+
+func TestTagHandler_Feed(t *testing.T) {
+	store := memory.NewEntryStore()
+	entry := domain.NewEntry()
+	entry.Title = "タグ付き"
+	entry.Tags = []string{"go"}
+	store.Save(context.Background(), entry)
+
+	h := handler.NewTag(store, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/tags/go/feed", nil)
+	req.SetPathValue("tag", "go")
+	rec := httptest.NewRecorder()
+
+	h.Feed(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ステータスコードが200であるべき: got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("Content-Type: got %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "urn:uuid:"+entry.ID.String()) {
+		t.Errorf("フィードにエントリが含まれるべき: %s", rec.Body.String())
+	}
+}
+
+func TestTagHandler_FeedOrdersNewestFirst(t *testing.T) {
+	store := memory.NewEntryStore()
+	now := time.Now().UTC().Truncate(time.Second)
+	var ids []string
+	for i, age := range []time.Duration{2 * time.Hour, 0, time.Hour} {
+		entry := domain.NewEntry()
+		entry.Title = fmt.Sprintf("entry %d", i)
+		entry.Tags = []string{"go"}
+		entry.UpdatedAt = now.Add(-age)
+		store.Save(context.Background(), entry)
+		ids = append(ids, "urn:uuid:"+entry.ID.String())
+	}
+
+	h := handler.NewTag(store, nil)
+	feed := func(tag string) (body struct {
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID string `xml:"id"`
+		} `xml:"entry"`
+	}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/tags/"+tag+"/feed", nil)
+		req.SetPathValue("tag", tag)
+		rec := httptest.NewRecorder()
+		h.Feed(rec, req)
+		if err := xml.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	body := feed("go")
+	var got []string
+	for _, e := range body.Entries {
+		got = append(got, e.ID)
+	}
+	if want := []string{ids[1], ids[2], ids[0]}; !slices.Equal(got, want) {
+		t.Errorf("エントリは更新の新しい順であるべき: got %v, want %v", got, want)
+	}
+	if want := now.Format(time.RFC3339); body.Updated != want {
+		t.Errorf("updated: got %q, want %q", body.Updated, want)
+	}
+
+	// エントリの無いフィードのupdatedはゼロ時刻にしない
+	empty := feed("rust")
+	if updated, err := time.Parse(time.RFC3339, empty.Updated); err != nil || updated.Year() < 2000 {
+		t.Errorf("空のフィードのupdated: got %q", empty.Updated)
+	}
+}
@@ -1,14 +1,113 @@
 package handler
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+
+	"flourish/server/application"
+)
+
+// HealthResponse はヘルスチェックのレスポンス。
+type HealthResponse struct {
+	Status     string                    `json:"status"`
+	Projection *ProjectionHealthResponse `json:"projection,omitempty"`
+	Queues     *QueueHealthResponse      `json:"queues,omitempty"`
+	// Replication はフォロワーとして起動したときの複製の状況。
+	Replication *ReplicationHealthResponse `json:"replication,omitempty"`
+}
+
+// ProjectionHealthResponse は投影の書き込み遅延と処理待ちの状況。時間はミリ秒。
+type ProjectionHealthResponse struct {
+	Pending   int   `json:"pending"`
+	Flushing  int   `json:"flushing"`
+	LagMs     int64 `json:"lag_ms"`
+	LastLagMs int64 `json:"last_lag_ms"`
+	MaxLagMs  int64 `json:"max_lag_ms"`
+	// ShardQueueDepth はシャードごとの処理待ちの数。
+	ShardQueueDepth []int64 `json:"shard_queue_depth"`
+}
+
+// QueueHealthResponse は購読者の送信キューの状況。
+type QueueHealthResponse struct {
+	Subscribers int    `json:"subscribers"`
+	Queued      int    `json:"queued"`
+	MaxDepth    int    `json:"max_depth"`
+	Capacity    int    `json:"capacity"`
+	Coalesced   uint64 `json:"coalesced"`
+	Overflows   uint64 `json:"overflows"`
+	Disconnects uint64 `json:"disconnects"`
+}
+
+// ReplicationHealthResponse はフォロワーの複製の状況。LastSyncは最後にリーダーに追いついた時刻（RFC3339）。
+type ReplicationHealthResponse struct {
+	Role       string `json:"role"`
+	LastSync   string `json:"last_sync,omitempty"`
+	Replicated uint64 `json:"replicated"`
+	Purged     uint64 `json:"purged"`
+	Errors     uint64 `json:"errors"`
+	Diverged   int    `json:"diverged"`
+}
 
 // Health はヘルスチェックハンドラー。
-type Health struct{}
+type Health struct {
+	projector   *application.EntryProjector
+	syncService *application.SyncService
+	replicator  *application.Replicator
+}
+
+// NewHealth は新しいHealthを生成する。projectorを渡すと投影のflush遅延とシャードの処理待ちも、
+// syncServiceを渡すと購読者の送信キューの状況も返す。
+func NewHealth(projector *application.EntryProjector, syncService *application.SyncService) *Health {
+	return &Health{projector: projector, syncService: syncService}
+}
 
-func NewHealth() *Health {
-	return &Health{}
+// SetReplicator はフォロワーのとき、複製の状況も返すよう設定する。
+func (h *Health) SetReplicator(replicator *application.Replicator) {
+	h.replicator = replicator
 }
 
 func (h *Health) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	resp := HealthResponse{Status: "ok"}
+	if h.projector != nil {
+		stats := h.projector.FlushStats()
+		resp.Projection = &ProjectionHealthResponse{
+			Pending:   stats.Pending,
+			Flushing:  stats.Flushing,
+			LagMs:     stats.Lag.Milliseconds(),
+			LastLagMs: stats.LastLag.Milliseconds(),
+			MaxLagMs:  stats.MaxLag.Milliseconds(),
+		}
+		for _, shard := range h.projector.ShardStats() {
+			resp.Projection.ShardQueueDepth = append(resp.Projection.ShardQueueDepth, shard.QueueDepth)
+		}
+	}
+	if h.syncService != nil {
+		stats := h.syncService.QueueStats()
+		resp.Queues = &QueueHealthResponse{
+			Subscribers: stats.Subscribers,
+			Queued:      stats.Queued,
+			MaxDepth:    stats.MaxDepth,
+			Capacity:    stats.Capacity,
+			Coalesced:   stats.Coalesced,
+			Overflows:   stats.Overflows,
+			Disconnects: stats.Disconnects,
+		}
+	}
+	if h.replicator != nil {
+		stats := h.replicator.Stats()
+		resp.Replication = &ReplicationHealthResponse{
+			Role:       RoleFollower,
+			Replicated: stats.Replicated,
+			Purged:     stats.Purged,
+			Errors:     stats.Errors,
+			Diverged:   stats.Diverged,
+		}
+		if stats.Promoted {
+			resp.Replication.Role = RoleLeader
+		}
+		if !stats.LastSync.IsZero() {
+			resp.Replication.LastSync = stats.LastSync.UTC().Format(time.RFC3339)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// LockResponse はロック状態レスポンス。
+type LockResponse struct {
+	ID     string `json:"id"`
+	Locked bool   `json:"locked"`
+}
+
+// Lock はエントリの編集ロックのHTTPハンドラー。
+type Lock struct {
+	service *application.LockService
+}
+
+func NewLock(service *application.LockService) *Lock {
+	return &Lock{service: service}
+}
+
+// Lock は POST /api/admin/entries/{id}/lock ハンドラー。
+func (h *Lock) Lock(w http.ResponseWriter, r *http.Request) {
+	h.set(w, r, true)
+}
+
+// Unlock は POST /api/admin/entries/{id}/unlock ハンドラー。
+func (h *Lock) Unlock(w http.ResponseWriter, r *http.Request) {
+	h.set(w, r, false)
+}
+
+func (h *Lock) set(w http.ResponseWriter, r *http.Request, locked bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	if err := h.service.SetLocked(r.Context(), id, locked); err != nil {
+		if errors.Is(err, domain.ErrEntryNotFound) || errors.Is(err, domain.ErrEntryDeleted) {
+			writeProblem(w, http.StatusNotFound, "error:entry_not_found", "Entry Not Found")
+			return
+		}
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, LockResponse{ID: id.String(), Locked: locked})
+}
+
+// writeEntryLocked はロック中のエントリへの変更を拒否する。
+func writeEntryLocked(w http.ResponseWriter) {
+	writeProblem(w, http.StatusLocked, "error:entry_locked", "Entry Locked")
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+	"flourish/server/domain/crdt"
+)
+
+// ContributionResponse は未承認コントリビューション。
+type ContributionResponse struct {
+	NodeIDs []NodeIDMsg `json:"node_ids"`
+	Text    string      `json:"text"`
+	Offset  int         `json:"offset"`
+	Before  string      `json:"before"`
+	After   string      `json:"after"`
+}
+
+// PendingResponse は未承認コントリビューション一覧と差分表示用テキスト。
+type PendingResponse struct {
+	EntryID       string                 `json:"entry_id"`
+	PublicText    string                 `json:"public_text"`
+	ProposedText  string                 `json:"proposed_text"`
+	Contributions []ContributionResponse `json:"contributions"`
+}
+
+// ModerationRequest は承認・却下リクエスト。node_idsが空なら全未承認ノードが対象。
+type ModerationRequest struct {
+	NodeIDs []NodeIDMsg `json:"node_ids"`
+}
+
+// Moderation はモデレーションのHTTPハンドラー。
+type Moderation struct {
+	service *application.ModerationService
+}
+
+func NewModeration(service *application.ModerationService) *Moderation {
+	return &Moderation{service: service}
+}
+
+// Pending は GET /api/admin/entries/{id}/pending ハンドラー。
+func (h *Moderation) Pending(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	view, err := h.service.Pending(id)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toPendingResponse(view))
+}
+
+// Approve は POST /api/admin/entries/{id}/pending/approve ハンドラー。
+func (h *Moderation) Approve(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Approve)
+}
+
+// Reject は POST /api/admin/entries/{id}/pending/reject ハンドラー。
+func (h *Moderation) Reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Reject)
+}
+
+func (h *Moderation) decide(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, entryID uuid.UUID, nodeIDs []crdt.NodeID) error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	var req ModerationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+	}
+	nodeIDs := make([]crdt.NodeID, 0, len(req.NodeIDs))
+	for _, n := range req.NodeIDs {
+		siteID, err := uuid.Parse(n.SiteID)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+		nodeIDs = append(nodeIDs, crdt.NodeID{ReplicaID: siteID, Timestamp: n.Timestamp})
+	}
+
+	if err := fn(r.Context(), id, nodeIDs); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+
+	view, err := h.service.Pending(id)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toPendingResponse(view))
+}
+
+func writeModerationError(w http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrEntryLocked) {
+		writeEntryLocked(w)
+		return
+	}
+	if errors.Is(err, domain.ErrEntryNotFound) || errors.Is(err, domain.ErrEntryDeleted) {
+		writeProblem(w, http.StatusNotFound, "error:entry_not_found", "Entry Not Found")
+		return
+	}
+	writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+}
+
+func toPendingResponse(view application.PendingView) PendingResponse {
+	contributions := make([]ContributionResponse, len(view.Contributions))
+	for i, c := range view.Contributions {
+		contributions[i] = ContributionResponse{
+			NodeIDs: toNodeIDMsgs(c.NodeIDs),
+			Text:    c.Text,
+			Offset:  c.Offset,
+			Before:  c.Before,
+			After:   c.After,
+		}
+	}
+	return PendingResponse{
+		EntryID:       view.EntryID.String(),
+		PublicText:    view.PublicText,
+		ProposedText:  view.ProposedText,
+		Contributions: contributions,
+	}
+}
+
+func toNodeIDMsgs(ids []crdt.NodeID) []NodeIDMsg {
+	msgs := make([]NodeIDMsg, len(ids))
+	for i, id := range ids {
+		msgs[i] = NodeIDMsg{SiteID: id.ReplicaID.String(), Timestamp: id.Timestamp}
+	}
+	return msgs
+}
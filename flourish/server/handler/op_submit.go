@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+)
+
+// opSubmitter はWebSocketとHTTPで共通のop受付処理。
+// 検証（prepare）と記録・適用・配信（commit）に分かれ、その間に呼び出し側が認証状態を決める。
+type opSubmitter struct {
+	syncService *application.SyncService
+	projector   *application.EntryProjector
+	locks       *application.LockService
+	trash       *application.TrashService
+	// replicator は昇格前のフォロワーならopを拒否するためのもの。リーダーならnil
+	replicator *application.Replicator
+}
+
+// opError はopを受け付けられなかった理由。WSではerrorメッセージ、HTTPではproblemとして返す。
+type opError struct {
+	status int
+	typ    string
+	title  string
+}
+
+var (
+	errOpInvalid      = &opError{http.StatusBadRequest, "error:invalid_op", "Invalid Operation"}
+	errOpEntryDeleted = &opError{http.StatusGone, "error:entry_deleted", "Entry Deleted"}
+	errOpEntryLocked  = &opError{http.StatusLocked, "error:entry_locked", "Entry Locked"}
+	errOpInternal     = &opError{http.StatusInternalServerError, "error:internal", "Internal Error"}
+	errOpReadOnly     = &opError{http.StatusServiceUnavailable, "error:read_only", "Read-Only Replica"}
+)
+
+// pendingOp は検証済みで記録前のop。
+type pendingOp struct {
+	msg       IncomingMessage
+	entryID   uuid.UUID
+	requestID uuid.UUID
+	siteID    uuid.UUID
+}
+
+// prepare はopのIDを検証し、削除済み・ロック中のエントリへのopと、昇格前のフォロワーへのopを拒否する。
+func (s opSubmitter) prepare(ctx context.Context, msg IncomingMessage) (pendingOp, *opError) {
+	if s.replicator != nil && s.replicator.ReadOnly() {
+		return pendingOp{}, errOpReadOnly
+	}
+	entryID, err := uuid.Parse(msg.EntryID)
+	if err != nil {
+		return pendingOp{}, errOpInvalid
+	}
+	requestID, err := uuid.Parse(msg.RequestID)
+	if err != nil {
+		return pendingOp{}, errOpInvalid
+	}
+	if msg.Suggestion != "" {
+		if _, err := uuid.Parse(msg.Suggestion); err != nil {
+			return pendingOp{}, errOpInvalid
+		}
+	}
+
+	if s.trash != nil && s.trash.IsDeleted(ctx, entryID) {
+		return pendingOp{}, errOpEntryDeleted
+	}
+	if s.locks != nil && s.locks.IsLocked(ctx, entryID) {
+		return pendingOp{}, errOpEntryLocked
+	}
+
+	siteID := uuid.Nil
+	if msg.NodeID != nil {
+		siteID, _ = uuid.Parse(msg.NodeID.SiteID)
+	}
+	return pendingOp{msg: msg, entryID: entryID, requestID: requestID, siteID: siteID}, nil
+}
+
+// commit はサーバーが決めた認証状態でopを記録し、ACKをonAckに渡してからprojector適用・配信する。
+// 利用者はctxのactorとして渡す。
+func (s opSubmitter) commit(ctx context.Context, op pendingOp, authenticated bool, onAck func(AckMsg)) *opError {
+	// サーバーが認証状態を強制付与（クライアントの自称は上書き）
+	msg := op.msg
+	msg.Authenticated = &authenticated
+
+	// opのpayloadをそのまま永続化（非認証deleteもイベントストアに記録する）
+	payload, _ := json.Marshal(msg)
+
+	ack, err := s.syncService.HandleOp(ctx, op.entryID, op.siteID, op.requestID, payload)
+	if err != nil {
+		return errOpInternal
+	}
+
+	// ACKを先に送信
+	onAck(AckMsg{
+		Type:      MsgTypeAck,
+		RequestID: msg.RequestID,
+		EntryID:   msg.EntryID,
+		ServerSeq: ack.ServerSeq,
+	})
+
+	// 重複でなければprojector適用 → broadcast（拒否時はブロードキャストせず配信位置だけ進める）
+	if ack.ServerSeq > 0 {
+		applied := true
+		if s.projector != nil {
+			applied = s.projector.Apply(ctx, op.entryID, payload)
+		}
+
+		if !applied {
+			// 配信しないopのserver_seqを抜けと取り違えないよう、配信位置だけ進める
+			s.syncService.Skip(op.entryID, ack.ServerSeq)
+			return nil
+		}
+
+		s.syncService.Broadcast(op.entryID, application.SyncMessage{
+			EntryID: op.entryID,
+			Ops: []application.SyncOp{
+				{
+					RequestID: op.requestID,
+					ServerSeq: ack.ServerSeq,
+					Payload:   payload,
+				},
+			},
+			LatestServerSeq: ack.ServerSeq,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+const (
+	// defaultLongPollWait はwait未指定時に新しいopを待つ時間。
+	defaultLongPollWait = 30 * time.Second
+	// maxLongPollWait はプロキシのタイムアウトに掛からないよう待ち時間を抑える上限。
+	maxLongPollWait = 60 * time.Second
+)
+
+// Ops はWebSocketを使えない環境向けの、HTTPによるop送信とロングポーリングのハンドラー。
+// 受付・ACK・認証の扱いと、opのサイズとレートの制限はWSのopと同じ（レートはクライアントIPごと）。
+type Ops struct {
+	ops         opSubmitter
+	store       domain.EntryStore
+	syncService *application.SyncService
+	projector   *application.EntryProjector
+	auth        *Auth
+
+	maxBytes       int64
+	limiters       *clientOpLimiters
+	publicTextOnly bool
+}
+
+func NewOps(store domain.EntryStore, syncService *application.SyncService, projector *application.EntryProjector, locks *application.LockService, trash *application.TrashService, auth *Auth) *Ops {
+	h := &Ops{
+		ops:         opSubmitter{syncService: syncService, projector: projector, locks: locks, trash: trash},
+		store:       store,
+		syncService: syncService,
+		projector:   projector,
+		auth:        auth,
+	}
+	h.SetConfig(DefaultWSConfig)
+	return h
+}
+
+// SetConfig はop送信のサイズとレートの制限を設定する。WSと同じ設定を渡す。リクエストを受け付ける前に呼ぶ。
+func (h *Ops) SetConfig(cfg WSConfig) {
+	h.maxBytes = cfg.MaxMessageBytes
+	h.limiters = newClientOpLimiters(cfg.OpRate, cfg.OpBurst)
+}
+
+// SetPublicTextOnly は、読み取りスコープを持たない利用者にはロングポーリングでopの代わりに公開用テキストを返すよう設定する。
+// Events.SetPublicTextOnlyと同じく、認証が有効なときに使う。Auth.Middlewareの後に置くこと。
+func (h *Ops) SetPublicTextOnly(enabled bool) {
+	h.publicTextOnly = enabled
+}
+
+// Submit は POST /api/entries/{id}/ops ハンドラー。WSのopと同じ形式のJSONを受け付け、ACKを返す。
+// 編集スコープを持つ認証済みの利用者のopだけを認証済みとして記録する。
+func (h *Ops) Submit(w http.ResponseWriter, r *http.Request) {
+	if !h.limiters.allow(h.clientIP(r)) {
+		writeProblem(w, http.StatusTooManyRequests, "error:rate_limited", "Too Many Requests")
+		return
+	}
+	if h.maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxBytes)
+	}
+	var msg IncomingMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, http.StatusRequestEntityTooLarge, "about:blank", "Request Entity Too Large")
+			return
+		}
+		writeProblem(w, http.StatusBadRequest, "error:invalid_op", "Invalid Operation")
+		return
+	}
+	if msg.Type == "" {
+		msg.Type = MsgTypeOp
+	}
+	if msg.EntryID == "" {
+		msg.EntryID = r.PathValue("id")
+	}
+	if msg.Type != MsgTypeOp || msg.EntryID != r.PathValue("id") {
+		writeProblem(w, http.StatusBadRequest, "error:invalid_op", "Invalid Operation")
+		return
+	}
+
+	op, opErr := h.ops.prepare(r.Context(), msg)
+	if opErr != nil {
+		writeProblem(w, opErr.status, opErr.typ, opErr.title)
+		return
+	}
+	var ack AckMsg
+	if opErr := h.ops.commit(r.Context(), op, HasScope(r.Context(), domain.ScopeEdit), func(a AckMsg) { ack = a }); opErr != nil {
+		writeProblem(w, opErr.status, opErr.typ, opErr.title)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ack)
+}
+
+// Poll は GET /api/entries/{id}/ops?after=N&wait=30s ハンドラー。
+// afterより新しいopがあればすぐに返し、無ければwaitの間新しいopを待つ。待っても無ければopが空のsyncを返す。
+// SetPublicTextOnlyなら、匿名の利用者にはsyncの代わりに公開用テキスト（server_seqは最新のもの）を返す。
+// 公開用テキストは承認や提案の採否でも変わるので、その場合はafterより新しいイベントがあれば返す。
+func (h *Ops) Poll(w http.ResponseWriter, r *http.Request) {
+	entryID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+	var after int64
+	if s := r.URL.Query().Get("after"); s != "" {
+		if after, err = strconv.ParseInt(s, 10, 64); err != nil || after < 0 {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+	}
+	wait := defaultLongPollWait
+	if s := r.URL.Query().Get("wait"); s != "" {
+		if wait, err = time.ParseDuration(s); err != nil || wait < 0 {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+		wait = min(wait, maxLongPollWait)
+	}
+	textMode := h.publicTextOnly && !HasScope(r.Context(), domain.ScopeRead)
+	if textMode && h.projector == nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	if _, err := h.store.FindByID(r.Context(), entryID); err != nil {
+		if errors.Is(err, domain.ErrEntryNotFound) || errors.Is(err, domain.ErrEntryDeleted) {
+			writeProblem(w, http.StatusNotFound, "error:entry_not_found", "Entry Not Found")
+			return
+		}
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	// 差分の取得中に届いたopを取りこぼさないよう、先に購読してから差分を取る
+	sub := &pollSubscriber{ready: make(chan struct{}, 1)}
+	h.syncService.Subscribe(entryID, sub)
+	defer h.syncService.Unsubscribe(entryID, sub)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		diff, err := h.syncService.GetDiff(r.Context(), entryID, after)
+		if err != nil {
+			writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+			return
+		}
+		if len(diff.Ops) > 0 || textMode && diff.LatestServerSeq > after {
+			h.writePoll(w, diff, textMode)
+			return
+		}
+		select {
+		case <-sub.ready:
+		case <-timer.C:
+			h.writePoll(w, diff, textMode)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writePoll はロングポーリングの応答を書き込む。textModeならopの代わりに公開用テキストを返す。
+func (h *Ops) writePoll(w http.ResponseWriter, msg application.SyncMessage, textMode bool) {
+	if !textMode {
+		writeSync(w, msg)
+		return
+	}
+	text, _ := h.projector.PublicText(msg.EntryID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(EntryTextMsg{
+		Type:      MsgTypeText,
+		EntryID:   msg.EntryID.String(),
+		ServerSeq: msg.LatestServerSeq,
+		Text:      text,
+	})
+}
+
+// clientIP はレート制限に使うクライアントのIPを返す。WSの接続と同じく、信用するプロキシの転送ヘッダーだけを使う。
+func (h *Ops) clientIP(r *http.Request) string {
+	if h.auth != nil {
+		return h.auth.clientIP(r)
+	}
+	return remoteIP(r)
+}
+
+func writeSync(w http.ResponseWriter, msg application.SyncMessage) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(convertSyncMessage(msg))
+}
+
+// pollSubscriber はロングポーリング中のSubscriber実装。新しいopか公開用テキストの変化が届いたことだけを伝える。
+type pollSubscriber struct {
+	ready chan struct{}
+}
+
+func (s *pollSubscriber) Send(application.SyncMessage) {
+	s.wake()
+}
+
+func (s *pollSubscriber) Notify(n application.Notification) {
+	if n.Type == application.NotifyPublicText {
+		s.wake()
+	}
+}
+
+func (s *pollSubscriber) wake() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,259 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/auth"
+	"flourish/server/domain"
+	"flourish/server/handler"
+)
+
+type opsFixture struct {
+	srv     *httptest.Server
+	ops     *handler.Ops
+	locks   *application.LockService
+	entryID uuid.UUID
+	siteID  uuid.UUID
+}
+
+func setupOpsServer(t *testing.T) *opsFixture {
+	return setupOpsServerWithAuth(t, nil)
+}
+
+// setupOpsServerWithAuth はaで認証し、匿名の利用者には公開用テキストだけを返すサーバーを立てる。aがnilなら認証しない。
+func setupOpsServerWithAuth(t *testing.T, a *handler.Auth) *opsFixture {
+	t.Helper()
+	entryStore := memory.NewEntryStore()
+	syncService := application.NewSyncService(memory.NewEventStore())
+	projector := application.NewEntryProjector(entryStore, memory.NewRGAStateStore(), t.TempDir(), slog.Default())
+	locks := application.NewLockService(syncService, entryStore)
+	entry := domain.NewEntry()
+	if err := entryStore.Save(t.Context(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	ops := handler.NewOps(entryStore, syncService, projector, locks, nil, a)
+	var submit, poll http.Handler = http.HandlerFunc(ops.Submit), http.HandlerFunc(ops.Poll)
+	if a != nil {
+		ops.SetPublicTextOnly(true)
+		submit, poll = a.Middleware(submit), a.Middleware(poll)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/entries/{id}/ops", submit)
+	mux.Handle("GET /api/entries/{id}/ops", poll)
+	mux.Handle("GET /", handler.NewWS(syncService, projector, locks, nil, nil, slog.Default()))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &opsFixture{srv: srv, ops: ops, locks: locks, entryID: entry.ID, siteID: uuid.New()}
+}
+
+func (f *opsFixture) post(t *testing.T, body map[string]any) *http.Response {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	resp, err := http.Post(f.srv.URL+"/api/entries/"+f.entryID.String()+"/ops", "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func (f *opsFixture) insertOp(reqID string, ts int, value string) map[string]any {
+	return map[string]any{
+		"request_id":    reqID,
+		"op_type":       1,
+		"node_id":       map[string]any{"site_id": f.siteID.String(), "timestamp": ts},
+		"value":         value,
+		"authenticated": true,
+	}
+}
+
+// poll はロングポーリングする。別goroutineからも呼べるよう失敗はErrorで報告する。
+func (f *opsFixture) poll(t *testing.T, query string) handler.SyncMsg {
+	t.Helper()
+	resp, err := http.Get(f.srv.URL + "/api/entries/" + f.entryID.String() + "/ops?" + query)
+	if err != nil {
+		t.Error(err)
+		return handler.SyncMsg{}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("ステータスコード: got %d", resp.StatusCode)
+	}
+	var msg handler.SyncMsg
+	json.NewDecoder(resp.Body).Decode(&msg)
+	return msg
+}
+
+func TestOps_SubmitAcksAndIsIdempotent(t *testing.T) {
+	f := setupOpsServer(t)
+	reqID := uuid.New().String()
+
+	resp := f.post(t, f.insertOp(reqID, 1, "a"))
+	var ack handler.AckMsg
+	json.NewDecoder(resp.Body).Decode(&ack)
+	if resp.StatusCode != http.StatusOK || ack.Type != "ack" || ack.RequestID != reqID || ack.ServerSeq != 1 {
+		t.Fatalf("ACKを返すべき: status=%d %+v", resp.StatusCode, ack)
+	}
+
+	// 同じrequest_idの再送は重複として扱われ、再記録されない
+	resp = f.post(t, f.insertOp(reqID, 1, "a"))
+	json.NewDecoder(resp.Body).Decode(&ack)
+	if ack.ServerSeq != 0 {
+		t.Errorf("重複のACKはserver_seq=0であるべき: got %d", ack.ServerSeq)
+	}
+
+	sync := f.poll(t, "after=0")
+	if len(sync.Ops) != 1 || sync.Ops[0].Value != "a" {
+		t.Fatalf("記録されたopは1件であるべき: got %+v", sync.Ops)
+	}
+	// クライアントの自称は上書きされ、非認証として記録される
+	if auth := sync.Ops[0].Authenticated; auth == nil || *auth {
+		t.Errorf("非認証として記録されるべき: got %v", auth)
+	}
+}
+
+func TestOps_SubmitRejected(t *testing.T) {
+	f := setupOpsServer(t)
+
+	resp := f.post(t, map[string]any{"request_id": "not-a-uuid", "op_type": 1})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("不正なopは400であるべき: got %d", resp.StatusCode)
+	}
+
+	op := f.insertOp(uuid.New().String(), 1, "a")
+	op["entry_id"] = uuid.New().String()
+	if resp := f.post(t, op); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("パスと異なるentry_idは400であるべき: got %d", resp.StatusCode)
+	}
+
+	if err := f.locks.SetLocked(t.Context(), f.entryID, true); err != nil {
+		t.Fatal(err)
+	}
+	resp = f.post(t, f.insertOp(uuid.New().String(), 1, "a"))
+	var problem handler.ProblemDetail
+	json.NewDecoder(resp.Body).Decode(&problem)
+	if resp.StatusCode != http.StatusLocked || problem.Type != "error:entry_locked" {
+		t.Errorf("ロック中は423であるべき: got %d %+v", resp.StatusCode, problem)
+	}
+}
+
+func TestOps_PollWaitsForNewOp(t *testing.T) {
+	f := setupOpsServer(t)
+	f.post(t, f.insertOp(uuid.New().String(), 1, "a"))
+
+	done := make(chan handler.SyncMsg)
+	go func() { done <- f.poll(t, "after=1&wait=5s") }()
+
+	select {
+	case msg := <-done:
+		t.Fatalf("新しいopが無ければ待つべき: got %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// WSから送ったopでも待機が解ける
+	conn := dial(t, f.srv)
+	op := f.insertOp(uuid.New().String(), 2, "b")
+	op["type"] = "op"
+	op["entry_id"] = f.entryID.String()
+	op["after"] = map[string]any{"site_id": f.siteID.String(), "timestamp": 1}
+	writeJSON(t, conn, op)
+
+	select {
+	case msg := <-done:
+		if len(msg.Ops) != 1 || msg.Ops[0].Value != "b" || msg.LatestServerSeq != 2 {
+			t.Errorf("after以降のopを返すべき: got %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("opが届いたら応答すべき")
+	}
+}
+
+func TestOps_PollTimesOut(t *testing.T) {
+	f := setupOpsServer(t)
+	f.post(t, f.insertOp(uuid.New().String(), 1, "a"))
+
+	start := time.Now()
+	msg := f.poll(t, "after=1&wait=50ms")
+	if len(msg.Ops) != 0 || msg.LatestServerSeq != 1 {
+		t.Errorf("待っても無ければopが空のsyncを返すべき: got %+v", msg)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("waitの間は待つべき")
+	}
+}
+
+func TestOps_SubmitLimits(t *testing.T) {
+	f := setupOpsServer(t)
+	f.ops.SetConfig(handler.WSConfig{MaxMessageBytes: 512, OpRate: 0.001, OpBurst: 2})
+
+	op := f.insertOp(uuid.New().String(), 1, strings.Repeat("a", 1024))
+	if resp := f.post(t, op); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("上限を超えるopは413であるべき: got %d", resp.StatusCode)
+	}
+	if resp := f.post(t, f.insertOp(uuid.New().String(), 1, "a")); resp.StatusCode != http.StatusOK {
+		t.Fatalf("ステータスコード: got %d", resp.StatusCode)
+	}
+	resp := f.post(t, f.insertOp(uuid.New().String(), 2, "b"))
+	var problem handler.ProblemDetail
+	json.NewDecoder(resp.Body).Decode(&problem)
+	if resp.StatusCode != http.StatusTooManyRequests || problem.Type != "error:rate_limited" {
+		t.Errorf("レートを超えたopは429であるべき: got %d %+v", resp.StatusCode, problem)
+	}
+}
+
+func TestOps_PollNotFound(t *testing.T) {
+	f := setupOpsServer(t)
+	resp, err := http.Get(f.srv.URL + "/api/entries/" + uuid.New().String() + "/ops?wait=0s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("ステータスコード: got %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestOps_AnonymousPollGetsPublicTextOnly(t *testing.T) {
+	tokens := application.NewAPITokenService(memory.NewAPITokenStore())
+	a := handler.NewAuth(nil, auth.NewTicketStore(0), tokens, "")
+	readToken, _, _ := tokens.Issue(t.Context(), "reader", []domain.Scope{domain.ScopeRead})
+	f := setupOpsServerWithAuth(t, a)
+	f.post(t, f.insertOp(uuid.New().String(), 1, "a"))
+	url := f.srv.URL + "/api/entries/" + f.entryID.String() + "/ops?after=0"
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text handler.EntryTextMsg
+	json.NewDecoder(resp.Body).Decode(&text)
+	resp.Body.Close()
+	if text.Type != handler.MsgTypeText || text.Text != "a" || text.ServerSeq != 1 {
+		t.Errorf("匿名の利用者にはopの代わりに公開用テキストを返すべき: got %+v", text)
+	}
+
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer "+readToken)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sync handler.SyncMsg
+	json.NewDecoder(resp.Body).Decode(&sync)
+	resp.Body.Close()
+	if len(sync.Ops) != 1 {
+		t.Errorf("読み取りスコープがあればopを返すべき: got %+v", sync)
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+)
+
+// maxReplicaEventsLimit は1回の複製で返すイベントの上限。
+const maxReplicaEventsLimit = 5000
+
+// ReplicaEventMsg は複製用のイベント。リーダーのイベントログの1行をそのまま表す。
+type ReplicaEventMsg struct {
+	EntryID   string    `json:"entry_id"`
+	ServerSeq int64     `json:"server_seq"`
+	RequestID string    `json:"request_id"`
+	EventType string    `json:"event_type"`
+	SiteID    string    `json:"site_id"`
+	Payload   []byte    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReplicaEntriesResponse はイベントログにある全エントリ。
+type ReplicaEntriesResponse struct {
+	Entries []application.ReplicaEntry `json:"entries"`
+}
+
+// ReplicaPurgedResponse は完全削除されたエントリ。フォロワーはここにあるエントリだけを削除する。
+type ReplicaPurgedResponse struct {
+	Purged []uuid.UUID `json:"purged"`
+}
+
+// ReplicaEventsResponse はエントリのafter以降のイベント。
+type ReplicaEventsResponse struct {
+	Events []ReplicaEventMsg `json:"events"`
+}
+
+// ReplicationRoleResponse はサーバーの役割。
+type ReplicationRoleResponse struct {
+	Role string `json:"role"`
+}
+
+// サーバーの役割
+const (
+	RoleLeader   = "leader"
+	RoleFollower = "follower"
+)
+
+// Replication はイベントログの複製のHTTPハンドラー。
+// リーダーとしてイベントログを公開し、フォロワーなら昇格を受け付ける。
+type Replication struct {
+	source     application.ReplicationSource
+	replicator *application.Replicator
+}
+
+// NewReplication は新しいReplicationを生成する。replicatorはフォロワーのときだけ渡す。
+func NewReplication(source application.ReplicationSource, replicator *application.Replicator) *Replication {
+	return &Replication{source: source, replicator: replicator}
+}
+
+// Entries は GET /api/replication/entries ハンドラー。
+func (h *Replication) Entries(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.source.Entries(r.Context())
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, ReplicaEntriesResponse{Entries: entries})
+}
+
+// Purged は GET /api/replication/purged ハンドラー。
+func (h *Replication) Purged(w http.ResponseWriter, r *http.Request) {
+	ids, err := h.source.Purged(r.Context())
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, ReplicaPurgedResponse{Purged: ids})
+}
+
+// Events は GET /api/replication/entries/{id}/events?after=N&limit=M ハンドラー。
+func (h *Replication) Events(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+	var after int64
+	if v := r.URL.Query().Get("after"); v != "" {
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil || after < 0 {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+	}
+	limit := application.DefaultReplicationBatch
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+		limit = min(limit, maxReplicaEventsLimit)
+	}
+
+	events, err := h.source.Events(r.Context(), id, after, limit)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+	resp := ReplicaEventsResponse{Events: make([]ReplicaEventMsg, 0, len(events))}
+	for _, ev := range events {
+		resp.Events = append(resp.Events, ReplicaEventMsg{
+			EntryID:   ev.EntryID.String(),
+			ServerSeq: ev.ServerSeq,
+			RequestID: ev.RequestID.String(),
+			EventType: string(ev.EventType),
+			SiteID:    ev.SiteID.String(),
+			Payload:   ev.Payload,
+			CreatedAt: ev.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Promote は POST /api/admin/replication/promote ハンドラー。フォロワーをリーダーに昇格させる。
+func (h *Replication) Promote(w http.ResponseWriter, r *http.Request) {
+	if h.replicator == nil {
+		writeProblem(w, http.StatusConflict, "error:not_follower", "Not a Follower")
+		return
+	}
+	if err := h.replicator.Promote(); err != nil {
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, ReplicationRoleResponse{Role: RoleLeader})
+}
+
+// ReadOnly は昇格前のフォロワーへの書き込み（GET・HEAD以外）を503で拒否するミドルウェア。
+// exemptのパスは昇格前でも受け付ける。
+func ReadOnly(replicator *application.Replicator, exempt []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if replicator.ReadOnly() && r.Method != http.MethodGet && r.Method != http.MethodHead && !slices.Contains(exempt, r.URL.Path) {
+			writeReadOnly(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeReadOnly は読み取り専用のフォロワーへの書き込みを拒否する。
+func writeReadOnly(w http.ResponseWriter) {
+	writeProblem(w, http.StatusServiceUnavailable, errOpReadOnly.typ, errOpReadOnly.title)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// LeaderClient はリーダーの複製用エンドポイントからイベントログを読むapplication.ReplicationSource。
+// フォロワーの追従と、replication-checkでの比較に使う。
+type LeaderClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewLeaderClient はbaseURL（例: https://leader.example.com）のサーバーを読むクライアントを生成する。
+// tokenが空でなければadminスコープのAPIトークンとしてAuthorizationヘッダーで送る。
+func NewLeaderClient(baseURL, token string, client *http.Client) *LeaderClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &LeaderClient{baseURL: strings.TrimRight(baseURL, "/"), token: token, client: client}
+}
+
+func (c *LeaderClient) Entries(ctx context.Context) ([]application.ReplicaEntry, error) {
+	var resp ReplicaEntriesResponse
+	if err := c.get(ctx, "/api/replication/entries", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+func (c *LeaderClient) Purged(ctx context.Context) ([]uuid.UUID, error) {
+	var resp ReplicaPurgedResponse
+	if err := c.get(ctx, "/api/replication/purged", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Purged, nil
+}
+
+func (c *LeaderClient) Events(ctx context.Context, entryID uuid.UUID, afterSeq int64, limit int) ([]domain.Event, error) {
+	q := url.Values{"after": {strconv.FormatInt(afterSeq, 10)}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var resp ReplicaEventsResponse
+	if err := c.get(ctx, "/api/replication/entries/"+entryID.String()+"/events?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	events := make([]domain.Event, 0, len(resp.Events))
+	for _, msg := range resp.Events {
+		ev, err := replicaEvent(msg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+func (c *LeaderClient) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// replicaEvent はReplicaEventMsgをイベントに戻す。
+func replicaEvent(msg ReplicaEventMsg) (domain.Event, error) {
+	entryID, err := uuid.Parse(msg.EntryID)
+	if err != nil {
+		return domain.Event{}, err
+	}
+	requestID, err := uuid.Parse(msg.RequestID)
+	if err != nil {
+		return domain.Event{}, err
+	}
+	siteID, err := uuid.Parse(msg.SiteID)
+	if err != nil {
+		return domain.Event{}, err
+	}
+	if msg.ServerSeq < 1 {
+		return domain.Event{}, errors.New("invalid server_seq")
+	}
+	return domain.Event{
+		EntryID:   entryID,
+		ServerSeq: msg.ServerSeq,
+		RequestID: requestID,
+		EventType: domain.EventType(msg.EventType),
+		SiteID:    siteID,
+		Payload:   msg.Payload,
+		CreatedAt: msg.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/domain"
+	"flourish/server/handler"
+)
+
+func TestReplication_FollowerTailsLeaderOverHTTP(t *testing.T) {
+	ctx := t.Context()
+	leaderEvents := memory.NewEventStore()
+	leaderSync := application.NewSyncService(leaderEvents)
+	replication := handler.NewReplication(application.NewLogSource(leaderEvents), nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/replication/entries", replication.Entries)
+	mux.HandleFunc("GET /api/replication/purged", replication.Purged)
+	mux.HandleFunc("GET /api/replication/entries/{id}/events", replication.Events)
+	leader := httptest.NewServer(mux)
+	t.Cleanup(leader.Close)
+
+	entryID, siteID := uuid.New(), uuid.New()
+	payload, _ := json.Marshal(map[string]any{
+		"type": "op", "request_id": uuid.New().String(), "entry_id": entryID.String(), "op_type": 1,
+		"node_id": map[string]any{"site_id": siteID.String(), "timestamp": 1}, "value": "a", "authenticated": true,
+	})
+	if _, err := leaderSync.HandleOp(ctx, entryID, siteID, uuid.New(), payload); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leaderSync.AppendEvent(ctx, entryID, uuid.New(), domain.EventEntryLock, []byte(`{"locked":true}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	followerEvents, followerEntries := memory.NewEventStore(), memory.NewEntryStore()
+	followerSync := application.NewSyncService(followerEvents)
+	projector := application.NewEntryProjector(followerEntries, memory.NewRGAStateStore(), t.TempDir(), slog.Default())
+	client := handler.NewLeaderClient(leader.URL+"/", "", nil)
+	replicator := application.NewReplicator(client, followerEvents, followerEntries, followerSync, projector, slog.Default())
+	if n, err := replicator.Sync(ctx); err != nil || n != 2 {
+		t.Fatalf("sync: got %d, %v", n, err)
+	}
+
+	want, _ := leaderEvents.ListAfter(ctx, entryID, 0)
+	got, _ := followerEvents.ListAfter(ctx, entryID, 0)
+	if len(got) != len(want) {
+		t.Fatalf("events: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ServerSeq != want[i].ServerSeq || got[i].RequestID != want[i].RequestID || got[i].SiteID != want[i].SiteID ||
+			got[i].EventType != want[i].EventType || !bytes.Equal(got[i].Payload, want[i].Payload) || !got[i].CreatedAt.Equal(want[i].CreatedAt) {
+			t.Errorf("event %d should be replicated verbatim: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	entry, err := followerEntries.FindByID(ctx, entryID)
+	if err != nil || entry.Text != "a" || !entry.Locked {
+		t.Errorf("follower projection: got %+v, %v", entry, err)
+	}
+
+	found, err := application.CompareLogs(ctx, client, application.NewLogSource(followerEvents))
+	if err != nil || len(found) != 0 {
+		t.Errorf("replicated logs should match: got %v, %v", found, err)
+	}
+}
+
+func TestReplication_ReadOnlyUntilPromoted(t *testing.T) {
+	eventStore := memory.NewEventStore()
+	syncService := application.NewSyncService(eventStore)
+	projector := application.NewEntryProjector(memory.NewEntryStore(), memory.NewRGAStateStore(), t.TempDir(), slog.Default())
+	replicator := application.NewReplicator(application.NewLogSource(memory.NewEventStore()), eventStore, memory.NewEntryStore(), syncService, projector, slog.Default())
+
+	ops := handler.NewOps(memory.NewEntryStore(), syncService, nil, nil, nil, nil)
+	ws := handler.NewWS(syncService, nil, nil, nil, nil, slog.Default())
+	ws.SetReplicator(replicator)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/entries/{id}/ops", ops.Submit)
+	mux.HandleFunc("POST /api/admin/replication/promote", handler.NewReplication(application.NewLogSource(eventStore), replicator).Promote)
+	mux.Handle("GET /", ws)
+	srv := httptest.NewServer(handler.ReadOnly(replicator, []string{"/api/admin/replication/promote"}, mux))
+	t.Cleanup(srv.Close)
+
+	entryID := uuid.New()
+	submit := func() *http.Response {
+		data, _ := json.Marshal(map[string]any{
+			"request_id": uuid.New().String(), "entry_id": entryID.String(), "op_type": 1,
+			"node_id": map[string]any{"site_id": uuid.New().String(), "timestamp": 1}, "value": "a",
+		})
+		resp, err := http.Post(srv.URL+"/api/entries/"+entryID.String()+"/ops", "application/json", bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := submit(); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("follower should reject HTTP writes: got %d", resp.StatusCode)
+	}
+	conn := dial(t, srv)
+	writeJSON(t, conn, map[string]any{
+		"type": "op", "request_id": uuid.New().String(), "entry_id": entryID.String(), "op_type": 1,
+		"node_id": map[string]any{"site_id": uuid.New().String(), "timestamp": 1}, "value": "a",
+	})
+	if errMsg := readJSON[handler.ErrorMsg](t, conn); errMsg.ErrorType != "error:read_only" {
+		t.Errorf("follower should reject WS ops: got %+v", errMsg)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/admin/replication/promote", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var role handler.ReplicationRoleResponse
+	json.NewDecoder(resp.Body).Decode(&role)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || role.Role != handler.RoleLeader {
+		t.Fatalf("promote: got %d %+v", resp.StatusCode, role)
+	}
+	if resp := submit(); resp.StatusCode != http.StatusOK {
+		t.Errorf("promoted server should accept writes: got %d", resp.StatusCode)
+	}
+	conn.Close(websocket.StatusNormalClosure, "")
+}
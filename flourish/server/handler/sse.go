@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// sseKeepaliveInterval はプロキシに切られないようにコメント行を送る間隔。
+const sseKeepaliveInterval = 15 * time.Second
+
+// MsgTypeText はSSEのformat=textで送る描画済みテキストのイベント名。
+const MsgTypeText = "text"
+
+// EntryTextMsg は公開用テキストの更新通知。
+type EntryTextMsg struct {
+	Type      string `json:"type"`
+	EntryID   string `json:"entry_id"`
+	ServerSeq int64  `json:"server_seq"`
+	Text      string `json:"text"`
+}
+
+// Events はエントリの読み取り専用の同期ストリーム（Server-Sent Events）のハンドラー。
+type Events struct {
+	store       domain.EntryStore
+	syncService *application.SyncService
+	projector   *application.EntryProjector
+	log         *slog.Logger
+
+	publicTextOnly bool
+}
+
+func NewEvents(store domain.EntryStore, syncService *application.SyncService, projector *application.EntryProjector, log *slog.Logger) *Events {
+	return &Events{store: store, syncService: syncService, projector: projector, log: log}
+}
+
+// SetPublicTextOnly は、読み取りスコープを持たない利用者にはformatによらず公開用テキストだけを送るよう設定する。
+// opには承認前の挿入や未採用の提案も含まれるため、認証が有効なときに使う。Auth.Middlewareの後に置くこと。
+func (h *Events) SetPublicTextOnly(enabled bool) {
+	h.publicTextOnly = enabled
+}
+
+// ServeHTTP は GET /api/entries/{id}/events ハンドラー。
+// Last-Event-ID（またはlast_event_idクエリ）のserver_seq以降の差分を送ってから、新しいopを流す。
+// format=textなら差分の代わりに公開用テキスト全体を送る。SetPublicTextOnlyなら匿名の利用者は常にテキストになる。
+func (h *Events) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var afterSeq int64
+	if lastEventID != "" {
+		if afterSeq, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || afterSeq < 0 {
+			writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+			return
+		}
+	}
+	textMode := r.URL.Query().Get("format") == MsgTypeText || (h.publicTextOnly && !HasScope(r.Context(), domain.ScopeRead))
+	if textMode && h.projector == nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	if _, err := h.store.FindByID(r.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrEntryNotFound) || errors.Is(err, domain.ErrEntryDeleted) {
+			writeProblem(w, http.StatusNotFound, "error:entry_not_found", "Entry Not Found")
+			return
+		}
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		h.log.Error("sse flush unsupported", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	sub := &sseSubscriber{w: w, rc: rc, entryID: id, projector: h.projector, textMode: textMode, log: h.log}
+	sub.out = h.syncService.NewOutbox(sub.deliver, func(error) { cancel() })
+	defer sub.out.Wait()
+	defer sub.out.Close()
+
+	// 差分の取得中に届いたopを取りこぼさないよう、先に購読してから差分を送る
+	h.syncService.Subscribe(id, sub)
+	defer h.syncService.Unsubscribe(id, sub)
+	diff, err := h.syncService.GetDiff(ctx, id, afterSeq)
+	if err != nil {
+		h.log.Error("sse diff error", "entryID", id, "error", err)
+		return
+	}
+	sub.start(diff)
+
+	ticker := time.NewTicker(sseKeepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sub.out.Push(sseKeepalive{})
+		}
+	}
+}
+
+// sseKeepalive はコメント行のキープアライブ。
+type sseKeepalive struct{}
+
+// sseSubscriber はSSEストリームのSubscriber実装。
+// 差分を送るまでに届いたsyncは保留し、差分より新しいものだけを後から送る。
+type sseSubscriber struct {
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	out       *application.Outbox
+	entryID   uuid.UUID
+	projector *application.EntryProjector
+	textMode  bool
+	log       *slog.Logger
+
+	mu      sync.Mutex
+	started bool
+	held    []application.SyncMessage
+}
+
+// start は差分を送り、保留していたsyncのうち差分より新しいものを送る。
+func (s *sseSubscriber) start(diff application.SyncMessage) {
+	s.out.Push(diff)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, msg := range s.held {
+		if msg.LatestServerSeq > diff.LatestServerSeq {
+			s.out.Send(msg)
+		}
+	}
+	s.held = nil
+	s.started = true
+}
+
+func (s *sseSubscriber) Send(msg application.SyncMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		s.held = append(s.held, msg)
+		return
+	}
+	s.out.Send(msg)
+}
+
+func (s *sseSubscriber) Notify(n application.Notification) {
+	s.out.Notify(n)
+}
+
+// deliver はメッセージをSSEのイベントとして書き込む。syncとtextのidはserver_seq。
+func (s *sseSubscriber) deliver(ctx context.Context, msg any) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		s.rc.SetWriteDeadline(deadline)
+	}
+	var err error
+	switch m := msg.(type) {
+	case application.SyncMessage:
+		if s.textMode {
+			err = s.writeText(m.LatestServerSeq)
+		} else {
+			err = s.writeEvent(MsgTypeSync, m.LatestServerSeq, convertSyncMessage(m))
+		}
+	case application.Notification:
+		switch m.Type {
+		case application.NotifyEntryLock:
+			err = s.writeEvent(MsgTypeEntryLock, -1, EntryLockMsg{Type: MsgTypeEntryLock, EntryID: m.EntryID.String(), Locked: m.Locked})
+		case application.NotifyEntryDelete:
+			err = s.writeEvent(MsgTypeEntryDelete, -1, EntryDeleteMsg{Type: MsgTypeEntryDelete, EntryID: m.EntryID.String(), Deleted: m.Deleted})
+		case application.NotifyPublicText:
+			// 承認や提案の採否はopを伴わないので、textで読んでいる利用者にだけ送り直す
+			if !s.textMode {
+				return nil
+			}
+			err = s.writeText(m.ServerSeq)
+		default:
+			return nil
+		}
+	case sseKeepalive:
+		_, err = fmt.Fprint(s.w, ": keepalive\n\n")
+	}
+	if err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// writeText は現在の公開用テキストをtextイベントとして書き込む。
+func (s *sseSubscriber) writeText(seq int64) error {
+	text, _ := s.projector.PublicText(s.entryID)
+	return s.writeEvent(MsgTypeText, seq, EntryTextMsg{
+		Type:      MsgTypeText,
+		EntryID:   s.entryID.String(),
+		ServerSeq: seq,
+		Text:      text,
+	})
+}
+
+// writeEvent は1イベントを書き込む。idが負ならidを付けない。
+func (s *sseSubscriber) writeEvent(event string, id int64, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.log.Error("sse message marshal error", "error", err)
+		return nil
+	}
+	if id >= 0 {
+		if _, err := fmt.Fprintf(s.w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
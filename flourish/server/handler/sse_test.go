@@ -0,0 +1,248 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/adapter/memory"
+	"flourish/server/application"
+	"flourish/server/auth"
+	"flourish/server/domain"
+	"flourish/server/handler"
+)
+
+type sseFixture struct {
+	srv       *httptest.Server
+	sync      *application.SyncService
+	projector *application.EntryProjector
+	entryID   uuid.UUID
+	siteID    uuid.UUID
+	ts        uint64
+}
+
+func setupSSEServer(t *testing.T) *sseFixture {
+	t.Helper()
+	return setupSSEServerWithAuth(t, nil)
+}
+
+// setupSSEServerWithAuth はauthがnilでなければ、匿名の読者に公開用テキストだけを送るストリームを立てる。
+func setupSSEServerWithAuth(t *testing.T, a *handler.Auth) *sseFixture {
+	t.Helper()
+	entryStore := memory.NewEntryStore()
+	syncService := application.NewSyncService(memory.NewEventStore())
+	projector := application.NewEntryProjector(entryStore, memory.NewRGAStateStore(), t.TempDir(), slog.Default())
+	entryID := uuid.New()
+	now := time.Now().UTC()
+	if err := entryStore.Save(context.Background(), domain.Entry{ID: entryID, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	events := handler.NewEvents(entryStore, syncService, projector, slog.Default())
+	var h http.Handler = events
+	if a != nil {
+		events.SetPublicTextOnly(true)
+		h = a.Middleware(events)
+	}
+	mux.Handle("GET /api/entries/{id}/events", h)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &sseFixture{srv: srv, sync: syncService, projector: projector, entryID: entryID, siteID: uuid.New()}
+}
+
+// insert はWSと同じ経路で認証済みのopを記録・適用・配信する。
+func (f *sseFixture) insert(t *testing.T, value string) int64 {
+	t.Helper()
+	return f.insertAs(t, value, true)
+}
+
+// insertAs はauthenticatedを認証状態としてopを記録・適用・配信する。
+func (f *sseFixture) insertAs(t *testing.T, value string, authenticated bool) int64 {
+	t.Helper()
+	f.ts++
+	reqID := uuid.New()
+	msg := map[string]any{
+		"type":          "op",
+		"request_id":    reqID.String(),
+		"op_type":       1,
+		"node_id":       map[string]any{"site_id": f.siteID.String(), "timestamp": f.ts},
+		"value":         value,
+		"authenticated": authenticated,
+	}
+	if f.ts > 1 {
+		msg["after"] = map[string]any{"site_id": f.siteID.String(), "timestamp": f.ts - 1}
+	}
+	payload, _ := json.Marshal(msg)
+	ack, err := f.sync.HandleOp(context.Background(), f.entryID, f.siteID, reqID, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.projector.Apply(context.Background(), f.entryID, payload)
+	f.sync.Broadcast(f.entryID, application.SyncMessage{
+		EntryID:         f.entryID,
+		Ops:             []application.SyncOp{{RequestID: reqID, ServerSeq: ack.ServerSeq, Payload: payload}},
+		LatestServerSeq: ack.ServerSeq,
+	})
+	return ack.ServerSeq
+}
+
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// openSSE はストリームを開き、イベントを1件ずつ返す関数を返す。
+func openSSE(t *testing.T, url, lastEventID string) (*http.Response, func() sseEvent) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	return streamSSE(t, req)
+}
+
+// streamSSE はreqでストリームを開く。
+func streamSSE(t *testing.T, req *http.Request) (*http.Response, func() sseEvent) {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	scanner := bufio.NewScanner(resp.Body)
+	return resp, func() sseEvent {
+		t.Helper()
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "" && ev.Event != "":
+				return ev
+			case strings.HasPrefix(line, "id: "):
+				ev.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.Data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		t.Fatalf("ストリームが終了した: %v", scanner.Err())
+		return ev
+	}
+}
+
+func TestEvents_ReplaysFromLastEventIDThenStreams(t *testing.T) {
+	f := setupSSEServer(t)
+	f.insert(t, "a")
+	f.insert(t, "b")
+
+	resp, next := openSSE(t, f.srv.URL+"/api/entries/"+f.entryID.String()+"/events", "1")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type: got %q", ct)
+	}
+
+	ev := next()
+	var diff handler.SyncMsg
+	json.Unmarshal([]byte(ev.Data), &diff)
+	if ev.Event != "sync" || ev.ID != "2" || len(diff.Ops) != 1 || diff.Ops[0].Value != "b" {
+		t.Fatalf("Last-Event-ID以降の差分であるべき: got %+v", ev)
+	}
+
+	seq := f.insert(t, "c")
+	ev = next()
+	var live handler.SyncMsg
+	json.Unmarshal([]byte(ev.Data), &live)
+	if ev.Event != "sync" || live.LatestServerSeq != seq || live.Ops[0].Value != "c" {
+		t.Errorf("新しいopが流れるべき: got %+v", ev)
+	}
+}
+
+func TestEvents_TextFormat(t *testing.T) {
+	f := setupSSEServer(t)
+	f.insert(t, "H")
+
+	_, next := openSSE(t, f.srv.URL+"/api/entries/"+f.entryID.String()+"/events?format=text", "")
+	ev := next()
+	var msg handler.EntryTextMsg
+	json.Unmarshal([]byte(ev.Data), &msg)
+	if ev.Event != "text" || msg.Text != "H" || ev.ID != "1" {
+		t.Fatalf("初回は公開用テキスト全体であるべき: got %+v", ev)
+	}
+
+	f.insert(t, "i")
+	ev = next()
+	json.Unmarshal([]byte(ev.Data), &msg)
+	if msg.Text != "Hi" || msg.ServerSeq != 2 {
+		t.Errorf("更新後のテキストが流れるべき: got %+v", msg)
+	}
+}
+
+func TestEvents_TextFollowsModerationApproval(t *testing.T) {
+	f := setupSSEServer(t)
+	f.projector.SetModeration(true)
+	f.insert(t, "H")
+	f.insertAs(t, "i", false)
+
+	_, next := openSSE(t, f.srv.URL+"/api/entries/"+f.entryID.String()+"/events?format=text", "")
+	ev := next()
+	var msg handler.EntryTextMsg
+	json.Unmarshal([]byte(ev.Data), &msg)
+	if msg.Text != "H" {
+		t.Fatalf("承認前の挿入は公開用テキストに含まれないべき: got %+v", msg)
+	}
+
+	// 承認は新しいopを伴わないが、公開用テキストは変わる
+	moderation := application.NewModerationService(f.sync, f.projector)
+	if err := moderation.Approve(context.Background(), f.entryID, nil); err != nil {
+		t.Fatal(err)
+	}
+	ev = next()
+	json.Unmarshal([]byte(ev.Data), &msg)
+	if ev.Event != handler.MsgTypeText || msg.Text != "Hi" || ev.ID != "3" {
+		t.Errorf("承認後のテキストが流れるべき: got %+v", ev)
+	}
+}
+
+func TestEvents_AnonymousReadersGetPublicTextOnly(t *testing.T) {
+	tokens := application.NewAPITokenService(memory.NewAPITokenStore())
+	a := handler.NewAuth(auth.NewStaticVerifier("", "", nil), auth.NewTicketStore(0), tokens, "")
+	readToken, _, _ := tokens.Issue(context.Background(), "reader", []domain.Scope{domain.ScopeRead})
+	f := setupSSEServerWithAuth(t, a)
+	f.insert(t, "H")
+	url := f.srv.URL + "/api/entries/" + f.entryID.String() + "/events"
+
+	_, next := openSSE(t, url, "")
+	if ev := next(); ev.Event != handler.MsgTypeText {
+		t.Errorf("匿名の読者にはopを流さず公開用テキストを送るべき: got %+v", ev)
+	}
+
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer "+readToken)
+	_, next = streamSSE(t, req)
+	if ev := next(); ev.Event != "sync" {
+		t.Errorf("読み取りスコープがあればopを流すべき: got %+v", ev)
+	}
+}
+
+func TestEvents_NotFound(t *testing.T) {
+	f := setupSSEServer(t)
+	resp, err := http.Get(f.srv.URL + "/api/entries/" + uuid.New().String() + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("ステータスコード: got %d, want 404", resp.StatusCode)
+	}
+}
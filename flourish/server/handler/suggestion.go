@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// SuggestionResponse は提案セット。
+type SuggestionResponse struct {
+	ID            string      `json:"id"`
+	Status        string      `json:"status"`
+	Inserted      string      `json:"inserted"`
+	Deleted       string      `json:"deleted"`
+	Offset        int         `json:"offset"`
+	InsertNodeIDs []NodeIDMsg `json:"insert_node_ids"`
+	DeleteNodeIDs []NodeIDMsg `json:"delete_node_ids"`
+}
+
+// SuggestionListResponse は提案セット一覧レスポンス。
+type SuggestionListResponse struct {
+	Suggestions []SuggestionResponse `json:"suggestions"`
+}
+
+// Suggestion は提案モードのHTTPハンドラー。
+type Suggestion struct {
+	service *application.SuggestionService
+}
+
+func NewSuggestion(service *application.SuggestionService) *Suggestion {
+	return &Suggestion{service: service}
+}
+
+// List は GET /api/admin/entries/{id}/suggestions ハンドラー。
+func (h *Suggestion) List(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+	writeJSON(w, http.StatusOK, toSuggestionListResponse(h.service.List(id)))
+}
+
+// Accept は POST /api/admin/entries/{id}/suggestions/{sid}/accept ハンドラー。
+func (h *Suggestion) Accept(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Accept)
+}
+
+// Reject は POST /api/admin/entries/{id}/suggestions/{sid}/reject ハンドラー。
+func (h *Suggestion) Reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, h.service.Reject)
+}
+
+func (h *Suggestion) decide(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, entryID, suggestionID uuid.UUID) error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+	sid, err := uuid.Parse(r.PathValue("sid"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	if err := fn(r.Context(), id, sid); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrEntryLocked):
+			writeEntryLocked(w)
+		case errors.Is(err, domain.ErrSuggestionNotFound):
+			writeProblem(w, http.StatusNotFound, "error:suggestion_not_found", "Suggestion Not Found")
+		case errors.Is(err, domain.ErrSuggestionClosed):
+			writeProblem(w, http.StatusConflict, "error:suggestion_closed", "Suggestion Already Closed")
+		default:
+			writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toSuggestionListResponse(h.service.List(id)))
+}
+
+func toSuggestionListResponse(views []application.SuggestionView) SuggestionListResponse {
+	suggestions := make([]SuggestionResponse, len(views))
+	for i, v := range views {
+		suggestions[i] = SuggestionResponse{
+			ID:            v.ID.String(),
+			Status:        string(v.Status),
+			Inserted:      v.Inserted,
+			Deleted:       v.Deleted,
+			Offset:        v.Offset,
+			InsertNodeIDs: toNodeIDMsgs(v.InsertNodeIDs),
+			DeleteNodeIDs: toNodeIDMsgs(v.DeleteNodeIDs),
+		}
+	}
+	return SuggestionListResponse{Suggestions: suggestions}
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// TagCountResponse はタグ別件数。
+type TagCountResponse struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TagListResponse はタグ一覧レスポンス。
+type TagListResponse struct {
+	Tags []TagCountResponse `json:"tags"`
+}
+
+// TagUpdateRequest はタグ編集リクエスト。
+type TagUpdateRequest struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// TagUpdateResponse はタグ編集後のタグ一覧。
+type TagUpdateResponse struct {
+	ID   string   `json:"id"`
+	Tags []string `json:"tags"`
+}
+
+// Tag はタグのHTTPハンドラー。
+type Tag struct {
+	store      domain.EntryStore
+	tagService *application.TagService
+}
+
+func NewTag(store domain.EntryStore, tagService *application.TagService) *Tag {
+	return &Tag{store: store, tagService: tagService}
+}
+
+// List は GET /api/tags ハンドラー。
+func (h *Tag) List(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.tagService.Counts(r.Context())
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	tags := make([]TagCountResponse, len(counts))
+	for i, c := range counts {
+		tags[i] = TagCountResponse{Tag: c.Tag, Count: c.Count}
+	}
+	writeJSON(w, http.StatusOK, TagListResponse{Tags: tags})
+}
+
+// Update は PUT /api/entries/{id}/tags ハンドラー。フロントマターのタグは削除できず409を返す。
+func (h *Tag) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	var req TagUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	tags, err := h.tagService.Update(r.Context(), id, req.Add, req.Remove)
+	if err != nil {
+		if errors.Is(err, domain.ErrEntryLocked) {
+			writeEntryLocked(w)
+			return
+		}
+		if errors.Is(err, domain.ErrEntryNotFound) || errors.Is(err, domain.ErrEntryDeleted) {
+			writeProblem(w, http.StatusNotFound, "error:entry_not_found", "Entry Not Found")
+			return
+		}
+		if errors.Is(err, domain.ErrTagInFrontMatter) {
+			writeProblem(w, http.StatusConflict, "error:tag_in_front_matter", "Tag In Front Matter")
+			return
+		}
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+	writeJSON(w, http.StatusOK, TagUpdateResponse{ID: id.String(), Tags: tags})
+}
+
+// atomFeed はAtom 1.0フィード。
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Link      atomLink `xml:"link"`
+	Published string   `xml:"published"`
+	Updated   string   `xml:"updated"`
+	Summary   string   `xml:"summary"`
+}
+
+// Feed は GET /api/tags/{tag}/feed ハンドラー。タグ付きエントリのAtomフィードを返す。
+func (h *Tag) Feed(w http.ResponseWriter, r *http.Request) {
+	tag := domain.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	items, err := h.store.List(r.Context())
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	base := baseURL(r)
+	feed := atomFeed{
+		ID:    base + "/api/tags/" + tag + "/feed",
+		Title: "#" + tag,
+		Link:  atomLink{Href: base + "/api/tags/" + tag + "/feed", Rel: "self"},
+	}
+	var tagged []domain.EntryListItem
+	for _, item := range items {
+		if item.HasTag(tag) {
+			tagged = append(tagged, item)
+		}
+	}
+	// 新しく更新されたものから並べる
+	slices.SortStableFunc(tagged, func(a, b domain.EntryListItem) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	for _, item := range tagged {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        "urn:uuid:" + item.ID.String(),
+			Title:     item.Title,
+			Link:      atomLink{Href: base + "/entries/" + item.ID.String()},
+			Published: item.CreatedAt.Format(time.RFC3339),
+			Updated:   item.UpdatedAt.Format(time.RFC3339),
+			Summary:   item.Content,
+		})
+	}
+	// updatedはAtomの必須要素なので、エントリが無ければ現在時刻にする
+	updated := time.Now()
+	if len(tagged) > 0 {
+		updated = tagged[0].UpdatedAt
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(feed)
+}
+
+// baseURL はリクエストからスキーム付きのオリジンを組み立てる。
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
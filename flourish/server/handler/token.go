@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// CreateTokenRequest はAPIトークン発行リクエスト。
+type CreateTokenRequest struct {
+	Name   string         `json:"name"`
+	Scopes []domain.Scope `json:"scopes"`
+}
+
+// TokenResponse はAPIトークンのレスポンス。Tokenは発行時のみ含まれる。
+type TokenResponse struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Scopes    []domain.Scope `json:"scopes"`
+	Token     string         `json:"token,omitempty"`
+	CreatedAt string         `json:"created_at"`
+}
+
+// TokenListResponse はAPIトークン一覧レスポンス。
+type TokenListResponse struct {
+	Tokens []TokenResponse `json:"tokens"`
+}
+
+// Token はAPIトークン管理のHTTPハンドラー。
+type Token struct {
+	service *application.APITokenService
+}
+
+func NewToken(service *application.APITokenService) *Token {
+	return &Token{service: service}
+}
+
+// Create は POST /api/admin/tokens ハンドラー。
+func (h *Token) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	secret, token, err := h.service.Issue(r.Context(), req.Name, req.Scopes)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidScope) {
+			writeProblem(w, http.StatusBadRequest, "error:invalid_scope", "Invalid Scope")
+			return
+		}
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	resp := toTokenResponse(token)
+	resp.Token = secret
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// List は GET /api/admin/tokens ハンドラー。
+func (h *Token) List(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.service.List(r.Context())
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	resp := TokenListResponse{Tokens: make([]TokenResponse, 0, len(tokens))}
+	for _, token := range tokens {
+		resp.Tokens = append(resp.Tokens, toTokenResponse(token))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Revoke は POST /api/admin/tokens/{id}/revoke ハンドラー。
+func (h *Token) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	if err := h.service.Revoke(r.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrTokenNotFound) {
+			writeProblem(w, http.StatusNotFound, "error:token_not_found", "Token Not Found")
+			return
+		}
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toTokenResponse(token domain.APIToken) TokenResponse {
+	return TokenResponse{
+		ID:        token.ID.String(),
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt.Format(time.RFC3339),
+	}
+}
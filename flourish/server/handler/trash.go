@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain"
+)
+
+// TrashItemResponse はゴミ箱のエントリ。PurgeAtは自動で完全削除される時刻（保持期間が無ければ空）。
+type TrashItemResponse struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	DeletedAt string `json:"deleted_at"`
+	PurgeAt   string `json:"purge_at,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// TrashListResponse はゴミ箱一覧レスポンス。
+type TrashListResponse struct {
+	Entries []TrashItemResponse `json:"entries"`
+}
+
+// Trash はエントリの削除・ゴミ箱のHTTPハンドラー。
+type Trash struct {
+	service *application.TrashService
+}
+
+func NewTrash(service *application.TrashService) *Trash {
+	return &Trash{service: service}
+}
+
+// List は GET /api/admin/trash ハンドラー。
+func (h *Trash) List(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.service.List(r.Context())
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		return
+	}
+
+	items := make([]TrashItemResponse, len(entries))
+	for i, entry := range entries {
+		items[i] = TrashItemResponse{
+			ID:        entry.ID.String(),
+			Title:     entry.Title,
+			DeletedAt: entry.DeletedAt.Format(time.RFC3339),
+			CreatedAt: entry.CreatedAt.Format(time.RFC3339),
+			UpdatedAt: entry.UpdatedAt.Format(time.RFC3339),
+		}
+		if purgeAt := h.service.PurgeAt(entry); !purgeAt.IsZero() {
+			items[i].PurgeAt = purgeAt.Format(time.RFC3339)
+		}
+	}
+	writeJSON(w, http.StatusOK, TrashListResponse{Entries: items})
+}
+
+// Delete は POST /api/admin/entries/{id}/delete ハンドラー。エントリをゴミ箱に入れる。
+func (h *Trash) Delete(w http.ResponseWriter, r *http.Request) {
+	h.do(w, r, h.service.Delete)
+}
+
+// Restore は POST /api/admin/entries/{id}/restore ハンドラー。
+func (h *Trash) Restore(w http.ResponseWriter, r *http.Request) {
+	h.do(w, r, h.service.Restore)
+}
+
+// Purge は POST /api/admin/entries/{id}/purge ハンドラー。ゴミ箱のエントリを完全削除する。
+func (h *Trash) Purge(w http.ResponseWriter, r *http.Request) {
+	h.do(w, r, h.service.Purge)
+}
+
+func (h *Trash) do(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, id uuid.UUID) error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	if err := fn(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrEntryNotFound), errors.Is(err, domain.ErrEntryDeleted):
+			writeProblem(w, http.StatusNotFound, "error:entry_not_found", "Entry Not Found")
+		case errors.Is(err, domain.ErrEntryNotDeleted):
+			writeProblem(w, http.StatusConflict, "error:entry_not_deleted", "Entry Not In Trash")
+		default:
+			writeProblem(w, http.StatusInternalServerError, "about:blank", "Internal Server Error")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
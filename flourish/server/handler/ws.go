@@ -3,10 +3,14 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/coder/websocket"
 	"github.com/google/uuid"
@@ -15,6 +19,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"flourish/server/application"
+	"flourish/server/domain/crdt"
 )
 
 var wsTracer = otel.Tracer("flourish/ws")
@@ -23,81 +28,191 @@ var wsTracer = otel.Tracer("flourish/ws")
 type WS struct {
 	syncService *application.SyncService
 	projector   *application.EntryProjector
+	locks       *application.LockService
+	trash       *application.TrashService
 	auth        *Auth
+	replicator  *application.Replicator
 	log         *slog.Logger
+
+	mu       sync.Mutex
+	cfg      WSConfig
+	sessions map[uuid.UUID]*wsSession
 }
 
-func NewWS(syncService *application.SyncService, projector *application.EntryProjector, auth *Auth, log *slog.Logger) *WS {
+func NewWS(syncService *application.SyncService, projector *application.EntryProjector, locks *application.LockService, trash *application.TrashService, auth *Auth, log *slog.Logger) *WS {
 	return &WS{
 		syncService: syncService,
 		projector:   projector,
+		locks:       locks,
+		trash:       trash,
 		auth:        auth,
 		log:         log,
+		cfg:         DefaultWSConfig,
+		sessions:    make(map[uuid.UUID]*wsSession),
 	}
 }
 
+// SetReplicator はフォロワーのとき、昇格するまでopを拒否するよう設定する。接続を受け付ける前に呼ぶ。
+func (h *WS) SetReplicator(replicator *application.Replicator) {
+	h.replicator = replicator
+}
+
 // wsSubscriber はWebSocket接続のSubscriber実装。
+// 送信はすべて接続ごとの送信キューを通し、書き込みgoroutineが順に書き込む。
 type wsSubscriber struct {
-	conn *websocket.Conn
-	mu   sync.Mutex
-	log  *slog.Logger
+	conn  *websocket.Conn
+	out   *application.Outbox
+	codec *BinaryCodec // バイナリ形式の接続なら送信用の辞書。JSONならnil
+	log   *slog.Logger
+
+	// 購読中のエントリと一覧の購読。読み込みループだけが触る
+	entries []uuid.UUID
+	list    bool
+	maxSubs int
+}
+
+func (h *WS) newSubscriber(conn *websocket.Conn) *wsSubscriber {
+	sub := &wsSubscriber{conn: conn, log: h.log}
+	if conn.Subprotocol() == WSSubprotocolBinary {
+		sub.codec = NewBinaryCodec()
+	}
+	sub.out = h.syncService.NewOutbox(sub.deliver, sub.closeOnFailure)
+	return sub
 }
 
 func (s *wsSubscriber) Send(msg application.SyncMessage) {
-	syncMsg := convertSyncMessage(msg)
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	data, err := json.Marshal(syncMsg)
+	s.out.Send(msg)
+}
+
+func (s *wsSubscriber) Notify(n application.Notification) {
+	s.out.Notify(n)
+}
+
+// write は接続自身への応答を送信キューに積む。
+func (s *wsSubscriber) write(v any) {
+	s.out.Push(v)
+}
+
+// deliver は送信キューのメッセージをJSONで書き込む。バイナリ形式の接続ではACKとsyncをバイナリで書き込む。
+func (s *wsSubscriber) deliver(ctx context.Context, msg any) error {
+	v := msg
+	switch m := msg.(type) {
+	case AckMsg:
+		if s.codec != nil {
+			return s.conn.Write(ctx, websocket.MessageBinary, s.codec.EncodeAck(m))
+		}
+	case application.SyncMessage:
+		if s.codec != nil {
+			return s.conn.Write(ctx, websocket.MessageBinary, s.codec.EncodeSync(m))
+		}
+		v = convertSyncMessage(m)
+	case wsClose:
+		return s.conn.Close(m.code, m.reason)
+	case application.Notification:
+		switch m.Type {
+		case application.NotifyEntryLock:
+			v = EntryLockMsg{Type: MsgTypeEntryLock, EntryID: m.EntryID.String(), Locked: m.Locked}
+		case application.NotifyEntryCreate:
+			v = EntryCreateMsg{Type: MsgTypeEntryCreate, EntryID: m.EntryID.String()}
+		case application.NotifyEntryDelete:
+			v = EntryDeleteMsg{Type: MsgTypeEntryDelete, EntryID: m.EntryID.String(), Deleted: m.Deleted}
+		case application.NotifyEntryUpdate:
+			v = EntryUpdateMsg{Type: MsgTypeEntryUpdate, EntryID: m.EntryID.String(), LatestServerSeq: m.ServerSeq}
+		case application.NotifySuggestion:
+			v = SuggestionMsg{Type: MsgTypeSuggestion, EntryID: m.EntryID.String(), Suggestion: m.Suggestion.String(), Status: string(m.Status)}
+		default:
+			return nil
+		}
+	}
+	data, err := json.Marshal(v)
 	if err != nil {
-		s.log.Error("sync message marshal error", "error", err)
-		return
+		s.log.Error("ws message marshal error", "error", err)
+		return nil
 	}
-	if err := s.conn.Write(context.Background(), websocket.MessageText, data); err != nil {
-		s.log.Debug("sync message write error", "error", err)
+	return s.conn.Write(ctx, websocket.MessageText, data)
+}
+
+// closeOnFailure は送信キューの溢れ・書き込み失敗で接続を閉じる。溢れの場合は再同期を促す。
+func (s *wsSubscriber) closeOnFailure(err error) {
+	if errors.Is(err, application.ErrSubscriberOverflow) {
+		s.log.Info("websocket send queue overflow, closing")
+		s.conn.Close(WSStatusResync, "resync")
+		return
 	}
+	s.log.Debug("ws message write error", "error", err)
+	s.conn.CloseNow()
 }
 
 func (h *WS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cfg := h.config()
 	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-		InsecureSkipVerify: true,
+		OriginPatterns: cfg.AllowedOrigins,
+		Subprotocols:   []string{WSSubprotocolBinary, WSSubprotocolJSON},
 	})
 	if err != nil {
-		h.log.Error("websocket accept error", "error", err)
+		h.log.Warn("websocket accept error", "origin", r.Header.Get("Origin"), "error", err)
 		return
 	}
 	defer conn.CloseNow()
+	if cfg.MaxMessageBytes > 0 {
+		conn.SetReadLimit(cfg.MaxMessageBytes)
+	}
 
-	h.log.Info("websocket connected", "remoteAddr", r.RemoteAddr)
+	h.log.Info("websocket connected", "remoteAddr", r.RemoteAddr, "subprotocol", conn.Subprotocol())
 
-	sub := &wsSubscriber{conn: conn, log: h.log}
-	var subscribedEntries []uuid.UUID
+	sub := h.newSubscriber(conn)
+	sub.maxSubs = cfg.MaxSubscriptions
+	defer sub.out.Close()
 	defer func() {
-		for _, entryID := range subscribedEntries {
+		for _, entryID := range sub.entries {
 			h.syncService.Unsubscribe(entryID, sub)
 		}
+		if sub.list {
+			h.syncService.UnsubscribeList(sub)
+		}
 		h.log.Info("websocket disconnected", "remoteAddr", r.RemoteAddr)
 	}()
 
-	// チケット検証による認証
-	authenticated := false
+	// チケットまたはAPIトークン（Authorizationヘッダー）による認証。
+	// 接続後もauthメッセージで認証情報を更新でき、期限が来ると降格する。
+	sess := &wsSession{
+		id:          uuid.New(),
+		conn:        conn,
+		sub:         sub,
+		clientIP:    remoteIP(r),
+		connectedAt: time.Now(),
+	}
 	if h.auth != nil {
-		ticket := r.URL.Query().Get("ticket")
-		if ticket != "" {
-			authenticated = h.auth.RedeemTicket(ticket)
-		}
+		sess.clientIP = h.auth.clientIP(r)
+	}
+	h.register(sess)
+	defer h.unregister(sess)
+	defer sess.stop()
+
+	// 応答の無い接続はpingで検知して切断する
+	var lastSeen atomic.Int64
+	lastSeen.Store(time.Now().UnixNano())
+	keepaliveCtx, stopKeepalive := context.WithCancel(r.Context())
+	defer stopKeepalive()
+	go h.keepalive(keepaliveCtx, conn, cfg, &lastSeen)
+	limiter := newOpLimiter(cfg.OpRate, cfg.OpBurst)
+
+	// 最初のフレームで対応バージョンと能力を告知する。helloを返さないクライアントはV1として扱う
+	sub.write(HelloMsg{Type: MsgTypeHello, ProtocolVersions: wsProtocolVersions, Capabilities: sub.capabilities()})
+
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !h.authenticate(r.Context(), sess, r.URL.Query().Get("ticket"), token) {
+		h.sendAuthStatus(sess)
 	}
 
-	// auth_statusメッセージを送信
-	authStatusMsg, _ := json.Marshal(map[string]any{
-		"type":          "auth_status",
-		"authenticated": authenticated,
-	})
-	sub.mu.Lock()
-	conn.Write(r.Context(), websocket.MessageText, authStatusMsg)
-	sub.mu.Unlock()
+	// 受信用の辞書は送信用とは別に持つ
+	var decoder *BinaryCodec
+	if sub.codec != nil {
+		decoder = NewBinaryCodec()
+	}
 
 	for {
-		_, data, err := conn.Read(r.Context())
+		typ, data, err := conn.Read(r.Context())
 		if err != nil {
 			if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
 				return
@@ -105,25 +220,63 @@ func (h *WS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			h.log.Debug("websocket read error", "error", err)
 			return
 		}
+		lastSeen.Store(time.Now().UnixNano())
 
 		var msg IncomingMessage
-		if err := json.Unmarshal(data, &msg); err != nil {
-			h.writeError(conn, nil, "error:invalid_op", "Invalid Operation")
+		if typ == websocket.MessageBinary {
+			if decoder != nil {
+				msg, err = decoder.DecodeOp(data)
+			} else {
+				err = ErrInvalidBinary
+			}
+		} else {
+			err = json.Unmarshal(data, &msg)
+		}
+		if err != nil {
+			h.writeError(sub, nil, "error:invalid_op", "Invalid Operation")
 			continue
 		}
 
-		switch msg.Type {
-		case MsgTypeOp:
-			h.handleOp(r.Context(), conn, sub, msg, &subscribedEntries, authenticated)
-		case MsgTypeSyncRequest:
-			h.handleSyncRequest(r.Context(), conn, sub, msg, &subscribedEntries)
-		default:
-			h.writeError(conn, &msg.RequestID, "error:invalid_op", "Invalid Operation")
+		h.dispatch(r.Context(), sub, msg, sess, limiter)
+	}
+}
+
+// dispatch は受信したメッセージを種類ごとに処理する。
+func (h *WS) dispatch(ctx context.Context, sub *wsSubscriber, msg IncomingMessage, sess *wsSession, limiter *opLimiter) {
+	switch msg.Type {
+	case MsgTypeOp:
+		if !limiter.allow() {
+			h.writeError(sub, &msg.RequestID, "error:rate_limited", "Too Many Requests")
+			return
+		}
+		h.handleOp(ctx, sub, msg, sess)
+	case MsgTypeSyncRequest:
+		h.handleSyncRequest(ctx, sub, msg)
+	case MsgTypeSubscribe:
+		h.handleSubscribe(ctx, sub, msg)
+	case MsgTypeUnsubscribe:
+		h.handleUnsubscribe(sub, msg)
+	case MsgTypeAuth:
+		if !h.authenticate(ctx, sess, msg.Ticket, msg.Token) {
+			h.writeError(sub, &msg.RequestID, "error:auth_failed", "Authentication Failed")
 		}
+	case MsgTypeHello:
+		h.handleHello(sub, msg, sess)
+	case MsgTypeBatch:
+		msgs, ok := splitBatch(msg)
+		if !ok || !sess.currentProtocol().has(CapBatching) {
+			h.writeError(sub, &msg.RequestID, "error:invalid_op", "Invalid Operation")
+			return
+		}
+		for _, m := range msgs {
+			h.dispatch(ctx, sub, m, sess, limiter)
+		}
+	default:
+		h.writeError(sub, &msg.RequestID, "error:invalid_op", "Invalid Operation")
 	}
 }
 
-func (h *WS) handleOp(ctx context.Context, conn *websocket.Conn, sub *wsSubscriber, msg IncomingMessage, subscribedEntries *[]uuid.UUID, authenticated bool) {
+func (h *WS) handleOp(ctx context.Context, sub *wsSubscriber, msg IncomingMessage, sess *wsSession) {
 	ctx, span := wsTracer.Start(ctx, "WS.handleOp",
 		trace.WithAttributes(
 			attribute.String("ws.msg_type", string(msg.Type)),
@@ -133,75 +286,36 @@ func (h *WS) handleOp(ctx context.Context, conn *websocket.Conn, sub *wsSubscrib
 	)
 	defer span.End()
 
-	entryID, err := uuid.Parse(msg.EntryID)
-	if err != nil {
-		h.writeError(conn, &msg.RequestID, "error:invalid_op", "Invalid Operation")
+	ops := h.submitter()
+	op, opErr := ops.prepare(ctx, msg)
+	if opErr != nil {
+		h.writeError(sub, &msg.RequestID, opErr.typ, opErr.title)
 		return
 	}
-	requestID, err := uuid.Parse(msg.RequestID)
-	if err != nil {
-		h.writeError(conn, &msg.RequestID, "error:invalid_op", "Invalid Operation")
-		return
-	}
-
-	// サーバーが認証状態を強制付与（クライアントの自称は上書き）
-	msg.Authenticated = &authenticated
-
-	// opのpayloadをそのまま永続化（非認証deleteもイベントストアに記録する）
-	payload, _ := json.Marshal(msg)
 
-	// Subscribe if not already
-	h.ensureSubscribed(entryID, sub, subscribedEntries)
-
-	siteID := uuid.Nil
-	if msg.NodeID != nil {
-		siteID, _ = uuid.Parse(msg.NodeID.SiteID)
+	// チケットのエントリ許可リスト外では非認証として扱う
+	authenticated := sess.authenticatedFor(op.entryID)
+	if identity := sess.currentIdentity(); identity != "" {
+		ctx = application.WithActor(ctx, identity)
 	}
 
-	ack, err := h.syncService.HandleOp(ctx, entryID, siteID, requestID, payload)
-	if err != nil {
-		h.writeError(conn, &msg.RequestID, "error:internal", "Internal Error")
+	// Subscribe if not already
+	if !h.ensureSubscribed(op.entryID, sub) {
+		h.writeError(sub, &msg.RequestID, "error:too_many_subscriptions", "Too Many Subscriptions")
 		return
 	}
 
-	// ACKを先に送信
-	ackMsg := AckMsg{
-		Type:      MsgTypeAck,
-		RequestID: msg.RequestID,
-		EntryID:   msg.EntryID,
-		ServerSeq: ack.ServerSeq,
+	if opErr := ops.commit(ctx, op, authenticated, func(ack AckMsg) { sub.write(ack) }); opErr != nil {
+		h.writeError(sub, &msg.RequestID, opErr.typ, opErr.title)
 	}
-	data, _ := json.Marshal(ackMsg)
-	sub.mu.Lock()
-	conn.Write(ctx, websocket.MessageText, data)
-	sub.mu.Unlock()
-
-	// 重複でなければprojector適用 → broadcast（拒否時はブロードキャストしない）
-	if ack.ServerSeq > 0 {
-		applied := true
-		if h.projector != nil {
-			applied = h.projector.Apply(ctx, entryID, payload)
-		}
-
-		if !applied {
-			return
-		}
+}
 
-		h.syncService.Broadcast(entryID, application.SyncMessage{
-			EntryID: entryID,
-			Ops: []application.SyncOp{
-				{
-					RequestID: requestID,
-					ServerSeq: ack.ServerSeq,
-					Payload:   payload,
-				},
-			},
-			LatestServerSeq: ack.ServerSeq,
-		})
-	}
+// submitter はop受付処理を返す。
+func (h *WS) submitter() opSubmitter {
+	return opSubmitter{syncService: h.syncService, projector: h.projector, locks: h.locks, trash: h.trash, replicator: h.replicator}
 }
 
-func (h *WS) handleSyncRequest(ctx context.Context, conn *websocket.Conn, sub *wsSubscriber, msg IncomingMessage, subscribedEntries *[]uuid.UUID) {
+func (h *WS) handleSyncRequest(ctx context.Context, sub *wsSubscriber, msg IncomingMessage) {
 	ctx, span := wsTracer.Start(ctx, "WS.handleSyncRequest",
 		trace.WithAttributes(
 			attribute.String("ws.entry_id", msg.EntryID),
@@ -212,55 +326,80 @@ func (h *WS) handleSyncRequest(ctx context.Context, conn *websocket.Conn, sub *w
 
 	entryID, err := uuid.Parse(msg.EntryID)
 	if err != nil {
-		h.writeError(conn, &msg.RequestID, "error:invalid_op", "Invalid Operation")
+		h.writeError(sub, &msg.RequestID, "error:invalid_op", "Invalid Operation")
 		return
 	}
+	h.subscribeEntry(ctx, sub, &msg.RequestID, entryID, msg.LastServerSeq)
+}
 
-	h.ensureSubscribed(entryID, sub, subscribedEntries)
+// subscribeEntry はエントリを購読し、afterSeq以降の差分と提案の採否、ロック・削除の状態を送る。
+func (h *WS) subscribeEntry(ctx context.Context, sub *wsSubscriber, requestID *string, entryID uuid.UUID, afterSeq int64) {
+	if !h.ensureSubscribed(entryID, sub) {
+		h.writeError(sub, requestID, "error:too_many_subscriptions", "Too Many Subscriptions")
+		return
+	}
 
-	diff, err := h.syncService.GetDiff(ctx, entryID, msg.LastServerSeq)
+	diff, err := h.syncService.GetDiff(ctx, entryID, afterSeq)
 	if err != nil {
-		h.writeError(conn, &msg.RequestID, "error:internal", "Internal Error")
+		h.writeError(sub, requestID, "error:internal", "Internal Error")
 		return
 	}
 
-	syncMsg := convertSyncMessage(diff)
-	data, _ := json.Marshal(syncMsg)
-	sub.mu.Lock()
-	conn.Write(ctx, websocket.MessageText, data)
-	sub.mu.Unlock()
+	sub.write(diff)
+
+	// 差分の後に、その間に採否の決まった提案を伝える（提案opは差分に含まれている）
+	if decisions, err := h.syncService.SuggestionDecisions(ctx, entryID, afterSeq); err == nil {
+		for _, n := range decisions {
+			sub.Notify(n)
+		}
+	}
+
+	// ロック中・ゴミ箱にある場合は購読開始時に状態を伝える
+	if h.locks != nil && h.locks.IsLocked(ctx, entryID) {
+		sub.Notify(application.Notification{Type: application.NotifyEntryLock, EntryID: entryID, Locked: true})
+	}
+	if h.trash != nil && h.trash.IsDeleted(ctx, entryID) {
+		sub.Notify(application.Notification{Type: application.NotifyEntryDelete, EntryID: entryID, Deleted: true})
+	}
 }
 
-func (h *WS) ensureSubscribed(entryID uuid.UUID, sub *wsSubscriber, subscribedEntries *[]uuid.UUID) {
-	if slices.Contains(*subscribedEntries, entryID) {
-		return
+// ensureSubscribed はエントリを未購読なら購読する。接続ごとの上限に達していればfalseを返す。
+func (h *WS) ensureSubscribed(entryID uuid.UUID, sub *wsSubscriber) bool {
+	if slices.Contains(sub.entries, entryID) {
+		return true
+	}
+	if sub.maxSubs > 0 && len(sub.entries) >= sub.maxSubs {
+		return false
 	}
 	h.syncService.Subscribe(entryID, sub)
-	*subscribedEntries = append(*subscribedEntries, entryID)
+	sub.entries = append(sub.entries, entryID)
+	return true
 }
 
-func (h *WS) writeError(conn *websocket.Conn, requestID *string, errorType, title string) {
-	msg := newErrorMsg(requestID, errorType, title)
-	data, _ := json.Marshal(msg)
-	conn.Write(context.Background(), websocket.MessageText, data)
+func (h *WS) writeError(sub *wsSubscriber, requestID *string, errorType, title string) {
+	sub.write(newErrorMsg(requestID, errorType, title))
 }
 
 func convertSyncMessage(msg application.SyncMessage) SyncMsg {
 	ops := make([]SyncOpMsg, len(msg.Ops))
 	for i, op := range msg.Ops {
-		// payloadからop情報を復元
-		var incoming IncomingMessage
-		json.Unmarshal(op.Payload, &incoming)
-
-		ops[i] = SyncOpMsg{
-			RequestID:     op.RequestID.String(),
-			ServerSeq:     op.ServerSeq,
-			OpType:        incoming.OpType,
-			NodeID:        incoming.NodeID,
-			After:         incoming.After,
-			Value:         incoming.Value,
-			Authenticated: incoming.Authenticated,
+		if op.Op == nil {
+			// 解釈済みでなければpayloadからop情報を復元
+			var incoming IncomingMessage
+			json.Unmarshal(op.Payload, &incoming)
+			ops[i] = SyncOpMsg{
+				RequestID:     op.RequestID.String(),
+				ServerSeq:     op.ServerSeq,
+				OpType:        incoming.OpType,
+				NodeID:        incoming.NodeID,
+				After:         incoming.After,
+				Value:         incoming.Value,
+				Authenticated: incoming.Authenticated,
+				Suggestion:    incoming.Suggestion,
+			}
+			continue
 		}
+		ops[i] = syncOpFromOperation(op.ServerSeq, *op.Op)
 	}
 
 	return SyncMsg{
@@ -270,3 +409,25 @@ func convertSyncMessage(msg application.SyncMessage) SyncMsg {
 		LatestServerSeq: msg.LatestServerSeq,
 	}
 }
+
+// syncOpFromOperation は解釈済みのopをsync内のopに変換する。
+func syncOpFromOperation(serverSeq int64, op crdt.Operation) SyncOpMsg {
+	authenticated := op.Authenticated
+	m := SyncOpMsg{
+		RequestID:     op.RequestID.String(),
+		ServerSeq:     serverSeq,
+		OpType:        int(op.OpType),
+		NodeID:        &NodeIDMsg{SiteID: op.NodeID.ReplicaID.String(), Timestamp: op.NodeID.Timestamp},
+		Authenticated: &authenticated,
+	}
+	if op.After != nil {
+		m.After = &NodeIDMsg{SiteID: op.After.ReplicaID.String(), Timestamp: op.After.Timestamp}
+	}
+	if op.OpType == crdt.OpInsert {
+		m.Value = string(op.Value)
+	}
+	if op.Suggestion != uuid.Nil {
+		m.Suggestion = op.Suggestion.String()
+	}
+	return m
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+
+	"flourish/server/auth"
+)
+
+// WSConnectionResponse はアクティブなWS接続の情報。
+type WSConnectionResponse struct {
+	ID            string   `json:"id"`
+	Identity      string   `json:"identity"`
+	Authenticated bool     `json:"authenticated"`
+	Entries       []string `json:"entries"`
+	ClientIP      string   `json:"client_ip"`
+	ConnectedAt   string   `json:"connected_at"`
+	ExpiresAt     string   `json:"expires_at,omitempty"`
+	// ProtocolVersion はhelloで取り決めたバージョン。helloの無い従来のクライアントは1。
+	ProtocolVersion int `json:"protocol_version"`
+}
+
+// WSConnectionListResponse はWS接続一覧レスポンス。
+type WSConnectionListResponse struct {
+	Connections []WSConnectionResponse `json:"connections"`
+}
+
+// WSRevokeResponse は切断した接続数のレスポンス。
+type WSRevokeResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+func (h *WS) register(sess *wsSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sessions[sess.id] = sess
+}
+
+func (h *WS) unregister(sess *wsSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, sess.id)
+}
+
+// snapshot は登録中の接続を接続日時順に返す。
+func (h *WS) snapshot() []*wsSession {
+	h.mu.Lock()
+	sessions := make([]*wsSession, 0, len(h.sessions))
+	for _, sess := range h.sessions {
+		sessions = append(sessions, sess)
+	}
+	h.mu.Unlock()
+
+	slices.SortFunc(sessions, func(a, b *wsSession) int {
+		return a.connectedAt.Compare(b.connectedAt)
+	})
+	return sessions
+}
+
+// ListConnections は GET /api/admin/ws/connections ハンドラー。?identity= で利用者を絞り込む。
+func (h *WS) ListConnections(w http.ResponseWriter, r *http.Request) {
+	identity := r.URL.Query().Get("identity")
+
+	resp := WSConnectionListResponse{Connections: []WSConnectionResponse{}}
+	for _, sess := range h.snapshot() {
+		sess.mu.Lock()
+		c := WSConnectionResponse{
+			ID:            sess.id.String(),
+			Identity:      sess.identity,
+			Authenticated: sess.authenticated,
+			Entries:       make([]string, len(sess.entries)),
+			ClientIP:      sess.clientIP,
+			ConnectedAt:   sess.connectedAt.Format(time.RFC3339),
+		}
+		for i, id := range sess.entries {
+			c.Entries[i] = id.String()
+		}
+		if sess.authenticated && !sess.expiresAt.IsZero() {
+			c.ExpiresAt = sess.expiresAt.UTC().Format(time.RFC3339)
+		}
+		sess.mu.Unlock()
+		c.ProtocolVersion = sess.currentProtocol().version
+
+		if identity != "" && c.Identity != auth.NormalizeIdentity(identity) {
+			continue
+		}
+		resp.Connections = append(resp.Connections, c)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeConnection は POST /api/admin/ws/connections/{id}/revoke ハンドラー。
+func (h *WS) RevokeConnection(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	h.mu.Lock()
+	sess, ok := h.sessions[id]
+	h.mu.Unlock()
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "error:connection_not_found", "Connection Not Found")
+		return
+	}
+
+	h.revoke(sess)
+	writeJSON(w, http.StatusOK, WSRevokeResponse{Revoked: 1})
+}
+
+// RevokeIdentity は POST /api/admin/ws/identities/{identity}/revoke ハンドラー。
+// 利用者の全接続を切断し、未使用のチケットも無効化する。
+func (h *WS) RevokeIdentity(w http.ResponseWriter, r *http.Request) {
+	identity := auth.NormalizeIdentity(r.PathValue("identity"))
+	if identity == "" {
+		writeProblem(w, http.StatusBadRequest, "about:blank", "Bad Request")
+		return
+	}
+
+	if h.auth != nil {
+		h.auth.RevokeTickets(identity)
+	}
+	n := 0
+	for _, sess := range h.snapshot() {
+		if sess.currentIdentity() == identity {
+			h.revoke(sess)
+			n++
+		}
+	}
+	writeJSON(w, http.StatusOK, WSRevokeResponse{Revoked: n})
+}
+
+// revoke は接続をpolicy violationで閉じる。クローズハンドシェイクを待たずに戻る。
+func (h *WS) revoke(sess *wsSession) {
+	h.log.Info("websocket revoked", "connection", sess.id, "identity", sess.currentIdentity())
+	sess.stop()
+	go sess.conn.Close(websocket.StatusPolicyViolation, "revoked")
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+
+	"flourish/server/auth"
+)
+
+// wsTokenRecheckInterval はAPIトークンで認証したWS接続が失効を再確認する間隔。
+const wsTokenRecheckInterval = 5 * time.Minute
+
+// wsSession はWS接続とその認証状態。認証情報の期限が来ると非認証へ降格（または切断）する。
+type wsSession struct {
+	id          uuid.UUID
+	conn        *websocket.Conn
+	sub         *wsSubscriber
+	clientIP    string
+	connectedAt time.Time
+
+	mu            sync.Mutex
+	authenticated bool
+	identity      string
+	entries       []uuid.UUID // 認証済み編集を許可するエントリ。空なら全エントリ
+	expiresAt     time.Time   // ゼロなら期限なし
+	token         string      // APIトークン認証時の平文（失効の再確認に使う）
+	protocol      wsProtocol  // helloで取り決めたプロトコル。ゼロ値ならhello前
+	timer         *time.Timer
+}
+
+// authenticatedFor はエントリに対して認証済みとして振る舞うかどうかを返す。
+func (s *wsSession) authenticatedFor(entryID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authenticated && (len(s.entries) == 0 || slices.Contains(s.entries, entryID))
+}
+
+// currentIdentity は接続の利用者を返す。
+func (s *wsSession) currentIdentity() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.identity
+}
+
+// stop は期限タイマーを止める。
+func (s *wsSession) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// authenticate はチケットまたはAPIトークンで認証状態を更新する。
+// どちらも空なら非認証に戻す（ログアウト）。認証に失敗した場合は状態を変えずfalseを返す。
+func (h *WS) authenticate(ctx context.Context, sess *wsSession, ticket, token string) bool {
+	var (
+		authenticated bool
+		identity      string
+		entries       []uuid.UUID
+		expiresAt     time.Time
+	)
+	if ticket != "" || token != "" {
+		if h.auth == nil {
+			return false
+		}
+		if ticket != "" {
+			grant, ok := h.auth.RedeemTicket(ticket, sess.clientIP)
+			authenticated = ok
+			identity, entries, expiresAt = grant.Identity, grant.Entries, grant.CredentialExpiresAt
+			token = ""
+		} else {
+			identity, authenticated = h.auth.AuthenticateToken(ctx, token)
+		}
+		if !authenticated {
+			return false
+		}
+	}
+
+	sess.mu.Lock()
+	sess.authenticated = authenticated
+	sess.identity = auth.NormalizeIdentity(identity)
+	sess.entries = entries
+	sess.expiresAt = expiresAt
+	sess.token = token
+	h.scheduleExpiry(sess)
+	sess.mu.Unlock()
+
+	h.sendAuthStatus(sess)
+	return true
+}
+
+// scheduleExpiry は期限タイマーを張り直す。ロック保持前提。
+func (h *WS) scheduleExpiry(sess *wsSession) {
+	if sess.timer != nil {
+		sess.timer.Stop()
+		sess.timer = nil
+	}
+	if !sess.authenticated {
+		return
+	}
+
+	var d time.Duration
+	switch {
+	case !sess.expiresAt.IsZero():
+		d = time.Until(sess.expiresAt)
+	case sess.token != "":
+		d = wsTokenRecheckInterval
+	default:
+		return
+	}
+	sess.timer = time.AfterFunc(d, func() { h.expire(sess) })
+}
+
+// expire は認証情報の期限切れを処理する。APIトークンは失効していなければ再確認を続ける。
+func (h *WS) expire(sess *wsSession) {
+	sess.mu.Lock()
+	if !sess.authenticated {
+		sess.mu.Unlock()
+		return
+	}
+	if sess.token != "" && sess.expiresAt.IsZero() {
+		if _, ok := h.auth.AuthenticateToken(context.Background(), sess.token); ok {
+			h.scheduleExpiry(sess)
+			sess.mu.Unlock()
+			return
+		}
+	}
+	sess.authenticated = false
+	sess.entries = nil
+	sess.expiresAt = time.Time{}
+	sess.token = ""
+	sess.timer = nil
+	identity := sess.identity
+	sess.mu.Unlock()
+
+	if h.auth.closeWSOnExpiry {
+		h.log.Info("websocket auth expired, closing", "identity", identity)
+		sess.conn.Close(websocket.StatusPolicyViolation, "credentials expired")
+		return
+	}
+	h.log.Info("websocket auth expired, demoted to unauthenticated", "identity", identity)
+	h.sendAuthStatus(sess)
+}
+
+// sendAuthStatus は現在の認証状態をauth_statusとして送信する。
+func (h *WS) sendAuthStatus(sess *wsSession) {
+	sess.mu.Lock()
+	msg := AuthStatusMsg{Type: MsgTypeAuthStatus, Authenticated: sess.authenticated}
+	if sess.authenticated && !sess.expiresAt.IsZero() {
+		msg.ExpiresAt = sess.expiresAt.UTC().Format(time.RFC3339)
+	}
+	sess.mu.Unlock()
+	sess.sub.write(msg)
+}
@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"encoding/binary"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/domain/crdt"
+)
+
+// WebSocketのサブプロトコル。指定が無ければJSON。
+const (
+	WSSubprotocolJSON   = "flourish.json"
+	WSSubprotocolBinary = "flourish.bin"
+)
+
+// バイナリ形式（サブプロトコル flourish.bin）
+//
+// op・ack・syncだけをバイナリフレームで送り、それ以外（hello・auth・sync_request・通知・エラー）は
+// テキストフレームのJSONのまま送る。各フレームは先頭1バイトの種別と本体からなり、整数はunsigned varint。
+//
+//	op   (0x01, クライアント→サーバー): entry_id(16) request_id(16) op
+//	ack  (0x02, サーバー→クライアント): entry_id(16) request_id(16) server_seq
+//	sync (0x03, サーバー→クライアント): entry_id(16) latest_server_seq count { request_id(16) server_seq op }
+//
+//	op: op_type(1) flags(1) node [after] [value] [suggestion(16)]
+//	    flags: bit0 after, bit1 value, bit2 suggestion, bit3 authenticated
+//	node・after: site timestamp
+//	site: 接続・方向ごとの辞書の添字+1。0なら続く16バイトがsite IDで、辞書に空きがあれば末尾に追加する
+//	value: バイト長とUTF-8
+const (
+	binaryOp   byte = 0x01
+	binaryAck  byte = 0x02
+	binarySync byte = 0x03
+)
+
+const (
+	binaryFlagAfter byte = 1 << iota
+	binaryFlagValue
+	binaryFlagSuggestion
+	binaryFlagAuthenticated
+)
+
+// maxBinarySites は接続・方向ごとのsite ID辞書の上限。超えた分は毎回16バイトで送る。
+const maxBinarySites = 4096
+
+// ErrInvalidBinary は解釈できないバイナリフレーム。
+var ErrInvalidBinary = errors.New("invalid binary frame")
+
+// BinaryCodec は1接続・1方向分のバイナリ形式の符号化・復号。
+// site IDの辞書を持つので、送信と受信にそれぞれ1つずつ使い、複数のgoroutineから使わない。
+type BinaryCodec struct {
+	sites []uuid.UUID
+	index map[uuid.UUID]uint64
+}
+
+func NewBinaryCodec() *BinaryCodec {
+	return &BinaryCodec{index: make(map[uuid.UUID]uint64)}
+}
+
+// EncodeOp はクライアントのopをバイナリにする。
+func (c *BinaryCodec) EncodeOp(msg IncomingMessage) ([]byte, error) {
+	entryID, err := uuid.Parse(msg.EntryID)
+	if err != nil {
+		return nil, err
+	}
+	requestID, err := uuid.Parse(msg.RequestID)
+	if err != nil {
+		return nil, err
+	}
+	op := SyncOpMsg{OpType: msg.OpType, NodeID: msg.NodeID, After: msg.After, Value: msg.Value, Authenticated: msg.Authenticated, Suggestion: msg.Suggestion}
+	b := append([]byte{binaryOp}, entryID[:]...)
+	b = append(b, requestID[:]...)
+	return c.appendOpMsg(b, op)
+}
+
+// DecodeOp はクライアントのバイナリのopをJSONと同じ形に戻す。
+func (c *BinaryCodec) DecodeOp(data []byte) (IncomingMessage, error) {
+	r := binaryReader{data: data}
+	if r.byte() != binaryOp {
+		return IncomingMessage{}, ErrInvalidBinary
+	}
+	entryID, requestID := r.uuid(), r.uuid()
+	op := c.readOp(&r)
+	if r.err != nil || len(r.data) > 0 {
+		return IncomingMessage{}, ErrInvalidBinary
+	}
+	return IncomingMessage{
+		Type:          MsgTypeOp,
+		RequestID:     requestID.String(),
+		EntryID:       entryID.String(),
+		OpType:        op.OpType,
+		NodeID:        op.NodeID,
+		After:         op.After,
+		Value:         op.Value,
+		Authenticated: op.Authenticated,
+		Suggestion:    op.Suggestion,
+	}, nil
+}
+
+// EncodeAck はACKをバイナリにする。
+func (c *BinaryCodec) EncodeAck(ack AckMsg) []byte {
+	entryID, _ := uuid.Parse(ack.EntryID)
+	requestID, _ := uuid.Parse(ack.RequestID)
+	b := append([]byte{binaryAck}, entryID[:]...)
+	b = append(b, requestID[:]...)
+	return binary.AppendUvarint(b, uint64(ack.ServerSeq))
+}
+
+// EncodeSync はsyncメッセージをバイナリにする。解釈済みのopをそのまま使い、payloadは読まない。
+func (c *BinaryCodec) EncodeSync(msg application.SyncMessage) []byte {
+	b := append([]byte{binarySync}, msg.EntryID[:]...)
+	b = binary.AppendUvarint(b, uint64(msg.LatestServerSeq))
+	b = binary.AppendUvarint(b, uint64(len(msg.Ops)))
+	for _, op := range msg.Ops {
+		b = append(b, op.RequestID[:]...)
+		b = binary.AppendUvarint(b, uint64(op.ServerSeq))
+		if op.Op == nil {
+			// 解釈できないopは種別0として送り、クライアントに無視させる
+			b = append(b, 0, 0)
+			b = c.appendNode(b, uuid.Nil, 0)
+			continue
+		}
+		b = c.appendOperation(b, *op.Op)
+	}
+	return b
+}
+
+// DecodeServerMessage はサーバーからのバイナリフレームをAckMsgまたはSyncMsgに戻す。
+func (c *BinaryCodec) DecodeServerMessage(data []byte) (any, error) {
+	r := binaryReader{data: data}
+	var msg any
+	switch r.byte() {
+	case binaryAck:
+		entryID, requestID := r.uuid(), r.uuid()
+		msg = AckMsg{Type: MsgTypeAck, RequestID: requestID.String(), EntryID: entryID.String(), ServerSeq: int64(r.uvarint())}
+	case binarySync:
+		entryID := r.uuid()
+		sync := SyncMsg{Type: MsgTypeSync, EntryID: entryID.String(), LatestServerSeq: int64(r.uvarint())}
+		n := r.uvarint()
+		if n > uint64(len(data)) {
+			return nil, ErrInvalidBinary
+		}
+		sync.Ops = make([]SyncOpMsg, 0, n)
+		for range n {
+			requestID := r.uuid()
+			serverSeq := int64(r.uvarint())
+			op := c.readOp(&r)
+			op.RequestID, op.ServerSeq = requestID.String(), serverSeq
+			sync.Ops = append(sync.Ops, op)
+		}
+		msg = sync
+	default:
+		return nil, ErrInvalidBinary
+	}
+	if r.err != nil || len(r.data) > 0 {
+		return nil, ErrInvalidBinary
+	}
+	return msg, nil
+}
+
+// appendOperation は解釈済みのopを書き込む。
+func (c *BinaryCodec) appendOperation(b []byte, op crdt.Operation) []byte {
+	var flags byte
+	if op.After != nil {
+		flags |= binaryFlagAfter
+	}
+	if op.OpType == crdt.OpInsert {
+		flags |= binaryFlagValue
+	}
+	if op.Suggestion != uuid.Nil {
+		flags |= binaryFlagSuggestion
+	}
+	if op.Authenticated {
+		flags |= binaryFlagAuthenticated
+	}
+	b = append(b, byte(op.OpType), flags)
+	b = c.appendNode(b, op.NodeID.ReplicaID, op.NodeID.Timestamp)
+	if op.After != nil {
+		b = c.appendNode(b, op.After.ReplicaID, op.After.Timestamp)
+	}
+	if flags&binaryFlagValue != 0 {
+		b = appendString(b, string(op.Value))
+	}
+	if flags&binaryFlagSuggestion != 0 {
+		b = append(b, op.Suggestion[:]...)
+	}
+	return b
+}
+
+// appendOpMsg はJSONの形のopを書き込む。
+func (c *BinaryCodec) appendOpMsg(b []byte, op SyncOpMsg) ([]byte, error) {
+	var flags byte
+	var siteID, afterSiteID, suggestion uuid.UUID
+	var err error
+	if op.NodeID == nil {
+		return nil, ErrInvalidBinary
+	}
+	if siteID, err = uuid.Parse(op.NodeID.SiteID); err != nil {
+		return nil, err
+	}
+	if op.After != nil {
+		flags |= binaryFlagAfter
+		if afterSiteID, err = uuid.Parse(op.After.SiteID); err != nil {
+			return nil, err
+		}
+	}
+	if op.Value != "" {
+		flags |= binaryFlagValue
+	}
+	if op.Suggestion != "" {
+		flags |= binaryFlagSuggestion
+		if suggestion, err = uuid.Parse(op.Suggestion); err != nil {
+			return nil, err
+		}
+	}
+	if op.Authenticated == nil || *op.Authenticated {
+		flags |= binaryFlagAuthenticated
+	}
+	b = append(b, byte(op.OpType), flags)
+	b = c.appendNode(b, siteID, op.NodeID.Timestamp)
+	if op.After != nil {
+		b = c.appendNode(b, afterSiteID, op.After.Timestamp)
+	}
+	if flags&binaryFlagValue != 0 {
+		b = appendString(b, op.Value)
+	}
+	if flags&binaryFlagSuggestion != 0 {
+		b = append(b, suggestion[:]...)
+	}
+	return b, nil
+}
+
+// readOp はopを読み、JSONの形で返す。
+func (c *BinaryCodec) readOp(r *binaryReader) SyncOpMsg {
+	op := SyncOpMsg{OpType: int(r.byte())}
+	flags := r.byte()
+	op.NodeID = c.readNode(r)
+	if flags&binaryFlagAfter != 0 {
+		op.After = c.readNode(r)
+	}
+	if flags&binaryFlagValue != 0 {
+		op.Value = r.string()
+	}
+	if flags&binaryFlagSuggestion != 0 {
+		op.Suggestion = r.uuid().String()
+	}
+	authenticated := flags&binaryFlagAuthenticated != 0
+	op.Authenticated = &authenticated
+	return op
+}
+
+// appendNode はsite参照とtimestampを書き込む。
+func (c *BinaryCodec) appendNode(b []byte, siteID uuid.UUID, timestamp uint64) []byte {
+	if i, ok := c.index[siteID]; ok {
+		b = binary.AppendUvarint(b, i+1)
+	} else {
+		b = append(binary.AppendUvarint(b, 0), siteID[:]...)
+		c.define(siteID)
+	}
+	return binary.AppendUvarint(b, timestamp)
+}
+
+func (c *BinaryCodec) readNode(r *binaryReader) *NodeIDMsg {
+	var siteID uuid.UUID
+	switch ref := r.uvarint(); {
+	case ref == 0:
+		siteID = r.uuid()
+		if r.err == nil {
+			c.define(siteID)
+		}
+	case ref <= uint64(len(c.sites)):
+		siteID = c.sites[ref-1]
+	default:
+		r.err = ErrInvalidBinary
+	}
+	return &NodeIDMsg{SiteID: siteID.String(), Timestamp: r.uvarint()}
+}
+
+// define は辞書に空きがあればsite IDを追加する。送信側と受信側で同じ順に呼ばれるので添字が一致する。
+func (c *BinaryCodec) define(siteID uuid.UUID) {
+	if len(c.sites) >= maxBinarySites {
+		return
+	}
+	c.index[siteID] = uint64(len(c.sites))
+	c.sites = append(c.sites, siteID)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// binaryReader は最初のエラーを覚えて以降の読み込みを空振りさせるリーダー。
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) take(n int) []byte {
+	if r.err != nil || n < 0 || len(r.data) < n {
+		r.err = ErrInvalidBinary
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *binaryReader) byte() byte {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *binaryReader) uuid() uuid.UUID {
+	var id uuid.UUID
+	copy(id[:], r.take(len(id)))
+	return id
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalidBinary
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) string() string {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.err = ErrInvalidBinary
+		return ""
+	}
+	s := string(r.take(int(n)))
+	if !utf8.ValidString(s) {
+		r.err = ErrInvalidBinary
+		return ""
+	}
+	return s
+}
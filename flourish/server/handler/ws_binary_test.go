@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+
+	"flourish/server/handler"
+)
+
+func TestBinaryCodec_OpRoundTripAndSiteDictionary(t *testing.T) {
+	enc, dec := handler.NewBinaryCodec(), handler.NewBinaryCodec()
+	siteID := uuid.New().String()
+	authenticated := true
+	op := func(ts uint64, value string) handler.IncomingMessage {
+		return handler.IncomingMessage{
+			Type:          handler.MsgTypeOp,
+			RequestID:     uuid.New().String(),
+			EntryID:       uuid.New().String(),
+			OpType:        1,
+			NodeID:        &handler.NodeIDMsg{SiteID: siteID, Timestamp: ts},
+			After:         &handler.NodeIDMsg{SiteID: siteID, Timestamp: ts - 1},
+			Value:         value,
+			Authenticated: &authenticated,
+		}
+	}
+
+	first, second := op(300, "あ"), op(301, "b")
+	firstBin, err := enc.EncodeOp(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondBin, _ := enc.EncodeOp(second)
+	// 2回目以降のsite IDは辞書の添字になる
+	if len(secondBin) >= len(firstBin)-16 {
+		t.Errorf("既知のsite IDは短く符号化されるべき: %d → %d bytes", len(firstBin), len(secondBin))
+	}
+
+	for _, tc := range []struct {
+		want handler.IncomingMessage
+		data []byte
+	}{{first, firstBin}, {second, secondBin}} {
+		got, err := dec.DecodeOp(tc.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.RequestID != tc.want.RequestID || got.EntryID != tc.want.EntryID || got.Value != tc.want.Value ||
+			*got.NodeID != *tc.want.NodeID || *got.After != *tc.want.After {
+			t.Errorf("復号結果が一致すべき: got %+v, want %+v", got, tc.want)
+		}
+	}
+
+	if _, err := dec.DecodeOp(firstBin[:len(firstBin)-1]); err == nil {
+		t.Error("途中で切れたフレームはエラーになるべき")
+	}
+	if _, err := handler.NewBinaryCodec().DecodeOp(secondBin); err == nil {
+		t.Error("辞書に無い添字はエラーになるべき")
+	}
+}
+
+func TestWS_BinarySubprotocol(t *testing.T) {
+	srv, _ := setupWSServer(t)
+	conn, resp, err := websocket.Dial(t.Context(), "ws"+srv.URL[len("http"):], &websocket.DialOptions{
+		Subprotocols: []string{handler.WSSubprotocolBinary},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != handler.WSSubprotocolBinary {
+		t.Fatalf("バイナリ形式が選ばれるべき: got %q", got)
+	}
+	// 制御メッセージはJSONのまま
+	if hello := readJSON[handler.HelloMsg](t, conn); len(hello.Capabilities) == 0 || hello.Capabilities[len(hello.Capabilities)-1] != handler.CapBinary {
+		t.Errorf("helloでbinaryを告知すべき: got %+v", hello)
+	}
+	readJSON[handler.AuthStatusMsg](t, conn)
+
+	// JSONの購読者にも同じopが届く
+	jsonConn := dial(t, srv)
+	entryID := uuid.New().String()
+	writeJSON(t, jsonConn, map[string]any{"type": "sync_request", "request_id": uuid.New().String(), "entry_id": entryID})
+	readJSON[handler.SyncMsg](t, jsonConn)
+
+	enc, dec := handler.NewBinaryCodec(), handler.NewBinaryCodec()
+	reqID := uuid.New().String()
+	data, err := enc.EncodeOp(handler.IncomingMessage{
+		RequestID: reqID,
+		EntryID:   entryID,
+		OpType:    1,
+		NodeID:    &handler.NodeIDMsg{SiteID: uuid.New().String(), Timestamp: 1},
+		Value:     "x",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Write(t.Context(), websocket.MessageBinary, data); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() any {
+		typ, data, err := conn.Read(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != websocket.MessageBinary {
+			t.Fatalf("ACKとsyncはバイナリであるべき: %s", data)
+		}
+		msg, err := dec.DecodeServerMessage(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	if ack, ok := read().(handler.AckMsg); !ok || ack.RequestID != reqID || ack.ServerSeq != 1 {
+		t.Errorf("ACKを受信すべき: got %+v", ack)
+	}
+	sync, ok := read().(handler.SyncMsg)
+	if !ok || len(sync.Ops) != 1 || sync.Ops[0].Value != "x" || sync.Ops[0].RequestID != reqID || *sync.Ops[0].Authenticated {
+		t.Errorf("非認証のopのsyncを受信すべき: got %+v", sync)
+	}
+
+	if got := readJSON[handler.SyncMsg](t, jsonConn); len(got.Ops) != 1 || got.Ops[0].Value != "x" {
+		t.Errorf("JSONの購読者にはJSONで届くべき: got %+v", got)
+	}
+
+	// 壊れたバイナリはJSONのエラーになる
+	conn.Write(t.Context(), websocket.MessageBinary, []byte{0x01, 0x02})
+	typ, data, err := conn.Read(t.Context())
+	if err != nil || typ != websocket.MessageText || !bytes.Contains(data, []byte("error:invalid_op")) {
+		t.Errorf("不正なフレームはerror:invalid_opになるべき: %s %v", data, err)
+	}
+}
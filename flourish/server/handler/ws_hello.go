@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/coder/websocket"
+)
+
+// WebSocketプロトコルのバージョン。
+const (
+	// WSProtocolV1 はhelloを送らない従来のクライアント。能力は使えない。
+	WSProtocolV1 = 1
+	// WSProtocolV2 はhelloでバージョンと能力を取り決めたクライアント。
+	WSProtocolV2 = 2
+)
+
+// wsProtocolVersions はサーバーが対応するバージョン（新しい順）。
+var wsProtocolVersions = []int{WSProtocolV2, WSProtocolV1}
+
+// WSStatusUnsupportedProtocol は対応していないバージョンのクライアントを切断するときのクローズコード。
+// クライアントは再接続せず、更新を促す。
+const WSStatusUnsupportedProtocol websocket.StatusCode = 4001
+
+// Capability はhelloで取り決める任意の機能。
+type Capability string
+
+const (
+	// CapBatching は複数のメッセージをbatchにまとめて送れる。
+	CapBatching  Capability = "batching"
+	CapSnapshots Capability = "snapshots"
+	CapBinary    Capability = "binary"
+	CapPresence  Capability = "presence"
+)
+
+// wsCapabilities はどの接続でも使える能力。binaryはサブプロトコルで選んだ接続だけで使える。
+var wsCapabilities = []Capability{CapBatching}
+
+// capabilities は接続で使える能力を返す。
+func (s *wsSubscriber) capabilities() []Capability {
+	if s.codec != nil {
+		return append(slices.Clone(wsCapabilities), CapBinary)
+	}
+	return wsCapabilities
+}
+
+// HelloMsg は接続直後にサーバーが送る対応バージョンと能力の告知。
+type HelloMsg struct {
+	Type             string       `json:"type"`
+	ProtocolVersions []int        `json:"protocol_versions"`
+	Capabilities     []Capability `json:"capabilities"`
+}
+
+// HelloAckMsg はクライアントのhelloに対する、取り決めたバージョンと能力の通知。
+type HelloAckMsg struct {
+	Type            string       `json:"type"`
+	ProtocolVersion int          `json:"protocol_version"`
+	Capabilities    []Capability `json:"capabilities"`
+}
+
+// wsProtocol は接続で取り決めたプロトコル。helloが無ければV1。
+type wsProtocol struct {
+	version      int
+	capabilities []Capability
+}
+
+func (p wsProtocol) has(c Capability) bool {
+	return slices.Contains(p.capabilities, c)
+}
+
+// negotiate はクライアントが対応するバージョンのうちサーバーも対応する最新のものと、双方が対応する能力を選ぶ。
+// 共通のバージョンが無ければfalseを返す。
+func negotiate(versions []int, capabilities []string, available []Capability) (wsProtocol, bool) {
+	for _, v := range wsProtocolVersions {
+		if !slices.Contains(versions, v) {
+			continue
+		}
+		p := wsProtocol{version: v, capabilities: []Capability{}}
+		if v >= WSProtocolV2 {
+			for _, c := range available {
+				if slices.Contains(capabilities, string(c)) {
+					p.capabilities = append(p.capabilities, c)
+				}
+			}
+		}
+		return p, true
+	}
+	return wsProtocol{}, false
+}
+
+// wsClose は送信キューに積んだメッセージを送り終えてから接続を閉じる指示。
+type wsClose struct {
+	code   websocket.StatusCode
+	reason string
+}
+
+// handleHello はクライアントのhelloでプロトコルを取り決める。
+// 取り決めは接続ごとに1回だけで、共通のバージョンが無ければ構造化エラーを送って切断する。
+func (h *WS) handleHello(sub *wsSubscriber, msg IncomingMessage, sess *wsSession) {
+	sess.mu.Lock()
+	negotiated := sess.protocol.version != 0
+	sess.mu.Unlock()
+	if negotiated {
+		h.writeError(sub, &msg.RequestID, "error:invalid_op", "Invalid Operation")
+		return
+	}
+
+	p, ok := negotiate(msg.ProtocolVersions, msg.Capabilities, sub.capabilities())
+	if !ok {
+		h.log.Info("websocket unsupported protocol", "versions", msg.ProtocolVersions)
+		errMsg := newErrorMsg(&msg.RequestID, "error:unsupported_protocol", "Unsupported Protocol Version")
+		errMsg.SupportedVersions = wsProtocolVersions
+		sub.write(errMsg)
+		sub.write(wsClose{code: WSStatusUnsupportedProtocol, reason: "unsupported protocol"})
+		return
+	}
+
+	sess.mu.Lock()
+	sess.protocol = p
+	sess.mu.Unlock()
+	sub.write(HelloAckMsg{Type: MsgTypeHelloAck, ProtocolVersion: p.version, Capabilities: p.capabilities})
+}
+
+// currentProtocol は接続で取り決めたプロトコルを返す。
+func (s *wsSession) currentProtocol() wsProtocol {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.protocol.version == 0 {
+		return wsProtocol{version: WSProtocolV1}
+	}
+	return s.protocol
+}
+
+// splitBatch はbatchメッセージを個々のメッセージに分ける。batchとhelloは入れ子にできない。
+func splitBatch(msg IncomingMessage) ([]IncomingMessage, bool) {
+	msgs := make([]IncomingMessage, len(msg.Messages))
+	for i, raw := range msg.Messages {
+		if err := json.Unmarshal(raw, &msgs[i]); err != nil {
+			return nil, false
+		}
+		if msgs[i].Type == MsgTypeBatch || msgs[i].Type == MsgTypeHello {
+			return nil, false
+		}
+	}
+	return msgs, true
+}
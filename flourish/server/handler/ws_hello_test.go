@@ -0,0 +1,96 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+
+	"flourish/server/handler"
+)
+
+func TestWS_HelloNegotiatesVersionAndCapabilities(t *testing.T) {
+	srv, _ := setupWSServer(t)
+	conn, _, err := websocket.Dial(t.Context(), "ws"+srv.URL[len("http"):], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+
+	hello := readJSON[handler.HelloMsg](t, conn)
+	if hello.Type != "hello" || !slices.Contains(hello.ProtocolVersions, handler.WSProtocolV1) || !slices.Contains(hello.ProtocolVersions, handler.WSProtocolV2) {
+		t.Fatalf("最初のフレームは対応バージョンの告知であるべき: got %+v", hello)
+	}
+	readJSON[handler.AuthStatusMsg](t, conn)
+
+	writeJSON(t, conn, map[string]any{
+		"type":              "hello",
+		"protocol_versions": []int{2, 3},
+		"capabilities":      []string{"batching", "teleport"},
+	})
+	ack := readJSON[handler.HelloAckMsg](t, conn)
+	if ack.Type != "hello_ack" || ack.ProtocolVersion != handler.WSProtocolV2 || !slices.Equal(ack.Capabilities, []handler.Capability{handler.CapBatching}) {
+		t.Errorf("共通の最新バージョンと能力を選ぶべき: got %+v", ack)
+	}
+
+	// 取り決めは1回だけ
+	writeJSON(t, conn, map[string]any{"type": "hello", "protocol_versions": []int{1}})
+	if errMsg := readJSON[handler.ErrorMsg](t, conn); errMsg.ErrorType != "error:invalid_op" {
+		t.Errorf("2回目のhelloは拒否されるべき: got %+v", errMsg)
+	}
+}
+
+func TestWS_HelloRejectsUnsupportedVersion(t *testing.T) {
+	srv, _ := setupWSServer(t)
+	conn := dial(t, srv)
+
+	writeJSON(t, conn, map[string]any{"type": "hello", "request_id": "h1", "protocol_versions": []int{9}})
+	errMsg := readJSON[handler.ErrorMsg](t, conn)
+	if errMsg.ErrorType != "error:unsupported_protocol" || !slices.Contains(errMsg.SupportedVersions, handler.WSProtocolV2) {
+		t.Errorf("対応バージョンを含む構造化エラーであるべき: got %+v", errMsg)
+	}
+	if _, _, err := conn.Read(t.Context()); websocket.CloseStatus(err) != handler.WSStatusUnsupportedProtocol {
+		t.Errorf("エラーの後に切断されるべき: got %v", err)
+	}
+}
+
+func TestWS_BatchRequiresCapability(t *testing.T) {
+	srv, _ := setupWSServer(t)
+	conn := dial(t, srv)
+	entryID := uuid.New().String()
+	siteID := uuid.New().String()
+	op := func(ts int) json.RawMessage {
+		data, _ := json.Marshal(map[string]any{
+			"type":       "op",
+			"request_id": uuid.New().String(),
+			"entry_id":   entryID,
+			"op_type":    1,
+			"node_id":    map[string]any{"site_id": siteID, "timestamp": ts},
+			"value":      "a",
+		})
+		return data
+	}
+
+	// V1（hello無し）ではbatchを使えない
+	writeJSON(t, conn, map[string]any{"type": "batch", "messages": []json.RawMessage{op(1)}})
+	if errMsg := readJSON[handler.ErrorMsg](t, conn); errMsg.ErrorType != "error:invalid_op" {
+		t.Fatalf("取り決めていないbatchは拒否されるべき: got %+v", errMsg)
+	}
+
+	writeJSON(t, conn, map[string]any{"type": "hello", "protocol_versions": []int{2}, "capabilities": []string{"batching"}})
+	readJSON[handler.HelloAckMsg](t, conn)
+	writeJSON(t, conn, map[string]any{"type": "batch", "messages": []json.RawMessage{op(1), op(2)}})
+
+	var acks []int64
+	for len(acks) < 2 {
+		msg := readJSON[map[string]any](t, conn)
+		if msg["type"] == "ack" {
+			acks = append(acks, int64(msg["server_seq"].(float64)))
+		}
+	}
+	if !slices.Equal(acks, []int64{1, 2}) {
+		t.Errorf("batch内のopはそれぞれACKされるべき: got %v", acks)
+	}
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// WSConfig はWebSocket接続の制限とkeepaliveの設定。
+type WSConfig struct {
+	// AllowedOrigins は接続を許可するOriginのパターン（例: "example.com", "*.example.com", "localhost:5173"）。
+	// 空なら同一オリジンのみ許可する。"*"で全オリジンを許可する。
+	AllowedOrigins []string
+	// PingInterval はpingの間隔。0ならpingしない。
+	PingInterval time.Duration
+	// IdleTimeout はメッセージもpongも届かない接続を切断するまでの時間。0なら切断しない。
+	IdleTimeout time.Duration
+	// MaxMessageBytes は受信メッセージの最大サイズ。
+	MaxMessageBytes int64
+	// OpRate は接続ごとの毎秒のop数の上限、OpBurstは瞬間的に許す数。OpRateが0なら制限しない。
+	OpRate  float64
+	OpBurst int
+	// MaxSubscriptions は接続ごとに購読できるエントリ数の上限。0なら制限しない。
+	MaxSubscriptions int
+}
+
+// DefaultWSConfig はWebSocket接続の既定の設定。
+var DefaultWSConfig = WSConfig{
+	PingInterval:     30 * time.Second,
+	IdleTimeout:      90 * time.Second,
+	MaxMessageBytes:  64 << 10,
+	OpRate:           50,
+	OpBurst:          200,
+	MaxSubscriptions: 500,
+}
+
+// SetConfig は以降の接続に適用する設定を変更する。
+func (h *WS) SetConfig(cfg WSConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cfg = cfg
+}
+
+func (h *WS) config() WSConfig {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cfg
+}
+
+// keepalive はctxが終了するまで定期的にpingし、IdleTimeoutの間メッセージもpongも無ければ切断する。
+// lastSeenは最後に受信した時刻（UnixNano）で、読み込みループと共有する。
+func (h *WS) keepalive(ctx context.Context, conn *websocket.Conn, cfg WSConfig, lastSeen *atomic.Int64) {
+	if cfg.PingInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if cfg.IdleTimeout > 0 && time.Since(time.Unix(0, lastSeen.Load())) > cfg.IdleTimeout {
+			h.log.Info("websocket idle timeout, closing")
+			conn.Close(websocket.StatusGoingAway, "idle timeout")
+			return
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.PingInterval)
+		if err := conn.Ping(pingCtx); err == nil {
+			lastSeen.Store(time.Now().UnixNano())
+		}
+		cancel()
+	}
+}
+
+// opLimiter は接続ごとのopのトークンバケット。
+type opLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newOpLimiter(rate float64, burst int) *opLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	b := float64(max(burst, 1))
+	return &opLimiter{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// allow はopを1つ受け付けられるかを返す。nilなら常に受け付ける。
+func (l *opLimiter) allow() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// opLimiterSweepInterval は使われなくなったクライアントのopLimiterを捨てる間隔。
+const opLimiterSweepInterval = time.Minute
+
+// clientOpLimiters はHTTPのop送信に使う、クライアントIPごとのopLimiter。
+// 接続の無いHTTPではWSの接続ごとの制限の代わりにクライアントIPごとに数える。
+type clientOpLimiters struct {
+	rate  float64
+	burst int
+	idle  time.Duration // トークンが空から満杯に戻るまでの時間
+
+	mu       sync.Mutex
+	limiters map[string]*opLimiter
+	swept    time.Time
+}
+
+func newClientOpLimiters(rate float64, burst int) *clientOpLimiters {
+	if rate <= 0 {
+		return nil
+	}
+	return &clientOpLimiters{
+		rate:     rate,
+		burst:    burst,
+		idle:     time.Duration(float64(max(burst, 1)) / rate * float64(time.Second)),
+		limiters: make(map[string]*opLimiter),
+		swept:    time.Now(),
+	}
+}
+
+// allow はclientのopを1つ受け付けられるかを返す。nilなら常に受け付ける。
+func (c *clientOpLimiters) allow(client string) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	now := time.Now()
+	if now.Sub(c.swept) >= opLimiterSweepInterval {
+		// トークンが満杯に戻るまで使われていなければ、新しく作り直すのと変わらない
+		for k, l := range c.limiters {
+			l.mu.Lock()
+			idle := now.Sub(l.last) >= c.idle
+			l.mu.Unlock()
+			if idle {
+				delete(c.limiters, k)
+			}
+		}
+		c.swept = now
+	}
+	l, ok := c.limiters[client]
+	if !ok {
+		l = newOpLimiter(c.rate, c.burst)
+		c.limiters[client] = l
+	}
+	c.mu.Unlock()
+	return l.allow()
+}
@@ -1,16 +1,38 @@
 package handler
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+)
 
 // WebSocketメッセージ型
 const (
-	MsgTypeOp          = "op"
-	MsgTypeAck         = "ack"
-	MsgTypeSyncRequest = "sync_request"
-	MsgTypeSync        = "sync"
-	MsgTypeError       = "error"
+	MsgTypeOp            = "op"
+	MsgTypeAck           = "ack"
+	MsgTypeSyncRequest   = "sync_request"
+	MsgTypeSync          = "sync"
+	MsgTypeError         = "error"
+	MsgTypeEntryLock     = "entry_lock"
+	MsgTypeEntryCreate   = "entry_create"
+	MsgTypeEntryDelete   = "entry_delete"
+	MsgTypeAuth          = "auth"
+	MsgTypeAuthStatus    = "auth_status"
+	MsgTypeHello         = "hello"
+	MsgTypeHelloAck      = "hello_ack"
+	MsgTypeBatch         = "batch"
+	MsgTypeSubscribe     = "subscribe"
+	MsgTypeUnsubscribe   = "unsubscribe"
+	MsgTypeSubscriptions = "subscriptions"
+	MsgTypeEntryUpdate   = "entry_update"
+	MsgTypeSuggestion    = "suggestion"
 )
 
+// WSStatusResync は送信キューが溢れて切断したときのクローズコード。
+// クライアントは再接続してsync_requestで追いつく。
+const WSStatusResync websocket.StatusCode = 4000
+
 // NodeIDMsg はNodeIDのJSON表現。
 type NodeIDMsg struct {
 	SiteID    string `json:"site_id"`
@@ -28,6 +50,38 @@ type IncomingMessage struct {
 	Value         string     `json:"value,omitempty"`
 	LastServerSeq int64      `json:"last_server_seq,omitempty"`
 	Authenticated *bool      `json:"authenticated,omitempty"`
+	Suggestion    string     `json:"suggestion,omitempty"`
+	Ticket        string     `json:"ticket,omitempty"`
+	Token         string     `json:"token,omitempty"`
+	// hello: クライアントが対応するバージョンと使いたい能力
+	ProtocolVersions []int    `json:"protocol_versions,omitempty"`
+	Capabilities     []string `json:"capabilities,omitempty"`
+	// batch: まとめて送るメッセージ
+	Messages []json.RawMessage `json:"messages,omitempty"`
+	// subscribe・unsubscribe: 対象のエントリ（unsubscribeではlast_server_seqを使わない）とエントリ一覧
+	Entries []SubscriptionEntryMsg `json:"entries,omitempty"`
+	List    bool                   `json:"list,omitempty"`
+}
+
+// SubscriptionEntryMsg はsubscribe・unsubscribeの対象のエントリ。last_server_seq以降の差分から再開する。
+type SubscriptionEntryMsg struct {
+	EntryID       string `json:"entry_id"`
+	LastServerSeq int64  `json:"last_server_seq,omitempty"`
+}
+
+// SubscriptionsMsg はsubscribe・unsubscribeの後の、接続の購読状況。
+type SubscriptionsMsg struct {
+	Type      string   `json:"type"`
+	RequestID string   `json:"request_id,omitempty"`
+	Entries   []string `json:"entries"`
+	List      bool     `json:"list"`
+}
+
+// AuthStatusMsg は接続の認証状態の通知。ExpiresAtは認証情報の期限（RFC3339）。
+type AuthStatusMsg struct {
+	Type          string `json:"type"`
+	Authenticated bool   `json:"authenticated"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
 }
 
 // AckMsg はACKレスポンス。
@@ -47,6 +101,7 @@ type SyncOpMsg struct {
 	After         *NodeIDMsg `json:"after,omitempty"`
 	Value         string     `json:"value,omitempty"`
 	Authenticated *bool      `json:"authenticated,omitempty"`
+	Suggestion    string     `json:"suggestion,omitempty"`
 }
 
 // SyncMsg はsyncメッセージ。
@@ -57,6 +112,42 @@ type SyncMsg struct {
 	LatestServerSeq int64       `json:"latest_server_seq"`
 }
 
+// EntryLockMsg はエントリのロック状態の通知。
+type EntryLockMsg struct {
+	Type    string `json:"type"`
+	EntryID string `json:"entry_id"`
+	Locked  bool   `json:"locked"`
+}
+
+// EntryUpdateMsg はエントリ一覧の購読者への、opなどによるエントリの更新の通知。短い間隔の更新はまとめて送る。
+type EntryUpdateMsg struct {
+	Type            string `json:"type"`
+	EntryID         string `json:"entry_id"`
+	LatestServerSeq int64  `json:"latest_server_seq"`
+}
+
+// SuggestionMsg は提案セットの採否の通知。提案opはsuggestion付きでsyncに含まれ、
+// クライアントは採否が決まるまで本文と分けて表示する（採用ならinsertを本文に含め、deleteは別のopで届く）。
+type SuggestionMsg struct {
+	Type       string `json:"type"`
+	EntryID    string `json:"entry_id"`
+	Suggestion string `json:"suggestion"`
+	Status     string `json:"status"`
+}
+
+// EntryCreateMsg はエントリ作成の通知。接続中の全クライアントに送る。
+type EntryCreateMsg struct {
+	Type    string `json:"type"`
+	EntryID string `json:"entry_id"`
+}
+
+// EntryDeleteMsg はエントリの削除（ゴミ箱入り）・復元の通知。
+type EntryDeleteMsg struct {
+	Type    string `json:"type"`
+	EntryID string `json:"entry_id"`
+	Deleted bool   `json:"deleted"`
+}
+
 // ErrorMsg はエラーメッセージ。
 type ErrorMsg struct {
 	Type      string  `json:"type"`
@@ -64,6 +155,8 @@ type ErrorMsg struct {
 	ErrorType string  `json:"error_type"`
 	Title     string  `json:"title"`
 	Instance  string  `json:"instance"`
+	// SupportedVersions はerror:unsupported_protocolのときにサーバーが対応するバージョン。
+	SupportedVersions []int `json:"supported_versions,omitempty"`
 }
 
 func newErrorMsg(requestID *string, errorType, title string) ErrorMsg {
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// handleSubscribe はentriesの各エントリを購読してそれぞれの差分を送り、listならエントリ一覧も購読する。
+// 最後に接続の購読状況を返す。
+func (h *WS) handleSubscribe(ctx context.Context, sub *wsSubscriber, msg IncomingMessage) {
+	entryIDs, ok := parseSubscriptionEntries(msg.Entries)
+	if !ok {
+		h.writeError(sub, &msg.RequestID, "error:invalid_op", "Invalid Operation")
+		return
+	}
+	for i, entryID := range entryIDs {
+		h.subscribeEntry(ctx, sub, &msg.RequestID, entryID, msg.Entries[i].LastServerSeq)
+	}
+	if msg.List && !sub.list {
+		h.syncService.SubscribeList(sub)
+		sub.list = true
+	}
+	h.writeSubscriptions(sub, msg.RequestID)
+}
+
+// handleUnsubscribe はentriesの各エントリの購読を解除し、listならエントリ一覧の購読も解除する。
+func (h *WS) handleUnsubscribe(sub *wsSubscriber, msg IncomingMessage) {
+	entryIDs, ok := parseSubscriptionEntries(msg.Entries)
+	if !ok {
+		h.writeError(sub, &msg.RequestID, "error:invalid_op", "Invalid Operation")
+		return
+	}
+	for _, entryID := range entryIDs {
+		if i := slices.Index(sub.entries, entryID); i >= 0 {
+			h.syncService.Unsubscribe(entryID, sub)
+			sub.entries = slices.Delete(sub.entries, i, i+1)
+		}
+	}
+	if msg.List && sub.list {
+		h.syncService.UnsubscribeList(sub)
+		sub.list = false
+	}
+	h.writeSubscriptions(sub, msg.RequestID)
+}
+
+func (h *WS) writeSubscriptions(sub *wsSubscriber, requestID string) {
+	entries := make([]string, len(sub.entries))
+	for i, id := range sub.entries {
+		entries[i] = id.String()
+	}
+	sub.write(SubscriptionsMsg{Type: MsgTypeSubscriptions, RequestID: requestID, Entries: entries, List: sub.list})
+}
+
+// parseSubscriptionEntries はエントリIDを検証する。1つでも不正なら何もしないようfalseを返す。
+func parseSubscriptionEntries(entries []SubscriptionEntryMsg) ([]uuid.UUID, bool) {
+	ids := make([]uuid.UUID, len(entries))
+	for i, e := range entries {
+		id, err := uuid.Parse(e.EntryID)
+		if err != nil || e.LastServerSeq < 0 {
+			return nil, false
+		}
+		ids[i] = id
+	}
+	return ids, true
+}
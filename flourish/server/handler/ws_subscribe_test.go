@@ -0,0 +1,109 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"flourish/server/application"
+	"flourish/server/handler"
+)
+
+// appendOp はエントリにopを1件記録してserver_seqを返す。
+func appendOp(t *testing.T, svc *application.SyncService, entryID uuid.UUID) int64 {
+	t.Helper()
+	ack, err := svc.HandleOp(context.Background(), entryID, uuid.New(), uuid.New(), []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ack.ServerSeq
+}
+
+func TestWS_SubscribeMultipleEntriesWithResumeSeqs(t *testing.T) {
+	srv, syncService := setupWSServer(t)
+	conn := dial(t, srv)
+	a, b := uuid.New(), uuid.New()
+	appendOp(t, syncService, a)
+	appendOp(t, syncService, a)
+	appendOp(t, syncService, b)
+
+	writeJSON(t, conn, map[string]any{
+		"type":       "subscribe",
+		"request_id": "s1",
+		"entries": []map[string]any{
+			{"entry_id": a.String(), "last_server_seq": 1},
+			{"entry_id": b.String()},
+		},
+	})
+	diffA := readJSON[handler.SyncMsg](t, conn)
+	diffB := readJSON[handler.SyncMsg](t, conn)
+	if diffA.EntryID != a.String() || len(diffA.Ops) != 1 || diffA.Ops[0].ServerSeq != 2 {
+		t.Errorf("aはlast_server_seq以降の差分であるべき: got %+v", diffA)
+	}
+	if diffB.EntryID != b.String() || len(diffB.Ops) != 1 {
+		t.Errorf("bは全件の差分であるべき: got %+v", diffB)
+	}
+	subs := readJSON[handler.SubscriptionsMsg](t, conn)
+	if subs.Type != "subscriptions" || subs.RequestID != "s1" || len(subs.Entries) != 2 || subs.List {
+		t.Fatalf("購読状況を返すべき: got %+v", subs)
+	}
+
+	writeJSON(t, conn, map[string]any{"type": "unsubscribe", "entries": []map[string]any{{"entry_id": a.String()}}})
+	if subs := readJSON[handler.SubscriptionsMsg](t, conn); len(subs.Entries) != 1 || subs.Entries[0] != b.String() {
+		t.Fatalf("解除したエントリは購読状況から消えるべき: got %+v", subs)
+	}
+
+	// 解除したエントリのopは届かず、購読中のエントリのopは届く
+	for _, entryID := range []uuid.UUID{a, b} {
+		seq := appendOp(t, syncService, entryID)
+		syncService.Broadcast(entryID, application.SyncMessage{EntryID: entryID, LatestServerSeq: seq})
+	}
+	if got := readJSON[handler.SyncMsg](t, conn); got.EntryID != b.String() {
+		t.Errorf("購読中のエントリだけが届くべき: got %+v", got)
+	}
+}
+
+func TestWS_ListSubscription(t *testing.T) {
+	srv, syncService := setupWSServer(t)
+	conn := dial(t, srv)
+
+	writeJSON(t, conn, map[string]any{"type": "subscribe", "list": true})
+	if subs := readJSON[handler.SubscriptionsMsg](t, conn); !subs.List || len(subs.Entries) != 0 {
+		t.Fatalf("一覧を購読すべき: got %+v", subs)
+	}
+
+	entryID := uuid.New()
+	syncService.NotifyAll(application.Notification{Type: application.NotifyEntryCreate, EntryID: entryID})
+	if msg := readJSON[handler.EntryCreateMsg](t, conn); msg.Type != "entry_create" || msg.EntryID != entryID.String() {
+		t.Errorf("entry_createが届くべき: got %+v", msg)
+	}
+	seq := appendOp(t, syncService, entryID)
+	syncService.Broadcast(entryID, application.SyncMessage{EntryID: entryID, LatestServerSeq: seq})
+	if msg := readJSON[handler.EntryUpdateMsg](t, conn); msg.Type != "entry_update" || msg.LatestServerSeq != seq {
+		t.Errorf("entry_updateが届くべき: got %+v", msg)
+	}
+	syncService.Notify(entryID, application.Notification{Type: application.NotifyEntryDelete, EntryID: entryID, Deleted: true})
+	if msg := readJSON[handler.EntryDeleteMsg](t, conn); msg.Type != "entry_delete" || !msg.Deleted {
+		t.Errorf("entry_deleteが届くべき: got %+v", msg)
+	}
+}
+
+func TestWS_MaxSubscriptions(t *testing.T) {
+	cfg := handler.DefaultWSConfig
+	cfg.MaxSubscriptions = 1
+	conn := dial(t, setupConfiguredWSServer(t, cfg))
+
+	writeJSON(t, conn, map[string]any{
+		"type":       "subscribe",
+		"request_id": "s1",
+		"entries":    []map[string]any{{"entry_id": uuid.New().String()}, {"entry_id": uuid.New().String()}},
+	})
+	readJSON[handler.SyncMsg](t, conn)
+	if errMsg := readJSON[handler.ErrorMsg](t, conn); errMsg.ErrorType != "error:too_many_subscriptions" {
+		t.Errorf("上限を超えた購読はエラーになるべき: got %+v", errMsg)
+	}
+	if subs := readJSON[handler.SubscriptionsMsg](t, conn); len(subs.Entries) != 1 {
+		t.Errorf("上限までは購読されるべき: got %+v", subs)
+	}
+}
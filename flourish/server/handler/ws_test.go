@@ -1,8 +1,11 @@
 package handler_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -12,6 +15,8 @@ import (
 
 	"flourish/server/adapter/memory"
 	"flourish/server/application"
+	"flourish/server/auth"
+	"flourish/server/domain"
 	"flourish/server/handler"
 )
 
@@ -20,7 +25,7 @@ func setupWSServer(t *testing.T) (*httptest.Server, *application.SyncService) {
 	eventStore := memory.NewEventStore()
 	syncService := application.NewSyncService(eventStore)
 	log := slog.Default()
-	wsHandler := handler.NewWS(syncService, nil, nil, log)
+	wsHandler := handler.NewWS(syncService, nil, nil, nil, nil, log)
 
 	srv := httptest.NewServer(wsHandler)
 	t.Cleanup(srv.Close)
@@ -37,7 +42,10 @@ func dial(t *testing.T, srv *httptest.Server) *websocket.Conn {
 	}
 	t.Cleanup(func() { conn.CloseNow() })
 
-	// auth_statusメッセージを読み飛ばす
+	// helloとauth_statusメッセージを読み飛ばす
+	if hello := readJSON[map[string]any](t, conn); hello["type"] != "hello" {
+		t.Fatalf("expected hello, got %v", hello["type"])
+	}
 	authStatus := readJSON[map[string]any](t, conn)
 	if authStatus["type"] != "auth_status" {
 		t.Fatalf("expected auth_status, got %v", authStatus["type"])
@@ -224,3 +232,306 @@ func TestWS_InvalidMessage(t *testing.T) {
 		t.Errorf("error_typeがerror:invalid_opであるべき: got %q", errMsg.ErrorType)
 	}
 }
+
+func TestWS_LockedEntry(t *testing.T) {
+	ctx := t.Context()
+	entryStore := memory.NewEntryStore()
+	syncService := application.NewSyncService(memory.NewEventStore())
+	locks := application.NewLockService(syncService, entryStore)
+	srv := httptest.NewServer(handler.NewWS(syncService, nil, locks, nil, nil, slog.Default()))
+	t.Cleanup(srv.Close)
+
+	entry := domain.NewEntry()
+	entryStore.Save(ctx, entry)
+	if err := locks.SetLocked(ctx, entry.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := dial(t, srv)
+	reqID := uuid.New().String()
+	writeJSON(t, conn, map[string]any{
+		"type":       "op",
+		"request_id": reqID,
+		"entry_id":   entry.ID.String(),
+		"op_type":    1,
+		"node_id":    map[string]any{"site_id": uuid.New().String(), "timestamp": 1},
+		"value":      "a",
+	})
+
+	errMsg := readJSON[handler.ErrorMsg](t, conn)
+	if errMsg.ErrorType != "error:entry_locked" {
+		t.Errorf("error_typeがerror:entry_lockedであるべき: got %q", errMsg.ErrorType)
+	}
+	if errMsg.RequestID == nil || *errMsg.RequestID != reqID {
+		t.Errorf("request_idが一致すべき: got %v", errMsg.RequestID)
+	}
+
+	// ロック中のエントリを購読するとentry_lockが届く
+	writeJSON(t, conn, map[string]any{
+		"type":            "sync_request",
+		"request_id":      uuid.New().String(),
+		"entry_id":        entry.ID.String(),
+		"last_server_seq": 0,
+	})
+	readJSON[handler.SyncMsg](t, conn)
+	lock := readJSON[handler.EntryLockMsg](t, conn)
+	if lock.Type != "entry_lock" || !lock.Locked {
+		t.Errorf("entry_lockを受信すべき: got %+v", lock)
+	}
+}
+
+type authWSFixture struct {
+	srv         *httptest.Server
+	ws          *handler.WS
+	auth        *handler.Auth
+	tickets     *auth.TicketStore
+	syncService *application.SyncService
+}
+
+func setupAuthWSServer(t *testing.T) authWSFixture {
+	t.Helper()
+	tickets := auth.NewTicketStore(time.Minute)
+	a := handler.NewAuth(auth.NewStaticVerifier("", "", nil), tickets, nil, "")
+	syncService := application.NewSyncService(memory.NewEventStore())
+	ws := handler.NewWS(syncService, nil, nil, nil, a, slog.Default())
+	srv := httptest.NewServer(ws)
+	t.Cleanup(srv.Close)
+	return authWSFixture{srv: srv, ws: ws, auth: a, tickets: tickets, syncService: syncService}
+}
+
+func dialTicket(t *testing.T, srv *httptest.Server, ticket string) (*websocket.Conn, handler.AuthStatusMsg) {
+	t.Helper()
+	url := "ws" + srv.URL[len("http"):] + "?ticket=" + ticket
+	conn, _, err := websocket.Dial(t.Context(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	readJSON[handler.HelloMsg](t, conn)
+	return conn, readJSON[handler.AuthStatusMsg](t, conn)
+}
+
+func TestWS_AuthExpiryAndRefresh(t *testing.T) {
+	f := setupAuthWSServer(t)
+
+	conn, status := dialTicket(t, f.srv, f.tickets.Issue(auth.Grant{CredentialExpiresAt: time.Now().Add(100 * time.Millisecond)}))
+	if !status.Authenticated || status.ExpiresAt == "" {
+		t.Fatalf("認証済みで期限付きであるべき: got %+v", status)
+	}
+
+	// 期限が来ると非認証に降格してauth_statusが再送される
+	status = readJSON[handler.AuthStatusMsg](t, conn)
+	if status.Type != "auth_status" || status.Authenticated {
+		t.Fatalf("期限切れで降格すべき: got %+v", status)
+	}
+
+	// authメッセージで接続を維持したまま再認証できる
+	writeJSON(t, conn, map[string]any{"type": "auth", "request_id": uuid.New().String(), "ticket": f.tickets.Issue(auth.Grant{})})
+	status = readJSON[handler.AuthStatusMsg](t, conn)
+	if !status.Authenticated || status.ExpiresAt != "" {
+		t.Errorf("再認証されるべき: got %+v", status)
+	}
+
+	// 無効なチケットは拒否され、状態は変わらない
+	writeJSON(t, conn, map[string]any{"type": "auth", "request_id": uuid.New().String(), "ticket": "bogus"})
+	errMsg := readJSON[handler.ErrorMsg](t, conn)
+	if errMsg.ErrorType != "error:auth_failed" {
+		t.Errorf("error_typeがerror:auth_failedであるべき: got %q", errMsg.ErrorType)
+	}
+
+	// 認証情報なしのauthでログアウトする
+	writeJSON(t, conn, map[string]any{"type": "auth", "request_id": uuid.New().String()})
+	status = readJSON[handler.AuthStatusMsg](t, conn)
+	if status.Authenticated {
+		t.Errorf("非認証に戻るべき: got %+v", status)
+	}
+}
+
+func TestWS_AuthExpiryClose(t *testing.T) {
+	f := setupAuthWSServer(t)
+	f.auth.SetCloseWSOnExpiry(true)
+
+	conn, status := dialTicket(t, f.srv, f.tickets.Issue(auth.Grant{CredentialExpiresAt: time.Now().Add(50 * time.Millisecond)}))
+	if !status.Authenticated {
+		t.Fatalf("認証済みであるべき: got %+v", status)
+	}
+
+	_, _, err := conn.Read(t.Context())
+	if websocket.CloseStatus(err) != websocket.StatusPolicyViolation {
+		t.Errorf("policy violationで閉じられるべき: got %v", err)
+	}
+}
+
+func TestWS_TicketEntryScope(t *testing.T) {
+	f := setupAuthWSServer(t)
+	allowed, other := uuid.New(), uuid.New()
+
+	conn, status := dialTicket(t, f.srv, f.tickets.Issue(auth.Grant{Identity: "alice@example.com", Entries: []uuid.UUID{allowed}}))
+	if !status.Authenticated {
+		t.Fatalf("認証済みであるべき: got %+v", status)
+	}
+
+	for _, entryID := range []uuid.UUID{allowed, other} {
+		writeJSON(t, conn, map[string]any{
+			"type":       "op",
+			"request_id": uuid.New().String(),
+			"entry_id":   entryID.String(),
+			"op_type":    1,
+			"node_id":    map[string]any{"site_id": uuid.New().String(), "timestamp": 1},
+			"value":      "a",
+		})
+		readJSON[handler.AckMsg](t, conn)
+		readJSON[handler.SyncMsg](t, conn)
+	}
+
+	// 許可リスト外のエントリへのopは非認証として記録される
+	for entryID, want := range map[uuid.UUID]bool{allowed: true, other: false} {
+		diff, _ := f.syncService.GetDiff(t.Context(), entryID, 0)
+		var op handler.IncomingMessage
+		json.Unmarshal(diff.Ops[0].Payload, &op)
+		if op.Authenticated == nil || *op.Authenticated != want {
+			t.Errorf("entry %s: authenticatedが%vであるべき: got %v", entryID, want, op.Authenticated)
+		}
+	}
+}
+
+func TestWS_TicketClientIPBinding(t *testing.T) {
+	f := setupAuthWSServer(t)
+
+	_, status := dialTicket(t, f.srv, f.tickets.Issue(auth.Grant{ClientIP: "203.0.113.1"}))
+	if status.Authenticated {
+		t.Error("別IPで発行されたチケットは無効であるべき")
+	}
+}
+
+func TestWS_RevokeIdentity(t *testing.T) {
+	f := setupAuthWSServer(t)
+
+	conn, _ := dialTicket(t, f.srv, f.tickets.Issue(auth.Grant{Identity: "alice@example.com"}))
+	dialTicket(t, f.srv, f.tickets.Issue(auth.Grant{Identity: "bob@example.com"}))
+	pending := f.tickets.Issue(auth.Grant{Identity: "Alice@Example.com"})
+
+	rec := httptest.NewRecorder()
+	f.ws.ListConnections(rec, httptest.NewRequest(http.MethodGet, "/api/admin/ws/connections?identity=alice@example.com", nil))
+	var list handler.WSConnectionListResponse
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list.Connections) != 1 || !list.Connections[0].Authenticated {
+		t.Fatalf("aliceの接続が1件あるべき: got %+v", list.Connections)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/ws/identities/ALICE@example.com/revoke", nil)
+	req.SetPathValue("identity", "ALICE@example.com")
+	rec = httptest.NewRecorder()
+	f.ws.RevokeIdentity(rec, req)
+	var revoked handler.WSRevokeResponse
+	json.NewDecoder(rec.Body).Decode(&revoked)
+	if revoked.Revoked != 1 {
+		t.Errorf("1件切断されるべき: got %d", revoked.Revoked)
+	}
+
+	_, _, err := conn.Read(t.Context())
+	if websocket.CloseStatus(err) != websocket.StatusPolicyViolation {
+		t.Errorf("policy violationで閉じられるべき: got %v", err)
+	}
+
+	// 未使用のチケットも無効化される
+	_, status := dialTicket(t, f.srv, pending)
+	if status.Authenticated {
+		t.Error("失効した利用者のチケットは無効であるべき")
+	}
+}
+
+func setupConfiguredWSServer(t *testing.T, cfg handler.WSConfig) *httptest.Server {
+	t.Helper()
+	ws := handler.NewWS(application.NewSyncService(memory.NewEventStore()), nil, nil, nil, nil, slog.Default())
+	ws.SetConfig(cfg)
+	srv := httptest.NewServer(ws)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWS_OriginAllowList(t *testing.T) {
+	url := func(srv *httptest.Server) string { return "ws" + srv.URL[len("http"):] }
+	opts := &websocket.DialOptions{HTTPHeader: http.Header{"Origin": {"https://cockpit.example"}}}
+
+	srv := setupConfiguredWSServer(t, handler.DefaultWSConfig)
+	if _, resp, err := websocket.Dial(t.Context(), url(srv), opts); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("許可されていないOriginは403であるべき: err=%v", err)
+	}
+
+	cfg := handler.DefaultWSConfig
+	cfg.AllowedOrigins = []string{"cockpit.example"}
+	srv = setupConfiguredWSServer(t, cfg)
+	conn, _, err := websocket.Dial(t.Context(), url(srv), opts)
+	if err != nil {
+		t.Fatalf("許可したOriginは接続できるべき: %v", err)
+	}
+	conn.CloseNow()
+}
+
+func TestWS_OpRateLimit(t *testing.T) {
+	cfg := handler.DefaultWSConfig
+	cfg.OpRate, cfg.OpBurst = 0.001, 1
+	conn := dial(t, setupConfiguredWSServer(t, cfg))
+
+	entryID := uuid.New().String()
+	op := func(ts int) string {
+		reqID := uuid.New().String()
+		writeJSON(t, conn, map[string]any{
+			"type":       "op",
+			"request_id": reqID,
+			"entry_id":   entryID,
+			"op_type":    1,
+			"node_id":    map[string]any{"site_id": uuid.New().String(), "timestamp": ts},
+			"value":      "a",
+		})
+		return reqID
+	}
+
+	op(1)
+	if ack := readJSON[handler.AckMsg](t, conn); ack.Type != handler.MsgTypeAck {
+		t.Fatalf("1件目はACKされるべき: got %+v", ack)
+	}
+	readJSON[handler.SyncMsg](t, conn) // 自分のopのbroadcast
+	reqID := op(2)
+	errMsg := readJSON[handler.ErrorMsg](t, conn)
+	if errMsg.ErrorType != "error:rate_limited" || errMsg.RequestID == nil || *errMsg.RequestID != reqID {
+		t.Errorf("上限を超えたopはrate_limitedになるべき: got %+v", errMsg)
+	}
+}
+
+func TestWS_MaxMessageSize(t *testing.T) {
+	cfg := handler.DefaultWSConfig
+	cfg.MaxMessageBytes = 1024
+	conn := dial(t, setupConfiguredWSServer(t, cfg))
+
+	conn.Write(t.Context(), websocket.MessageText, make([]byte, 2048))
+	if _, _, err := conn.Read(t.Context()); websocket.CloseStatus(err) != websocket.StatusMessageTooBig {
+		t.Errorf("上限を超えたメッセージで切断されるべき: got %v", err)
+	}
+}
+
+func TestWS_IdleTimeout(t *testing.T) {
+	cfg := handler.DefaultWSConfig
+	cfg.PingInterval, cfg.IdleTimeout = 20*time.Millisecond, 60*time.Millisecond
+	conn := dial(t, setupConfiguredWSServer(t, cfg))
+
+	// 読み込まない（pongを返さない）クライアントは切断される
+	time.Sleep(200 * time.Millisecond)
+	if _, _, err := conn.Read(t.Context()); websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Errorf("応答の無い接続は切断されるべき: got %v", err)
+	}
+}
+
+func TestWS_KeepaliveKeepsResponsiveClient(t *testing.T) {
+	cfg := handler.DefaultWSConfig
+	cfg.PingInterval, cfg.IdleTimeout = 20*time.Millisecond, 60*time.Millisecond
+	conn := dial(t, setupConfiguredWSServer(t, cfg))
+
+	// 読み込み中のクライアントはpongを返すので切断されない
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+	if _, _, err := conn.Read(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("pongを返す接続は維持されるべき: got %v", err)
+	}
+}
@@ -17,14 +17,38 @@ func NewRouter(
 	log *slog.Logger,
 	entryStore domain.EntryStore,
 	syncService *application.SyncService,
+	entryService *application.EntryService,
 	projector *application.EntryProjector,
+	tagService *application.TagService,
+	moderationService *application.ModerationService,
+	suggestionService *application.SuggestionService,
+	lockService *application.LockService,
+	trashService *application.TrashService,
+	tokenService *application.APITokenService,
+	auditLog *application.AuditLog,
 	authHandler *handler.Auth,
+	wsConfig handler.WSConfig,
+	replicationSource application.ReplicationSource,
+	replicator *application.Replicator,
 ) http.Handler {
 	mux := http.NewServeMux()
 
-	health := handler.NewHealth()
-	entry := handler.NewEntry(entryStore)
-	ws := handler.NewWS(syncService, projector, authHandler, log)
+	health := handler.NewHealth(projector, syncService)
+	entry := handler.NewEntry(entryStore, entryService)
+	tag := handler.NewTag(entryStore, tagService)
+	events := handler.NewEvents(entryStore, syncService, projector, log)
+	ops := handler.NewOps(entryStore, syncService, projector, lockService, trashService, authHandler)
+	ops.SetConfig(wsConfig)
+	ws := handler.NewWS(syncService, projector, lockService, trashService, authHandler, log)
+	ws.SetConfig(wsConfig)
+	replication := handler.NewReplication(replicationSource, replicator)
+	if replicator != nil {
+		health.SetReplicator(replicator)
+		ws.SetReplicator(replicator)
+	}
+	// 認証が有効なら、匿名の読者にはモデレーション前のopを流さない
+	events.SetPublicTextOnly(authHandler != nil)
+	ops.SetPublicTextOnly(authHandler != nil)
 
 	// CSRF保護（state-changing APIに適用）
 	csrf := http.NewCrossOriginProtection()
@@ -33,20 +57,77 @@ func NewRouter(
 	mux.HandleFunc("GET /api/entries", entry.List)
 	mux.Handle("POST /api/entries", csrf.Handler(http.HandlerFunc(entry.Create)))
 	if authHandler != nil {
-		deleteHandler := csrf.Handler(authHandler.CFAccessMiddleware(handler.RequireAuth(http.HandlerFunc(entry.Delete))))
-		mux.Handle("POST /api/admin/entries/{id}/delete", deleteHandler)
+		// read: 管理用の参照、edit: 編集系の操作、admin: 削除とトークン管理
+		scoped := func(scope domain.Scope, h http.HandlerFunc) http.Handler {
+			return authHandler.Middleware(handler.RequireAuth(scope, h))
+		}
+		// 変更系は権限不足も含めて監査ログに記録する
+		audited := func(action, targetParam string, scope domain.Scope, h http.HandlerFunc) http.Handler {
+			return csrf.Handler(authHandler.Middleware(handler.Audit(auditLog, action, targetParam, handler.RequireAuth(scope, h))))
+		}
+		trash := handler.NewTrash(trashService)
+		mux.Handle("POST /api/admin/entries/{id}/delete", audited(application.AuditEntryDelete, "id", domain.ScopeAdmin, trash.Delete))
+		mux.Handle("GET /api/admin/trash", scoped(domain.ScopeAdmin, trash.List))
+		mux.Handle("POST /api/admin/entries/{id}/restore", audited(application.AuditEntryRestore, "id", domain.ScopeAdmin, trash.Restore))
+		mux.Handle("POST /api/admin/entries/{id}/purge", audited(application.AuditEntryPurge, "id", domain.ScopeAdmin, trash.Purge))
+
+		moderation := handler.NewModeration(moderationService)
+		mux.Handle("GET /api/admin/entries/{id}/pending", scoped(domain.ScopeRead, moderation.Pending))
+		mux.Handle("POST /api/admin/entries/{id}/pending/approve", audited(application.AuditModerationAccept, "id", domain.ScopeEdit, moderation.Approve))
+		mux.Handle("POST /api/admin/entries/{id}/pending/reject", audited(application.AuditModerationReject, "id", domain.ScopeEdit, moderation.Reject))
+
+		suggestion := handler.NewSuggestion(suggestionService)
+		mux.Handle("GET /api/admin/entries/{id}/suggestions", scoped(domain.ScopeRead, suggestion.List))
+		mux.Handle("POST /api/admin/entries/{id}/suggestions/{sid}/accept", audited(application.AuditSuggestionAccept, "sid", domain.ScopeEdit, suggestion.Accept))
+		mux.Handle("POST /api/admin/entries/{id}/suggestions/{sid}/reject", audited(application.AuditSuggestionReject, "sid", domain.ScopeEdit, suggestion.Reject))
+
+		lock := handler.NewLock(lockService)
+		mux.Handle("POST /api/admin/entries/{id}/lock", audited(application.AuditEntryLock, "id", domain.ScopeEdit, lock.Lock))
+		mux.Handle("POST /api/admin/entries/{id}/unlock", audited(application.AuditEntryUnlock, "id", domain.ScopeEdit, lock.Unlock))
+		mux.Handle("PUT /api/entries/{id}/tags", audited(application.AuditEntryTags, "id", domain.ScopeEdit, tag.Update))
+
+		token := handler.NewToken(tokenService)
+		mux.Handle("GET /api/admin/tokens", scoped(domain.ScopeAdmin, token.List))
+		mux.Handle("POST /api/admin/tokens", audited(application.AuditTokenCreate, "", domain.ScopeAdmin, token.Create))
+		mux.Handle("POST /api/admin/tokens/{id}/revoke", audited(application.AuditTokenRevoke, "id", domain.ScopeAdmin, token.Revoke))
+
+		mux.Handle("GET /api/admin/ws/connections", scoped(domain.ScopeAdmin, ws.ListConnections))
+		mux.Handle("POST /api/admin/ws/connections/{id}/revoke", audited(application.AuditWSRevoke, "id", domain.ScopeAdmin, ws.RevokeConnection))
+		mux.Handle("POST /api/admin/ws/identities/{identity}/revoke", audited(application.AuditWSRevoke, "identity", domain.ScopeAdmin, ws.RevokeIdentity))
+
+		audit := handler.NewAuditLog(auditLog)
+		mux.Handle("GET /api/admin/audit", scoped(domain.ScopeAdmin, audit.List))
+
+		// 複製用のイベントログにはモデレーション前の内容も含まれるのでadminに限る
+		mux.Handle("GET /api/replication/entries", scoped(domain.ScopeAdmin, replication.Entries))
+		mux.Handle("GET /api/replication/purged", scoped(domain.ScopeAdmin, replication.Purged))
+		mux.Handle("GET /api/replication/entries/{id}/events", scoped(domain.ScopeAdmin, replication.Events))
+		mux.Handle("POST /api/admin/replication/promote", audited(application.AuditReplicationPromote, "", domain.ScopeAdmin, replication.Promote))
+
 		mux.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, "/", http.StatusFound)
 		})
-		mux.HandleFunc("GET /logout", authHandler.Logout)
+		mux.Handle("GET /logout", authHandler.Middleware(handler.Audit(auditLog, application.AuditLogout, "", http.HandlerFunc(authHandler.Logout))))
+	} else {
+		// 認証が無効なら他の書き込みと同じく誰でも複製・タグ編集できる
+		mux.HandleFunc("GET /api/replication/entries", replication.Entries)
+		mux.HandleFunc("GET /api/replication/purged", replication.Purged)
+		mux.HandleFunc("GET /api/replication/entries/{id}/events", replication.Events)
+		mux.Handle("PUT /api/entries/{id}/tags", csrf.Handler(http.HandlerFunc(tag.Update)))
 	}
 	mux.HandleFunc("GET /api/entries/{id}", entry.Get)
+	mux.Handle("GET /api/entries/{id}/events", withAuth(authHandler, events))
+	// WebSocketが使えない環境向けのフォールバック
+	mux.Handle("GET /api/entries/{id}/ops", withAuth(authHandler, http.HandlerFunc(ops.Poll)))
+	mux.Handle("POST /api/entries/{id}/ops", csrf.Handler(withAuth(authHandler, http.HandlerFunc(ops.Submit))))
+	mux.HandleFunc("GET /api/tags", tag.List)
+	mux.HandleFunc("GET /api/tags/{tag}/feed", tag.Feed)
 	mux.Handle("GET /api/ws", ws)
 
 	// 認証エンドポイント
 	if authHandler != nil {
-		mux.Handle("POST /api/ws-ticket", csrf.Handler(authHandler.CFAccessMiddleware(http.HandlerFunc(authHandler.WSTicket))))
-		mux.HandleFunc("GET /api/auth/status", authHandler.CFAccessMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Handle("POST /api/ws-ticket", csrf.Handler(authHandler.Middleware(handler.Audit(auditLog, application.AuditWSTicketIssue, "", http.HandlerFunc(authHandler.WSTicket)))))
+		mux.HandleFunc("GET /api/auth/status", authHandler.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if handler.IsAuthenticated(r.Context()) {
 				w.Write([]byte(`{"authenticated":true}`))
 			} else {
@@ -55,6 +136,20 @@ func NewRouter(
 		})).ServeHTTP)
 	}
 
+	// フォロワーは昇格するまで読み取り専用（昇格とWSチケットの発行は受け付ける）
+	var h http.Handler = mux
+	if replicator != nil {
+		h = handler.ReadOnly(replicator, []string{"/api/admin/replication/promote", "/api/ws-ticket"}, mux)
+	}
+
 	// ミドルウェアチェーン: otelhttp(トレース) → HTTPMiddleware(ログ) → mux
-	return otelhttp.NewHandler(logger.HTTPMiddleware(log)(mux), "crdt-blog")
+	return otelhttp.NewHandler(logger.HTTPMiddleware(log)(h), "crdt-blog")
+}
+
+// withAuth は認証が有効なら認証状態を設定するミドルウェアを挟む。
+func withAuth(authHandler *handler.Auth, h http.Handler) http.Handler {
+	if authHandler == nil {
+		return h
+	}
+	return authHandler.Middleware(h)
 }